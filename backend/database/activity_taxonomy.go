@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActivityTypeMongo is an admin-managed attendance activity type, e.g. "Daily Activity"
+type ActivityTypeMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code      string             `bson:"code" json:"code"`
+	Label     string             `bson:"label" json:"label"`
+	Active    bool               `bson:"active" json:"active"`
+	SortOrder int                `bson:"sort_order" json:"sort_order"`
+	CreatedAt string             `bson:"created_at" json:"created_at"`
+}
+
+// ActivityCategoryMongo is an admin-managed activity category with the rules attendance must satisfy
+type ActivityCategoryMongo struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code  string             `bson:"code" json:"code"`
+	Label string             `bson:"label" json:"label"`
+	// Activity type codes the category can be used with; empty means any type
+	TypeCodes         []string `bson:"type_codes" json:"type_codes"`
+	Active            bool     `bson:"active" json:"active"`
+	RequiresSchool    bool     `bson:"requires_school" json:"requires_school"`
+	RequiresTimeRange bool     `bson:"requires_time_range" json:"requires_time_range"`
+	SortOrder         int      `bson:"sort_order" json:"sort_order"`
+	CreatedAt         string   `bson:"created_at" json:"created_at"`
+}
+
+// DefaultActivityTypes and DefaultActivityCategories mirror the options the attendance form used to hard-code
+func DefaultActivityTypes() []ActivityTypeMongo {
+	return []ActivityTypeMongo{
+		{Code: "daily_activity", Label: "Daily Activity", Active: true, SortOrder: 1},
+		{Code: "event_activity", Label: "Event Activity", Active: true, SortOrder: 2},
+	}
+}
+
+func DefaultActivityCategories() []ActivityCategoryMongo {
+	return []ActivityCategoryMongo{
+		{Code: "regular_class", Label: "Regular Class", TypeCodes: []string{"daily_activity"}, Active: true, SortOrder: 1},
+		{Code: "school_class", Label: "School Class", TypeCodes: []string{"daily_activity"}, Active: true, RequiresSchool: true, RequiresTimeRange: true, SortOrder: 2},
+		{Code: "private_class", Label: "Private Class", TypeCodes: []string{}, Active: true, RequiresTimeRange: true, SortOrder: 3},
+		{Code: "offline", Label: "Offline", TypeCodes: []string{"event_activity"}, Active: true, SortOrder: 4},
+		{Code: "online", Label: "Online", TypeCodes: []string{"event_activity"}, Active: true, SortOrder: 5},
+	}
+}
+
+// EnsureActivityTaxonomy creates unique code indexes and seeds the default taxonomy when empty
+func EnsureActivityTaxonomy(now string) error {
+	ctx := context.Background()
+	unique := mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)}
+	if _, err := ActivityTypesCollection().Indexes().CreateOne(ctx, unique); err != nil {
+		return err
+	}
+	if _, err := ActivityCategoriesCollection().Indexes().CreateOne(ctx, unique); err != nil {
+		return err
+	}
+
+	if count, err := ActivityTypesCollection().CountDocuments(ctx, bson.M{}); err != nil {
+		return err
+	} else if count == 0 {
+		for _, t := range DefaultActivityTypes() {
+			t.CreatedAt = now
+			if _, err := ActivityTypesCollection().InsertOne(ctx, t); err != nil {
+				return err
+			}
+		}
+	}
+	if count, err := ActivityCategoriesCollection().CountDocuments(ctx, bson.M{}); err != nil {
+		return err
+	} else if count == 0 {
+		for _, cat := range DefaultActivityCategories() {
+			cat.CreatedAt = now
+			if _, err := ActivityCategoriesCollection().InsertOne(ctx, cat); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func taxonomyFilter(activeOnly bool) bson.M {
+	if activeOnly {
+		return bson.M{"active": true}
+	}
+	return bson.M{}
+}
+
+func GetActivityTypesMongo(activeOnly bool) ([]ActivityTypeMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "sort_order", Value: 1}, {Key: "label", Value: 1}})
+	cursor, err := ActivityTypesCollection().Find(ctx, taxonomyFilter(activeOnly), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var types []ActivityTypeMongo
+	if err = cursor.All(ctx, &types); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+func GetActivityCategoriesMongo(activeOnly bool) ([]ActivityCategoryMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "sort_order", Value: 1}, {Key: "label", Value: 1}})
+	cursor, err := ActivityCategoriesCollection().Find(ctx, taxonomyFilter(activeOnly), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var categories []ActivityCategoryMongo
+	if err = cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func CreateActivityTypeMongo(t ActivityTypeMongo) (*ActivityTypeMongo, error) {
+	ctx := context.Background()
+	result, err := ActivityTypesCollection().InsertOne(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = result.InsertedID.(primitive.ObjectID)
+	return &t, nil
+}
+
+func GetActivityTypeByIDMongo(id string) (*ActivityTypeMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var t ActivityTypeMongo
+	if err := ActivityTypesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// UpdateActivityTypeMongo updates label, active flag and order. Codes are immutable because attendance stores them.
+func UpdateActivityTypeMongo(id string, t ActivityTypeMongo) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = ActivityTypesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"label":      t.Label,
+		"active":     t.Active,
+		"sort_order": t.SortOrder,
+	}})
+	return err
+}
+
+func DeleteActivityTypeMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = ActivityTypesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+func CreateActivityCategoryMongo(cat ActivityCategoryMongo) (*ActivityCategoryMongo, error) {
+	ctx := context.Background()
+	result, err := ActivityCategoriesCollection().InsertOne(ctx, cat)
+	if err != nil {
+		return nil, err
+	}
+	cat.ID = result.InsertedID.(primitive.ObjectID)
+	return &cat, nil
+}
+
+func GetActivityCategoryByIDMongo(id string) (*ActivityCategoryMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var cat ActivityCategoryMongo
+	if err := ActivityCategoriesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&cat); err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+// UpdateActivityCategoryMongo updates everything except the code
+func UpdateActivityCategoryMongo(id string, cat ActivityCategoryMongo) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = ActivityCategoriesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"label":               cat.Label,
+		"type_codes":          cat.TypeCodes,
+		"active":              cat.Active,
+		"requires_school":     cat.RequiresSchool,
+		"requires_time_range": cat.RequiresTimeRange,
+		"sort_order":          cat.SortOrder,
+	}})
+	return err
+}
+
+func DeleteActivityCategoryMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = ActivityCategoriesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// CountAttendanceByActivityCode counts attendance entries referencing a type or category code
+func CountAttendanceByActivityCode(field, code string) (int64, error) {
+	ctx := context.Background()
+	return AttendanceCollection().CountDocuments(ctx, bson.M{field: code})
+}
+
+// BackfillActivityCodes sets taxonomy codes on attendance recorded before the taxonomy existed,
+// matching labels case-insensitively. Entries with unknown spellings are left without codes.
+func BackfillActivityCodes() (int64, error) {
+	ctx := context.Background()
+	types, err := GetActivityTypesMongo(false)
+	if err != nil {
+		return 0, err
+	}
+	categories, err := GetActivityCategoriesMongo(false)
+	if err != nil {
+		return 0, err
+	}
+	typeCodes := make(map[string]string)
+	for _, t := range types {
+		typeCodes[strings.ToLower(t.Label)] = t.Code
+	}
+	categoryCodes := make(map[string]ActivityCategoryMongo)
+	for _, cat := range categories {
+		categoryCodes[strings.ToLower(cat.Label)] = cat
+	}
+
+	cursor, err := AttendanceCollection().Find(ctx,
+		bson.M{"status": "present", "activity_type_code": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"activity_type": 1, "activity_categories": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var att AttendanceMongo
+		if err := cursor.Decode(&att); err != nil {
+			return updated, err
+		}
+		code, ok := typeCodes[strings.ToLower(strings.TrimSpace(att.ActivityType))]
+		if !ok {
+			continue
+		}
+		set := bson.M{"activity_type_code": code}
+		codes := []string{}
+		labels := []string{}
+		for _, label := range att.ActivityCategories {
+			if cat, ok := categoryCodes[strings.ToLower(strings.TrimSpace(label))]; ok {
+				codes = append(codes, cat.Code)
+				labels = append(labels, cat.Label)
+			} else {
+				labels = append(labels, label)
+			}
+		}
+		set["activity_category_codes"] = codes
+		set["activity_categories"] = labels
+		if _, err := AttendanceCollection().UpdateOne(ctx, bson.M{"_id": att.ID}, bson.M{"$set": set}); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
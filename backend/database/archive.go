@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// archiveTTL is how long removed records stay restorable before MongoDB drops them
+const archiveTTL = 30 * 24 * time.Hour
+
+// ArchivedRecordMongo is a record removed by a cleanup, kept for archiveTTL so it can be restored
+type ArchivedRecordMongo struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Collection string             `bson:"collection" json:"collection"`
+	Reason     string             `bson:"reason" json:"reason"` // orphan, migrated
+	Doc        bson.M             `bson:"doc" json:"doc"`
+	ArchivedAt string             `bson:"archived_at" json:"archived_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// archiveAndDelete copies the documents matching filter into the archive and then deletes them
+// from coll, all on the server
+func archiveAndDelete(coll *mongo.Collection, filter bson.M, reason string, now time.Time) (int64, error) {
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"collection":  bson.M{"$literal": coll.Name()},
+			"reason":      bson.M{"$literal": reason},
+			"doc":         "$$ROOT",
+			"archived_at": bson.M{"$literal": now.Format("2006-01-02 15:04:05")},
+			"expires_at":  bson.M{"$literal": now.Add(archiveTTL)},
+		}}},
+		{{Key: "$merge", Value: bson.M{"into": ArchivedRecordsCollection().Name(), "whenNotMatched": "insert"}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	cursor.Close(ctx)
+
+	result, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// GetArchivedRecordsMongo lists archived records newest first; an empty collection lists all
+func GetArchivedRecordsMongo(collection string, limit int64) ([]ArchivedRecordMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if collection != "" {
+		filter["collection"] = collection
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "archived_at", Value: -1}}).SetLimit(limit)
+	cursor, err := ArchivedRecordsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []ArchivedRecordMongo{}
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// RestoreArchivedRecordMongo puts an archived record back into its collection
+func RestoreArchivedRecordMongo(id string) (*ArchivedRecordMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var rec ArchivedRecordMongo
+	if err := ArchivedRecordsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&rec); err != nil {
+		return nil, err
+	}
+	if _, err := database.Collection(rec.Collection).InsertOne(ctx, rec.Doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("record already exists in %s", rec.Collection)
+		}
+		return nil, err
+	}
+	if _, err := ArchivedRecordsCollection().DeleteOne(ctx, bson.M{"_id": objID}); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// EnsureArchiveIndexes expires archived records and backs the list by collection
+func EnsureArchiveIndexes() error {
+	ctx := context.Background()
+	_, err := ArchivedRecordsCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "collection", Value: 1}, {Key: "archived_at", Value: -1}}},
+	})
+	return err
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AttendanceImportMongo records one confirmed bulk attendance import
+type AttendanceImportMongo struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FileName     string             `bson:"file_name" json:"file_name"`
+	ImportedBy   string             `bson:"imported_by" json:"imported_by"`
+	RowCount     int                `bson:"row_count" json:"row_count"`
+	WarningCount int                `bson:"warning_count" json:"warning_count"`
+	Status       string             `bson:"status" json:"status"` // imported, rolled_back
+	CreatedAt    string             `bson:"created_at" json:"created_at"`
+	RolledBackBy string             `bson:"rolled_back_by,omitempty" json:"rolled_back_by,omitempty"`
+	RolledBackAt string             `bson:"rolled_back_at,omitempty" json:"rolled_back_at,omitempty"`
+}
+
+// AddAttendanceImportMongo stores the batch record and inserts its attendance entries tagged with the batch ID.
+// If the insert fails part way the inserted entries are removed again.
+func AddAttendanceImportMongo(batch AttendanceImportMongo, records []AttendanceMongo) (*AttendanceImportMongo, error) {
+	ctx := context.Background()
+	batch.ID = primitive.NewObjectID()
+	batch.RowCount = len(records)
+
+	docs := make([]interface{}, len(records))
+	for i := range records {
+		records[i].ImportBatchID = batch.ID.Hex()
+		docs[i] = records[i]
+	}
+
+	if _, err := AttendanceImportsCollection().InsertOne(ctx, batch); err != nil {
+		return nil, err
+	}
+	if len(docs) > 0 {
+		if _, err := AttendanceCollection().InsertMany(ctx, docs); err != nil {
+			AttendanceCollection().DeleteMany(ctx, bson.M{"import_batch_id": batch.ID.Hex()})
+			AttendanceImportsCollection().DeleteOne(ctx, bson.M{"_id": batch.ID})
+			return nil, err
+		}
+	}
+	return &batch, nil
+}
+
+func GetAttendanceImportsMongo() ([]AttendanceImportMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := AttendanceImportsCollection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var batches []AttendanceImportMongo
+	if err = cursor.All(ctx, &batches); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+func GetAttendanceImportByIDMongo(id string) (*AttendanceImportMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var batch AttendanceImportMongo
+	if err := AttendanceImportsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetAttendanceByImportBatch returns the entries still present from a batch
+func GetAttendanceByImportBatch(batchID string) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{"import_batch_id": batchID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AttendanceMongo
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// RollbackAttendanceImportMongo deletes every entry of the batch and marks it rolled back
+func RollbackAttendanceImportMongo(id, userID, at string) (int64, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, err
+	}
+	result, err := AttendanceCollection().DeleteMany(ctx, bson.M{"import_batch_id": id})
+	if err != nil {
+		return 0, err
+	}
+	_, err = AttendanceImportsCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"status":         "rolled_back",
+		"rolled_back_by": userID,
+		"rolled_back_at": at,
+	}})
+	return result.DeletedCount, err
+}
@@ -0,0 +1,388 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AttendanceRecapFilter narrows the attendance recap. Empty fields are not filtered on.
+type AttendanceRecapFilter struct {
+	From          string
+	To            string
+	BranchID      string
+	UserID        string
+	Statuses      []string
+	ActivityTypes []string
+	// ViewerID limits non-admin callers to their own records plus everyone's leave
+	ViewerID string
+	Cursor   string
+	Limit    int
+}
+
+type AttendanceRecapRow struct {
+	ID                 primitive.ObjectID `bson:"_id" json:"id"`
+	UserID             string             `bson:"user_id" json:"user_id"`
+	UserName           string             `bson:"user_name" json:"user_name"`
+	BranchID           string             `bson:"branch_id" json:"branch_id"`
+	BranchName         string             `bson:"branch_name" json:"branch_name"`
+	Date               string             `bson:"date" json:"date"`
+	Session            string             `bson:"session" json:"session"`
+	Status             string             `bson:"status" json:"status"`
+	ActivityType       string             `bson:"activity_type" json:"activity_type"`
+	ActivityCategories []string           `bson:"activity_categories" json:"activity_categories"`
+	ActivityDetails    string             `bson:"activity_details" json:"activity_details"`
+	ActivityNotes      string             `bson:"activity_notes" json:"activity_notes"`
+	StartingTime       string             `bson:"starting_time" json:"starting_time"`
+	EndingTime         string             `bson:"ending_time" json:"ending_time"`
+	CheckIn            string             `bson:"check_in" json:"check_in"`
+	CheckOut           string             `bson:"check_out" json:"check_out"`
+}
+
+type AttendanceRecapUserTotals struct {
+	UserID     string `bson:"_id" json:"user_id"`
+	UserName   string `bson:"user_name" json:"user_name"`
+	BranchName string `bson:"branch_name" json:"branch_name"`
+	Total      int    `bson:"total" json:"total_days"`
+	Present    int    `bson:"present" json:"present"`
+	Ijin       int    `bson:"ijin" json:"ijin"`
+	Sakit      int    `bson:"sakit" json:"sakit"`
+	Absent     int    `bson:"absent" json:"absent"`
+}
+
+type AttendanceRecapSummary struct {
+	Total    int                         `json:"total"`
+	ByStatus map[string]int              `json:"by_status"`
+	ByUser   []AttendanceRecapUserTotals `json:"by_user"`
+}
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeRecapCursor builds an opaque cursor from the last row of a page
+func EncodeRecapCursor(row AttendanceRecapRow) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(row.Date + "|" + row.ID.Hex()))
+}
+
+func decodeRecapCursor(cursor string) (string, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", primitive.NilObjectID, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return "", primitive.NilObjectID, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return "", primitive.NilObjectID, ErrInvalidCursor
+	}
+	return parts[0], id, nil
+}
+
+// recapMatch builds the attendance-level $match; branch filtering happens after the user lookup
+func recapMatch(f AttendanceRecapFilter) bson.M {
+	match := bson.M{}
+	dateRange := bson.M{}
+	if f.From != "" {
+		dateRange["$gte"] = f.From
+	}
+	if f.To != "" {
+		dateRange["$lte"] = f.To
+	}
+	if len(dateRange) > 0 {
+		match["date"] = dateRange
+	}
+	if f.UserID != "" {
+		match["user_id"] = f.UserID
+	}
+	if len(f.Statuses) > 0 {
+		match["status"] = bson.M{"$in": f.Statuses}
+	}
+	var and bson.A
+	if len(f.ActivityTypes) > 0 {
+		// Accept taxonomy codes as well as labels
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"activity_type_code": bson.M{"$in": f.ActivityTypes}},
+			bson.M{"activity_type": bson.M{"$in": f.ActivityTypes}},
+		}})
+	}
+	if f.ViewerID != "" {
+		and = append(and, bson.M{"$or": bson.A{
+			bson.M{"user_id": f.ViewerID},
+			bson.M{"status": bson.M{"$in": bson.A{"ijin", "sakit"}}},
+		}})
+	}
+	if len(and) > 0 {
+		match["$and"] = and
+	}
+	return match
+}
+
+// userBranchLookupStages joins documents keyed by user_id to the user and the user's branch,
+// optionally keeping only users of one branch
+func userBranchLookupStages(branchID string) mongo.Pipeline {
+	toObjectID := func(field string) bson.M {
+		return bson.M{"$convert": bson.M{"input": field, "to": "objectId", "onError": nil, "onNull": nil}}
+	}
+	stages := mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{
+			"from":     "users",
+			"let":      bson.M{"uid": toObjectID("$user_id")},
+			"pipeline": bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$uid"}}}}, bson.M{"$project": bson.M{"name": 1, "branch_id": 1}}},
+			"as":       "user",
+		}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$user", "preserveNullAndEmptyArrays": true}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":     "branches",
+			"let":      bson.M{"bid": toObjectID("$user.branch_id")},
+			"pipeline": bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$bid"}}}}, bson.M{"$project": bson.M{"name": 1}}},
+			"as":       "branch",
+		}}},
+		{{Key: "$unwind", Value: bson.M{"path": "$branch", "preserveNullAndEmptyArrays": true}}},
+	}
+	if branchID != "" {
+		stages = append(stages, bson.D{{Key: "$match", Value: bson.M{"user.branch_id": branchID}}})
+	}
+	return stages
+}
+
+// recapRowsPipeline sorts newest first, joins users and branches and shapes recap rows.
+// A limit of 0 returns every matching row.
+func recapRowsPipeline(f AttendanceRecapFilter, match bson.M, limit int) mongo.Pipeline {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}}},
+	}
+	pipeline = append(pipeline, userBranchLookupStages(f.BranchID)...)
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	return append(pipeline, bson.D{{Key: "$project", Value: bson.M{
+		"user_id":             1,
+		"user_name":           bson.M{"$ifNull": bson.A{"$user.name", ""}},
+		"branch_id":           bson.M{"$ifNull": bson.A{bson.M{"$toString": "$user.branch_id"}, ""}},
+		"branch_name":         bson.M{"$ifNull": bson.A{"$branch.name", ""}},
+		"date":                1,
+		"session":             1,
+		"status":              1,
+		"activity_type":       1,
+		"activity_categories": 1,
+		"activity_details":    1,
+		"activity_notes":      1,
+		"starting_time":       1,
+		"ending_time":         1,
+		"check_in":            "$starting_time",
+		"check_out":           "$ending_time",
+	}}})
+}
+
+// GetAttendanceRecapMongo returns one page of recap rows, newest first, and the cursor for the next page
+func GetAttendanceRecapMongo(f AttendanceRecapFilter) ([]AttendanceRecapRow, string, error) {
+	ctx := context.Background()
+
+	match := recapMatch(f)
+	if f.Cursor != "" {
+		date, id, err := decodeRecapCursor(f.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after := bson.M{"$or": bson.A{
+			bson.M{"date": bson.M{"$lt": date}},
+			bson.M{"date": date, "_id": bson.M{"$lt": id}},
+		}}
+		match = bson.M{"$and": bson.A{match, after}}
+	}
+
+	pipeline := recapRowsPipeline(f, match, f.Limit+1)
+	cursor, err := AttendanceCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	rows := []AttendanceRecapRow{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(rows) > f.Limit {
+		rows = rows[:f.Limit]
+		next = EncodeRecapCursor(rows[len(rows)-1])
+	}
+	return rows, next, nil
+}
+
+// StreamAttendanceRecapMongo calls fn for every row of the filtered recap without buffering it
+func StreamAttendanceRecapMongo(f AttendanceRecapFilter, fn func(AttendanceRecapRow) error) error {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Aggregate(ctx, recapRowsPipeline(f, recapMatch(f), 0))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var row AttendanceRecapRow
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// GetAttendanceRecapSummaryMongo totals the whole filtered recap per user and per status
+func GetAttendanceRecapSummaryMongo(f AttendanceRecapFilter) (*AttendanceRecapSummary, error) {
+	ctx := context.Background()
+
+	countStatus := func(statuses ...string) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$status", statuses}}, 1, 0}}}
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: recapMatch(f)}}}
+	pipeline = append(pipeline, userBranchLookupStages(f.BranchID)...)
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: bson.M{
+		"by_user": bson.A{
+			bson.M{"$group": bson.M{
+				"_id":         "$user_id",
+				"user_name":   bson.M{"$first": bson.M{"$ifNull": bson.A{"$user.name", ""}}},
+				"branch_name": bson.M{"$first": bson.M{"$ifNull": bson.A{"$branch.name", ""}}},
+				"total":       bson.M{"$sum": 1},
+				"present":     countStatus("present"),
+				"ijin":        countStatus("ijin"),
+				"sakit":       countStatus("sakit"),
+				"absent":      countStatus("absent", "alpha"),
+			}},
+			bson.M{"$sort": bson.M{"user_name": 1}},
+		},
+		"by_status": bson.A{
+			bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+		},
+	}}})
+
+	cursor, err := AttendanceCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		ByUser   []AttendanceRecapUserTotals `bson:"by_user"`
+		ByStatus []struct {
+			Status string `bson:"_id"`
+			Count  int    `bson:"count"`
+		} `bson:"by_status"`
+	}
+	if err = cursor.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+
+	summary := &AttendanceRecapSummary{ByStatus: map[string]int{}, ByUser: []AttendanceRecapUserTotals{}}
+	if len(facets) == 0 {
+		return summary, nil
+	}
+	if facets[0].ByUser != nil {
+		summary.ByUser = facets[0].ByUser
+	}
+	for _, s := range facets[0].ByStatus {
+		summary.ByStatus[s.Status] = s.Count
+		summary.Total += s.Count
+	}
+	return summary, nil
+}
+
+// EnsureAttendanceIndexes creates the indexes the recap and per-user lookups rely on
+func EnsureAttendanceIndexes() error {
+	ctx := context.Background()
+	_, err := AttendanceCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "date", Value: -1}}},
+	})
+	return err
+}
+
+// LeaveReportFilter narrows the leave report built from work permits
+type LeaveReportFilter struct {
+	From       string
+	To         string
+	BranchID   string
+	UserID     string
+	Statuses   []string
+	LeaveTypes []string
+}
+
+type LeaveReportRow struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	UserName   string             `bson:"user_name" json:"user_name"`
+	BranchName string             `bson:"branch_name" json:"branch_name"`
+	Date       string             `bson:"date" json:"date"`
+	Session    string             `bson:"session" json:"session"`
+	LeaveType  string             `bson:"leave_type" json:"leave_type"`
+	Reason     string             `bson:"reason" json:"reason"`
+	Status     string             `bson:"status" json:"status"`
+}
+
+// GetLeaveReportMongo returns work permits with user and branch names, newest first
+func GetLeaveReportMongo(f LeaveReportFilter) ([]LeaveReportRow, error) {
+	ctx := context.Background()
+
+	match := bson.M{}
+	dateRange := bson.M{}
+	if f.From != "" {
+		dateRange["$gte"] = f.From
+	}
+	if f.To != "" {
+		dateRange["$lte"] = f.To
+	}
+	if len(dateRange) > 0 {
+		match["date"] = dateRange
+	}
+	if f.UserID != "" {
+		match["user_id"] = f.UserID
+	}
+	if len(f.Statuses) > 0 {
+		match["status"] = bson.M{"$in": f.Statuses}
+	}
+	if len(f.LeaveTypes) > 0 {
+		match["leave_type"] = bson.M{"$in": f.LeaveTypes}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}}},
+	}
+	pipeline = append(pipeline, userBranchLookupStages(f.BranchID)...)
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.M{
+		"user_id":     1,
+		"user_name":   bson.M{"$ifNull": bson.A{"$user.name", ""}},
+		"branch_name": bson.M{"$ifNull": bson.A{"$branch.name", ""}},
+		"date":        1,
+		"session":     1,
+		"leave_type":  1,
+		"reason":      1,
+		"status":      1,
+	}}})
+
+	cursor, err := WorkPermitsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rows := []LeaveReportRow{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AttendanceRulesMongo holds the configurable validation rules applied when attendance is added.
+// There is a single settings document; defaults are returned until an admin saves one.
+type AttendanceRulesMongo struct {
+	MaxBackdateDays           int    `bson:"max_backdate_days" json:"max_backdate_days"`
+	WorkdayStart              string `bson:"workday_start" json:"workday_start"` // HH:MM
+	WorkdayEnd                string `bson:"workday_end" json:"workday_end"`     // HH:MM
+	CheckFutureDate           bool   `bson:"check_future_date" json:"check_future_date"`
+	CheckBackdate             bool   `bson:"check_backdate" json:"check_backdate"`
+	CheckTimeRange            bool   `bson:"check_time_range" json:"check_time_range"`
+	CheckOverlap              bool   `bson:"check_overlap" json:"check_overlap"`
+	CheckWorkingHours         bool   `bson:"check_working_hours" json:"check_working_hours"`
+	CheckApprovedLeave        bool   `bson:"check_approved_leave" json:"check_approved_leave"`
+	CheckDuplicateSchoolClass bool   `bson:"check_duplicate_school_class" json:"check_duplicate_school_class"`
+	UpdatedAt                 string `bson:"updated_at" json:"updated_at"`
+	UpdatedBy                 string `bson:"updated_by" json:"updated_by"`
+}
+
+// AttendanceRuleOverride records an admin bypassing a validation rule for one attendance entry
+type AttendanceRuleOverride struct {
+	Rule          string `bson:"rule" json:"rule"`
+	Justification string `bson:"justification" json:"justification"`
+	OverriddenBy  string `bson:"overridden_by" json:"overridden_by"`
+	OverriddenAt  string `bson:"overridden_at" json:"overridden_at"`
+}
+
+// DefaultAttendanceRules mirrors the limits the attendance form already enforces client-side
+func DefaultAttendanceRules() AttendanceRulesMongo {
+	return AttendanceRulesMongo{
+		MaxBackdateDays:           2,
+		WorkdayStart:              "06:00",
+		WorkdayEnd:                "22:00",
+		CheckFutureDate:           true,
+		CheckBackdate:             true,
+		CheckTimeRange:            true,
+		CheckOverlap:              true,
+		CheckWorkingHours:         true,
+		CheckApprovedLeave:        true,
+		CheckDuplicateSchoolClass: true,
+	}
+}
+
+func GetAttendanceRulesMongo() (*AttendanceRulesMongo, error) {
+	ctx := context.Background()
+	var rules AttendanceRulesMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "attendance_rules"}).Decode(&rules)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultAttendanceRules()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+func UpdateAttendanceRulesMongo(rules AttendanceRulesMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "attendance_rules"},
+		bson.M{"$set": rules},
+		opts)
+	return err
+}
+
+// GetAttendanceByUserAndDate returns the user's attendance entries for a single day
+func GetAttendanceByUserAndDate(userID string, date string) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{"user_id": userID, "date": date})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AttendanceMongo
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetApprovedWorkPermitsByUserAndDate returns approved leave for the user on a single day
+func GetApprovedWorkPermitsByUserAndDate(userID string, date string) ([]WorkPermitMongo, error) {
+	ctx := context.Background()
+	cursor, err := WorkPermitsCollection().Find(ctx, bson.M{"user_id": userID, "date": date, "status": "approved"})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var permits []WorkPermitMongo
+	if err = cursor.All(ctx, &permits); err != nil {
+		return nil, err
+	}
+	return permits, nil
+}
+
+// GetAttendanceForUsersMongo returns the attendance of several users between two dates (inclusive)
+func GetAttendanceForUsersMongo(userIDs []string, from, to string) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{
+		"user_id": bson.M{"$in": userIDs},
+		"date":    bson.M{"$gte": from, "$lte": to},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AttendanceMongo
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetApprovedWorkPermitsForUsersMongo returns approved leave of several users between two dates (inclusive)
+func GetApprovedWorkPermitsForUsersMongo(userIDs []string, from, to string) ([]WorkPermitMongo, error) {
+	ctx := context.Background()
+	cursor, err := WorkPermitsCollection().Find(ctx, bson.M{
+		"user_id": bson.M{"$in": userIDs},
+		"date":    bson.M{"$gte": from, "$lte": to},
+		"status":  "approved",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var permits []WorkPermitMongo
+	if err = cursor.All(ctx, &permits); err != nil {
+		return nil, err
+	}
+	return permits, nil
+}
+
+// GetLockedPeriodsMongo is IsPeriodLockedMongo for several users and months (YYYY-MM) at once.
+// The result holds "userID|YYYY-MM" for every locked combination.
+func GetLockedPeriodsMongo(userIDs, periods []string) (map[string]bool, error) {
+	ctx := context.Background()
+	locked := make(map[string]bool)
+	cursor, err := TimesheetsCollection().Find(ctx, bson.M{
+		"user_id": bson.M{"$in": userIDs},
+		"period":  bson.M{"$in": periods},
+		"status":  bson.M{"$in": []string{"submitted", "approved"}},
+	}, options.Find().SetProjection(bson.M{"user_id": 1, "period": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var timesheets []TimesheetMongo
+	if err = cursor.All(ctx, &timesheets); err != nil {
+		return nil, err
+	}
+	for _, ts := range timesheets {
+		locked[ts.UserID+"|"+ts.Period] = true
+	}
+
+	cursor, err = PayrollRunsCollection().Find(ctx, bson.M{"period": bson.M{"$in": periods}, "status": "finalized"},
+		options.Find().SetProjection(bson.M{"period": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var runs []PayrollRunMongo
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	for _, run := range runs {
+		for _, id := range userIDs {
+			locked[id+"|"+run.Period] = true
+		}
+	}
+	return locked, nil
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TransferField is one column of a bulk-transfer layout. Value is a text/template evaluated
+// against a transfer record (detail) or the batch (header and trailer), e.g. {{.Account}}.
+type TransferField struct {
+	Name  string `bson:"name" json:"name"`   // column name for CSV layouts
+	Value string `bson:"value" json:"value"` // text/template
+	Width int    `bson:"width" json:"width"` // fixed-width layouts only
+	Align string `bson:"align" json:"align"` // left (default) or right
+	Pad   string `bson:"pad" json:"pad"`     // padding character, default space
+}
+
+// BankTransferTemplateMongo describes a bank's bulk-transfer upload file
+type BankTransferTemplateMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code      string             `bson:"code" json:"code"`
+	Name      string             `bson:"name" json:"name"`
+	Format    string             `bson:"format" json:"format"` // csv, fixed
+	Delimiter string             `bson:"delimiter" json:"delimiter"`
+	// Write Detail field names as the first CSV row
+	ColumnNames bool            `bson:"column_names" json:"column_names"`
+	Header      []TransferField `bson:"header" json:"header"`
+	Detail      []TransferField `bson:"detail" json:"detail"`
+	Trailer     []TransferField `bson:"trailer" json:"trailer"`
+	CRLF        bool            `bson:"crlf" json:"crlf"`
+	Extension   string          `bson:"extension" json:"extension"`
+	// Company account debited for the batch, available to templates as .SourceAccount
+	SourceAccount string `bson:"source_account" json:"source_account"`
+	Active        bool   `bson:"active" json:"active"`
+	CreatedAt     string `bson:"created_at" json:"created_at"`
+	UpdatedAt     string `bson:"updated_at" json:"updated_at"`
+}
+
+// BankRule validates account numbers of one bank, identified by its BI bank code
+type BankRule struct {
+	Code      string `bson:"code" json:"code"`
+	Name      string `bson:"name" json:"name"`
+	MinDigits int    `bson:"min_digits" json:"min_digits"`
+	MaxDigits int    `bson:"max_digits" json:"max_digits"`
+}
+
+// BankSettingsMongo lists the banks employees may be paid to
+type BankSettingsMongo struct {
+	Banks     []BankRule `bson:"banks" json:"banks"`
+	UpdatedAt string     `bson:"updated_at" json:"updated_at"`
+	UpdatedBy string     `bson:"updated_by" json:"updated_by"`
+}
+
+func DefaultBankSettings() BankSettingsMongo {
+	return BankSettingsMongo{Banks: []BankRule{
+		{Code: "002", Name: "BRI", MinDigits: 15, MaxDigits: 15},
+		{Code: "008", Name: "Mandiri", MinDigits: 13, MaxDigits: 13},
+		{Code: "009", Name: "BNI", MinDigits: 10, MaxDigits: 10},
+		{Code: "014", Name: "BCA", MinDigits: 10, MaxDigits: 10},
+		{Code: "022", Name: "CIMB Niaga", MinDigits: 12, MaxDigits: 14},
+		{Code: "200", Name: "BTN", MinDigits: 10, MaxDigits: 16},
+		{Code: "451", Name: "BSI", MinDigits: 10, MaxDigits: 10},
+	}}
+}
+
+// DefaultBankTransferTemplates are generic layouts to copy when configuring a specific bank
+func DefaultBankTransferTemplates() []BankTransferTemplateMongo {
+	return []BankTransferTemplateMongo{
+		{
+			Code: "generic_csv", Name: "Generic CSV", Format: "csv", Delimiter: ",", ColumnNames: true, Extension: "csv", Active: true,
+			Detail: []TransferField{
+				{Name: "No", Value: "{{.No}}"},
+				{Name: "Bank Code", Value: "{{.BankCode}}"},
+				{Name: "Account", Value: "{{.Account}}"},
+				{Name: "Account Holder", Value: "{{.Holder}}"},
+				{Name: "Amount", Value: "{{amount .Amount 2}}"},
+				{Name: "Reference", Value: "{{.Reference}}"},
+			},
+		},
+		{
+			Code: "generic_fixed", Name: "Generic fixed-width", Format: "fixed", CRLF: true, Extension: "txt", Active: true,
+			Header: []TransferField{
+				{Value: "H", Width: 1},
+				{Value: "{{digits .SourceAccount}}", Width: 20},
+				{Value: "{{date \"20060102\" .Date}}", Width: 8},
+				{Value: "{{.Count}}", Width: 6, Align: "right", Pad: "0"},
+				{Value: "{{cents .Total}}", Width: 17, Align: "right", Pad: "0"},
+				{Value: "{{upper .Reference}}", Width: 30},
+			},
+			Detail: []TransferField{
+				{Value: "D", Width: 1},
+				{Value: "{{.BankCode}}", Width: 3},
+				{Value: "{{.Account}}", Width: 20},
+				{Value: "{{upper .Holder}}", Width: 35},
+				{Value: "{{cents .Amount}}", Width: 17, Align: "right", Pad: "0"},
+				{Value: "{{upper .Reference}}", Width: 30},
+			},
+			Trailer: []TransferField{
+				{Value: "T", Width: 1},
+				{Value: "{{.Count}}", Width: 6, Align: "right", Pad: "0"},
+				{Value: "{{cents .Total}}", Width: 17, Align: "right", Pad: "0"},
+			},
+		},
+	}
+}
+
+// EnsureBankTransferTemplates indexes templates by code and seeds the generic layouts
+func EnsureBankTransferTemplates(now string) error {
+	ctx := context.Background()
+	unique := mongo.IndexModel{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)}
+	if _, err := BankTransferTemplatesCollection().Indexes().CreateOne(ctx, unique); err != nil {
+		return err
+	}
+
+	count, err := BankTransferTemplatesCollection().CountDocuments(ctx, bson.M{})
+	if err != nil || count > 0 {
+		return err
+	}
+	for _, t := range DefaultBankTransferTemplates() {
+		t.CreatedAt = now
+		t.UpdatedAt = now
+		if _, err := BankTransferTemplatesCollection().InsertOne(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetBankTransferTemplatesMongo() ([]BankTransferTemplateMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := BankTransferTemplatesCollection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	templates := []BankTransferTemplateMongo{}
+	if err = cursor.All(ctx, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func GetBankTransferTemplateByCodeMongo(code string) (*BankTransferTemplateMongo, error) {
+	ctx := context.Background()
+	var t BankTransferTemplateMongo
+	if err := BankTransferTemplatesCollection().FindOne(ctx, bson.M{"code": code}).Decode(&t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func CreateBankTransferTemplateMongo(t BankTransferTemplateMongo) (*BankTransferTemplateMongo, error) {
+	ctx := context.Background()
+	result, err := BankTransferTemplatesCollection().InsertOne(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	t.ID = result.InsertedID.(primitive.ObjectID)
+	return &t, nil
+}
+
+func UpdateBankTransferTemplateMongo(id string, t BankTransferTemplateMongo) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = BankTransferTemplatesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"code":           t.Code,
+		"name":           t.Name,
+		"format":         t.Format,
+		"delimiter":      t.Delimiter,
+		"column_names":   t.ColumnNames,
+		"header":         t.Header,
+		"detail":         t.Detail,
+		"trailer":        t.Trailer,
+		"crlf":           t.CRLF,
+		"extension":      t.Extension,
+		"source_account": t.SourceAccount,
+		"active":         t.Active,
+		"updated_at":     t.UpdatedAt,
+	}})
+	return err
+}
+
+func DeleteBankTransferTemplateMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = BankTransferTemplatesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+func GetBankSettingsMongo() (*BankSettingsMongo, error) {
+	ctx := context.Background()
+	var settings BankSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "banks"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultBankSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdateBankSettingsMongo(settings BankSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "banks"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BPJSProgram is one BPJS contribution. Rates are percent of the monthly wage (base pay plus
+// fixed allowances), clamped to WageFloor and WageCap when those are set.
+type BPJSProgram struct {
+	Code         string  `bson:"code" json:"code"` // KES, JHT, JP, JKK, JKM
+	Name         string  `bson:"name" json:"name"`
+	Agency       string  `bson:"agency" json:"agency"` // kesehatan, ketenagakerjaan
+	EmployerRate float64 `bson:"employer_rate" json:"employer_rate"`
+	EmployeeRate float64 `bson:"employee_rate" json:"employee_rate"`
+	WageCap      int64   `bson:"wage_cap" json:"wage_cap"`
+	WageFloor    int64   `bson:"wage_floor" json:"wage_floor"`
+	// Employer share counts as taxable income for PPh 21 (JKK, JKM, Kesehatan)
+	EmployerTaxable bool `bson:"employer_taxable" json:"employer_taxable"`
+	// Employee share reduces annual net income for PPh 21 (JHT, JP)
+	EmployeeDeductible bool `bson:"employee_deductible" json:"employee_deductible"`
+	Active             bool `bson:"active" json:"active"`
+}
+
+// BPJSSettingsMongo holds the configurable BPJS rates applied in payroll runs
+type BPJSSettingsMongo struct {
+	Programs  []BPJSProgram `bson:"programs" json:"programs"`
+	UpdatedAt string        `bson:"updated_at" json:"updated_at"`
+	UpdatedBy string        `bson:"updated_by" json:"updated_by"`
+}
+
+// DefaultBPJSSettings are the statutory rates; JKK uses the lowest risk group and the
+// JP wage cap is adjusted every March
+func DefaultBPJSSettings() BPJSSettingsMongo {
+	return BPJSSettingsMongo{
+		Programs: []BPJSProgram{
+			{Code: "KES", Name: "BPJS Kesehatan", Agency: "kesehatan", EmployerRate: 4, EmployeeRate: 1, WageCap: 12000000, EmployerTaxable: true, Active: true},
+			{Code: "JHT", Name: "Jaminan Hari Tua", Agency: "ketenagakerjaan", EmployerRate: 3.7, EmployeeRate: 2, EmployeeDeductible: true, Active: true},
+			{Code: "JP", Name: "Jaminan Pensiun", Agency: "ketenagakerjaan", EmployerRate: 2, EmployeeRate: 1, WageCap: 10547400, EmployeeDeductible: true, Active: true},
+			{Code: "JKK", Name: "Jaminan Kecelakaan Kerja", Agency: "ketenagakerjaan", EmployerRate: 0.24, EmployerTaxable: true, Active: true},
+			{Code: "JKM", Name: "Jaminan Kematian", Agency: "ketenagakerjaan", EmployerRate: 0.3, EmployerTaxable: true, Active: true},
+		},
+	}
+}
+
+func GetBPJSSettingsMongo() (*BPJSSettingsMongo, error) {
+	ctx := context.Background()
+	var settings BPJSSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "bpjs"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultBPJSSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdateBPJSSettingsMongo(settings BPJSSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "bpjs"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// GetPayslipsByPeriodMongo returns every payslip of a period's run, with lines
+func GetPayslipsByPeriodMongo(period string) ([]PayslipMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "user_name", Value: 1}})
+	cursor, err := PayslipsCollection().Find(ctx, bson.M{"period": period}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payslips []PayslipMongo
+	if err = cursor.All(ctx, &payslips); err != nil {
+		return nil, err
+	}
+	return payslips, nil
+}
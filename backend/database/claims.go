@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClaimCategory limits what can be reimbursed. A limit of 0 means no limit.
+type ClaimCategory struct {
+	Code            string `bson:"code" json:"code"`
+	Name            string `bson:"name" json:"name"`
+	PerClaimLimit   int64  `bson:"per_claim_limit" json:"per_claim_limit"`
+	MonthlyLimit    int64  `bson:"monthly_limit" json:"monthly_limit"` // per employee, by claim date
+	RequiresReceipt bool   `bson:"requires_receipt" json:"requires_receipt"`
+	Active          bool   `bson:"active" json:"active"`
+}
+
+type ClaimSettingsMongo struct {
+	Categories []ClaimCategory `bson:"categories" json:"categories"`
+	// PayoutMethod is "payroll" to pay approved claims with the next open payroll run, or
+	// "transfer" to collect them on a payout list paid separately
+	PayoutMethod string `bson:"payout_method" json:"payout_method"`
+	UpdatedAt    string `bson:"updated_at" json:"updated_at"`
+	UpdatedBy    string `bson:"updated_by" json:"updated_by"`
+}
+
+// ClaimReceipt points to a receipt in the file service. Data only remains on receipts saved
+// before the file service until MigrateInlineFiles moves them.
+type ClaimReceipt struct {
+	FileID      string `bson:"file_id" json:"file_id"`
+	Name        string `bson:"name" json:"name"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	Size        int    `bson:"size" json:"size"`
+	Data        string `bson:"data,omitempty" json:"-"`
+}
+
+// ClaimMongo moves pending -> approved -> paid, or pending -> rejected. Approved claims carry the
+// payroll period that pays them when the payout method is payroll.
+type ClaimMongo struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	UserName      string             `bson:"user_name" json:"user_name"`
+	Category      string             `bson:"category" json:"category"`
+	CategoryName  string             `bson:"category_name" json:"category_name"`
+	Date          string             `bson:"date" json:"date"`
+	Amount        int64              `bson:"amount" json:"amount"`
+	Description   string             `bson:"description" json:"description"`
+	SchoolID      string             `bson:"school_id,omitempty" json:"school_id,omitempty"`
+	AttendanceID  string             `bson:"attendance_id,omitempty" json:"attendance_id,omitempty"`
+	Receipts      []ClaimReceipt     `bson:"receipts" json:"receipts"`
+	Status        string             `bson:"status" json:"status"`
+	RejectReason  string             `bson:"reject_reason,omitempty" json:"reject_reason,omitempty"`
+	PayoutMethod  string             `bson:"payout_method,omitempty" json:"payout_method,omitempty"`
+	PayrollPeriod string             `bson:"payroll_period,omitempty" json:"payroll_period,omitempty"`
+	ApprovedBy    string             `bson:"approved_by,omitempty" json:"approved_by,omitempty"`
+	ApprovedAt    string             `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+	PaidAt        string             `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	PaidRef       string             `bson:"paid_ref,omitempty" json:"paid_ref,omitempty"` // payroll run ID or transfer reference
+	CreatedAt     string             `bson:"created_at" json:"created_at"`
+}
+
+// ClaimFilter narrows claim lists; empty fields match everything
+type ClaimFilter struct {
+	UserID        string
+	Status        string
+	Category      string
+	From          string
+	To            string
+	PayoutMethod  string
+	PayrollPeriod string
+}
+
+// receipts are left out of lists; they are fetched one at a time
+var claimListProjection = bson.M{"receipts.data": 0}
+
+// --- Claim Settings ---
+
+func DefaultClaimSettings() ClaimSettingsMongo {
+	return ClaimSettingsMongo{
+		Categories: []ClaimCategory{
+			{Code: "TRANSPORT", Name: "Transportasi ke Sekolah", PerClaimLimit: 200000, MonthlyLimit: 1500000, Active: true},
+			{Code: "EQUIPMENT", Name: "Peralatan Mengajar", PerClaimLimit: 1000000, MonthlyLimit: 2000000, RequiresReceipt: true, Active: true},
+			{Code: "OTHER", Name: "Lainnya", PerClaimLimit: 500000, MonthlyLimit: 1000000, RequiresReceipt: true, Active: true},
+		},
+		PayoutMethod: "payroll",
+	}
+}
+
+func GetClaimSettingsMongo() (*ClaimSettingsMongo, error) {
+	ctx := context.Background()
+	var settings ClaimSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "claims"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultClaimSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdateClaimSettingsMongo(settings ClaimSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "claims"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// --- Claims CRUD ---
+
+func AddClaimMongo(claim ClaimMongo) (*ClaimMongo, error) {
+	ctx := context.Background()
+	result, err := ClaimsCollection().InsertOne(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
+	claim.ID = result.InsertedID.(primitive.ObjectID)
+	return &claim, nil
+}
+
+func GetClaimsMongo(f ClaimFilter) ([]ClaimMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if f.UserID != "" {
+		filter["user_id"] = f.UserID
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.Category != "" {
+		filter["category"] = f.Category
+	}
+	if f.PayoutMethod != "" {
+		filter["payout_method"] = f.PayoutMethod
+	}
+	if f.PayrollPeriod != "" {
+		filter["payroll_period"] = f.PayrollPeriod
+	}
+	if f.From != "" || f.To != "" {
+		date := bson.M{}
+		if f.From != "" {
+			date["$gte"] = f.From
+		}
+		if f.To != "" {
+			date["$lte"] = f.To
+		}
+		filter["date"] = date
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}}).SetProjection(claimListProjection)
+	cursor, err := ClaimsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	claims := []ClaimMongo{}
+	if err = cursor.All(ctx, &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// GetClaimByIDMongo returns a claim with its receipts
+func GetClaimByIDMongo(id string) (*ClaimMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var claim ClaimMongo
+	if err := ClaimsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&claim); err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// SumClaimsMongo totals an employee's claims of a category dated in [from, to] that are not
+// rejected, leaving out excludeID
+func SumClaimsMongo(userID, category, from, to, excludeID string) (int64, error) {
+	ctx := context.Background()
+	match := bson.M{
+		"user_id":  userID,
+		"category": category,
+		"status":   bson.M{"$ne": "rejected"},
+		"date":     bson.M{"$gte": from, "$lte": to},
+	}
+	if objID, err := primitive.ObjectIDFromHex(excludeID); err == nil {
+		match["_id"] = bson.M{"$ne": objID}
+	}
+	cursor, err := ClaimsCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$amount"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []struct {
+		Total int64 `bson:"total"`
+	}
+	if err = cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return 0, err
+	}
+	return result[0].Total, nil
+}
+
+// UpdateClaimMongo applies set only while the claim still has fromStatus
+func UpdateClaimMongo(id, fromStatus string, set bson.M) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := ClaimsCollection().UpdateOne(ctx, bson.M{"_id": objID, "status": fromStatus}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// MarkClaimsPaidMongo marks approved transfer claims paid; other ids are skipped
+func MarkClaimsPaidMongo(ids []string, paidAt, paidRef string) (int64, error) {
+	ctx := context.Background()
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return 0, err
+		}
+		objIDs = append(objIDs, objID)
+	}
+	result, err := ClaimsCollection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": objIDs}, "payout_method": "transfer", "status": "approved"},
+		bson.M{"$set": bson.M{"status": "paid", "paid_at": paidAt, "paid_ref": paidRef}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// MarkPayrollClaimsPaidMongo marks the claims a finalized payroll run paid
+func MarkPayrollClaimsPaidMongo(period, runID, paidAt string) error {
+	ctx := context.Background()
+	_, err := ClaimsCollection().UpdateMany(ctx,
+		bson.M{"payroll_period": period, "payout_method": "payroll", "status": "approved"},
+		bson.M{"$set": bson.M{"status": "paid", "paid_at": paidAt, "paid_ref": runID}})
+	return err
+}
+
+func DeleteClaimMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = ClaimsCollection().DeleteOne(ctx, bson.M{"_id": objID, "status": "pending"})
+	return err
+}
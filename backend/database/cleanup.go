@@ -2,61 +2,130 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func CleanupOrphanedData() (int, error) {
+// orphanDeleteBatch bounds the number of user IDs in one DeleteMany
+const orphanDeleteBatch = 500
+
+// OrphanReport is the cleanup outcome for one collection. Records without a user_id are only
+// counted: they are data problems to look at, not proof that the owner is gone.
+type OrphanReport struct {
+	Collection    string   `json:"collection"`
+	Orphaned      int64    `json:"orphaned"`
+	OrphanUserIDs []string `json:"orphan_user_ids"`
+	MissingUserID int64    `json:"missing_user_id"`
+	Archived      int64    `json:"archived"`
+}
+
+type OrphanCleanupResult struct {
+	DryRun      bool           `json:"dry_run"`
+	Collections []OrphanReport `json:"collections"`
+	Orphaned    int64          `json:"orphaned"`
+	Archived    int64          `json:"archived"`
+	RanAt       string         `json:"ran_at"`
+}
+
+// orphanCollections hold per-user records that are useless once the user is gone
+func orphanCollections() []*mongo.Collection {
+	return []*mongo.Collection{
+		AttendanceCollection(),
+		WorkPermitsCollection(),
+		PendingRequestsCollection(),
+		AwardsCollection(),
+		LeaveQuotasCollection(),
+	}
+}
+
+// findOrphans groups a collection by user_id and looks each distinct ID up in users, so the
+// lookup runs once per user rather than once per record
+func findOrphans(coll *mongo.Collection) (OrphanReport, error) {
 	ctx := context.Background()
+	report := OrphanReport{Collection: coll.Name(), OrphanUserIDs: []string{}}
+
+	missing, err := coll.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"user_id": bson.M{"$exists": false}},
+		bson.M{"user_id": bson.M{"$in": bson.A{"", nil}}},
+	}})
+	if err != nil {
+		return report, err
+	}
+	report.MissingUserID = missing
 
-	// Get all valid user IDs
-	cursor, err := UsersCollection().Find(ctx, bson.M{})
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": bson.M{"$type": "string", "$ne": ""}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$user_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": UsersCollection().Name(),
+			"let":  bson.M{"uid": bson.M{"$convert": bson.M{"input": "$_id", "to": "objectId", "onError": nil, "onNull": nil}}},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$uid"}}}},
+				bson.M{"$project": bson.M{"_id": 1}},
+			},
+			"as": "user",
+		}}},
+		{{Key: "$match", Value: bson.M{"user": bson.M{"$size": 0}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := coll.Aggregate(ctx, pipeline)
 	if err != nil {
-		return 0, err
+		return report, err
 	}
 	defer cursor.Close(ctx)
 
-	validUserIDs := make(map[string]bool)
-	for cursor.Next(ctx) {
-		var user UserMongo
-		if err := cursor.Decode(&user); err == nil {
-			validUserIDs[user.ID.Hex()] = true
-		}
+	var rows []struct {
+		UserID string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return report, err
 	}
+	for _, r := range rows {
+		report.OrphanUserIDs = append(report.OrphanUserIDs, r.UserID)
+		report.Orphaned += r.Count
+	}
+	return report, nil
+}
 
-	deletedCount := 0
+// CleanupOrphanedData finds records whose user no longer exists. Unless dryRun is set it moves
+// them to the archive, where they can be restored for 30 days. A cancelled ctx stops it between
+// batches.
+func CleanupOrphanedData(ctx context.Context, dryRun bool) (*OrphanCleanupResult, error) {
+	now := time.Now()
+	result := &OrphanCleanupResult{DryRun: dryRun, Collections: []OrphanReport{}, RanAt: now.Format("2006-01-02 15:04:05")}
 
-	// Helper to cleanup a collection
-	cleanupCollection := func(coll *mongo.Collection, dateField string) {
-		cur, err := coll.Find(ctx, bson.M{})
+	for _, coll := range orphanCollections() {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		report, err := findOrphans(coll)
 		if err != nil {
-			return
+			return result, err
 		}
-		defer cur.Close(ctx)
-
-		for cur.Next(ctx) {
-			var doc bson.M
-			if err := cur.Decode(&doc); err != nil {
-				continue
-			}
-
-			// Check if user_id exists and is valid
-			userID, ok := doc["user_id"].(string)
-			if !ok || !validUserIDs[userID] {
-				// Delete
-				_, _ = coll.DeleteOne(ctx, bson.M{"_id": doc["_id"]})
-				deletedCount++
+		if !dryRun {
+			for start := 0; start < len(report.OrphanUserIDs); start += orphanDeleteBatch {
+				if err := ctx.Err(); err != nil {
+					result.Collections = append(result.Collections, report)
+					return result, err
+				}
+				end := start + orphanDeleteBatch
+				if end > len(report.OrphanUserIDs) {
+					end = len(report.OrphanUserIDs)
+				}
+				archived, err := archiveAndDelete(coll, bson.M{"user_id": bson.M{"$in": report.OrphanUserIDs[start:end]}}, "orphan", now)
+				report.Archived += archived
+				if err != nil {
+					result.Collections = append(result.Collections, report)
+					return result, err
+				}
 			}
 		}
+		result.Collections = append(result.Collections, report)
+		result.Orphaned += report.Orphaned
+		result.Archived += report.Archived
 	}
-
-	// Collections to cleanup
-	cleanupCollection(AttendanceCollection(), "date")
-	cleanupCollection(WorkPermitsCollection(), "date")
-	cleanupCollection(PendingRequestsCollection(), "date")
-	cleanupCollection(AwardsCollection(), "date")
-	cleanupCollection(LeaveQuotasCollection(), "created_at")
-
-	return deletedCount, nil
+	return result, nil
 }
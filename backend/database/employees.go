@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacyEmployeeFields are the profile fields the old employees collection shared with users
+var legacyEmployeeFields = []string{
+	"name", "email", "center", "roles", "photo_url", "photo_file_id", "branch_id", "sex", "pob", "dob",
+	"age", "religion", "phone", "address1", "address2", "nik", "npwp", "education_level",
+	"institution", "major", "graduation_year", "bank_account", "status_ptkp", "jabatan",
+}
+
+// directoryFilter matches active users shown in the directory. show_in_directory defaults to
+// true when it was never set, which a decoded UserMongo cannot tell apart from false.
+func directoryFilter() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"show_in_directory": true},
+		bson.M{"show_in_directory": bson.M{"$exists": false}},
+	}, "status": bson.M{"$nin": bson.A{"inactive", "deleted"}}}
+}
+
+// GetDirectoryUsersMongo returns the active users shown in the employee directory
+func GetDirectoryUsersMongo() ([]UserMongo, error) {
+	ctx := context.Background()
+	cursor, err := UsersCollection().Find(ctx, directoryFilter())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []UserMongo{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// InDirectoryMongo reports whether a user is listed in the employee directory
+func InDirectoryMongo(id primitive.ObjectID) (bool, error) {
+	ctx := context.Background()
+	filter := directoryFilter()
+	filter["_id"] = id
+	count, err := UsersCollection().CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+// emptyValue reports whether a decoded BSON value carries no data
+func emptyValue(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case int32:
+		return t == 0
+	case int64:
+		return t == 0
+	case float64:
+		return t == 0
+	}
+	return false
+}
+
+// legacyEmployeeOwner finds the user a legacy employee belongs to, by user_id and then by email
+func legacyEmployeeOwner(emp bson.M) (bson.M, error) {
+	ctx := context.Background()
+	var filters []bson.M
+	if uid, ok := emp["user_id"].(string); ok && uid != "" {
+		if objID, err := primitive.ObjectIDFromHex(uid); err == nil {
+			filters = append(filters, bson.M{"_id": objID})
+		}
+	}
+	if email, ok := emp["email"].(string); ok && email != "" {
+		filters = append(filters, bson.M{"email": email})
+	}
+	for _, f := range filters {
+		var user bson.M
+		err := UsersCollection().FindOne(ctx, f).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		return user, err
+	}
+	return nil, nil
+}
+
+// MigrateLegacyEmployees retires the employees collection: users is the only employee record.
+// An employee that belongs to a user (same user_id or email) fills the user's empty fields;
+// any other becomes a user without a password under the same _id. Migrated documents go to
+// the archive, so the collection ends up empty and the originals stay restorable for 30 days.
+func MigrateLegacyEmployees() (merged, created int64, err error) {
+	ctx := context.Background()
+	cursor, err := EmployeesCollection().Find(ctx, bson.M{})
+	if err != nil {
+		return 0, 0, err
+	}
+	var legacy []bson.M
+	if err = cursor.All(ctx, &legacy); err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	for _, emp := range legacy {
+		owner, err := legacyEmployeeOwner(emp)
+		if err != nil {
+			return merged, created, err
+		}
+		if owner != nil {
+			set := bson.M{}
+			for _, f := range legacyEmployeeFields {
+				if !emptyValue(emp[f]) && emptyValue(owner[f]) {
+					set[f] = emp[f]
+				}
+			}
+			if len(set) > 0 {
+				if _, err := UsersCollection().UpdateOne(ctx, bson.M{"_id": owner["_id"]}, bson.M{"$set": set}); err != nil {
+					return merged, created, err
+				}
+			}
+			merged++
+		} else {
+			user := bson.M{"_id": emp["_id"], "role": "staff", "is_admin": false, "show_in_directory": true}
+			for _, f := range legacyEmployeeFields {
+				if v, ok := emp[f]; ok {
+					user[f] = v
+				}
+			}
+			if _, err := UsersCollection().InsertOne(ctx, user); err != nil && !mongo.IsDuplicateKeyError(err) {
+				return merged, created, err
+			}
+			created++
+		}
+		if _, err := archiveAndDelete(EmployeesCollection(), bson.M{"_id": emp["_id"]}, "migrated", now); err != nil {
+			return merged, created, err
+		}
+	}
+	return merged, created, nil
+}
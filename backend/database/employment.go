@@ -0,0 +1,407 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EmploymentTypes are the values of UserMongo.EmploymentType. An empty type is treated as permanent.
+var EmploymentTypes = map[string]string{
+	"permanent": "Karyawan Tetap (PKWTT)",
+	"contract":  "Kontrak (PKWT)",
+	"freelance": "Harian Lepas / Freelance",
+	"intern":    "Magang",
+}
+
+// FieldChange is one field of an employment event
+type FieldChange struct {
+	Field string `bson:"field" json:"field"`
+	From  string `bson:"from" json:"from"`
+	To    string `bson:"to" json:"to"`
+}
+
+// EmploymentEventMongo records a change in someone's employment: hiring, a new contract,
+// conversion to permanent, the end of probation, termination
+type EmploymentEventMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	UserName  string             `bson:"user_name" json:"user_name"`
+	Type      string             `bson:"type" json:"type"` // hired, type_changed, probation_set, contract_started, contract_extended, terminated, reinstated, updated
+	Date      string             `bson:"date" json:"date"` // effective date
+	Changes   []FieldChange      `bson:"changes" json:"changes"`
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt string             `bson:"created_at" json:"created_at"`
+}
+
+// EmploymentAlertMongo warns HR ahead of a contract end or probation review. One alert exists
+// per user, kind, due date and threshold, so the daily job can run any number of times.
+type EmploymentAlertMongo struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         string             `bson:"user_id" json:"user_id"`
+	UserName       string             `bson:"user_name" json:"user_name"`
+	Kind           string             `bson:"kind" json:"kind"` // contract_expiry, probation_review
+	DueDate        string             `bson:"due_date" json:"due_date"`
+	DaysBefore     int                `bson:"days_before" json:"days_before"`
+	Status         string             `bson:"status" json:"status"` // open, acknowledged
+	CreatedAt      string             `bson:"created_at" json:"created_at"`
+	AcknowledgedBy string             `bson:"acknowledged_by,omitempty" json:"acknowledged_by,omitempty"`
+	AcknowledgedAt string             `bson:"acknowledged_at,omitempty" json:"acknowledged_at,omitempty"`
+}
+
+var (
+	contractAlertDays  = []int{7, 14, 30}
+	probationAlertDays = []int{0, 7, 14}
+)
+
+// --- Employment Rules ---
+
+// parseDay reads a YYYY-MM-DD date; ok is false for empty or invalid dates
+func parseDay(s string) (time.Time, bool) {
+	t, err := time.Parse("2006-01-02", s)
+	return t, err == nil
+}
+
+// LeaveEntitlement is the annual leave a user earns in a year. Under UU 13/2003 leave starts after
+// 12 months of service; the year of that anniversary and the year of termination are pro-rated.
+// Freelancers and interns earn none. Users without a hire date keep the flat 12 days.
+func LeaveEntitlement(user *UserMongo, year int) int {
+	const fullYear = 12
+	if user == nil {
+		return fullYear
+	}
+	if user.EmploymentType == "freelance" || user.EmploymentType == "intern" {
+		return 0
+	}
+	hire, ok := parseDay(user.HireDate)
+	if !ok {
+		return fullYear
+	}
+
+	yearStart := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+	from := hire.AddDate(1, 0, 0)
+	if from.Before(yearStart) {
+		from = yearStart
+	}
+	to := yearEnd
+	if end, ok := parseDay(user.TerminationDate); ok && end.Before(to) {
+		to = end
+	}
+	if to.Before(from) {
+		return 0
+	}
+	covered := int(to.Sub(from).Hours()/24) + 1
+	total := int(yearEnd.Sub(yearStart).Hours()/24) + 1
+	return fullYear * covered / total
+}
+
+// EmployedDuring reports whether the employment terms overlap from..to; a missing hire or
+// termination date leaves that end open
+func EmployedDuring(user *UserMongo, from, to time.Time) bool {
+	if hire, ok := parseDay(user.HireDate); ok && hire.After(to) {
+		return false
+	}
+	if end, ok := parseDay(user.TerminationDate); ok && end.Before(from) {
+		return false
+	}
+	return true
+}
+
+// THREligibility applies Permenaker 6/2016 to employment terms at a holiday: interns are not
+// covered, contracts that ended before the holiday are not paid, and permanent employees
+// terminated at most 30 days before it still are. It returns a note when the user is excluded.
+func THREligibility(user *UserMongo, holiday time.Time) (bool, string) {
+	if user.EmploymentType == "intern" {
+		return false, "Magang tidak termasuk penerima THR"
+	}
+	if end, ok := parseDay(user.TerminationDate); ok && end.Before(holiday) {
+		if user.EmploymentType == "contract" || holiday.Sub(end) > 30*24*time.Hour {
+			return false, "Sudah berhenti bekerja sebelum hari raya (" + user.TerminationDate + ")"
+		}
+	}
+	if user.EmploymentType == "contract" {
+		if end, ok := parseDay(user.ContractEnd); ok && end.Before(holiday) {
+			return false, "Kontrak berakhir sebelum hari raya (" + user.ContractEnd + ")"
+		}
+	}
+	return true, ""
+}
+
+// EmploymentEvents compares the terms before and after an update and returns the events to record
+func EmploymentEvents(before, after *UserMongo) []EmploymentEventMongo {
+	type group struct {
+		kind   func(changes []FieldChange) string
+		date   string
+		fields [][3]string // field, from, to
+	}
+	groups := []group{
+		{
+			kind: func(ch []FieldChange) string {
+				if ch[0].From == "" {
+					return "hired"
+				}
+				return "updated"
+			},
+			date:   after.HireDate,
+			fields: [][3]string{{"hire_date", before.HireDate, after.HireDate}},
+		},
+		{
+			kind:   func([]FieldChange) string { return "type_changed" },
+			date:   "",
+			fields: [][3]string{{"employment_type", before.EmploymentType, after.EmploymentType}},
+		},
+		{
+			kind:   func([]FieldChange) string { return "probation_set" },
+			date:   after.ProbationEnd,
+			fields: [][3]string{{"probation_end", before.ProbationEnd, after.ProbationEnd}},
+		},
+		{
+			kind: func(ch []FieldChange) string {
+				for _, c := range ch {
+					if c.Field == "contract_start" {
+						return "contract_started"
+					}
+				}
+				return "contract_extended"
+			},
+			date: after.ContractStart,
+			fields: [][3]string{
+				{"contract_start", before.ContractStart, after.ContractStart},
+				{"contract_end", before.ContractEnd, after.ContractEnd},
+			},
+		},
+		{
+			kind: func(ch []FieldChange) string {
+				for _, c := range ch {
+					if c.Field == "termination_date" {
+						if c.To == "" {
+							return "reinstated"
+						}
+						if c.From == "" {
+							return "terminated"
+						}
+					}
+				}
+				return "updated"
+			},
+			date: after.TerminationDate,
+			fields: [][3]string{
+				{"termination_date", before.TerminationDate, after.TerminationDate},
+				{"termination_reason", before.TerminationReason, after.TerminationReason},
+			},
+		},
+	}
+
+	events := []EmploymentEventMongo{}
+	for _, g := range groups {
+		changes := []FieldChange{}
+		for _, f := range g.fields {
+			if f[1] != f[2] {
+				changes = append(changes, FieldChange{Field: f[0], From: f[1], To: f[2]})
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		events = append(events, EmploymentEventMongo{
+			UserID:   after.ID.Hex(),
+			UserName: after.Name,
+			Type:     g.kind(changes),
+			Date:     g.date,
+			Changes:  changes,
+		})
+	}
+	return events
+}
+
+// --- Employment Events ---
+
+// AddEmploymentEventsMongo stamps and stores events
+func AddEmploymentEventsMongo(events []EmploymentEventMongo, createdBy, note, now string) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	docs := make([]interface{}, len(events))
+	for i := range events {
+		events[i].CreatedBy = createdBy
+		events[i].CreatedAt = now
+		if events[i].Note == "" {
+			events[i].Note = note
+		}
+		if events[i].Date == "" {
+			events[i].Date = now[:10]
+		}
+		docs[i] = events[i]
+	}
+	_, err := EmploymentEventsCollection().InsertMany(ctx, docs)
+	return err
+}
+
+// GetEmploymentEventsMongo lists a user's events, newest first
+func GetEmploymentEventsMongo(userID string) ([]EmploymentEventMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := EmploymentEventsCollection().Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []EmploymentEventMongo{}
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// UpdateEmploymentMongo saves the employment terms of a user
+func UpdateEmploymentMongo(user *UserMongo) error {
+	ctx := context.Background()
+	_, err := UsersCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"hire_date":          user.HireDate,
+		"employment_type":    user.EmploymentType,
+		"probation_end":      user.ProbationEnd,
+		"contract_start":     user.ContractStart,
+		"contract_end":       user.ContractEnd,
+		"termination_date":   user.TerminationDate,
+		"termination_reason": user.TerminationReason,
+	}})
+	return err
+}
+
+// --- Employment Alerts ---
+
+type alertCheck struct {
+	kind       string
+	date       string
+	thresholds []int
+}
+
+// alertThreshold picks the tightest threshold the remaining days fall under, so a job that
+// first sees a contract 5 days before its end raises only the 7-day alert
+func alertThreshold(daysLeft int, thresholds []int) (int, bool) {
+	for _, t := range thresholds {
+		if daysLeft <= t {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateEmploymentAlertsMongo raises alerts for contracts ending within 30, 14 or 7 days and
+// probation periods ending within 14 or 7 days or today, and returns how many were new
+func GenerateEmploymentAlertsMongo(today time.Time) (map[string]int64, error) {
+	ctx := context.Background()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	users, err := GetAllUsersMongo()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{"contract_expiry": 0, "probation_review": 0}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	for _, u := range users {
+		if end, ok := parseDay(u.TerminationDate); ok && !end.After(today) {
+			continue
+		}
+		checks := []alertCheck{{"probation_review", u.ProbationEnd, probationAlertDays}}
+		if u.EmploymentType == "contract" {
+			checks = append(checks, alertCheck{"contract_expiry", u.ContractEnd, contractAlertDays})
+		}
+		for _, chk := range checks {
+			due, ok := parseDay(chk.date)
+			if !ok {
+				continue
+			}
+			daysLeft := int(due.Sub(today).Hours() / 24)
+			if daysLeft < 0 {
+				continue
+			}
+			threshold, ok := alertThreshold(daysLeft, chk.thresholds)
+			if !ok {
+				continue
+			}
+			_, err := EmploymentAlertsCollection().InsertOne(ctx, EmploymentAlertMongo{
+				UserID:     u.ID.Hex(),
+				UserName:   u.Name,
+				Kind:       chk.kind,
+				DueDate:    chk.date,
+				DaysBefore: threshold,
+				Status:     "open",
+				CreatedAt:  now,
+			})
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			if err != nil {
+				return counts, fmt.Errorf("alert for %s: %v", u.Name, err)
+			}
+			counts[chk.kind]++
+		}
+	}
+	return counts, nil
+}
+
+// GetEmploymentAlertsMongo lists alerts by due date; an empty status lists all
+func GetEmploymentAlertsMongo(status string) ([]EmploymentAlertMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "due_date", Value: 1}, {Key: "days_before", Value: 1}})
+	cursor, err := EmploymentAlertsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []EmploymentAlertMongo{}
+	if err = cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// AcknowledgeEmploymentAlertMongo closes an open alert; false means it was not open
+func AcknowledgeEmploymentAlertMongo(id, by, now string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := EmploymentAlertsCollection().UpdateOne(ctx,
+		bson.M{"_id": objID, "status": "open"},
+		bson.M{"$set": bson.M{"status": "acknowledged", "acknowledged_by": by, "acknowledged_at": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// EnsureEmploymentIndexes keeps alerts unique and backs the event history lookup
+func EnsureEmploymentIndexes() error {
+	ctx := context.Background()
+	_, err := EmploymentAlertsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_id", Value: 1}, {Key: "kind", Value: 1},
+			{Key: "due_date", Value: 1}, {Key: "days_before", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = EmploymentEventsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	return err
+}
@@ -0,0 +1,255 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"kkhris-clone/storage"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FileMongo describes an uploaded file. Its contents live in the storage backend under the
+// hex ID; documents that use the file store only that ID.
+type FileMongo struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerID     string             `bson:"owner_id" json:"owner_id"`
+	Purpose     string             `bson:"purpose" json:"purpose"` // photo, document, receipt
+	Name        string             `bson:"name" json:"name"`
+	ContentType string             `bson:"content_type" json:"content_type"`
+	Size        int64              `bson:"size" json:"size"`
+	SHA256      string             `bson:"sha256" json:"sha256"`
+	Driver      string             `bson:"driver" json:"driver"`
+	CreatedAt   string             `bson:"created_at" json:"created_at"`
+}
+
+var fileStore storage.Backend
+
+// InitFileStorage opens the storage backend configured by STORAGE_DRIVER
+func InitFileStorage() error {
+	store, err := storage.FromEnv(database)
+	if err != nil {
+		return err
+	}
+	fileStore = store
+	log.Println("File storage:", store.Name())
+	return nil
+}
+
+// SaveFileMongo stores data and records its metadata. An owner uploading the same content for
+// the same purpose again gets the existing file back.
+func SaveFileMongo(f FileMongo, data []byte) (*FileMongo, error) {
+	if fileStore == nil {
+		return nil, fmt.Errorf("file storage is not initialized")
+	}
+	ctx := context.Background()
+	sum := sha256.Sum256(data)
+	f.SHA256 = hex.EncodeToString(sum[:])
+	f.Size = int64(len(data))
+
+	var existing FileMongo
+	err := FilesCollection().FindOne(ctx, bson.M{"owner_id": f.OwnerID, "purpose": f.Purpose, "sha256": f.SHA256}).Decode(&existing)
+	if err == nil {
+		return &existing, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	f.ID = primitive.NewObjectID()
+	f.Driver = fileStore.Name()
+	key := f.ID.Hex()
+	if err := fileStore.Put(ctx, key, f.ContentType, bytes.NewReader(data), f.Size); err != nil {
+		return nil, err
+	}
+	if _, err := FilesCollection().InsertOne(ctx, f); err != nil {
+		fileStore.Delete(ctx, key)
+		return nil, err
+	}
+	return &f, nil
+}
+
+func GetFileByIDMongo(id string) (*FileMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var f FileMongo
+	if err := FilesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetFilesByIDsMongo returns the metadata of several files keyed by ID
+func GetFilesByIDsMongo(ids []string) (map[string]FileMongo, error) {
+	ctx := context.Background()
+	objIDs := []primitive.ObjectID{}
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	files := make(map[string]FileMongo, len(objIDs))
+	if len(objIDs) == 0 {
+		return files, nil
+	}
+	cursor, err := FilesCollection().Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []FileMongo
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	for _, f := range list {
+		files[f.ID.Hex()] = f
+	}
+	return files, nil
+}
+
+// OpenFileMongo opens the stored contents of a file
+func OpenFileMongo(f *FileMongo) (io.ReadCloser, error) {
+	if fileStore == nil {
+		return nil, fmt.Errorf("file storage is not initialized")
+	}
+	return fileStore.Open(context.Background(), f.ID.Hex())
+}
+
+func DeleteFileMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	if fileStore != nil {
+		if err := fileStore.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	_, err = FilesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// EnsureFileIndexes backs the duplicate lookup in SaveFileMongo
+func EnsureFileIndexes() error {
+	ctx := context.Background()
+	_, err := FilesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner_id", Value: 1}, {Key: "purpose", Value: 1}, {Key: "sha256", Value: 1}},
+	})
+	return err
+}
+
+// saveDataURL moves an inline base64 data URL into the file service
+func saveDataURL(ownerID, purpose, name, dataURL, now string) (string, error) {
+	contentType, data, err := storage.ParseDataURL(dataURL)
+	if err != nil {
+		return "", err
+	}
+	f, err := SaveFileMongo(FileMongo{OwnerID: ownerID, Purpose: purpose, Name: name, ContentType: contentType, CreatedAt: now}, data)
+	if err != nil {
+		return "", err
+	}
+	return f.ID.Hex(), nil
+}
+
+var inlineDataFilter = bson.M{"$regex": "^data:"}
+
+// MigrateInlineFiles moves base64 data URLs stored inline in users, work permits, pending requests
+// and claims into the file service and replaces them with file IDs. A work permit and its pending
+// request share one file. Documents whose data cannot be decoded are left as they are and logged.
+func MigrateInlineFiles(now string) (int, error) {
+	ctx := context.Background()
+	migrated := 0
+
+	type inlineDoc struct {
+		ID     primitive.ObjectID `bson:"_id"`
+		UserID string             `bson:"user_id"`
+		Photo  string             `bson:"photo_url"`
+		File   string             `bson:"supporting_file"`
+	}
+	moves := []struct {
+		coll    *mongo.Collection
+		field   string
+		idField string
+		purpose string
+		name    string
+	}{
+		{UsersCollection(), "photo_url", "photo_file_id", "photo", "photo"},
+		{WorkPermitsCollection(), "supporting_file", "supporting_file_id", "document", "supporting-file"},
+		{PendingRequestsCollection(), "supporting_file", "supporting_file_id", "document", "supporting-file"},
+	}
+	for _, m := range moves {
+		opts := options.Find().SetProjection(bson.M{"user_id": 1, m.field: 1})
+		cursor, err := m.coll.Find(ctx, bson.M{m.field: inlineDataFilter}, opts)
+		if err != nil {
+			return migrated, err
+		}
+		var docs []inlineDoc
+		if err = cursor.All(ctx, &docs); err != nil {
+			return migrated, err
+		}
+		for _, d := range docs {
+			owner, data := d.UserID, d.File
+			if m.field == "photo_url" {
+				owner, data = d.ID.Hex(), d.Photo
+			}
+			fileID, err := saveDataURL(owner, m.purpose, m.name, data, now)
+			if err != nil {
+				log.Printf("Migrate inline file %s %s: %v", m.coll.Name(), d.ID.Hex(), err)
+				continue
+			}
+			_, err = m.coll.UpdateOne(ctx, bson.M{"_id": d.ID}, bson.M{
+				"$set":   bson.M{m.idField: fileID},
+				"$unset": bson.M{m.field: ""},
+			})
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	cursor, err := ClaimsCollection().Find(ctx, bson.M{"receipts.data": inlineDataFilter})
+	if err != nil {
+		return migrated, err
+	}
+	var claims []ClaimMongo
+	if err = cursor.All(ctx, &claims); err != nil {
+		return migrated, err
+	}
+	for _, cl := range claims {
+		changed := false
+		for i, r := range cl.Receipts {
+			if !strings.HasPrefix(r.Data, "data:") {
+				continue
+			}
+			fileID, err := saveDataURL(cl.UserID, "receipt", r.Name, r.Data, now)
+			if err != nil {
+				log.Printf("Migrate inline receipt %s/%d: %v", cl.ID.Hex(), i, err)
+				continue
+			}
+			cl.Receipts[i].FileID, cl.Receipts[i].Data = fileID, ""
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if _, err := ClaimsCollection().UpdateOne(ctx, bson.M{"_id": cl.ID}, bson.M{"$set": bson.M{"receipts": cl.Receipts}}); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
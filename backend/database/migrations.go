@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrationMongo marks a one-time migration as done so later startups skip it
+type MigrationMongo struct {
+	Name        string `bson:"_id" json:"name"`
+	Updated     int64  `bson:"updated" json:"updated"`
+	CompletedAt string `bson:"completed_at" json:"completed_at"`
+}
+
+// RunMigrationOnce runs migrate unless a migration with this name has completed before, and
+// records it once it succeeds. A failed migration is retried on the next startup, so migrate
+// must be safe to run again. ran is false when the migration had already completed.
+func RunMigrationOnce(name string, migrate func() (int64, error)) (ran bool, updated int64, err error) {
+	ctx := context.Background()
+	err = MigrationsCollection().FindOne(ctx, bson.M{"_id": name}).Err()
+	if err == nil {
+		return false, 0, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return false, 0, err
+	}
+
+	if updated, err = migrate(); err != nil {
+		return true, updated, err
+	}
+	_, err = MigrationsCollection().InsertOne(ctx, MigrationMongo{
+		Name:        name,
+		Updated:     updated,
+		CompletedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+	// Another replica finishing the same migration first is fine
+	if mongo.IsDuplicateKeyError(err) {
+		err = nil
+	}
+	return true, updated, err
+}
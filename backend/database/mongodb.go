@@ -50,35 +50,33 @@ type UserMongo struct {
 	StatusPTKP      string      `bson:"status_ptkp" json:"status_ptkp"`
 	Jabatan         string      `bson:"jabatan" json:"jabatan"`
 	ShowInDirectory bool        `bson:"show_in_directory" json:"show_in_directory"`
-}
-
-// EmployeeMongo kept for backwards compatibility, maps to UserMongo
-type EmployeeMongo struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID         string             `bson:"user_id" json:"user_id"`
-	Name           string             `bson:"name" json:"name"`
-	Center         string             `bson:"center" json:"center"`
-	Roles          string             `bson:"roles" json:"roles"`
-	PhotoURL       string             `bson:"photo_url" json:"photo_url"`
-	BranchID       interface{}        `bson:"branch_id" json:"branch_id"`
-	Sex            string             `bson:"sex" json:"sex"`
-	PoB            string             `bson:"pob" json:"pob"`
-	DoB            string             `bson:"dob" json:"dob"`
-	Age            int                `bson:"age" json:"age"`
-	Religion       string             `bson:"religion" json:"religion"`
-	Phone          string             `bson:"phone" json:"phone"`
-	Address1       string             `bson:"address1" json:"address1"`
-	Address2       string             `bson:"address2" json:"address2"`
-	NIK            string             `bson:"nik" json:"nik"`
-	NPWP           string             `bson:"npwp" json:"npwp"`
-	EducationLevel string             `bson:"education_level" json:"education_level"`
-	Institution    string             `bson:"institution" json:"institution"`
-	Major          string             `bson:"major" json:"major"`
-	GraduationYear int                `bson:"graduation_year" json:"graduation_year"`
-	BankAccount    string             `bson:"bank_account" json:"bank_account"`
-	StatusPTKP     string             `bson:"status_ptkp" json:"status_ptkp"`
-	Jabatan        string             `bson:"jabatan" json:"jabatan"`
-	Email          string             `bson:"email" json:"email"`
+	// BPJS membership numbers; payroll applies a program's contributions only to its members
+	BPJSKesehatanNo       string `bson:"bpjs_kesehatan_no" json:"bpjs_kesehatan_no"`
+	BPJSKetenagakerjaanNo string `bson:"bpjs_ketenagakerjaan_no" json:"bpjs_ketenagakerjaan_no"`
+	// Salary transfer destination; BankAccount holds the account number
+	BankCode          string `bson:"bank_code" json:"bank_code"`
+	BankAccountHolder string `bson:"bank_account_holder" json:"bank_account_holder"`
+	// Employment start (YYYY-MM-DD), the basis of service-length entitlements like THR
+	HireDate string `bson:"hire_date" json:"hire_date"`
+	// Last working day (YYYY-MM-DD); retention policies count from it
+	TerminationDate string `bson:"termination_date" json:"termination_date"`
+	// Uploaded photo in the file service; PhotoURL is only stored for external links and is
+	// filled with a signed download URL in responses
+	PhotoFileID string `bson:"photo_file_id" json:"photo_file_id"`
+	// Account lifecycle: "active", "inactive" (deactivated, login blocked) or "deleted" (offboarded,
+	// history kept until purged). Empty means active. omitempty keeps profile updates from resetting it.
+	Status       string `bson:"status,omitempty" json:"status"`
+	StatusReason string `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	DeletedAt    string `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy    string `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	// Employment terms (dates YYYY-MM-DD); changes are recorded as employment events
+	EmploymentType    string `bson:"employment_type" json:"employment_type"` // permanent, contract (PKWT), freelance, intern
+	ProbationEnd      string `bson:"probation_end" json:"probation_end"`
+	ContractStart     string `bson:"contract_start" json:"contract_start"`
+	ContractEnd       string `bson:"contract_end" json:"contract_end"`
+	TerminationReason string `bson:"termination_reason" json:"termination_reason"`
+	// Direct manager's user ID; requests are routed to them for approval
+	ReportsTo string `bson:"reports_to" json:"reports_to"`
 }
 
 type AttendanceMongo struct {
@@ -95,6 +93,17 @@ type AttendanceMongo struct {
 	Session            string             `bson:"session" json:"session"`
 	Status             string             `bson:"status" json:"status"`
 	CreatedAt          string             `bson:"created_at" json:"created_at"`
+	// Rules an admin bypassed when this entry was recorded
+	RuleOverrides []AttendanceRuleOverride `bson:"rule_overrides,omitempty" json:"rule_overrides,omitempty"`
+	// Set for entries created by a bulk import so the batch can be rolled back
+	ImportBatchID string `bson:"import_batch_id,omitempty" json:"import_batch_id,omitempty"`
+	// Set for kiosk check-ins: the branch whose QR code was scanned
+	BranchID string `bson:"branch_id,omitempty" json:"branch_id,omitempty"`
+	Source   string `bson:"source,omitempty" json:"source,omitempty"` // kiosk
+	// Codes from the managed activity taxonomy; ActivityType/ActivityCategories hold the labels
+	ActivityTypeCode      string   `bson:"activity_type_code,omitempty" json:"activity_type_code,omitempty"`
+	ActivityCategoryCodes []string `bson:"activity_category_codes,omitempty" json:"activity_category_codes,omitempty"`
+	SchoolID              string   `bson:"school_id,omitempty" json:"school_id,omitempty"`
 }
 
 type AnnouncementMongo struct {
@@ -120,8 +129,11 @@ type WorkPermitMongo struct {
 	Session        string             `bson:"session" json:"session"`
 	LeaveType      string             `bson:"leave_type" json:"leave_type"`
 	Reason         string             `bson:"reason" json:"reason"`
-	SupportingFile string             `bson:"supporting_file" json:"supporting_file"`
+	SupportingFile string             `bson:"supporting_file,omitempty" json:"supporting_file"`
 	Status         string             `bson:"status" json:"status"`
+	// File service ID of the supporting file; SupportingFile carries its signed download URL
+	// in responses and is no longer stored
+	SupportingFileID string `bson:"supporting_file_id" json:"supporting_file_id"`
 }
 
 type LeaveQuotaMongo struct {
@@ -145,13 +157,27 @@ type PendingRequestMongo struct {
 	RejectReason   string             `bson:"reject_reason" json:"reject_reason"`
 	CreatedAt      string             `bson:"created_at" json:"created_at"`
 	RefID          string             `bson:"ref_id" json:"ref_id"`
-	SupportingFile string             `bson:"supporting_file" json:"supporting_file"`
+	SupportingFile string             `bson:"supporting_file,omitempty" json:"supporting_file"`
+	// Shares the work permit's file; SupportingFile carries its signed download URL in responses
+	SupportingFileID string `bson:"supporting_file_id" json:"supporting_file_id"`
+	// Filled in responses so the file can be previewed without fetching it
+	SupportingFileType string `bson:"-" json:"supporting_file_type,omitempty"`
+	// Manager the request is routed to; empty leaves it to admins and managers. Their acting
+	// manager may decide it too, and DecidedBy records who did.
+	ApproverID   string `bson:"approver_id,omitempty" json:"approver_id,omitempty"`
+	ApproverName string `bson:"approver_name,omitempty" json:"approver_name,omitempty"`
+	DecidedBy    string `bson:"decided_by,omitempty" json:"decided_by,omitempty"`
+	DecidedAt    string `bson:"decided_at,omitempty" json:"decided_at,omitempty"`
 }
 
 type BranchMongo struct {
 	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name   string             `bson:"name" json:"name"`
 	Region string             `bson:"region" json:"region"`
+	// Secret the kiosk QR tokens are derived from; never sent to clients
+	KioskSecret string `bson:"kiosk_secret,omitempty" json:"-"`
+	// SHA-256 of the key the branch's kiosk display signs in with; the key itself is shown once
+	KioskDeviceKeyHash string `bson:"kiosk_device_key_hash,omitempty" json:"-"`
 }
 
 type SchoolMongo struct {
@@ -207,6 +233,7 @@ func UsersCollection() *mongo.Collection {
 	return database.Collection("users")
 }
 
+// EmployeesCollection is the legacy employee store, read only by MigrateLegacyEmployees
 func EmployeesCollection() *mongo.Collection {
 	return database.Collection("employees")
 }
@@ -247,6 +274,119 @@ func AwardsCollection() *mongo.Collection {
 	return database.Collection("awards")
 }
 
+func SettingsCollection() *mongo.Collection {
+	return database.Collection("settings")
+}
+
+func OvertimeRequestsCollection() *mongo.Collection {
+	return database.Collection("overtime_requests")
+}
+
+func TimesheetsCollection() *mongo.Collection {
+	return database.Collection("timesheets")
+}
+
+func AttendanceImportsCollection() *mongo.Collection {
+	return database.Collection("attendance_imports")
+}
+
+func ActivityTypesCollection() *mongo.Collection {
+	return database.Collection("activity_types")
+}
+
+func ActivityCategoriesCollection() *mongo.Collection {
+	return database.Collection("activity_categories")
+}
+
+func SchoolSchedulesCollection() *mongo.Collection {
+	return database.Collection("school_schedules")
+}
+
+func ScheduleRemindersCollection() *mongo.Collection {
+	return database.Collection("schedule_reminders")
+}
+
+func SchoolContractsCollection() *mongo.Collection {
+	return database.Collection("school_contracts")
+}
+
+func InvoicesCollection() *mongo.Collection {
+	return database.Collection("invoices")
+}
+
+func CountersCollection() *mongo.Collection {
+	return database.Collection("counters")
+}
+
+func SalaryStructuresCollection() *mongo.Collection {
+	return database.Collection("salary_structures")
+}
+
+func PayrollAdjustmentsCollection() *mongo.Collection {
+	return database.Collection("payroll_adjustments")
+}
+
+func PayrollRunsCollection() *mongo.Collection {
+	return database.Collection("payroll_runs")
+}
+
+func PayslipsCollection() *mongo.Collection {
+	return database.Collection("payslips")
+}
+
+func PayslipAuditCollection() *mongo.Collection {
+	return database.Collection("payslip_audit")
+}
+
+func BankTransferTemplatesCollection() *mongo.Collection {
+	return database.Collection("bank_transfer_templates")
+}
+
+func FilesCollection() *mongo.Collection {
+	return database.Collection("files")
+}
+
+func ClaimsCollection() *mongo.Collection {
+	return database.Collection("claims")
+}
+
+func THRSchedulesCollection() *mongo.Collection {
+	return database.Collection("thr_schedules")
+}
+
+func TaxRulesCollection() *mongo.Collection {
+	return database.Collection("tax_rules")
+}
+
+func LocksCollection() *mongo.Collection {
+	return database.Collection("locks")
+}
+
+func JobRunsCollection() *mongo.Collection {
+	return database.Collection("job_runs")
+}
+
+func ArchivedRecordsCollection() *mongo.Collection {
+	return database.Collection("archived_records")
+}
+
+func EmploymentEventsCollection() *mongo.Collection {
+	return database.Collection("employment_events")
+}
+
+func EmploymentAlertsCollection() *mongo.Collection {
+	return database.Collection("employment_alerts")
+}
+
+func DelegationsCollection() *mongo.Collection {
+	return database.Collection("delegations")
+}
+
+// MigrationsCollection records one-time data migrations that have completed
+func MigrationsCollection() *mongo.Collection {
+	return database.Collection("migrations")
+}
+
 // --- User CRUD ---
 func GetUserByEmail(email string) (*UserMongo, error) {
 	ctx := context.Background()
@@ -258,6 +398,36 @@ func GetUserByEmail(email string) (*UserMongo, error) {
 	return &user, nil
 }
 
+// GetUsersByIDsMongo returns several users keyed by ID in one query, without passwords
+func GetUsersByIDsMongo(ids []string) (map[string]UserMongo, error) {
+	ctx := context.Background()
+	objIDs := []primitive.ObjectID{}
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	users := make(map[string]UserMongo, len(objIDs))
+	if len(objIDs) == 0 {
+		return users, nil
+	}
+	cursor, err := UsersCollection().Find(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []UserMongo
+	if err = cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	for _, u := range list {
+		u.Password = ""
+		users[u.ID.Hex()] = u
+	}
+	return users, nil
+}
+
 func GetUserByIDMongo(id string) (*UserMongo, error) {
 	ctx := context.Background()
 	objID, err := primitive.ObjectIDFromHex(id)
@@ -272,9 +442,26 @@ func GetUserByIDMongo(id string) (*UserMongo, error) {
 	return &user, nil
 }
 
+// GetAllUsersMongo returns every user that has not been deleted
 func GetAllUsersMongo() ([]UserMongo, error) {
+	return GetUsersByStatusMongo("")
+}
+
+// GetUsersByStatusMongo filters users by lifecycle status; "" means all but deleted, "all"
+// includes deleted users
+func GetUsersByStatusMongo(status string) ([]UserMongo, error) {
 	ctx := context.Background()
-	cursor, err := UsersCollection().Find(ctx, bson.M{})
+	filter := bson.M{}
+	switch status {
+	case "":
+		filter["status"] = bson.M{"$ne": "deleted"}
+	case "all":
+	case "active":
+		filter["status"] = bson.M{"$nin": bson.A{"inactive", "deleted"}}
+	default:
+		filter["status"] = status
+	}
+	cursor, err := UsersCollection().Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -322,6 +509,19 @@ func DeleteUserMongo(id string) error {
 }
 
 // --- Attendance CRUD ---
+func GetAttendanceByIDMongo(id string) (*AttendanceMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var record AttendanceMongo
+	if err := AttendanceCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
 func GetAttendanceByUser(userID string) ([]AttendanceMongo, error) {
 	ctx := context.Background()
 	cursor, err := AttendanceCollection().Find(ctx, bson.M{"user_id": userID})
@@ -362,105 +562,32 @@ func AddAttendanceMongo(att AttendanceMongo) (*AttendanceMongo, error) {
 	return &att, nil
 }
 
-func DeleteAttendanceMongo(id string) error {
-	ctx := context.Background()
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-	_, err = AttendanceCollection().DeleteOne(ctx, bson.M{"_id": objID})
-	return err
-}
-
-func DeleteAttendanceByUser(userID string) error {
-	ctx := context.Background()
-	_, err := AttendanceCollection().DeleteMany(ctx, bson.M{"user_id": userID})
-	return err
-}
-
-// --- Employee CRUD ---
-// GetAllEmployeesMongo now returns users from users collection since User == Employee
-func GetAllEmployeesMongo() ([]EmployeeMongo, error) {
+// SetAttendanceEndingTimeMongo records a clock-out on an entry that has none yet; false means
+// it was already clocked out
+func SetAttendanceEndingTimeMongo(id primitive.ObjectID, endingTime string) (bool, error) {
 	ctx := context.Background()
-	// Filter: Show in directory is true OR doesn't exist (default true)
-	// Using bson.A for the array is safer with mongo-driver
-	filter := bson.M{"$or": bson.A{
-		bson.M{"show_in_directory": true},
-		bson.M{"show_in_directory": bson.M{"$exists": false}},
-	}}
-	fmt.Println("GetAllEmployeesMongo: Fetching with filter:", filter)
-	cursor, err := UsersCollection().Find(ctx, filter)
+	result, err := AttendanceCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "ending_time": bson.M{"$in": bson.A{"", nil}}},
+		bson.M{"$set": bson.M{"ending_time": endingTime}})
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer cursor.Close(ctx)
-
-	var users []UserMongo
-	if err = cursor.All(ctx, &users); err != nil {
-		return nil, err
-	}
-
-	// Convert to EmployeeMongo format for backwards compatibility
-	var employees []EmployeeMongo
-	for _, u := range users {
-		emp := EmployeeMongo{
-			ID:             u.ID,
-			UserID:         u.ID.Hex(),
-			Name:           u.Name,
-			Center:         u.Center,
-			Roles:          u.Roles,
-			PhotoURL:       u.PhotoURL,
-			BranchID:       u.BranchID,
-			Sex:            u.Sex,
-			PoB:            u.PoB,
-			DoB:            u.DoB,
-			Age:            u.Age,
-			Religion:       u.Religion,
-			Phone:          u.Phone,
-			Address1:       u.Address1,
-			NIK:            u.NIK,
-			NPWP:           u.NPWP,
-			EducationLevel: u.EducationLevel,
-			Institution:    u.Institution,
-			Major:          u.Major,
-			GraduationYear: u.GraduationYear,
-			BankAccount:    u.BankAccount,
-			StatusPTKP:     u.StatusPTKP,
-			Jabatan:        u.Jabatan,
-			Email:          u.Email,
-		}
-		employees = append(employees, emp)
-	}
-	return employees, nil
-}
-
-func CreateEmployeeMongo(emp EmployeeMongo) (*EmployeeMongo, error) {
-	ctx := context.Background()
-	result, err := EmployeesCollection().InsertOne(ctx, emp)
-	if err != nil {
-		return nil, err
-	}
-	emp.ID = result.InsertedID.(primitive.ObjectID)
-	return &emp, nil
+	return result.ModifiedCount > 0, nil
 }
 
-func UpdateEmployeeMongo(id string, emp EmployeeMongo) error {
+func DeleteAttendanceMongo(id string) error {
 	ctx := context.Background()
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	_, err = EmployeesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": emp})
+	_, err = AttendanceCollection().DeleteOne(ctx, bson.M{"_id": objID})
 	return err
 }
 
-func DeleteEmployeeMongo(id string) error {
+func DeleteAttendanceByUser(userID string) error {
 	ctx := context.Background()
-	objID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		return err
-	}
-	_, err = EmployeesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	_, err := AttendanceCollection().DeleteMany(ctx, bson.M{"user_id": userID})
 	return err
 }
 
@@ -589,14 +716,19 @@ func DeleteWorkPermitsByUser(userID string) error {
 }
 
 // --- Leave Quota ---
+// GetLeaveQuotaMongo returns the year's quota. Total always follows the user's current employment
+// terms (see LeaveEntitlement), so a hire, termination or type change applies to stored quotas too.
 func GetLeaveQuotaMongo(userID string, year int) (*LeaveQuotaMongo, error) {
 	ctx := context.Background()
 	var quota LeaveQuotaMongo
 	err := LeaveQuotasCollection().FindOne(ctx, bson.M{"user_id": userID, "year": year}).Decode(&quota)
 	if err != nil {
 		// Return default if not found
-		return &LeaveQuotaMongo{UserID: userID, Year: year, Total: 12, Used: 0, Remaining: 12}, nil
+		quota = LeaveQuotaMongo{UserID: userID, Year: year}
 	}
+	user, _ := GetUserByIDMongo(userID)
+	quota.Total = LeaveEntitlement(user, year)
+	quota.Remaining = quota.Total - quota.Used
 	return &quota, nil
 }
 
@@ -641,8 +773,16 @@ func GetPendingRequestsMongo() ([]PendingRequestMongo, error) {
 	return requests, nil
 }
 
+// AddPendingRequestMongo stores a request, routed to the requester's manager unless the caller
+// already picked an approver
 func AddPendingRequestMongo(req PendingRequestMongo) (*PendingRequestMongo, error) {
 	ctx := context.Background()
+	if req.ApproverID == "" {
+		if manager := ApproverForMongo(req.UserID); manager != nil {
+			req.ApproverID = manager.ID.Hex()
+			req.ApproverName = manager.Name
+		}
+	}
 	result, err := PendingRequestsCollection().InsertOne(ctx, req)
 	if err != nil {
 		return nil, err
@@ -691,13 +831,39 @@ func GetPendingRequestByID(id string) (*PendingRequestMongo, error) {
 	return &req, nil
 }
 
-func UpdateRequestStatusMongo(id string, status string, rejectReason string) error {
+// UpdateRequestStatusMongo decides a request that is still pending; false means someone else
+// decided it first
+func UpdateRequestStatusMongo(id string, status string, rejectReason string, decidedBy string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := PendingRequestsCollection().UpdateOne(ctx, bson.M{"_id": objID, "status": "pending"}, bson.M{"$set": bson.M{
+		"status":        status,
+		"reject_reason": rejectReason,
+		"decided_by":    decidedBy,
+		"decided_at":    time.Now().Format("2006-01-02 15:04:05"),
+	}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// ReopenRequestMongo puts a decided request back to pending, e.g. when applying the decision failed
+func ReopenRequestMongo(id string) error {
 	ctx := context.Background()
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
-	_, err = PendingRequestsCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"status": status, "reject_reason": rejectReason}})
+	_, err = PendingRequestsCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"status":        "pending",
+		"reject_reason": "",
+		"decided_by":    "",
+		"decided_at":    "",
+	}})
 	return err
 }
 
@@ -750,6 +916,39 @@ func UpdateBranchMongo(id string, branch BranchMongo) error {
 	return err
 }
 
+func GetBranchByIDMongo(id string) (*BranchMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var branch BranchMongo
+	if err := BranchesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&branch); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+func SetBranchKioskSecretMongo(id, secret string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = BranchesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"kiosk_secret": secret}})
+	return err
+}
+
+func SetBranchKioskDeviceKeyMongo(id, keyHash string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = BranchesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"kiosk_device_key_hash": keyHash}})
+	return err
+}
+
 // --- Calendar Events CRUD ---
 func GetCalendarEventsMongo() ([]CalendarEventMongo, error) {
 	ctx := context.Background()
@@ -790,8 +989,8 @@ func DeleteCalendarEventMongo(id string) error {
 func GetStatsMongo() (map[string]interface{}, error) {
 	ctx := context.Background()
 
-	usersCount, _ := UsersCollection().CountDocuments(ctx, bson.M{})
-	employeesCount, _ := EmployeesCollection().CountDocuments(ctx, bson.M{})
+	usersCount, _ := UsersCollection().CountDocuments(ctx, bson.M{"status": bson.M{"$ne": "deleted"}})
+	employeesCount, _ := UsersCollection().CountDocuments(ctx, bson.M{"status": bson.M{"$nin": bson.A{"inactive", "deleted"}}})
 	attendanceCount, _ := AttendanceCollection().CountDocuments(ctx, bson.M{})
 	workPermitsCount, _ := WorkPermitsCollection().CountDocuments(ctx, bson.M{})
 	pendingCount, _ := PendingRequestsCollection().CountDocuments(ctx, bson.M{"status": "pending"})
@@ -874,6 +1073,19 @@ func CreateSchoolMongo(school SchoolMongo) (*SchoolMongo, error) {
 	return &school, nil
 }
 
+func GetSchoolByIDMongo(id string) (*SchoolMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var school SchoolMongo
+	if err := SchoolsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&school); err != nil {
+		return nil, err
+	}
+	return &school, nil
+}
+
 func UpdateSchoolMongo(id string, school SchoolMongo) error {
 	ctx := context.Background()
 	objID, err := primitive.ObjectIDFromHex(id)
@@ -903,26 +1115,91 @@ func ClearUserBranchID(branchID string) error {
 	return err
 }
 
-// CleanupExpiredLeaveAttendance - DISABLED: Leave records should be kept for history
-// This was incorrectly deleting leave records after they passed, causing calendar dots to disappear
-// If cleanup is needed, only remove records older than 2 years, not recent history
-func CleanupExpiredLeaveAttendance() (int64, error) {
-	// Disabled - do not delete leave history
-	return 0, nil
-}
-
-// CleanupOldSupportFiles clears supporting_file from work permits older than 30 days
-func CleanupOldSupportFiles() (int64, error) {
+// CleanupOldSupportFiles removes supporting files of work permits dated before the given day
+// (YYYY-MM-DD), both the stored files and inline data URLs that have not been migrated yet.
+// Uploads are deduplicated, so a stored file is only deleted once nothing else refers to it.
+func CleanupOldSupportFiles(before string) (int64, error) {
 	ctx := context.Background()
-	thirtyDaysAgo := time.Now().AddDate(0, -1, 0).Format("2006-01-02")
-	result, err := WorkPermitsCollection().UpdateMany(ctx,
+
+	filter := bson.M{
+		"supporting_file_id": bson.M{"$nin": bson.A{"", nil}},
+		"date":               bson.M{"$lt": before},
+	}
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "supporting_file_id": 1})
+	cursor, err := WorkPermitsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	var permits []WorkPermitMongo
+	if err = cursor.All(ctx, &permits); err != nil {
+		return 0, err
+	}
+
+	permitIDs := make([]primitive.ObjectID, len(permits))
+	refIDs := make([]string, len(permits))
+	fileIDs := make(map[string]bool)
+	for i, wp := range permits {
+		permitIDs[i] = wp.ID
+		refIDs[i] = wp.ID.Hex()
+		fileIDs[wp.SupportingFileID] = true
+	}
+	var stored int64
+	if len(permits) > 0 {
+		result, err := WorkPermitsCollection().UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": permitIDs}},
+			bson.M{"$set": bson.M{"supporting_file_id": ""}})
+		if err != nil {
+			return 0, err
+		}
+		stored = result.ModifiedCount
+		if _, err := PendingRequestsCollection().UpdateMany(ctx,
+			bson.M{"type": "work_permit", "ref_id": bson.M{"$in": refIDs}},
+			bson.M{"$set": bson.M{"supporting_file_id": ""}}); err != nil {
+			return 0, err
+		}
+	}
+	for id := range fileIDs {
+		used, err := fileReferenced(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if used {
+			continue
+		}
+		if err := DeleteFileMongo(id); err != nil {
+			log.Printf("Delete supporting file %s: %v", id, err)
+		}
+	}
+
+	inline, err := WorkPermitsCollection().UpdateMany(ctx,
 		bson.M{
-			"supporting_file": bson.M{"$ne": ""},
-			"date":            bson.M{"$lt": thirtyDaysAgo},
+			"supporting_file": bson.M{"$nin": bson.A{"", nil}},
+			"date":            bson.M{"$lt": before},
 		},
-		bson.M{"$set": bson.M{"supporting_file": ""}})
+		bson.M{"$unset": bson.M{"supporting_file": ""}})
 	if err != nil {
 		return 0, err
 	}
-	return result.ModifiedCount, nil
+	return stored + inline.ModifiedCount, nil
+}
+
+// fileReferenced reports whether any user photo, work permit, request or claim receipt still
+// points at a stored file
+func fileReferenced(ctx context.Context, fileID string) (bool, error) {
+	refs := []struct {
+		coll  *mongo.Collection
+		field string
+	}{
+		{UsersCollection(), "photo_file_id"},
+		{WorkPermitsCollection(), "supporting_file_id"},
+		{PendingRequestsCollection(), "supporting_file_id"},
+		{ClaimsCollection(), "receipts.file_id"},
+	}
+	for _, ref := range refs {
+		n, err := ref.coll.CountDocuments(ctx, bson.M{ref.field: fileID}, options.Count().SetLimit(1))
+		if err != nil || n > 0 {
+			return n > 0, err
+		}
+	}
+	return false, nil
 }
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrReportingCycle    = errors.New("reports_to would create a reporting cycle")
+	ErrInvalidManager    = errors.New("manager not found or not active")
+	ErrDelegationOverlap = errors.New("an active delegation already covers part of this period")
+)
+
+// DelegationMongo makes DelegateID the acting manager of ManagerID from StartDate to EndDate
+// (inclusive, YYYY-MM-DD): they may decide requests routed to the manager. Delegations do not
+// chain, so an acting manager's own delegate does not inherit them.
+type DelegationMongo struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ManagerID    string             `bson:"manager_id" json:"manager_id"`
+	ManagerName  string             `bson:"manager_name" json:"manager_name"`
+	DelegateID   string             `bson:"delegate_id" json:"delegate_id"`
+	DelegateName string             `bson:"delegate_name" json:"delegate_name"`
+	StartDate    string             `bson:"start_date" json:"start_date"`
+	EndDate      string             `bson:"end_date" json:"end_date"`
+	Reason       string             `bson:"reason" json:"reason"`
+	Status       string             `bson:"status" json:"status"` // active, cancelled
+	CreatedBy    string             `bson:"created_by" json:"created_by"`
+	CreatedAt    string             `bson:"created_at" json:"created_at"`
+	CancelledBy  string             `bson:"cancelled_by,omitempty" json:"cancelled_by,omitempty"`
+	CancelledAt  string             `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+}
+
+// --- Reporting Lines ---
+
+// activeUser loads a user that is not deactivated or deleted
+func activeUser(id string) (*UserMongo, error) {
+	user, err := GetUserByIDMongo(id)
+	if err != nil || !UserIsActive(user.Status) {
+		return nil, ErrInvalidManager
+	}
+	return user, nil
+}
+
+// ValidateReportsToMongo checks that userID may report to managerID: the manager must be an
+// active user and must not already report, directly or not, to userID. An empty managerID is valid.
+func ValidateReportsToMongo(userID, managerID string) error {
+	if managerID == "" {
+		return nil
+	}
+	if managerID == userID {
+		return ErrReportingCycle
+	}
+	manager, err := activeUser(managerID)
+	if err != nil {
+		return err
+	}
+	// Walk up from the manager; visited stops on cycles already in the data that do not involve userID
+	visited := map[string]bool{managerID: true}
+	for next := manager.ReportsTo; next != "" && !visited[next]; {
+		if next == userID {
+			return ErrReportingCycle
+		}
+		visited[next] = true
+		up, err := GetUserByIDMongo(next)
+		if err != nil {
+			break
+		}
+		next = up.ReportsTo
+	}
+	return nil
+}
+
+// ApproverForMongo returns the manager a user's requests go to, or nil when the user has no
+// active manager and requests fall back to admins
+func ApproverForMongo(userID string) *UserMongo {
+	user, err := GetUserByIDMongo(userID)
+	if err != nil || user.ReportsTo == "" {
+		return nil
+	}
+	manager, err := activeUser(user.ReportsTo)
+	if err != nil {
+		return nil
+	}
+	return manager
+}
+
+// ReroutePendingRequestsMongo sends a user's pending requests to their current manager, e.g.
+// after reports_to changed
+func ReroutePendingRequestsMongo(userID string) error {
+	ctx := context.Background()
+	set := bson.M{"approver_id": "", "approver_name": ""}
+	if manager := ApproverForMongo(userID); manager != nil {
+		set = bson.M{"approver_id": manager.ID.Hex(), "approver_name": manager.Name}
+	}
+	_, err := PendingRequestsCollection().UpdateMany(ctx, bson.M{"user_id": userID, "status": "pending"}, bson.M{"$set": set})
+	return err
+}
+
+// GetPendingRequestsForApproversMongo lists pending requests routed to any of approverIDs, and
+// with includeUnrouted also those without an approver
+func GetPendingRequestsForApproversMongo(approverIDs []string, includeUnrouted bool) ([]PendingRequestMongo, error) {
+	ctx := context.Background()
+	routes := bson.A{bson.M{"approver_id": bson.M{"$in": approverIDs}}}
+	if includeUnrouted {
+		routes = append(routes, bson.M{"approver_id": bson.M{"$in": bson.A{"", nil}}})
+	}
+	cursor, err := PendingRequestsCollection().Find(ctx, bson.M{"status": "pending", "$or": routes})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []PendingRequestMongo{}
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// --- Delegations ---
+
+// AddDelegationMongo stores a delegation unless another active one for the manager overlaps it
+func AddDelegationMongo(d DelegationMongo) (*DelegationMongo, error) {
+	ctx := context.Background()
+	// YYYY-MM-DD compares correctly as a string
+	overlap, err := DelegationsCollection().CountDocuments(ctx, bson.M{
+		"manager_id": d.ManagerID,
+		"status":     "active",
+		"start_date": bson.M{"$lte": d.EndDate},
+		"end_date":   bson.M{"$gte": d.StartDate},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if overlap > 0 {
+		return nil, ErrDelegationOverlap
+	}
+	d.Status = "active"
+	result, err := DelegationsCollection().InsertOne(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	d.ID = result.InsertedID.(primitive.ObjectID)
+	return &d, nil
+}
+
+// GetDelegationsMongo lists delegations a user gave or received, newest first; an empty userID lists all
+func GetDelegationsMongo(userID string) ([]DelegationMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if userID != "" {
+		filter["$or"] = bson.A{bson.M{"manager_id": userID}, bson.M{"delegate_id": userID}}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start_date", Value: -1}})
+	cursor, err := DelegationsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	delegations := []DelegationMongo{}
+	if err = cursor.All(ctx, &delegations); err != nil {
+		return nil, err
+	}
+	return delegations, nil
+}
+
+func GetDelegationByIDMongo(id string) (*DelegationMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var d DelegationMongo
+	if err := DelegationsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CancelDelegationMongo ends an active delegation; false means it was not active
+func CancelDelegationMongo(id primitive.ObjectID, by, now string) (bool, error) {
+	ctx := context.Background()
+	result, err := DelegationsCollection().UpdateOne(ctx,
+		bson.M{"_id": id, "status": "active"},
+		bson.M{"$set": bson.M{"status": "cancelled", "cancelled_by": by, "cancelled_at": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// ActingForMongo returns the managers whose requests delegateID may decide on date
+func ActingForMongo(delegateID, date string) ([]string, error) {
+	ctx := context.Background()
+	cursor, err := DelegationsCollection().Find(ctx, bson.M{
+		"delegate_id": delegateID,
+		"status":      "active",
+		"start_date":  bson.M{"$lte": date},
+		"end_date":    bson.M{"$gte": date},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var delegations []DelegationMongo
+	if err = cursor.All(ctx, &delegations); err != nil {
+		return nil, err
+	}
+	managers := []string{}
+	for _, d := range delegations {
+		managers = append(managers, d.ManagerID)
+	}
+	return managers, nil
+}
+
+// EnsureOrgIndexes backs the delegation lookups and the routed request queue
+func EnsureOrgIndexes() error {
+	ctx := context.Background()
+	_, err := DelegationsCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "delegate_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "manager_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = PendingRequestsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "approver_id", Value: 1}},
+	})
+	return err
+}
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OvertimeTier is a block of overtime hours paid at the same multiplier.
+// Hours of 0 means the tier covers all remaining hours; minutes beyond a last tier with
+// finite hours are paid at its multiplier.
+type OvertimeTier struct {
+	Hours      float64 `bson:"hours" json:"hours"`
+	Multiplier float64 `bson:"multiplier" json:"multiplier"`
+}
+
+// OvertimeSettingsMongo configures how overtime is derived from attendance and weighted for pay
+type OvertimeSettingsMongo struct {
+	StandardDailyMinutes int            `bson:"standard_daily_minutes" json:"standard_daily_minutes"` // weekday minimum; a longer roster raises it
+	MaxDailyMinutes      int            `bson:"max_daily_minutes" json:"max_daily_minutes"`
+	HourlyDivisor        int            `bson:"hourly_divisor" json:"hourly_divisor"` // monthly wage / divisor = hourly wage
+	WeekdayTiers         []OvertimeTier `bson:"weekday_tiers" json:"weekday_tiers"`
+	WeekendTiers         []OvertimeTier `bson:"weekend_tiers" json:"weekend_tiers"`
+	HolidayTiers         []OvertimeTier `bson:"holiday_tiers" json:"holiday_tiers"`
+	UpdatedAt            string         `bson:"updated_at" json:"updated_at"`
+	UpdatedBy            string         `bson:"updated_by" json:"updated_by"`
+}
+
+type OvertimeRequestMongo struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	Date          string             `bson:"date" json:"date"`
+	StartTime     string             `bson:"start_time" json:"start_time"`
+	EndTime       string             `bson:"end_time" json:"end_time"`
+	Minutes       int                `bson:"minutes" json:"minutes"`
+	DayType       string             `bson:"day_type" json:"day_type"` // weekday, weekend, holiday
+	WeightedHours float64            `bson:"weighted_hours" json:"weighted_hours"`
+	Reason        string             `bson:"reason" json:"reason"`
+	Status        string             `bson:"status" json:"status"`
+	CreatedAt     string             `bson:"created_at" json:"created_at"`
+}
+
+// DefaultOvertimeSettings follows PP 35/2021 for a 5-day work week:
+// weekdays pay 1.5x for the first hour and 2x after; rest days and public holidays
+// pay 2x for the first 8 hours, 3x for the 9th hour and 4x for the 10th-11th hours.
+func DefaultOvertimeSettings() OvertimeSettingsMongo {
+	return OvertimeSettingsMongo{
+		StandardDailyMinutes: 8 * 60,
+		MaxDailyMinutes:      4 * 60,
+		HourlyDivisor:        173,
+		WeekdayTiers:         []OvertimeTier{{Hours: 1, Multiplier: 1.5}, {Hours: 0, Multiplier: 2}},
+		WeekendTiers:         []OvertimeTier{{Hours: 8, Multiplier: 2}, {Hours: 1, Multiplier: 3}, {Hours: 2, Multiplier: 4}},
+		HolidayTiers:         []OvertimeTier{{Hours: 8, Multiplier: 2}, {Hours: 1, Multiplier: 3}, {Hours: 2, Multiplier: 4}},
+	}
+}
+
+func GetOvertimeSettingsMongo() (*OvertimeSettingsMongo, error) {
+	ctx := context.Background()
+	var settings OvertimeSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "overtime"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultOvertimeSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdateOvertimeSettingsMongo(settings OvertimeSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "overtime"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// --- Overtime Requests CRUD ---
+func AddOvertimeRequestMongo(ot OvertimeRequestMongo) (*OvertimeRequestMongo, error) {
+	ctx := context.Background()
+	result, err := OvertimeRequestsCollection().InsertOne(ctx, ot)
+	if err != nil {
+		return nil, err
+	}
+	ot.ID = result.InsertedID.(primitive.ObjectID)
+	return &ot, nil
+}
+
+func GetOvertimeRequestsByUser(userID string) ([]OvertimeRequestMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: -1}})
+	cursor, err := OvertimeRequestsCollection().Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []OvertimeRequestMongo
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func GetOvertimeRequestByIDMongo(id string) (*OvertimeRequestMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var ot OvertimeRequestMongo
+	err = OvertimeRequestsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&ot)
+	if err != nil {
+		return nil, err
+	}
+	return &ot, nil
+}
+
+// GetOvertimeRequestsByPeriod returns overtime requests with the given status between two dates (inclusive).
+// An empty userID matches all users.
+func GetOvertimeRequestsByPeriod(userID, status, from, to string) ([]OvertimeRequestMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"status": status,
+		"date":   bson.M{"$gte": from, "$lte": to},
+	}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	cursor, err := OvertimeRequestsCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []OvertimeRequestMongo
+	if err = cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetRequestedOvertimeMinutesMongo totals the minutes of a user's pending and approved overtime
+// requests for one date
+func GetRequestedOvertimeMinutesMongo(userID, date string) (int, error) {
+	ctx := context.Background()
+	cursor, err := OvertimeRequestsCollection().Find(ctx, bson.M{
+		"user_id": userID,
+		"date":    date,
+		"status":  bson.M{"$in": bson.A{"pending", "approved"}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []OvertimeRequestMongo
+	if err = cursor.All(ctx, &requests); err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, r := range requests {
+		total += r.Minutes
+	}
+	return total, nil
+}
+
+// UpdateOvertimeRequestStatus decides a pending overtime request of the given user; it reports
+// false when no such request is pending
+func UpdateOvertimeRequestStatus(id, userID, status string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := OvertimeRequestsCollection().UpdateOne(ctx,
+		bson.M{"_id": objID, "user_id": userID, "status": "pending"},
+		bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func DeleteOvertimeRequestMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = OvertimeRequestsCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// --- Period helpers ---
+
+// GetAttendanceByUserAndPeriod returns the user's attendance between two dates (inclusive)
+func GetAttendanceByUserAndPeriod(userID, from, to string) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{
+		"user_id": userID,
+		"date":    bson.M{"$gte": from, "$lte": to},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AttendanceMongo
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetHolidayDatesMongo maps calendar holiday dates to their titles between two dates (inclusive)
+func GetHolidayDatesMongo(from, to string) (map[string]string, error) {
+	ctx := context.Background()
+	cursor, err := CalendarEventsCollection().Find(ctx, bson.M{
+		"type": "holiday",
+		"date": bson.M{"$gte": from, "$lte": to},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []CalendarEventMongo
+	if err = cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	holidays := make(map[string]string)
+	for _, e := range events {
+		holidays[e.Date] = e.Title
+	}
+	return holidays, nil
+}
@@ -0,0 +1,571 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SalaryComponent is a fixed monthly earning or deduction. Amounts are in rupiah.
+type SalaryComponent struct {
+	Code    string `bson:"code" json:"code"`
+	Name    string `bson:"name" json:"name"`
+	Amount  int64  `bson:"amount" json:"amount"`
+	Taxable bool   `bson:"taxable" json:"taxable"`
+}
+
+// SalaryStructureMongo is a user's compensation from EffectiveFrom until the next structure takes effect
+type SalaryStructureMongo struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	EffectiveFrom string             `bson:"effective_from" json:"effective_from"` // YYYY-MM-DD
+	BasePay       int64              `bson:"base_pay" json:"base_pay"`
+	Allowances    []SalaryComponent  `bson:"allowances" json:"allowances"`
+	Deductions    []SalaryComponent  `bson:"deductions" json:"deductions"`
+	// Paid per delivered School Class session
+	SessionRate int64  `bson:"session_rate" json:"session_rate"`
+	Notes       string `bson:"notes" json:"notes"`
+	CreatedBy   string `bson:"created_by" json:"created_by"`
+	CreatedAt   string `bson:"created_at" json:"created_at"`
+}
+
+// PayrollAdjustmentMongo is a one-off earning or deduction for a period, e.g. a bonus or loan installment
+type PayrollAdjustmentMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Period    string             `bson:"period" json:"period"` // YYYY-MM
+	Type      string             `bson:"type" json:"type"`     // earning, deduction
+	Code      string             `bson:"code" json:"code"`
+	Name      string             `bson:"name" json:"name"`
+	Amount    int64              `bson:"amount" json:"amount"`
+	Taxable   bool               `bson:"taxable" json:"taxable"`
+	Note      string             `bson:"note" json:"note"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt string             `bson:"created_at" json:"created_at"`
+}
+
+// PayrollSettingsMongo configures which leave is unpaid and how payslips are issued
+type PayrollSettingsMongo struct {
+	UnpaidLeaveTypes []string `bson:"unpaid_leave_types" json:"unpaid_leave_types"`
+	// Password-protect payslip PDFs with the employee's date of birth (DDMMYYYY); payslips of
+	// employees without a valid date of birth are not served while this is on
+	ProtectPayslips bool   `bson:"protect_payslips" json:"protect_payslips"`
+	UpdatedAt       string `bson:"updated_at" json:"updated_at"`
+	UpdatedBy       string `bson:"updated_by" json:"updated_by"`
+}
+
+// PayslipLineSource points a payslip line back to the records it was computed from
+type PayslipLineSource struct {
+	Kind   string   `bson:"kind" json:"kind"` // salary_structure, attendance, overtime_request, work_permit, adjustment, bpjs, tax
+	RefIDs []string `bson:"ref_ids" json:"ref_ids"`
+	Note   string   `bson:"note,omitempty" json:"note,omitempty"`
+}
+
+// PayslipLine is one earning, deduction or employer contribution. Employer lines do not change
+// net pay. Taxable earnings and employer lines count toward PPh 21 gross; taxable deductions
+// (e.g. employee pension contributions) reduce annual net income in the December reconciliation,
+// except pay cuts such as unpaid leave, which reduce the taxable gross itself.
+type PayslipLine struct {
+	Code     string            `bson:"code" json:"code"`
+	Name     string            `bson:"name" json:"name"`
+	Type     string            `bson:"type" json:"type"` // earning, deduction, employer
+	Quantity float64           `bson:"quantity,omitempty" json:"quantity,omitempty"`
+	Rate     int64             `bson:"rate,omitempty" json:"rate,omitempty"`
+	Amount   int64             `bson:"amount" json:"amount"`
+	Taxable  bool              `bson:"taxable" json:"taxable"`
+	PayCut   bool              `bson:"pay_cut,omitempty" json:"pay_cut,omitempty"`
+	Source   PayslipLineSource `bson:"source" json:"source"`
+}
+
+// PayslipMongo is one employee's result in a payroll run
+type PayslipMongo struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RunID           string             `bson:"run_id" json:"run_id"`
+	Period          string             `bson:"period" json:"period"`
+	UserID          string             `bson:"user_id" json:"user_id"`
+	UserName        string             `bson:"user_name" json:"user_name"`
+	Jabatan         string             `bson:"jabatan" json:"jabatan"`
+	BankAccount     string             `bson:"bank_account" json:"bank_account"`
+	NPWP            string             `bson:"npwp" json:"npwp"`
+	StatusPTKP      string             `bson:"status_ptkp" json:"status_ptkp"`
+	Lines           []PayslipLine      `bson:"lines" json:"lines"`
+	Tax             *PayslipTax        `bson:"tax,omitempty" json:"tax,omitempty"`
+	BPJS            []PayslipBPJS      `bson:"bpjs,omitempty" json:"bpjs,omitempty"`
+	Gross           int64              `bson:"gross" json:"gross"`
+	TotalDeductions int64              `bson:"total_deductions" json:"total_deductions"`
+	Net             int64              `bson:"net" json:"net"`
+	Status          string             `bson:"status" json:"status"` // mirrors the run
+	CreatedAt       string             `bson:"created_at" json:"created_at"`
+	// Employer BPJS shares; a cost to the company, not part of gross or net pay
+	EmployerContributions int64 `bson:"employer_contributions" json:"employer_contributions"`
+	// Set when the run is finalized; Version goes up each time an admin re-issues the payslip
+	Version       int    `bson:"version" json:"version"`
+	IssuedAt      string `bson:"issued_at,omitempty" json:"issued_at,omitempty"`
+	ReissuedBy    string `bson:"reissued_by,omitempty" json:"reissued_by,omitempty"`
+	ReissuedAt    string `bson:"reissued_at,omitempty" json:"reissued_at,omitempty"`
+	ReissueReason string `bson:"reissue_reason,omitempty" json:"reissue_reason,omitempty"`
+}
+
+// PayslipBPJS records one BPJS program's contribution for the monthly contribution report
+type PayslipBPJS struct {
+	Program        string `bson:"program" json:"program"`
+	Agency         string `bson:"agency" json:"agency"`
+	MemberNo       string `bson:"member_no" json:"member_no"`
+	Wage           int64  `bson:"wage" json:"wage"`
+	EmployeeAmount int64  `bson:"employee_amount" json:"employee_amount"`
+	EmployerAmount int64  `bson:"employer_amount" json:"employer_amount"`
+}
+
+// PayslipTax records how the PPh 21 line was computed
+type PayslipTax struct {
+	RuleYear     int     `bson:"rule_year" json:"rule_year"`
+	Method       string  `bson:"method" json:"method"` // ter, annual
+	StatusPTKP   string  `bson:"status_ptkp" json:"status_ptkp"`
+	Category     string  `bson:"category,omitempty" json:"category,omitempty"`
+	TaxableGross int64   `bson:"taxable_gross" json:"taxable_gross"`
+	Deductible   int64   `bson:"deductible" json:"deductible"`
+	Rate         float64 `bson:"rate,omitempty" json:"rate,omitempty"`
+	NoNPWP       bool    `bson:"no_npwp" json:"no_npwp"`
+	// December reconciliation
+	AnnualGross    int64 `bson:"annual_gross,omitempty" json:"annual_gross,omitempty"`
+	PositionCost   int64 `bson:"position_cost,omitempty" json:"position_cost,omitempty"`
+	AnnualNet      int64 `bson:"annual_net,omitempty" json:"annual_net,omitempty"`
+	PTKP           int64 `bson:"ptkp,omitempty" json:"ptkp,omitempty"`
+	TaxableIncome  int64 `bson:"taxable_income,omitempty" json:"taxable_income,omitempty"`
+	AnnualTax      int64 `bson:"annual_tax,omitempty" json:"annual_tax,omitempty"`
+	WithheldBefore int64 `bson:"withheld_before,omitempty" json:"withheld_before,omitempty"`
+	// Earlier months of the year without a finalized payslip, left out of the reconciliation
+	MissingPeriods []string `bson:"missing_periods,omitempty" json:"missing_periods,omitempty"`
+	// Withheld this month; negative when December refunds over-withholding
+	Amount int64 `bson:"amount" json:"amount"`
+}
+
+type PayrollTotals struct {
+	Employees             int   `bson:"employees" json:"employees"`
+	Gross                 int64 `bson:"gross" json:"gross"`
+	Deductions            int64 `bson:"deductions" json:"deductions"`
+	Net                   int64 `bson:"net" json:"net"`
+	EmployerContributions int64 `bson:"employer_contributions" json:"employer_contributions"`
+}
+
+// PayrollRunMongo moves draft -> reviewed -> finalized. Finalized runs are locked.
+type PayrollRunMongo struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Period      string             `bson:"period" json:"period"` // YYYY-MM
+	Status      string             `bson:"status" json:"status"`
+	Totals      PayrollTotals      `bson:"totals" json:"totals"`
+	Notes       string             `bson:"notes" json:"notes"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   string             `bson:"created_at" json:"created_at"`
+	ComputedAt  string             `bson:"computed_at" json:"computed_at"`
+	ReviewedBy  string             `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt  string             `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	FinalizedBy string             `bson:"finalized_by,omitempty" json:"finalized_by,omitempty"`
+	FinalizedAt string             `bson:"finalized_at,omitempty" json:"finalized_at,omitempty"`
+	ComputingAt string             `bson:"computing_at,omitempty" json:"-"` // set while payslips are being replaced
+}
+
+// --- Payroll Settings ---
+
+func DefaultPayrollSettings() PayrollSettingsMongo {
+	return PayrollSettingsMongo{UnpaidLeaveTypes: []string{"Other"}, ProtectPayslips: true}
+}
+
+func GetPayrollSettingsMongo() (*PayrollSettingsMongo, error) {
+	ctx := context.Background()
+	var settings PayrollSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "payroll"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultPayrollSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdatePayrollSettingsMongo(settings PayrollSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "payroll"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// --- Salary Structures ---
+
+func GetSalaryStructuresMongo(userID string) ([]SalaryStructureMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "user_id", Value: 1}, {Key: "effective_from", Value: -1}})
+	cursor, err := SalaryStructuresCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	structures := []SalaryStructureMongo{}
+	if err = cursor.All(ctx, &structures); err != nil {
+		return nil, err
+	}
+	return structures, nil
+}
+
+// GetEffectiveSalaryStructuresMongo returns, per user, the latest structure effective on or before date
+func GetEffectiveSalaryStructuresMongo(date string) (map[string]SalaryStructureMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "effective_from", Value: -1}})
+	cursor, err := SalaryStructuresCollection().Find(ctx, bson.M{"effective_from": bson.M{"$lte": date}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var structures []SalaryStructureMongo
+	if err = cursor.All(ctx, &structures); err != nil {
+		return nil, err
+	}
+	effective := make(map[string]SalaryStructureMongo)
+	for _, s := range structures {
+		if _, ok := effective[s.UserID]; !ok {
+			effective[s.UserID] = s
+		}
+	}
+	return effective, nil
+}
+
+func CreateSalaryStructureMongo(structure SalaryStructureMongo) (*SalaryStructureMongo, error) {
+	ctx := context.Background()
+	result, err := SalaryStructuresCollection().InsertOne(ctx, structure)
+	if err != nil {
+		return nil, err
+	}
+	structure.ID = result.InsertedID.(primitive.ObjectID)
+	return &structure, nil
+}
+
+func DeleteSalaryStructureMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = SalaryStructuresCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// --- Payroll Adjustments ---
+
+func GetPayrollAdjustmentsMongo(period, userID string) ([]PayrollAdjustmentMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if period != "" {
+		filter["period"] = period
+	}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	cursor, err := PayrollAdjustmentsCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	adjustments := []PayrollAdjustmentMongo{}
+	if err = cursor.All(ctx, &adjustments); err != nil {
+		return nil, err
+	}
+	return adjustments, nil
+}
+
+func GetPayrollAdjustmentByIDMongo(id string) (*PayrollAdjustmentMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var adj PayrollAdjustmentMongo
+	if err := PayrollAdjustmentsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&adj); err != nil {
+		return nil, err
+	}
+	return &adj, nil
+}
+
+func CreatePayrollAdjustmentMongo(adj PayrollAdjustmentMongo) (*PayrollAdjustmentMongo, error) {
+	ctx := context.Background()
+	result, err := PayrollAdjustmentsCollection().InsertOne(ctx, adj)
+	if err != nil {
+		return nil, err
+	}
+	adj.ID = result.InsertedID.(primitive.ObjectID)
+	return &adj, nil
+}
+
+func DeletePayrollAdjustmentMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = PayrollAdjustmentsCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// --- Payroll Runs ---
+
+func GetPayrollRunsMongo() ([]PayrollRunMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: -1}})
+	cursor, err := PayrollRunsCollection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []PayrollRunMongo{}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetOpenPayrollRunsForTaxYearMongo returns the runs in year before the given period that are
+// not finalized yet
+func GetOpenPayrollRunsForTaxYearMongo(year, beforePeriod string) ([]PayrollRunMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: 1}})
+	cursor, err := PayrollRunsCollection().Find(ctx, bson.M{
+		"period": bson.M{"$gte": year + "-01", "$lt": beforePeriod},
+		"status": bson.M{"$ne": "finalized"},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []PayrollRunMongo{}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func GetPayrollRunByIDMongo(id string) (*PayrollRunMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var run PayrollRunMongo
+	if err := PayrollRunsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetPayrollRunByPeriodMongo returns nil, nil when the period has no run
+func GetPayrollRunByPeriodMongo(period string) (*PayrollRunMongo, error) {
+	ctx := context.Background()
+	var run PayrollRunMongo
+	err := PayrollRunsCollection().FindOne(ctx, bson.M{"period": period}).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ErrPayrollRunExists is returned when the period already has a payroll run
+var ErrPayrollRunExists = errors.New("payroll run already exists for this period")
+
+func CreatePayrollRunMongo(run PayrollRunMongo) (*PayrollRunMongo, error) {
+	ctx := context.Background()
+	result, err := PayrollRunsCollection().InsertOne(ctx, run)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrPayrollRunExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.ID = result.InsertedID.(primitive.ObjectID)
+	return &run, nil
+}
+
+// UpdatePayrollRunMongo applies set only while the run is in fromStatus and not being
+// recomputed, so concurrent transitions cannot both succeed. It reports whether the run was updated.
+func UpdatePayrollRunMongo(id, fromStatus string, set bson.M) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": objID, "status": fromStatus, "$or": notComputing()}
+	result, err := PayrollRunsCollection().UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// payrollComputeLease bounds how long a crashed recalculation can keep a run locked
+const payrollComputeLease = 10 * time.Minute
+
+// notComputing matches runs without a live recalculation lease
+func notComputing() bson.A {
+	stale := time.Now().Add(-payrollComputeLease).Format("2006-01-02 15:04:05")
+	return bson.A{
+		bson.M{"computing_at": bson.M{"$exists": false}},
+		bson.M{"computing_at": bson.M{"$lt": stale}},
+	}
+}
+
+// BeginPayrollRunComputeMongo leases a draft run for recalculation, so its payslips cannot be
+// replaced after it has moved on to review. It reports false when the run is no longer a draft
+// or another recalculation holds it.
+func BeginPayrollRunComputeMongo(id string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": objID, "status": "draft", "$or": notComputing()}
+	result, err := PayrollRunsCollection().UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"computing_at": time.Now().Format("2006-01-02 15:04:05")},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// EndPayrollRunComputeMongo releases the recalculation lease, applying set when it is not nil
+func EndPayrollRunComputeMongo(id string, set bson.M) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$unset": bson.M{"computing_at": ""}}
+	if set != nil {
+		update["$set"] = set
+	}
+	_, err = PayrollRunsCollection().UpdateOne(ctx, bson.M{"_id": objID}, update)
+	return err
+}
+
+// EnsurePayrollRunIndexes allows a single payroll run per period
+func EnsurePayrollRunIndexes() error {
+	ctx := context.Background()
+	_, err := PayrollRunsCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "period", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func DeletePayrollRunMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	if _, err := PayslipsCollection().DeleteMany(ctx, bson.M{"run_id": id}); err != nil {
+		return err
+	}
+	_, err = PayrollRunsCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// --- Payslips ---
+
+// ReplacePayslipsMongo swaps a run's payslips for a freshly computed set
+func ReplacePayslipsMongo(runID string, payslips []PayslipMongo) error {
+	ctx := context.Background()
+	if _, err := PayslipsCollection().DeleteMany(ctx, bson.M{"run_id": runID}); err != nil {
+		return err
+	}
+	if len(payslips) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(payslips))
+	for i := range payslips {
+		docs[i] = payslips[i]
+	}
+	_, err := PayslipsCollection().InsertMany(ctx, docs)
+	return err
+}
+
+// GetPayslipsByRunMongo lists a run's payslips without their lines
+func GetPayslipsByRunMongo(runID string) ([]PayslipMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "user_name", Value: 1}}).SetProjection(bson.M{"lines": 0})
+	cursor, err := PayslipsCollection().Find(ctx, bson.M{"run_id": runID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	payslips := []PayslipMongo{}
+	if err = cursor.All(ctx, &payslips); err != nil {
+		return nil, err
+	}
+	return payslips, nil
+}
+
+// GetFullPayslipsByRunMongo returns a run's payslips with their lines, for rendering
+func GetFullPayslipsByRunMongo(runID string) ([]PayslipMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "user_name", Value: 1}})
+	cursor, err := PayslipsCollection().Find(ctx, bson.M{"run_id": runID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	payslips := []PayslipMongo{}
+	if err = cursor.All(ctx, &payslips); err != nil {
+		return nil, err
+	}
+	return payslips, nil
+}
+
+func GetPayslipByIDMongo(id string) (*PayslipMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var payslip PayslipMongo
+	if err := PayslipsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&payslip); err != nil {
+		return nil, err
+	}
+	return &payslip, nil
+}
+
+// CountFinalizedPayslipsByRefMongo counts finalized payslips with a line sourced from refID
+func CountFinalizedPayslipsByRefMongo(refID string) (int64, error) {
+	ctx := context.Background()
+	return PayslipsCollection().CountDocuments(ctx, bson.M{
+		"status":               "finalized",
+		"lines.source.ref_ids": refID,
+	})
+}
+
+func SetPayslipsStatusMongo(runID, status string) error {
+	ctx := context.Background()
+	_, err := PayslipsCollection().UpdateMany(ctx, bson.M{"run_id": runID}, bson.M{"$set": bson.M{"status": status}})
+	return err
+}
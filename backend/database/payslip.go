@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PayslipAuditMongo records every payslip download and re-issue
+type PayslipAuditMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PayslipID string             `bson:"payslip_id" json:"payslip_id"`
+	RunID     string             `bson:"run_id" json:"run_id"`
+	Period    string             `bson:"period" json:"period"`
+	OwnerID   string             `bson:"owner_id" json:"owner_id"`
+	ActorID   string             `bson:"actor_id" json:"actor_id"`
+	Action    string             `bson:"action" json:"action"` // download, admin_download, bulk_download, reissue
+	Version   int                `bson:"version" json:"version"`
+	Protected bool               `bson:"protected" json:"protected"`
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	CreatedAt string             `bson:"created_at" json:"created_at"`
+}
+
+// GetIssuedPayslipsByUserMongo lists a user's payslips from finalized runs, newest first, without lines
+func GetIssuedPayslipsByUserMongo(userID string) ([]PayslipMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: -1}}).SetProjection(bson.M{"lines": 0})
+	cursor, err := PayslipsCollection().Find(ctx, bson.M{"user_id": userID, "status": "finalized"}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	payslips := []PayslipMongo{}
+	if err = cursor.All(ctx, &payslips); err != nil {
+		return nil, err
+	}
+	return payslips, nil
+}
+
+// IssuePayslipsMongo marks a finalized run's payslips as issued, version 1
+func IssuePayslipsMongo(runID, issuedAt string) error {
+	ctx := context.Background()
+	_, err := PayslipsCollection().UpdateMany(ctx, bson.M{"run_id": runID}, bson.M{"$set": bson.M{
+		"status":    "finalized",
+		"version":   1,
+		"issued_at": issuedAt,
+	}})
+	return err
+}
+
+// ReissuePayslipMongo applies set and bumps the payslip version
+func ReissuePayslipMongo(id string, set bson.M) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = PayslipsCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	return err
+}
+
+func AddPayslipAuditMongo(entries []PayslipAuditMongo) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	docs := make([]interface{}, len(entries))
+	for i := range entries {
+		docs[i] = entries[i]
+	}
+	_, err := PayslipAuditCollection().InsertMany(ctx, docs)
+	return err
+}
+
+func GetPayslipAuditMongo(payslipID string) ([]PayslipAuditMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := PayslipAuditCollection().Find(ctx, bson.M{"payslip_id": payslipID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []PayslipAuditMongo{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
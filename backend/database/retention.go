@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetentionCategory is a kind of data the retention job can remove
+type RetentionCategory struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DefaultDays int    `json:"default_days"`
+	// Categories that remove records nobody asked to delete start disabled
+	DefaultEnabled bool `json:"default_enabled"`
+}
+
+var RetentionCategories = []RetentionCategory{
+	{Code: "supporting_files", Name: "File pendukung izin", Description: "Supporting files of work permits, counted from the permit date", DefaultDays: 30, DefaultEnabled: true},
+	{Code: "audit_logs", Name: "Log audit slip gaji", Description: "Payslip download and re-issue log entries", DefaultDays: 1825, DefaultEnabled: true},
+	{Code: "resolved_requests", Name: "Pengajuan selesai", Description: "Approved and rejected requests, counted from submission; the approved records themselves are kept", DefaultDays: 365, DefaultEnabled: true},
+	{Code: "terminated_employees", Name: "Data karyawan keluar", Description: "Attendance, permits, requests, overtime, awards, leave quotas and uploads of employees, counted from their termination date; the account, payroll and claims are kept", DefaultDays: 1825},
+	{Code: "deleted_users", Name: "Akun dihapus", Description: "Accounts deleted by an admin, counted from deletion; purged together with their records except payroll and claims", DefaultDays: 365, DefaultEnabled: true},
+	{Code: "job_history", Name: "Riwayat job terjadwal", Description: "Run history of scheduled jobs", DefaultDays: 90, DefaultEnabled: true},
+}
+
+// RetentionPolicy keeps records of a category for Days days
+type RetentionPolicy struct {
+	Category string `bson:"category" json:"category"`
+	Days     int    `bson:"days" json:"days"`
+	Enabled  bool   `bson:"enabled" json:"enabled"`
+}
+
+type RetentionSettingsMongo struct {
+	Policies  []RetentionPolicy `bson:"policies" json:"policies"`
+	UpdatedAt string            `bson:"updated_at" json:"updated_at"`
+	UpdatedBy string            `bson:"updated_by" json:"updated_by"`
+}
+
+func FindRetentionCategory(code string) (RetentionCategory, bool) {
+	for _, cat := range RetentionCategories {
+		if cat.Code == code {
+			return cat, true
+		}
+	}
+	return RetentionCategory{}, false
+}
+
+// --- Retention Settings ---
+
+func DefaultRetentionSettings() RetentionSettingsMongo {
+	policies := make([]RetentionPolicy, 0, len(RetentionCategories))
+	for _, cat := range RetentionCategories {
+		policies = append(policies, RetentionPolicy{Category: cat.Code, Days: cat.DefaultDays, Enabled: cat.DefaultEnabled})
+	}
+	return RetentionSettingsMongo{Policies: policies}
+}
+
+// GetRetentionSettingsMongo returns one policy per category, in category order, filling in
+// defaults for categories the stored settings do not mention yet
+func GetRetentionSettingsMongo() (*RetentionSettingsMongo, error) {
+	ctx := context.Background()
+	var stored RetentionSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "retention"}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultRetentionSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byCategory := make(map[string]RetentionPolicy, len(stored.Policies))
+	for _, p := range stored.Policies {
+		byCategory[p.Category] = p
+	}
+	settings := DefaultRetentionSettings()
+	for i, p := range settings.Policies {
+		if s, ok := byCategory[p.Category]; ok {
+			settings.Policies[i] = s
+		}
+	}
+	settings.UpdatedAt = stored.UpdatedAt
+	settings.UpdatedBy = stored.UpdatedBy
+	return &settings, nil
+}
+
+func UpdateRetentionSettingsMongo(settings RetentionSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "retention"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// --- Retention Rules ---
+
+// ApplyRetentionPolicies removes data older than each enabled policy allows and returns the
+// number of records removed per category. A failing category does not stop the others; a
+// cancelled ctx stops before the next category.
+func ApplyRetentionPolicies(ctx context.Context, now time.Time) (map[string]int64, error) {
+	settings, err := GetRetentionSettingsMongo()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	var errs []error
+	for _, p := range settings.Policies {
+		if !p.Enabled || p.Days <= 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		cutoff := now.AddDate(0, 0, -p.Days)
+		var n int64
+		switch p.Category {
+		case "supporting_files":
+			n, err = CleanupOldSupportFiles(cutoff.Format("2006-01-02"))
+		case "audit_logs":
+			n, err = deleteOlderThan(PayslipAuditCollection(), bson.M{}, "created_at", cutoff.Format("2006-01-02 15:04:05"))
+		case "resolved_requests":
+			n, err = deleteOlderThan(PendingRequestsCollection(),
+				bson.M{"status": bson.M{"$in": bson.A{"approved", "rejected"}}},
+				"created_at", cutoff.Format("2006-01-02"))
+		case "terminated_employees":
+			n, err = purgeTerminatedEmployeeData(cutoff.Format("2006-01-02"))
+		case "deleted_users":
+			n, err = purgeDeletedUsers(cutoff.Format("2006-01-02 15:04:05"))
+		case "job_history":
+			n, err = deleteOlderThan(JobRunsCollection(),
+				bson.M{"status": bson.M{"$ne": "running"}},
+				"started_at", cutoff.Format("2006-01-02 15:04:05"))
+		default:
+			continue
+		}
+		counts[p.Category] = n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", p.Category, err))
+		}
+	}
+	return counts, errors.Join(errs...)
+}
+
+// deleteOlderThan removes documents matching filter whose field sorts before cutoff. Dates are
+// stored as "2006-01-02" or "2006-01-02 15:04:05" strings, which sort chronologically.
+func deleteOlderThan(coll *mongo.Collection, filter bson.M, field, cutoff string) (int64, error) {
+	ctx := context.Background()
+	filter[field] = bson.M{"$lt": cutoff, "$gt": ""}
+	result, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// purgeTerminatedEmployeeData removes the day-to-day records of employees who left before
+// cutoff. Their account, payslips, claims and THR entries stay for payroll and tax audits.
+func purgeTerminatedEmployeeData(cutoff string) (int64, error) {
+	users, err := findUsersForPurge(bson.M{"termination_date": bson.M{"$gt": "", "$lt": cutoff}})
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+	return deleteUserRecords(users)
+}
+
+// purgeDeletedUsers permanently removes accounts deleted before cutoff, with their records
+func purgeDeletedUsers(cutoff string) (int64, error) {
+	users, err := findUsersForPurge(bson.M{"status": "deleted", "deleted_at": bson.M{"$gt": "", "$lt": cutoff}})
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+	return purgeUsers(users)
+}
+
+func findUsersForPurge(filter bson.M) ([]UserMongo, error) {
+	ctx := context.Background()
+	cursor, err := UsersCollection().Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "photo_file_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []UserMongo
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// purgeUsers deletes the accounts after their records. Payslips, claims and THR entries keep
+// their own copy of the employee's name and stay for tax audits.
+func purgeUsers(users []UserMongo) (int64, error) {
+	ctx := context.Background()
+	total, err := deleteUserRecords(users)
+	if err != nil {
+		return total, err
+	}
+	result, err := UsersCollection().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": usersObjectIDs(users)}})
+	if err != nil {
+		return total, err
+	}
+	return total + result.DeletedCount, nil
+}
+
+// deleteUserRecords removes the attendance, permits, requests, overtime, awards, leave quotas,
+// photos and supporting documents of users, and clears their photo
+func deleteUserRecords(users []UserMongo) (int64, error) {
+	ctx := context.Background()
+	userIDs := make(bson.A, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, u.ID.Hex())
+	}
+
+	var total int64
+	for _, coll := range []*mongo.Collection{
+		AttendanceCollection(),
+		WorkPermitsCollection(),
+		PendingRequestsCollection(),
+		OvertimeRequestsCollection(),
+		AwardsCollection(),
+		LeaveQuotasCollection(),
+	} {
+		result, err := coll.DeleteMany(ctx, bson.M{"user_id": bson.M{"$in": userIDs}})
+		if err != nil {
+			return total, err
+		}
+		total += result.DeletedCount
+	}
+
+	// Their photos and the supporting documents they uploaded; claim receipts stay with the claims
+	fileIDs, err := FilesCollection().Distinct(ctx, "_id", bson.M{
+		"owner_id": bson.M{"$in": userIDs},
+		"purpose":  bson.M{"$in": bson.A{"photo", "document"}},
+	})
+	if err != nil {
+		return total, err
+	}
+	toDelete := make(map[string]bool)
+	for _, id := range fileIDs {
+		if oid, ok := id.(primitive.ObjectID); ok {
+			toDelete[oid.Hex()] = true
+		}
+	}
+	for _, u := range users {
+		if u.PhotoFileID != "" {
+			toDelete[u.PhotoFileID] = true
+		}
+	}
+	for id := range toDelete {
+		if err := DeleteFileMongo(id); err != nil {
+			return total, err
+		}
+		total++
+	}
+	_, err = UsersCollection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": usersObjectIDs(users)}},
+		bson.M{"$set": bson.M{"photo_file_id": "", "photo_url": ""}})
+	return total, err
+}
+
+func usersObjectIDs(users []UserMongo) bson.A {
+	ids := make(bson.A, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
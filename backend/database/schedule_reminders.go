@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ScheduleReminderMongo is a reminder the schedule_reminders job raised for a coach about one
+// dated class: it starts soon, or it is over and has no attendance yet
+type ScheduleReminderMongo struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	ScheduleID string             `bson:"schedule_id" json:"schedule_id"`
+	Date       string             `bson:"date" json:"date"`
+	Kind       string             `bson:"kind" json:"kind"` // starting_soon, missing_attendance
+	Message    string             `bson:"message" json:"message"`
+	SchoolID   string             `bson:"school_id" json:"school_id"`
+	SchoolName string             `bson:"school_name" json:"school_name"`
+	ClassName  string             `bson:"class_name" json:"class_name"`
+	StartTime  string             `bson:"start_time" json:"start_time"`
+	EndTime    string             `bson:"end_time" json:"end_time"`
+	Status     string             `bson:"status" json:"status"` // open, dismissed, resolved
+	CreatedAt  string             `bson:"created_at" json:"created_at"`
+}
+
+// AddScheduleReminderMongo stores a reminder unless the same one was raised before; false means
+// it already existed
+func AddScheduleReminderMongo(r ScheduleReminderMongo) (bool, error) {
+	ctx := context.Background()
+	r.Status = "open"
+	_, err := ScheduleRemindersCollection().InsertOne(ctx, r)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ResolveScheduleRemindersMongo closes the open reminders of one dated class except those of
+// keepKind, e.g. a starting_soon reminder once the class is over or all of them once attended
+func ResolveScheduleRemindersMongo(userID, scheduleID, date, keepKind string) error {
+	ctx := context.Background()
+	_, err := ScheduleRemindersCollection().UpdateMany(ctx, bson.M{
+		"user_id":     userID,
+		"schedule_id": scheduleID,
+		"date":        date,
+		"kind":        bson.M{"$ne": keepKind},
+		"status":      "open",
+	}, bson.M{"$set": bson.M{"status": "resolved"}})
+	return err
+}
+
+// GetOpenScheduleRemindersMongo lists a coach's open reminders, oldest class first
+func GetOpenScheduleRemindersMongo(userID string) ([]ScheduleReminderMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "start_time", Value: 1}})
+	cursor, err := ScheduleRemindersCollection().Find(ctx, bson.M{"user_id": userID, "status": "open"}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reminders := []ScheduleReminderMongo{}
+	if err = cursor.All(ctx, &reminders); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// DismissScheduleReminderMongo closes an open reminder of userID; false means there was none
+func DismissScheduleReminderMongo(id, userID string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := ScheduleRemindersCollection().UpdateOne(ctx,
+		bson.M{"_id": objID, "user_id": userID, "status": "open"},
+		bson.M{"$set": bson.M{"status": "dismissed"}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// GetScheduledCoachIDsMongo returns the coaches with an active timetable slot
+func GetScheduledCoachIDsMongo() ([]string, error) {
+	ctx := context.Background()
+	values, err := SchoolSchedulesCollection().Distinct(ctx, "coach_id", bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, v := range values {
+		if id, ok := v.(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// EnsureScheduleReminderIndexes raises each reminder once per class and backs the inbox lookup
+func EnsureScheduleReminderIndexes() error {
+	ctx := context.Background()
+	_, err := ScheduleRemindersCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1}, {Key: "schedule_id", Value: 1},
+				{Key: "date", Value: 1}, {Key: "kind", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	return err
+}
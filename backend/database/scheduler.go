@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LockMongo is a lease held by one process until it expires or is released. The scheduler uses
+// it to elect a leader among replicas and to keep a job from running twice at the same time.
+type LockMongo struct {
+	Name      string    `bson:"_id" json:"name"`
+	Owner     string    `bson:"owner" json:"owner"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	RenewedAt string    `bson:"renewed_at" json:"renewed_at"`
+}
+
+// JobRunMongo is one execution of a scheduled job
+type JobRunMongo struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Job          string             `bson:"job" json:"job"`
+	Trigger      string             `bson:"trigger" json:"trigger"` // schedule, manual
+	TriggeredBy  string             `bson:"triggered_by,omitempty" json:"triggered_by,omitempty"`
+	Instance     string             `bson:"instance" json:"instance"`
+	ScheduledFor string             `bson:"scheduled_for,omitempty" json:"scheduled_for,omitempty"`
+	Status       string             `bson:"status" json:"status"` // running, success, failed, abandoned
+	Counts       map[string]int64   `bson:"counts,omitempty" json:"counts,omitempty"`
+	Error        string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt    string             `bson:"started_at" json:"started_at"`
+	FinishedAt   string             `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	DurationMs   int64              `bson:"duration_ms" json:"duration_ms"`
+}
+
+// JobOverride changes a job's built-in schedule; an empty Schedule keeps the default
+type JobOverride struct {
+	Schedule string `bson:"schedule" json:"schedule"`
+	Disabled bool   `bson:"disabled" json:"disabled"`
+}
+
+type JobSettingsMongo struct {
+	Jobs      map[string]JobOverride `bson:"jobs" json:"jobs"`
+	UpdatedAt string                 `bson:"updated_at" json:"updated_at"`
+	UpdatedBy string                 `bson:"updated_by" json:"updated_by"`
+}
+
+// --- Locks ---
+
+// AcquireLockMongo takes or renews the lock for owner. It returns false while another owner
+// holds an unexpired lock.
+func AcquireLockMongo(name, owner string, ttl time.Duration) (bool, error) {
+	ctx := context.Background()
+	now := time.Now()
+	filter := bson.M{
+		"_id": name,
+		"$or": bson.A{
+			bson.M{"owner": owner},
+			bson.M{"expires_at": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{
+		"owner":      owner,
+		"expires_at": now.Add(ttl),
+		"renewed_at": now.Format("2006-01-02 15:04:05"),
+	}}
+	_, err := LocksCollection().UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// The lock exists and belongs to someone else
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func ReleaseLockMongo(name, owner string) error {
+	ctx := context.Background()
+	_, err := LocksCollection().DeleteOne(ctx, bson.M{"_id": name, "owner": owner})
+	return err
+}
+
+// GetLockMongo returns the current holder of a lock, or nil if it is free
+func GetLockMongo(name string) (*LockMongo, error) {
+	ctx := context.Background()
+	var lock LockMongo
+	err := LocksCollection().FindOne(ctx, bson.M{"_id": name, "expires_at": bson.M{"$gte": time.Now()}}).Decode(&lock)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// --- Job Runs ---
+
+func AddJobRunMongo(run JobRunMongo) (*JobRunMongo, error) {
+	ctx := context.Background()
+	result, err := JobRunsCollection().InsertOne(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	run.ID = result.InsertedID.(primitive.ObjectID)
+	return &run, nil
+}
+
+func UpdateJobRunMongo(id primitive.ObjectID, set bson.M) error {
+	ctx := context.Background()
+	_, err := JobRunsCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// AbandonJobRunsMongo closes runs of a job left "running" by a process that died. Call it only
+// while holding the job's lock.
+func AbandonJobRunsMongo(job, now string) error {
+	ctx := context.Background()
+	_, err := JobRunsCollection().UpdateMany(ctx,
+		bson.M{"job": job, "status": "running"},
+		bson.M{"$set": bson.M{"status": "abandoned", "finished_at": now}})
+	return err
+}
+
+// GetJobRunsMongo lists runs newest first; an empty job lists all jobs
+func GetJobRunsMongo(job string, limit int64) ([]JobRunMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if job != "" {
+		filter["job"] = job
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	cursor, err := JobRunsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []JobRunMongo{}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetLastScheduledRunMongo returns the job's latest scheduled run, or nil if it never ran on schedule
+func GetLastScheduledRunMongo(job string) (*JobRunMongo, error) {
+	ctx := context.Background()
+	opts := options.FindOne().SetSort(bson.D{{Key: "scheduled_for", Value: -1}})
+	var run JobRunMongo
+	err := JobRunsCollection().FindOne(ctx, bson.M{"job": job, "trigger": "schedule"}, opts).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetLatestJobRunsMongo returns the most recent run of every job, keyed by job name
+func GetLatestJobRunsMongo() (map[string]JobRunMongo, error) {
+	ctx := context.Background()
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "started_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$job", "run": bson.M{"$first": "$$ROOT"}}}},
+	}
+	cursor, err := JobRunsCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Run JobRunMongo `bson:"run"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	latest := make(map[string]JobRunMongo, len(rows))
+	for _, r := range rows {
+		latest[r.Run.Job] = r.Run
+	}
+	return latest, nil
+}
+
+// EnsureJobRunIndexes backs the history lookups by job
+func EnsureJobRunIndexes() error {
+	ctx := context.Background()
+	_, err := JobRunsCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}}},
+		{Keys: bson.D{{Key: "job", Value: 1}, {Key: "trigger", Value: 1}, {Key: "scheduled_for", Value: -1}}},
+	})
+	return err
+}
+
+// --- Job Settings ---
+
+func GetJobSettingsMongo() (*JobSettingsMongo, error) {
+	ctx := context.Background()
+	var settings JobSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "jobs"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return &JobSettingsMongo{Jobs: map[string]JobOverride{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if settings.Jobs == nil {
+		settings.Jobs = map[string]JobOverride{}
+	}
+	return &settings, nil
+}
+
+// UpdateJobOverrideMongo sets the override of one job
+func UpdateJobOverrideMongo(job string, override JobOverride, updatedAt, updatedBy string) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "jobs"},
+		bson.M{"$set": bson.M{
+			"jobs." + job: override,
+			"updated_at":  updatedAt,
+			"updated_by":  updatedBy,
+		}},
+		opts)
+	return err
+}
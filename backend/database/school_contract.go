@@ -0,0 +1,339 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BillingContact struct {
+	Name    string `bson:"name" json:"name"`
+	Email   string `bson:"email" json:"email"`
+	Phone   string `bson:"phone" json:"phone"`
+	Address string `bson:"address" json:"address"`
+}
+
+// SchoolContractMongo is a school's agreement to buy class sessions. Amounts are in rupiah.
+type SchoolContractMongo struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SchoolID        string             `bson:"school_id" json:"school_id"`
+	ContractNumber  string             `bson:"contract_number" json:"contract_number"`
+	StartDate       string             `bson:"start_date" json:"start_date"`
+	EndDate         string             `bson:"end_date" json:"end_date"`
+	RatePerSession  int64              `bson:"rate_per_session" json:"rate_per_session"`
+	SessionsPerTerm int                `bson:"sessions_per_term" json:"sessions_per_term"`
+	// Terms run back to back from StartDate, e.g. 6 for semesters
+	TermMonths      int            `bson:"term_months" json:"term_months"`
+	PaymentTermDays int            `bson:"payment_term_days" json:"payment_term_days"`
+	BillingContact  BillingContact `bson:"billing_contact" json:"billing_contact"`
+	Notes           string         `bson:"notes" json:"notes"`
+	CreatedAt       string         `bson:"created_at" json:"created_at"`
+}
+
+type InvoiceLine struct {
+	Description string `bson:"description" json:"description"`
+	Quantity    int    `bson:"quantity" json:"quantity"`
+	UnitPrice   int64  `bson:"unit_price" json:"unit_price"`
+	Amount      int64  `bson:"amount" json:"amount"`
+}
+
+// InvoiceSession is one delivered class listed on the invoice for the school's reference
+type InvoiceSession struct {
+	AttendanceID string `bson:"attendance_id" json:"attendance_id"`
+	Date         string `bson:"date" json:"date"`
+	StartTime    string `bson:"start_time" json:"start_time"`
+	EndTime      string `bson:"end_time" json:"end_time"`
+	CoachName    string `bson:"coach_name" json:"coach_name"`
+	Details      string `bson:"details" json:"details"`
+}
+
+// InvoiceMongo is a monthly invoice for the sessions delivered under one contract
+type InvoiceMongo struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Number         string             `bson:"number" json:"number"`
+	ContractID     string             `bson:"contract_id" json:"contract_id"`
+	ContractNumber string             `bson:"contract_number" json:"contract_number"`
+	SchoolID       string             `bson:"school_id" json:"school_id"`
+	SchoolName     string             `bson:"school_name" json:"school_name"`
+	BillingContact BillingContact     `bson:"billing_contact" json:"billing_contact"`
+	Period         string             `bson:"period" json:"period"` // YYYY-MM
+	Lines          []InvoiceLine      `bson:"lines" json:"lines"`
+	Sessions       []InvoiceSession   `bson:"sessions" json:"sessions"`
+	Total          int64              `bson:"total" json:"total"`
+	// Delivery against the contract term when the invoice was generated: on_track, over, under
+	DeliveryStatus string `bson:"delivery_status" json:"delivery_status"`
+	Status         string `bson:"status" json:"status"` // draft, sent, paid
+	IssueDate      string `bson:"issue_date" json:"issue_date"`
+	DueDate        string `bson:"due_date" json:"due_date"`
+	SentAt         string `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	PaidAt         string `bson:"paid_at,omitempty" json:"paid_at,omitempty"`
+	CreatedBy      string `bson:"created_by" json:"created_by"`
+	CreatedAt      string `bson:"created_at" json:"created_at"`
+}
+
+// NextSequenceMongo atomically increments and returns a named counter, starting at 1
+func NextSequenceMongo(name string) (int64, error) {
+	ctx := context.Background()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := CountersCollection().FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&counter)
+	return counter.Seq, err
+}
+
+// --- School Contracts ---
+
+func GetSchoolContractsMongo(schoolID string) ([]SchoolContractMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if schoolID != "" {
+		filter["school_id"] = schoolID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "start_date", Value: -1}})
+	cursor, err := SchoolContractsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	contracts := []SchoolContractMongo{}
+	if err = cursor.All(ctx, &contracts); err != nil {
+		return nil, err
+	}
+	return contracts, nil
+}
+
+// GetActiveSchoolContractsMongo returns contracts whose window overlaps from..to
+func GetActiveSchoolContractsMongo(from, to string) ([]SchoolContractMongo, error) {
+	ctx := context.Background()
+	cursor, err := SchoolContractsCollection().Find(ctx, bson.M{
+		"start_date": bson.M{"$lte": to},
+		"end_date":   bson.M{"$gte": from},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	contracts := []SchoolContractMongo{}
+	if err = cursor.All(ctx, &contracts); err != nil {
+		return nil, err
+	}
+	return contracts, nil
+}
+
+func GetSchoolContractByIDMongo(id string) (*SchoolContractMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var contract SchoolContractMongo
+	if err := SchoolContractsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&contract); err != nil {
+		return nil, err
+	}
+	return &contract, nil
+}
+
+func CreateSchoolContractMongo(contract SchoolContractMongo) (*SchoolContractMongo, error) {
+	ctx := context.Background()
+	result, err := SchoolContractsCollection().InsertOne(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+	contract.ID = result.InsertedID.(primitive.ObjectID)
+	return &contract, nil
+}
+
+func UpdateSchoolContractMongo(id string, contract SchoolContractMongo) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = SchoolContractsCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"contract_number":   contract.ContractNumber,
+		"start_date":        contract.StartDate,
+		"end_date":          contract.EndDate,
+		"rate_per_session":  contract.RatePerSession,
+		"sessions_per_term": contract.SessionsPerTerm,
+		"term_months":       contract.TermMonths,
+		"payment_term_days": contract.PaymentTermDays,
+		"billing_contact":   contract.BillingContact,
+		"notes":             contract.Notes,
+	}})
+	return err
+}
+
+func DeleteSchoolContractMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = SchoolContractsCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// GetSchoolClassSessionsMongo returns present attendance linked to a school in a date range, oldest first
+func GetSchoolClassSessionsMongo(schoolID, from, to string) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "starting_time", Value: 1}})
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{
+		"school_id":               schoolID,
+		"status":                  "present",
+		"activity_category_codes": "school_class",
+		"date":                    bson.M{"$gte": from, "$lte": to},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AttendanceMongo
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// CountSchoolClassSessionsMongo counts delivered sessions for a school in a date range
+func CountSchoolClassSessionsMongo(schoolID, from, to string) (int64, error) {
+	ctx := context.Background()
+	return AttendanceCollection().CountDocuments(ctx, bson.M{
+		"school_id":               schoolID,
+		"status":                  "present",
+		"activity_category_codes": "school_class",
+		"date":                    bson.M{"$gte": from, "$lte": to},
+	})
+}
+
+// --- Invoices ---
+
+// InvoiceFilter narrows the invoice list; empty fields are ignored
+type InvoiceFilter struct {
+	Period     string
+	Status     string
+	SchoolID   string
+	ContractID string
+}
+
+func GetInvoicesMongo(f InvoiceFilter) ([]InvoiceMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if f.Period != "" {
+		filter["period"] = f.Period
+	}
+	if f.Status != "" {
+		filter["status"] = f.Status
+	}
+	if f.SchoolID != "" {
+		filter["school_id"] = f.SchoolID
+	}
+	if f.ContractID != "" {
+		filter["contract_id"] = f.ContractID
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: -1}, {Key: "number", Value: -1}}).
+		SetProjection(bson.M{"sessions": 0})
+	cursor, err := InvoicesCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invoices := []InvoiceMongo{}
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+func GetInvoiceByIDMongo(id string) (*InvoiceMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var invoice InvoiceMongo
+	if err := InvoicesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceByContractAndPeriodMongo returns nil, nil when the month has not been invoiced
+func GetInvoiceByContractAndPeriodMongo(contractID, period string) (*InvoiceMongo, error) {
+	ctx := context.Background()
+	var invoice InvoiceMongo
+	err := InvoicesCollection().FindOne(ctx, bson.M{"contract_id": contractID, "period": period}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ErrInvoiceExists is returned when a contract already has an invoice for the period
+var ErrInvoiceExists = errors.New("invoice already generated for this contract and period")
+
+func CreateInvoiceMongo(invoice InvoiceMongo) (*InvoiceMongo, error) {
+	ctx := context.Background()
+	result, err := InvoicesCollection().InsertOne(ctx, invoice)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrInvoiceExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	invoice.ID = result.InsertedID.(primitive.ObjectID)
+	return &invoice, nil
+}
+
+// ReplaceDraftInvoiceMongo regenerates a draft's contents, keeping its number
+func ReplaceDraftInvoiceMongo(id primitive.ObjectID, invoice InvoiceMongo) error {
+	ctx := context.Background()
+	invoice.ID = id
+	_, err := InvoicesCollection().ReplaceOne(ctx, bson.M{"_id": id, "status": "draft"}, invoice)
+	return err
+}
+
+func UpdateInvoiceStatusMongo(id string, set bson.M) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = InvoicesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": set})
+	return err
+}
+
+func DeleteDraftInvoiceMongo(id string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := InvoicesCollection().DeleteOne(ctx, bson.M{"_id": objID, "status": "draft"})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// EnsureInvoiceIndexes keeps one invoice per contract and period, so concurrent generation runs
+// cannot bill the same month twice
+func EnsureInvoiceIndexes() error {
+	ctx := context.Background()
+	_, err := InvoicesCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "contract_id", Value: 1}, {Key: "period", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SchoolScheduleMongo is one weekly class slot a coach teaches at a school
+type SchoolScheduleMongo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SchoolID  string             `bson:"school_id" json:"school_id"`
+	CoachID   string             `bson:"coach_id" json:"coach_id"`
+	Day       int                `bson:"day" json:"day"` // ISO weekday: 1 Monday ... 7 Sunday
+	StartTime string             `bson:"start_time" json:"start_time"`
+	EndTime   string             `bson:"end_time" json:"end_time"`
+	Level     string             `bson:"level" json:"level"`
+	ClassName string             `bson:"class_name" json:"class_name"`
+	// Optional validity window (YYYY-MM-DD), e.g. a school term
+	EffectiveFrom string `bson:"effective_from" json:"effective_from"`
+	EffectiveTo   string `bson:"effective_to" json:"effective_to"`
+	Active        bool   `bson:"active" json:"active"`
+	CreatedAt     string `bson:"created_at" json:"created_at"`
+}
+
+// SchoolScheduleFilter selects schedules; empty fields are ignored
+type SchoolScheduleFilter struct {
+	SchoolID   string
+	CoachID    string
+	Day        int
+	ActiveOnly bool
+}
+
+func GetSchoolSchedulesMongo(f SchoolScheduleFilter) ([]SchoolScheduleMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if f.SchoolID != "" {
+		filter["school_id"] = f.SchoolID
+	}
+	if f.CoachID != "" {
+		filter["coach_id"] = f.CoachID
+	}
+	if f.Day != 0 {
+		filter["day"] = f.Day
+	}
+	if f.ActiveOnly {
+		filter["active"] = true
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}, {Key: "start_time", Value: 1}})
+	cursor, err := SchoolSchedulesCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	schedules := []SchoolScheduleMongo{}
+	if err = cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func GetSchoolScheduleByIDMongo(id string) (*SchoolScheduleMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var schedule SchoolScheduleMongo
+	if err := SchoolSchedulesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func CreateSchoolScheduleMongo(schedule SchoolScheduleMongo) (*SchoolScheduleMongo, error) {
+	ctx := context.Background()
+	result, err := SchoolSchedulesCollection().InsertOne(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = result.InsertedID.(primitive.ObjectID)
+	return &schedule, nil
+}
+
+func UpdateSchoolScheduleMongo(id string, schedule SchoolScheduleMongo) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = SchoolSchedulesCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"coach_id":       schedule.CoachID,
+		"day":            schedule.Day,
+		"start_time":     schedule.StartTime,
+		"end_time":       schedule.EndTime,
+		"level":          schedule.Level,
+		"class_name":     schedule.ClassName,
+		"effective_from": schedule.EffectiveFrom,
+		"effective_to":   schedule.EffectiveTo,
+		"active":         schedule.Active,
+	}})
+	return err
+}
+
+func DeleteSchoolScheduleMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = SchoolSchedulesCollection().DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+// DeleteSchoolSchedulesBySchool removes the timetable of a deleted school
+func DeleteSchoolSchedulesBySchool(schoolID string) error {
+	ctx := context.Background()
+	_, err := SchoolSchedulesCollection().DeleteMany(ctx, bson.M{"school_id": schoolID})
+	return err
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TaxBracket applies Rate (percent) to amounts up to UpTo; the last bracket has UpTo 0 (no limit)
+type TaxBracket struct {
+	UpTo int64   `bson:"up_to" json:"up_to"`
+	Rate float64 `bson:"rate" json:"rate"`
+}
+
+// PTKPAmounts are the annual non-taxable income allowances
+type PTKPAmounts struct {
+	Self          int64 `bson:"self" json:"self"`
+	Married       int64 `bson:"married" json:"married"`
+	PerDependent  int64 `bson:"per_dependent" json:"per_dependent"`
+	MaxDependents int   `bson:"max_dependents" json:"max_dependents"`
+}
+
+// TaxRuleSetMongo holds the PPh 21 rules for a tax year. A payroll period uses the latest
+// rule set whose Year is not after the period's year, so rules only need a new version when they change.
+type TaxRuleSetMongo struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Year int                `bson:"year" json:"year"`
+	// PTKP status (TK/0 .. K/3) to TER category (A, B, C)
+	TERCategories map[string]string `bson:"ter_categories" json:"ter_categories"`
+	// Monthly TER brackets on gross taxable income, per category
+	TERTables map[string][]TaxBracket `bson:"ter_tables" json:"ter_tables"`
+	PTKP      PTKPAmounts             `bson:"ptkp" json:"ptkp"`
+	// Progressive Pasal 17 brackets on annual taxable income, used for the December reconciliation
+	AnnualBrackets []TaxBracket `bson:"annual_brackets" json:"annual_brackets"`
+	// Biaya jabatan: percent of gross, capped per month worked
+	PositionCostRate       float64 `bson:"position_cost_rate" json:"position_cost_rate"`
+	PositionCostMaxMonthly int64   `bson:"position_cost_max_monthly" json:"position_cost_max_monthly"`
+	// Extra percent withheld from employees without an NPWP
+	NoNPWPSurcharge float64 `bson:"no_npwp_surcharge" json:"no_npwp_surcharge"`
+	Notes           string  `bson:"notes" json:"notes"`
+	UpdatedAt       string  `bson:"updated_at" json:"updated_at"`
+	UpdatedBy       string  `bson:"updated_by" json:"updated_by"`
+}
+
+// DefaultTaxRuleSet is PP 58/2023 and PMK 168/2023, in force from January 2024
+func DefaultTaxRuleSet() TaxRuleSetMongo {
+	return TaxRuleSetMongo{
+		Year: 2024,
+		TERCategories: map[string]string{
+			"TK/0": "A", "TK/1": "A", "K/0": "A",
+			"TK/2": "B", "TK/3": "B", "K/1": "B", "K/2": "B",
+			"K/3": "C",
+		},
+		TERTables: map[string][]TaxBracket{
+			"A": {
+				{5400000, 0}, {5650000, 0.25}, {5950000, 0.5}, {6300000, 0.75}, {6750000, 1},
+				{7500000, 1.25}, {8550000, 1.5}, {9650000, 1.75}, {10050000, 2}, {10350000, 2.25},
+				{10700000, 2.5}, {11050000, 3}, {11600000, 3.5}, {12500000, 4}, {13750000, 5},
+				{15100000, 6}, {16950000, 7}, {19750000, 8}, {24150000, 9}, {26450000, 10},
+				{28000000, 11}, {30050000, 12}, {32400000, 13}, {35400000, 14}, {39100000, 15},
+				{43850000, 16}, {47800000, 17}, {51400000, 18}, {56300000, 19}, {62200000, 20},
+				{68600000, 21}, {77500000, 22}, {89000000, 23}, {103000000, 24}, {125000000, 25},
+				{157000000, 26}, {206000000, 27}, {337000000, 28}, {454000000, 29}, {550000000, 30},
+				{695000000, 31}, {910000000, 32}, {1400000000, 33}, {0, 34},
+			},
+			"B": {
+				{6200000, 0}, {6500000, 0.25}, {6850000, 0.5}, {7300000, 0.75}, {9200000, 1},
+				{10750000, 1.5}, {11250000, 2}, {11600000, 2.5}, {12600000, 3}, {13600000, 4},
+				{14950000, 5}, {16400000, 6}, {18450000, 7}, {21850000, 8}, {26000000, 9},
+				{27700000, 10}, {29350000, 11}, {31450000, 12}, {33950000, 13}, {37100000, 14},
+				{41100000, 15}, {45800000, 16}, {49500000, 17}, {53800000, 18}, {58500000, 19},
+				{64000000, 20}, {71000000, 21}, {80000000, 22}, {93000000, 23}, {109000000, 24},
+				{129000000, 25}, {163000000, 26}, {211000000, 27}, {374000000, 28}, {459000000, 29},
+				{555000000, 30}, {704000000, 31}, {957000000, 32}, {1405000000, 33}, {0, 34},
+			},
+			"C": {
+				{6600000, 0}, {6950000, 0.25}, {7350000, 0.5}, {7800000, 0.75}, {8850000, 1},
+				{9800000, 1.25}, {10950000, 1.5}, {11200000, 1.75}, {12050000, 2}, {12950000, 3},
+				{14150000, 4}, {15550000, 5}, {17050000, 6}, {19500000, 7}, {22700000, 8},
+				{26600000, 9}, {28100000, 10}, {30100000, 11}, {32600000, 12}, {35400000, 13},
+				{38900000, 14}, {43000000, 15}, {47400000, 16}, {51200000, 17}, {55800000, 18},
+				{60400000, 19}, {66700000, 20}, {74500000, 21}, {83200000, 22}, {95600000, 23},
+				{110000000, 24}, {134000000, 25}, {169000000, 26}, {221000000, 27}, {390000000, 28},
+				{463000000, 29}, {561000000, 30}, {709000000, 31}, {965000000, 32}, {1419000000, 33},
+				{0, 34},
+			},
+		},
+		PTKP: PTKPAmounts{Self: 54000000, Married: 4500000, PerDependent: 4500000, MaxDependents: 3},
+		AnnualBrackets: []TaxBracket{
+			{60000000, 5}, {250000000, 15}, {500000000, 25}, {5000000000, 30}, {0, 35},
+		},
+		PositionCostRate:       5,
+		PositionCostMaxMonthly: 500000,
+		NoNPWPSurcharge:        20,
+		Notes:                  "PP 58/2023, PMK 168/2023",
+	}
+}
+
+// EnsureTaxRules indexes tax rule sets by year and seeds the current rules on an empty collection
+func EnsureTaxRules(now string) error {
+	ctx := context.Background()
+	unique := mongo.IndexModel{Keys: bson.D{{Key: "year", Value: 1}}, Options: options.Index().SetUnique(true)}
+	if _, err := TaxRulesCollection().Indexes().CreateOne(ctx, unique); err != nil {
+		return err
+	}
+
+	count, err := TaxRulesCollection().CountDocuments(ctx, bson.M{})
+	if err != nil || count > 0 {
+		return err
+	}
+	rules := DefaultTaxRuleSet()
+	rules.UpdatedAt = now
+	_, err = TaxRulesCollection().InsertOne(ctx, rules)
+	return err
+}
+
+func GetTaxRuleSetsMongo() ([]TaxRuleSetMongo, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: "year", Value: -1}})
+	cursor, err := TaxRulesCollection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []TaxRuleSetMongo{}
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetTaxRuleSetForYearMongo returns the rule set in force for year: the latest one not after it
+func GetTaxRuleSetForYearMongo(year int) (*TaxRuleSetMongo, error) {
+	ctx := context.Background()
+	opts := options.FindOne().SetSort(bson.D{{Key: "year", Value: -1}})
+	var rules TaxRuleSetMongo
+	if err := TaxRulesCollection().FindOne(ctx, bson.M{"year": bson.M{"$lte": year}}, opts).Decode(&rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// SaveTaxRuleSetMongo creates or replaces the rule set for rules.Year
+func SaveTaxRuleSetMongo(rules TaxRuleSetMongo) error {
+	ctx := context.Background()
+	rules.ID = primitive.NilObjectID
+	opts := options.Replace().SetUpsert(true)
+	_, err := TaxRulesCollection().ReplaceOne(ctx, bson.M{"year": rules.Year}, rules, opts)
+	return err
+}
+
+func DeleteTaxRuleSetMongo(year int) (bool, error) {
+	ctx := context.Background()
+	result, err := TaxRulesCollection().DeleteOne(ctx, bson.M{"year": year})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// CountFinalizedPayslipsByTaxRuleMongo counts finalized payslips withheld under a rule set
+func CountFinalizedPayslipsByTaxRuleMongo(year int) (int64, error) {
+	ctx := context.Background()
+	return PayslipsCollection().CountDocuments(ctx, bson.M{"status": "finalized", "tax.rule_year": year})
+}
+
+// GetFinalizedPayslipsForTaxYearMongo returns a user's finalized payslips in year before the given period
+func GetFinalizedPayslipsForTaxYearMongo(userID, year, beforePeriod string) ([]PayslipMongo, error) {
+	ctx := context.Background()
+	cursor, err := PayslipsCollection().Find(ctx, bson.M{
+		"user_id": userID,
+		"status":  "finalized",
+		"period":  bson.M{"$gte": year + "-01", "$lt": beforePeriod},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payslips []PayslipMongo
+	if err = cursor.All(ctx, &payslips); err != nil {
+		return nil, err
+	}
+	return payslips, nil
+}
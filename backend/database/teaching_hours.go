@@ -0,0 +1,225 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TeachingHoursFilter narrows the teaching hours report; From/To are YYYY-MM-DD
+type TeachingHoursFilter struct {
+	From     string
+	To       string
+	SchoolID string
+	UserID   string
+}
+
+// TeachingHoursRow totals the school sessions one coach delivered at one school in one month
+type TeachingHoursRow struct {
+	Month      string  `bson:"month" json:"month"` // YYYY-MM
+	SchoolID   string  `bson:"school_id" json:"school_id"`
+	SchoolName string  `bson:"school_name" json:"school_name"`
+	UserID     string  `bson:"user_id" json:"user_id"`
+	UserName   string  `bson:"user_name" json:"user_name"`
+	Sessions   int     `bson:"sessions" json:"sessions"`
+	Minutes    int     `bson:"minutes" json:"minutes"`
+	Hours      float64 `bson:"-" json:"hours"`
+}
+
+// SchoolNameFromNotes extracts the "[School]" prefix the attendance form prepends to activity notes
+func SchoolNameFromNotes(notes string) string {
+	notes = strings.TrimSpace(notes)
+	if !strings.HasPrefix(notes, "[") {
+		return ""
+	}
+	end := strings.Index(notes, "]")
+	if end < 0 {
+		return ""
+	}
+	return strings.TrimSpace(notes[1:end])
+}
+
+// BackfillAttendanceSchoolIDs links School Class attendance recorded before school_id existed
+// to SchoolMongo by the "[School]" name in the notes. Names that match no school are left unlinked.
+func BackfillAttendanceSchoolIDs() (int64, error) {
+	ctx := context.Background()
+	schools, err := GetSchoolsMongo()
+	if err != nil {
+		return 0, err
+	}
+	schoolIDs := make(map[string]string)
+	for _, s := range schools {
+		schoolIDs[strings.ToLower(s.Name)] = s.ID.Hex()
+	}
+
+	cursor, err := AttendanceCollection().Find(ctx, bson.M{
+		"activity_category_codes": "school_class",
+		"school_id":               bson.M{"$in": bson.A{nil, ""}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var att AttendanceMongo
+		if err := cursor.Decode(&att); err != nil {
+			return updated, err
+		}
+		id, ok := schoolIDs[strings.ToLower(SchoolNameFromNotes(att.ActivityNotes))]
+		if !ok {
+			continue
+		}
+		if _, err := AttendanceCollection().UpdateOne(ctx, bson.M{"_id": att.ID}, bson.M{"$set": bson.M{"school_id": id}}); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, cursor.Err()
+}
+
+func teachingHoursMatch(f TeachingHoursFilter) bson.M {
+	match := bson.M{
+		"status":                  "present",
+		"activity_category_codes": "school_class",
+		"school_id":               bson.M{"$nin": bson.A{nil, ""}},
+	}
+	dateRange := bson.M{}
+	if f.From != "" {
+		dateRange["$gte"] = f.From
+	}
+	if f.To != "" {
+		dateRange["$lte"] = f.To
+	}
+	if len(dateRange) > 0 {
+		match["date"] = dateRange
+	}
+	if f.SchoolID != "" {
+		match["school_id"] = f.SchoolID
+	}
+	if f.UserID != "" {
+		match["user_id"] = f.UserID
+	}
+	return match
+}
+
+// sessionMinutesExpr computes ending_time - starting_time in minutes from "HH:MM" strings, 0 when either is missing
+func sessionMinutesExpr() bson.M {
+	toInt := func(v interface{}) bson.M {
+		return bson.M{"$convert": bson.M{"input": v, "to": "int", "onError": 0, "onNull": 0}}
+	}
+	clock := func(field string) bson.M {
+		return bson.M{"$add": bson.A{
+			bson.M{"$multiply": bson.A{toInt(bson.M{"$substrCP": bson.A{field, 0, 2}}), 60}},
+			toInt(bson.M{"$substrCP": bson.A{field, 3, 2}}),
+		}}
+	}
+	isClock := func(field string) bson.M {
+		return bson.M{"$eq": bson.A{bson.M{"$strLenCP": bson.M{"$ifNull": bson.A{field, ""}}}, 5}}
+	}
+	return bson.M{"$cond": bson.A{
+		bson.M{"$and": bson.A{isClock("$starting_time"), isClock("$ending_time")}},
+		bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{clock("$ending_time"), clock("$starting_time")}}}},
+		0,
+	}}
+}
+
+// GetTeachingSessionsMongo lists the School Class attendance the teaching hours report counts
+func GetTeachingSessionsMongo(f TeachingHoursFilter) ([]AttendanceMongo, error) {
+	ctx := context.Background()
+	cursor, err := AttendanceCollection().Find(ctx, teachingHoursMatch(f))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []AttendanceMongo{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetTeachingHoursMongo groups linked School Class attendance by month, school and coach
+func GetTeachingHoursMongo(f TeachingHoursFilter) ([]TeachingHoursRow, error) {
+	ctx := context.Background()
+	toObjectID := func(field string) bson.M {
+		return bson.M{"$convert": bson.M{"input": field, "to": "objectId", "onError": nil, "onNull": nil}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: teachingHoursMatch(f)}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"month":     bson.M{"$substrCP": bson.A{"$date", 0, 7}},
+				"school_id": "$school_id",
+				"user_id":   "$user_id",
+			},
+			"sessions": bson.M{"$sum": 1},
+			"minutes":  bson.M{"$sum": sessionMinutesExpr()},
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":     "schools",
+			"let":      bson.M{"sid": toObjectID("$_id.school_id")},
+			"pipeline": bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$sid"}}}}, bson.M{"$project": bson.M{"name": 1}}},
+			"as":       "school",
+		}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":     "users",
+			"let":      bson.M{"uid": toObjectID("$_id.user_id")},
+			"pipeline": bson.A{bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$uid"}}}}, bson.M{"$project": bson.M{"name": 1}}},
+			"as":       "user",
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":         0,
+			"month":       "$_id.month",
+			"school_id":   "$_id.school_id",
+			"user_id":     "$_id.user_id",
+			"school_name": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$school.name", 0}}, ""}},
+			"user_name":   bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$user.name", 0}}, ""}},
+			"sessions":    1,
+			"minutes":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "month", Value: 1}, {Key: "school_name", Value: 1}, {Key: "user_name", Value: 1}}}},
+	}
+
+	cursor, err := AttendanceCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rows := []TeachingHoursRow{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Hours = float64(rows[i].Minutes) / 60
+	}
+	return rows, nil
+}
+
+// CountUnlinkedSchoolClassMongo counts School Class attendance in the range that has no school_id
+// and is therefore missing from the teaching hours report
+func CountUnlinkedSchoolClassMongo(from, to string) (int64, error) {
+	ctx := context.Background()
+	filter := bson.M{
+		"status":                  "present",
+		"activity_category_codes": "school_class",
+		"school_id":               bson.M{"$in": bson.A{nil, ""}},
+	}
+	dateRange := bson.M{}
+	if from != "" {
+		dateRange["$gte"] = from
+	}
+	if to != "" {
+		dateRange["$lte"] = to
+	}
+	if len(dateRange) > 0 {
+		filter["date"] = dateRange
+	}
+	return AttendanceCollection().CountDocuments(ctx, filter)
+}
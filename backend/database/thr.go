@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// THRHoliday maps a religion (as entered in UserMongo.Religion, case-insensitive) to its holiday
+type THRHoliday struct {
+	Religion string `bson:"religion" json:"religion"`
+	Holiday  string `bson:"holiday" json:"holiday"`
+}
+
+type THRSettingsMongo struct {
+	Holidays []THRHoliday `bson:"holidays" json:"holidays"`
+	// DefaultHoliday applies to employees whose religion is empty or not listed
+	DefaultHoliday string `bson:"default_holiday" json:"default_holiday"`
+	// PayDaysBefore schedules payment this many days before the holiday (the law requires at least 7)
+	PayDaysBefore int `bson:"pay_days_before" json:"pay_days_before"`
+	// IncludeAllowances adds the salary structure allowances to base pay as the THR wage
+	IncludeAllowances bool   `bson:"include_allowances" json:"include_allowances"`
+	UpdatedAt         string `bson:"updated_at" json:"updated_at"`
+	UpdatedBy         string `bson:"updated_by" json:"updated_by"`
+}
+
+// THREntry is one employee's line on a schedule's review sheet
+type THREntry struct {
+	UserID        string `bson:"user_id" json:"user_id"`
+	UserName      string `bson:"user_name" json:"user_name"`
+	Religion      string `bson:"religion" json:"religion"`
+	HireDate      string `bson:"hire_date" json:"hire_date"`
+	ServiceMonths int    `bson:"service_months" json:"service_months"`
+	StructureID   string `bson:"structure_id" json:"structure_id"`
+	Wage          int64  `bson:"wage" json:"wage"`
+	Computed      int64  `bson:"computed" json:"computed"`
+	Amount        int64  `bson:"amount" json:"amount"`
+	Overridden    bool   `bson:"overridden" json:"overridden"`
+	Note          string `bson:"note" json:"note"`
+}
+
+// THRScheduleMongo pays THR for one holiday of a year to the employees of the religions that
+// celebrate it. Approved schedules are paid through the payroll run of Period.
+type THRScheduleMongo struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Year        int                `bson:"year" json:"year"`
+	Holiday     string             `bson:"holiday" json:"holiday"`
+	HolidayDate string             `bson:"holiday_date" json:"holiday_date"`
+	PayDate     string             `bson:"pay_date" json:"pay_date"`
+	Period      string             `bson:"period" json:"period"` // YYYY-MM of PayDate
+	Status      string             `bson:"status" json:"status"` // draft, approved
+	Entries     []THREntry         `bson:"entries" json:"entries"`
+	Total       int64              `bson:"total" json:"total"`
+	Notes       string             `bson:"notes" json:"notes"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   string             `bson:"created_at" json:"created_at"`
+	ComputedAt  string             `bson:"computed_at" json:"computed_at"`
+	ApprovedBy  string             `bson:"approved_by,omitempty" json:"approved_by,omitempty"`
+	ApprovedAt  string             `bson:"approved_at,omitempty" json:"approved_at,omitempty"`
+}
+
+// --- THR Settings ---
+
+func DefaultTHRSettings() THRSettingsMongo {
+	return THRSettingsMongo{
+		Holidays: []THRHoliday{
+			{Religion: "Islam", Holiday: "Idul Fitri"},
+			{Religion: "Kristen", Holiday: "Natal"},
+			{Religion: "Katolik", Holiday: "Natal"},
+			{Religion: "Hindu", Holiday: "Nyepi"},
+			{Religion: "Buddha", Holiday: "Waisak"},
+			{Religion: "Konghucu", Holiday: "Imlek"},
+		},
+		DefaultHoliday:    "Idul Fitri",
+		PayDaysBefore:     7,
+		IncludeAllowances: true,
+	}
+}
+
+func GetTHRSettingsMongo() (*THRSettingsMongo, error) {
+	ctx := context.Background()
+	var settings THRSettingsMongo
+	err := SettingsCollection().FindOne(ctx, bson.M{"key": "thr"}).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		defaults := DefaultTHRSettings()
+		return &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func UpdateTHRSettingsMongo(settings THRSettingsMongo) error {
+	ctx := context.Background()
+	opts := options.Update().SetUpsert(true)
+	_, err := SettingsCollection().UpdateOne(ctx,
+		bson.M{"key": "thr"},
+		bson.M{"$set": settings},
+		opts)
+	return err
+}
+
+// --- THR Schedules ---
+
+// GetTHRSchedulesMongo lists schedules without their entries, optionally for one year
+func GetTHRSchedulesMongo(year int) ([]THRScheduleMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if year > 0 {
+		filter["year"] = year
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "holiday_date", Value: -1}}).
+		SetProjection(bson.M{"entries": 0})
+	cursor, err := THRSchedulesCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	schedules := []THRScheduleMongo{}
+	if err = cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func GetTHRScheduleByIDMongo(id string) (*THRScheduleMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var schedule THRScheduleMongo
+	if err := THRSchedulesCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetTHRScheduleMongo finds the schedule of a holiday in a year, or nil when there is none
+func GetTHRScheduleMongo(year int, holiday string) (*THRScheduleMongo, error) {
+	ctx := context.Background()
+	var schedule THRScheduleMongo
+	err := THRSchedulesCollection().FindOne(ctx, bson.M{"year": year, "holiday": holiday}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// GetApprovedTHRSchedulesByPeriodMongo returns the schedules payroll pays in a period
+func GetApprovedTHRSchedulesByPeriodMongo(period string) ([]THRScheduleMongo, error) {
+	ctx := context.Background()
+	cursor, err := THRSchedulesCollection().Find(ctx, bson.M{"period": period, "status": "approved"})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	schedules := []THRScheduleMongo{}
+	if err = cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func CreateTHRScheduleMongo(schedule THRScheduleMongo) (*THRScheduleMongo, error) {
+	ctx := context.Background()
+	result, err := THRSchedulesCollection().InsertOne(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = result.InsertedID.(primitive.ObjectID)
+	return &schedule, nil
+}
+
+// UpdateTHRScheduleMongo applies set only while the schedule still has fromStatus
+func UpdateTHRScheduleMongo(id, fromStatus string, set bson.M) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := THRSchedulesCollection().UpdateOne(ctx, bson.M{"_id": objID, "status": fromStatus}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func DeleteTHRScheduleMongo(id string) error {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = THRSchedulesCollection().DeleteOne(ctx, bson.M{"_id": objID, "status": "draft"})
+	return err
+}
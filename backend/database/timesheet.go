@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TimesheetDay is one calendar day of a monthly timesheet
+type TimesheetDay struct {
+	Date          string   `bson:"date" json:"date"`
+	DayType       string   `bson:"day_type" json:"day_type"` // weekday, weekend, holiday
+	Holiday       string   `bson:"holiday,omitempty" json:"holiday,omitempty"`
+	Status        string   `bson:"status" json:"status"` // present, ijin, sakit, holiday, weekend, absent, upcoming
+	LeaveType     string   `bson:"leave_type,omitempty" json:"leave_type,omitempty"`
+	Minutes       int      `bson:"minutes" json:"minutes"`
+	Sessions      int      `bson:"sessions" json:"sessions"`
+	SchoolClasses int      `bson:"school_classes" json:"school_classes"`
+	Activities    []string `bson:"activities" json:"activities"`
+}
+
+type TimesheetTotals struct {
+	PresentDays   int `bson:"present_days" json:"present_days"`
+	LeaveDays     int `bson:"leave_days" json:"leave_days"`
+	SickDays      int `bson:"sick_days" json:"sick_days"`
+	AbsentDays    int `bson:"absent_days" json:"absent_days"`
+	HolidayDays   int `bson:"holiday_days" json:"holiday_days"`
+	Minutes       int `bson:"minutes" json:"minutes"`
+	Sessions      int `bson:"sessions" json:"sessions"`
+	SchoolClasses int `bson:"school_classes" json:"school_classes"`
+}
+
+// TimesheetMongo is a user's monthly timesheet. It is a snapshot taken on submission;
+// while submitted or approved the period is locked against attendance edits.
+type TimesheetMongo struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       string             `bson:"user_id" json:"user_id"`
+	UserName     string             `bson:"user_name" json:"user_name"`
+	Period       string             `bson:"period" json:"period"` // YYYY-MM
+	Status       string             `bson:"status" json:"status"` // draft, submitted, approved, rejected
+	Days         []TimesheetDay     `bson:"days" json:"days"`
+	Totals       TimesheetTotals    `bson:"totals" json:"totals"`
+	SubmittedAt  string             `bson:"submitted_at" json:"submitted_at"`
+	SignedBy     string             `bson:"signed_by" json:"signed_by"`
+	SignedAt     string             `bson:"signed_at" json:"signed_at"`
+	RejectReason string             `bson:"reject_reason" json:"reject_reason"`
+}
+
+// GetTimesheetMongo returns the stored timesheet for a user and period, or nil if none was submitted
+func GetTimesheetMongo(userID, period string) (*TimesheetMongo, error) {
+	ctx := context.Background()
+	var ts TimesheetMongo
+	err := TimesheetsCollection().FindOne(ctx, bson.M{"user_id": userID, "period": period}).Decode(&ts)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+func GetTimesheetByIDMongo(id string) (*TimesheetMongo, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	var ts TimesheetMongo
+	err = TimesheetsCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&ts)
+	if err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// GetTimesheetsMongo lists stored timesheets, optionally filtered by period and status
+func GetTimesheetsMongo(period, status string) ([]TimesheetMongo, error) {
+	ctx := context.Background()
+	filter := bson.M{}
+	if period != "" {
+		filter["period"] = period
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	opts := options.Find().SetProjection(bson.M{"days": 0}).SetSort(bson.D{{Key: "period", Value: -1}, {Key: "user_name", Value: 1}})
+	cursor, err := TimesheetsCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var timesheets []TimesheetMongo
+	if err = cursor.All(ctx, &timesheets); err != nil {
+		return nil, err
+	}
+	return timesheets, nil
+}
+
+// SaveTimesheetMongo upserts the timesheet for its user and period
+func SaveTimesheetMongo(ts TimesheetMongo) (*TimesheetMongo, error) {
+	ctx := context.Background()
+	ts.ID = primitive.NilObjectID
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved TimesheetMongo
+	err := TimesheetsCollection().FindOneAndUpdate(ctx,
+		bson.M{"user_id": ts.UserID, "period": ts.Period},
+		bson.M{"$set": ts},
+		opts).Decode(&saved)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// UpdateTimesheetStatusMongo applies fields only while the timesheet has one of the from statuses
+func UpdateTimesheetStatusMongo(id string, from []string, fields bson.M) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	result, err := TimesheetsCollection().UpdateOne(ctx, bson.M{"_id": objID, "status": bson.M{"$in": from}}, bson.M{"$set": fields})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// IsPeriodLockedMongo reports whether the month containing date (YYYY-MM-DD) has a
+// submitted or signed-off timesheet for the user, or has been paid out by a finalized payroll run
+func IsPeriodLockedMongo(userID, date string) (bool, error) {
+	if len(date) < 7 {
+		return false, nil
+	}
+	ctx := context.Background()
+	count, err := TimesheetsCollection().CountDocuments(ctx, bson.M{
+		"user_id": userID,
+		"period":  date[:7],
+		"status":  bson.M{"$in": []string{"submitted", "approved"}},
+	})
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	count, err = PayrollRunsCollection().CountDocuments(ctx, bson.M{"period": date[:7], "status": "finalized"})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetApprovedWorkPermitsByUserAndPeriod returns approved leave for the user between two dates (inclusive)
+func GetApprovedWorkPermitsByUserAndPeriod(userID, from, to string) ([]WorkPermitMongo, error) {
+	ctx := context.Background()
+	cursor, err := WorkPermitsCollection().Find(ctx, bson.M{
+		"user_id": userID,
+		"status":  "approved",
+		"date":    bson.M{"$gte": from, "$lte": to},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var permits []WorkPermitMongo
+	if err = cursor.All(ctx, &permits); err != nil {
+		return nil, err
+	}
+	return permits, nil
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// statusFilter matches users in any of the given statuses; "active" also matches users saved
+// before statuses existed
+func statusFilter(statuses ...string) bson.M {
+	in := bson.A{}
+	for _, s := range statuses {
+		in = append(in, s)
+		if s == "active" {
+			in = append(in, "", nil)
+		}
+	}
+	return bson.M{"$in": in}
+}
+
+// UserIsActive reports whether a status allows logging in
+func UserIsActive(status string) bool {
+	return status == "" || status == "active"
+}
+
+// GetUserStatusMongo returns a user's lifecycle status
+func GetUserStatusMongo(id string) (string, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return "", err
+	}
+	var user UserMongo
+	opts := options.FindOne().SetProjection(bson.M{"status": 1})
+	if err := UsersCollection().FindOne(ctx, bson.M{"_id": objID}, opts).Decode(&user); err != nil {
+		return "", err
+	}
+	return user.Status, nil
+}
+
+// SetUserStatusMongo moves a user from one of the from statuses to status. Deleting stamps who
+// deleted the user and when; reactivating clears the reason and that stamp. Returns false if the
+// user was not in a from status.
+func SetUserStatusMongo(id string, from []string, status, reason, by, now string) (bool, error) {
+	ctx := context.Background()
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	update := bson.M{}
+	switch status {
+	case "active":
+		update["$set"] = bson.M{"status": "active"}
+		update["$unset"] = bson.M{"status_reason": "", "deleted_at": "", "deleted_by": ""}
+	case "inactive":
+		update["$set"] = bson.M{"status": "inactive", "status_reason": reason}
+	case "deleted":
+		update["$set"] = bson.M{"status": "deleted", "status_reason": reason, "deleted_at": now, "deleted_by": by}
+	default:
+		return false, fmt.Errorf("unknown user status %q", status)
+	}
+	result, err := UsersCollection().UpdateOne(ctx, bson.M{"_id": objID, "status": statusFilter(from...)}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// PurgeUserMongo permanently removes a deleted user and their records. Payslips, claims and THR
+// entries are kept.
+func PurgeUserMongo(id string) (int64, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return 0, err
+	}
+	users, err := findUsersForPurge(bson.M{"_id": objID, "status": "deleted"})
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("user is not deleted")
+	}
+	return purgeUsers(users)
+}
@@ -0,0 +1,110 @@
+// Package export writes tabular reports as CSV, XLSX or PDF, and reads CSV/XLSX uploads,
+// without third-party dependencies.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RowWriter receives report rows one at a time. CSV and XLSX stream rows straight to the
+// underlying writer; PDF buffers them and lays out pages on Close.
+type RowWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// Document describes the report header and footer used by formats that support them
+type Document struct {
+	Title      string
+	Subtitle   string
+	Headers    []string
+	Signatures []string // labels for signature lines, e.g. "Dibuat oleh", "Disetujui oleh"
+}
+
+// Company returns the company name and address printed on report headers
+func Company() (string, string) {
+	name := os.Getenv("COMPANY_NAME")
+	if name == "" {
+		name = "HR System"
+	}
+	return name, os.Getenv("COMPANY_ADDRESS")
+}
+
+// ContentType returns the MIME type and file extension for a format
+func ContentType(format string) (string, string, error) {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8", "csv", nil
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "xlsx", nil
+	case "pdf":
+		return "application/pdf", "pdf", nil
+	}
+	return "", "", fmt.Errorf("unsupported export format %q (use csv, xlsx or pdf)", format)
+}
+
+// NewWriter returns a RowWriter for the format. The document headers are written immediately.
+func NewWriter(w io.Writer, format string, doc Document) (RowWriter, error) {
+	switch format {
+	case "csv":
+		return NewCSVWriter(w, doc.Headers)
+	case "xlsx":
+		return NewXLSXWriter(w, doc.Title, doc.Headers)
+	case "pdf":
+		return NewPDFWriter(w, doc), nil
+	}
+	_, _, err := ContentType(format)
+	return nil, err
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer, headers []string) (RowWriter, error) {
+	// UTF-8 BOM so Excel opens names with non-ASCII characters correctly
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.WriteRow(headers); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(cells []string) error {
+	if err := cw.w.Write(cells); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Rupiah formats an amount as "Rp 1.234.567"
+func Rupiah(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatInt(amount, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + "Rp " + b.String()
+}
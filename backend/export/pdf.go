@@ -0,0 +1,325 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PDF is a minimal single-font PDF builder for printable reports. Coordinates are in
+// points measured from the top-left corner of the page.
+type PDF struct {
+	Width  float64
+	Height float64
+	Margin float64
+	// Y is the current vertical cursor used by the flowing helpers
+	Y     float64
+	pages []*bytes.Buffer
+	cur   *bytes.Buffer
+	// Password required to open the document; empty means unprotected
+	password string
+}
+
+// NewPDF creates an A4 document with one empty page
+func NewPDF(landscape bool) *PDF {
+	p := &PDF{Width: 595, Height: 842, Margin: 40}
+	if landscape {
+		p.Width, p.Height = p.Height, p.Width
+	}
+	p.AddPage()
+	return p
+}
+
+func (p *PDF) AddPage() {
+	p.cur = &bytes.Buffer{}
+	p.pages = append(p.pages, p.cur)
+	p.Y = p.Margin
+}
+
+// EnsureSpace starts a new page when fewer than h points remain above the bottom margin
+func (p *PDF) EnsureSpace(h float64) bool {
+	if p.Y+h > p.Height-p.Margin {
+		p.AddPage()
+		return true
+	}
+	return false
+}
+
+// Text draws s with its baseline at (x, y)
+func (p *PDF) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.cur, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(p.Height-y), pdfString(s))
+}
+
+// TextRight draws s so that it ends at x
+func (p *PDF) TextRight(x, y, size float64, bold bool, s string) {
+	p.Text(x-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+func (p *PDF) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(p.cur, "0.5 w %s %s m %s %s l S\n", num(x1), num(p.Height-y1), num(x2), num(p.Height-y2))
+}
+
+// Rect fills a rectangle with a gray level (0 black, 1 white)
+func (p *PDF) Rect(x, y, w, h, gray float64) {
+	fmt.Fprintf(p.cur, "%s g %s %s %s %s re f 0 g\n", num(gray), num(x), num(p.Height-y-h), num(w), num(h))
+}
+
+// Paragraph writes s at the cursor and advances it
+func (p *PDF) Paragraph(size float64, bold bool, s string) {
+	p.EnsureSpace(size * 1.5)
+	p.Y += size * 1.2
+	p.Text(p.Margin, p.Y, size, bold, s)
+	p.Y += size * 0.3
+}
+
+// Header prints the company letterhead followed by the document title
+func (p *PDF) Header(title, subtitle string) {
+	name, address := Company()
+	p.Paragraph(14, true, name)
+	if address != "" {
+		p.Paragraph(9, false, address)
+	}
+	p.Y += 4
+	p.Line(p.Margin, p.Y, p.Width-p.Margin, p.Y)
+	p.Y += 8
+	p.Paragraph(12, true, title)
+	if subtitle != "" {
+		p.Paragraph(9, false, subtitle)
+	}
+	p.Y += 8
+}
+
+// Signatures draws evenly spaced signature lines with their labels
+func (p *PDF) Signatures(labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+	p.EnsureSpace(90)
+	p.Y += 20
+	slot := (p.Width - 2*p.Margin) / float64(len(labels))
+	for i, label := range labels {
+		x := p.Margin + float64(i)*slot + 10
+		p.Text(x, p.Y, 9, false, label)
+		p.Line(x, p.Y+55, x+slot-30, p.Y+55)
+		p.Text(x, p.Y+67, 8, false, "Nama & Tanggal")
+	}
+	p.Y += 75
+}
+
+// Table draws rows with repeated headers on each page. Column widths are proportional
+// to content length; overlong cells are truncated.
+func (p *PDF) Table(headers []string, rows [][]string) {
+	const size, lineHeight = 8.0, 13.0
+	usable := p.Width - 2*p.Margin
+	widths := columnWidths(headers, rows, usable)
+
+	drawHeader := func() {
+		p.Rect(p.Margin, p.Y, usable, lineHeight, 0.88)
+		x := p.Margin
+		for i, h := range headers {
+			p.Text(x+2, p.Y+9.5, size, true, fitText(h, widths[i]-4, size, true))
+			x += widths[i]
+		}
+		p.Y += lineHeight
+	}
+
+	drawHeader()
+	for _, row := range rows {
+		if p.EnsureSpace(lineHeight) {
+			drawHeader()
+		}
+		x := p.Margin
+		for i := range headers {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			p.Text(x+2, p.Y+9.5, size, false, fitText(cell, widths[i]-4, size, false))
+			x += widths[i]
+		}
+		p.Y += lineHeight
+		p.Line(p.Margin, p.Y, p.Margin+usable, p.Y)
+	}
+	p.Y += 6
+}
+
+// KeyValues draws label/value pairs in two columns, used for document metadata blocks
+func (p *PDF) KeyValues(pairs [][2]string) {
+	const size = 9.0
+	for _, kv := range pairs {
+		p.EnsureSpace(14)
+		p.Y += 12
+		p.Text(p.Margin, p.Y, size, false, kv[0])
+		p.Text(p.Margin+140, p.Y, size, false, ": "+kv[1])
+	}
+	p.Y += 6
+}
+
+// SetPassword makes readers ask for password before opening the document
+func (p *PDF) SetPassword(password string) {
+	p.password = password
+}
+
+// Output stamps page numbers and writes the finished document
+func (p *PDF) Output(w io.Writer) error {
+	var enc *pdfEncryption
+	if p.password != "" {
+		var err error
+		if enc, err = newPDFEncryption(p.password); err != nil {
+			return err
+		}
+	}
+
+	total := len(p.pages)
+	for i, page := range p.pages {
+		p.cur = page
+		label := "Halaman " + strconv.Itoa(i+1) + " dari " + strconv.Itoa(total)
+		p.TextRight(p.Width-p.Margin, p.Height-p.Margin/2, 7, false, label)
+	}
+
+	var out bytes.Buffer
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+	kids := make([]string, total)
+	for i := range p.pages {
+		kids[i] = strconv.Itoa(5+2*i) + " 0 R"
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), total))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range p.pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(p.Width), num(p.Height), 6+2*i))
+		content := page.Bytes()
+		if enc != nil {
+			content = enc.encrypt(len(offsets)+1, content)
+		}
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	trailer := fmt.Sprintf("/Size %d /Root 1 0 R", len(offsets)+1)
+	if enc != nil {
+		obj(enc.dictionary())
+		trailer = fmt.Sprintf("/Size %d /Root 1 0 R /Encrypt %d 0 R %s", len(offsets)+1, len(offsets), enc.trailerID())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< %s >>\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// TextWidth approximates the rendered width of s in Helvetica
+func TextWidth(s string, size float64, bold bool) float64 {
+	factor := 0.5
+	if bold {
+		factor = 0.55
+	}
+	return float64(len([]rune(s))) * size * factor
+}
+
+func fitText(s string, width, size float64, bold bool) string {
+	if TextWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && TextWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func columnWidths(headers []string, rows [][]string, usable float64) []float64 {
+	weights := make([]float64, len(headers))
+	for i, h := range headers {
+		weights[i] = float64(len([]rune(h)))
+	}
+	for _, row := range rows {
+		for i := 0; i < len(row) && i < len(headers); i++ {
+			if l := float64(len([]rune(row[i]))); l > weights[i] {
+				weights[i] = l
+			}
+		}
+	}
+	total := 0.0
+	for i := range weights {
+		// Keep very long free-text columns from starving the others
+		if weights[i] > 40 {
+			weights[i] = 40
+		}
+		if weights[i] < 4 {
+			weights[i] = 4
+		}
+		total += weights[i]
+	}
+	widths := make([]float64, len(weights))
+	for i, wgt := range weights {
+		widths[i] = usable * wgt / total
+	}
+	return widths
+}
+
+// pdfString encodes s for a literal string in WinAnsiEncoding, replacing unsupported runes
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// pdfReportWriter buffers rows and renders a landscape table report on Close
+type pdfReportWriter struct {
+	w    io.Writer
+	doc  Document
+	rows [][]string
+}
+
+func NewPDFWriter(w io.Writer, doc Document) RowWriter {
+	return &pdfReportWriter{w: w, doc: doc}
+}
+
+func (pw *pdfReportWriter) WriteRow(cells []string) error {
+	pw.rows = append(pw.rows, append([]string(nil), cells...))
+	return nil
+}
+
+func (pw *pdfReportWriter) Close() error {
+	p := NewPDF(true)
+	p.Header(pw.doc.Title, pw.doc.Subtitle)
+	p.Table(pw.doc.Headers, pw.rows)
+	p.Signatures(pw.doc.Signatures)
+	return p.Output(pw.w)
+}
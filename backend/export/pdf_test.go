@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// checkXref verifies that every xref entry points at the start of its object
+func checkXref(t *testing.T, doc []byte) int {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatalf("missing startxref trailer")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n0 ")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	lines := strings.Split(string(doc[xref:]), "\n")
+	count, _ := strconv.Atoi(strings.Fields(lines[1])[1])
+	for i := 1; i < count; i++ {
+		off, err := strconv.Atoi(lines[2+i][:10])
+		if err != nil {
+			t.Fatalf("bad xref line %q", lines[2+i])
+		}
+		if want := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(doc[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i, doc[off:off+12])
+		}
+	}
+	return count - 1
+}
+
+func TestPDFWriterLayout(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPDFWriter(&buf, Document{
+		Title:      "Rekap Absensi",
+		Subtitle:   "Periode 2026-01-01 s/d 2026-01-31",
+		Headers:    []string{"Tanggal", "Nama"},
+		Signatures: []string{"Dibuat oleh"},
+	})
+	// Enough rows to need a second landscape page
+	for i := 0; i < 60; i++ {
+		if err := w.WriteRow([]string{"2026-01-02", "Karyawan (" + strconv.Itoa(i) + ")"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.Bytes()
+
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header")
+	}
+	objects := checkXref(t, doc)
+	pages := bytes.Count(doc, []byte("/Type /Page /Parent"))
+	if pages < 2 {
+		t.Fatalf("expected the table to span pages, got %d", pages)
+	}
+	// Catalog, page tree and two fonts, then a page and a content stream per page
+	if objects != 4+2*pages {
+		t.Errorf("objects = %d, want %d", objects, 4+2*pages)
+	}
+	if !bytes.Contains(doc, []byte("/MediaBox [0 0 842.00 595.00]")) {
+		t.Errorf("report is not landscape A4")
+	}
+	for _, want := range []string{"(Rekap Absensi) Tj", "(Karyawan \\(59\\)) Tj", "(Dibuat oleh) Tj", fmt.Sprintf("(Halaman %d dari %d) Tj", pages, pages)} {
+		if !bytes.Contains(doc, []byte(want)) {
+			t.Errorf("document does not contain %q", want)
+		}
+	}
+	if bytes.Contains(doc, []byte("/Encrypt")) {
+		t.Errorf("unprotected document has an /Encrypt entry")
+	}
+}
+
+func TestPDFStreamLengths(t *testing.T) {
+	p := NewPDF(false)
+	p.Paragraph(10, false, "Slip Gaji")
+	var buf bytes.Buffer
+	if err := p.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	doc := buf.String()
+	re := regexp.MustCompile(`<< /Length (\d+) >>\nstream\n`)
+	for _, loc := range re.FindAllStringSubmatchIndex(doc, -1) {
+		n, _ := strconv.Atoi(doc[loc[2]:loc[3]])
+		if !strings.HasPrefix(doc[loc[1]+n:], "\nendstream") {
+			t.Fatalf("stream /Length %d does not end at endstream", n)
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	cases := map[string]string{
+		`a(b)c\d`:   `a\(b\)c\\d`,
+		"baris\nke": "baris ke",
+		"Rp 1.000":  "Rp 1.000",
+		"Müller":    `M\374ller`,
+		"日本":        "??",
+	}
+	for in, want := range cases {
+		if got := pdfString(in); got != want {
+			t.Errorf("pdfString(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFitText(t *testing.T) {
+	if got := fitText("pendek", 100, 8, false); got != "pendek" {
+		t.Errorf("fitText changed text that fits: %q", got)
+	}
+	long := strings.Repeat("x", 100)
+	got := fitText(long, 60, 8, false)
+	if !strings.HasSuffix(got, "...") || TextWidth(got, 8, false) > 60 {
+		t.Errorf("fitText(%d chars) = %q, width %.1f", len(long), got, TextWidth(got, 8, false))
+	}
+}
+
+func TestColumnWidthsFillUsableWidth(t *testing.T) {
+	widths := columnWidths([]string{"No", "Keterangan"}, [][]string{{"1", strings.Repeat("y", 200)}}, 500)
+	total := 0.0
+	for _, w := range widths {
+		total += w
+	}
+	if total < 499.99 || total > 500.01 {
+		t.Errorf("widths sum to %.2f, want 500", total)
+	}
+	// Long free text is capped at 40 and short columns are raised to 4
+	if want := 500 * 4.0 / 44; widths[0] < want-0.01 || widths[0] > want+0.01 {
+		t.Errorf("first column width = %.2f, want %.2f", widths[0], want)
+	}
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// passwordPadding pads passwords to 32 bytes as defined by the PDF standard security handler
+var passwordPadding = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+// pdfPermissions allows printing and copying once the document is opened
+const pdfPermissions int32 = -1836
+
+// pdfEncryption implements the standard security handler, revision 3 (128-bit RC4), which
+// every common reader can open
+type pdfEncryption struct {
+	key   []byte
+	owner []byte
+	user  []byte
+	id    []byte
+}
+
+func padPassword(password string) []byte {
+	return append([]byte(password), passwordPadding...)[:32]
+}
+
+func rc4Crypt(key, data []byte) []byte {
+	c, _ := rc4.NewCipher(key)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// rc4Rounds encrypts data with key and then 19 more times with key XORed with the round number
+func rc4Rounds(key, data []byte) []byte {
+	out := rc4Crypt(key, data)
+	round := make([]byte, len(key))
+	for i := 1; i <= 19; i++ {
+		for j := range key {
+			round[j] = key[j] ^ byte(i)
+		}
+		out = rc4Crypt(round, out)
+	}
+	return out
+}
+
+func newPDFEncryption(userPassword string) (*pdfEncryption, error) {
+	e := &pdfEncryption{id: make([]byte, 16)}
+	if _, err := rand.Read(e.id); err != nil {
+		return nil, err
+	}
+	// Nobody needs the owner password, so it is random
+	ownerPassword := make([]byte, 16)
+	if _, err := rand.Read(ownerPassword); err != nil {
+		return nil, err
+	}
+
+	// O entry (algorithm 3)
+	h := md5.Sum(padPassword(hex.EncodeToString(ownerPassword)))
+	for i := 0; i < 50; i++ {
+		h = md5.Sum(h[:])
+	}
+	e.owner = rc4Rounds(h[:], padPassword(userPassword))
+
+	// File key (algorithm 2)
+	m := md5.New()
+	m.Write(padPassword(userPassword))
+	m.Write(e.owner)
+	perms := make([]byte, 4)
+	p := pdfPermissions
+	binary.LittleEndian.PutUint32(perms, uint32(p))
+	m.Write(perms)
+	m.Write(e.id)
+	key := m.Sum(nil)
+	for i := 0; i < 50; i++ {
+		sum := md5.Sum(key[:16])
+		key = sum[:]
+	}
+	e.key = key[:16]
+
+	// U entry (algorithm 5)
+	u := md5.Sum(append(append([]byte{}, passwordPadding...), e.id...))
+	e.user = append(rc4Rounds(e.key, u[:]), make([]byte, 16)...)
+	return e, nil
+}
+
+// encrypt encrypts a stream or string belonging to object num, generation 0
+func (e *pdfEncryption) encrypt(num int, data []byte) []byte {
+	k := append(append([]byte{}, e.key...), byte(num), byte(num>>8), byte(num>>16), 0, 0)
+	objKey := md5.Sum(k)
+	return rc4Crypt(objKey[:], data)
+}
+
+func (e *pdfEncryption) dictionary() string {
+	return fmt.Sprintf("<< /Filter /Standard /V 2 /R 3 /Length 128 /P %d /O <%x> /U <%x> >>", pdfPermissions, e.owner, e.user)
+}
+
+func (e *pdfEncryption) trailerID() string {
+	return fmt.Sprintf("/ID [<%x> <%x>]", e.id, e.id)
+}
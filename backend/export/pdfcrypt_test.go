@@ -0,0 +1,157 @@
+package export
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// readerKey derives the file key from a user password the way a PDF reader does (algorithm 2,
+// revision 3) and reports whether the password opens the document (algorithm 6)
+func readerKey(t *testing.T, password string, owner, user, id []byte) ([]byte, bool) {
+	t.Helper()
+	pad := append([]byte(password), passwordPadding...)[:32]
+	perms := make([]byte, 4)
+	p := pdfPermissions
+	binary.LittleEndian.PutUint32(perms, uint32(p))
+
+	h := md5.New()
+	h.Write(pad)
+	h.Write(owner)
+	h.Write(perms)
+	h.Write(id)
+	key := h.Sum(nil)
+	for i := 0; i < 50; i++ {
+		sum := md5.Sum(key[:16])
+		key = sum[:]
+	}
+	key = key[:16]
+
+	sum := md5.Sum(append(append([]byte{}, passwordPadding...), id...))
+	check := sum[:]
+	for i := 0; i <= 19; i++ {
+		round := make([]byte, len(key))
+		for j := range key {
+			round[j] = key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(round)
+		c.XORKeyStream(check, check)
+	}
+	return key, bytes.Equal(check, user[:16])
+}
+
+// encryptedDocument renders a one-page document protected with password
+func encryptedDocument(t *testing.T, password string) []byte {
+	t.Helper()
+	pdf := NewPDF(false)
+	pdf.Header("Slip Gaji", "Periode 2026-01")
+	pdf.Paragraph(9, false, "Gaji Bersih Rp 7.500.000")
+	pdf.SetPassword(password)
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func hexField(t *testing.T, doc []byte, pattern string) []byte {
+	t.Helper()
+	m := regexp.MustCompile(pattern).FindSubmatch(doc)
+	if m == nil {
+		t.Fatalf("%s not found", pattern)
+	}
+	b, err := hex.DecodeString(string(m[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestPDFEncryptionOpensWithUserPassword(t *testing.T) {
+	doc := encryptedDocument(t, "17081990")
+	checkXref(t, doc)
+	if !bytes.Contains(doc, []byte("/Filter /Standard /V 2 /R 3 /Length 128")) {
+		t.Fatal("missing revision 3 encryption dictionary")
+	}
+	if bytes.Contains(doc, []byte("Gaji Bersih")) {
+		t.Fatal("page content is stored in the clear")
+	}
+
+	owner := hexField(t, doc, `/O <([0-9a-f]+)>`)
+	user := hexField(t, doc, `/U <([0-9a-f]+)>`)
+	id := hexField(t, doc, `/ID \[<([0-9a-f]+)>`)
+	if len(owner) != 32 || len(user) != 32 || len(id) != 16 {
+		t.Fatalf("O, U and ID are %d, %d and %d bytes", len(owner), len(user), len(id))
+	}
+
+	if _, ok := readerKey(t, "01011990", owner, user, id); ok {
+		t.Fatal("wrong password opened the document")
+	}
+	key, ok := readerKey(t, "17081990", owner, user, id)
+	if !ok {
+		t.Fatal("user password does not open the document")
+	}
+
+	// The first page's content stream is object 6
+	m := regexp.MustCompile(`(?s)\n6 0 obj\n<< /Length (\d+) >>\nstream\n`).FindSubmatchIndex(doc)
+	if m == nil {
+		t.Fatal("page content stream not found")
+	}
+	length, _ := strconv.Atoi(string(doc[m[2]:m[3]]))
+	stream := doc[m[1] : m[1]+length]
+	objKey := md5.Sum(append(append([]byte{}, key...), 6, 0, 0, 0, 0))
+	c, _ := rc4.NewCipher(objKey[:])
+	plain := make([]byte, len(stream))
+	c.XORKeyStream(plain, stream)
+	if !bytes.Contains(plain, []byte("Gaji Bersih Rp 7.500.000")) {
+		t.Errorf("decrypted stream does not contain the page text: %q", plain)
+	}
+}
+
+func TestPDFEncryptionIsFreshPerDocument(t *testing.T) {
+	a := encryptedDocument(t, "17081990")
+	b := encryptedDocument(t, "17081990")
+	if bytes.Equal(hexField(t, a, `/ID \[<([0-9a-f]+)>`), hexField(t, b, `/ID \[<([0-9a-f]+)>`)) {
+		t.Error("two documents share a file ID")
+	}
+	if bytes.Equal(hexField(t, a, `/O <([0-9a-f]+)>`), hexField(t, b, `/O <([0-9a-f]+)>`)) {
+		t.Error("two documents share an owner entry")
+	}
+}
+
+func TestPDFObjectEncryption(t *testing.T) {
+	e, err := newPDFEncryption("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("BT /F1 9 Tf 40 800 Td (Rahasia) Tj ET")
+	sealed := e.encrypt(6, data)
+	if bytes.Equal(sealed, data) {
+		t.Fatal("encrypt returned the plaintext")
+	}
+	if bytes.Equal(sealed, e.encrypt(8, data)) {
+		t.Error("different objects share a key stream")
+	}
+	if got := e.encrypt(6, sealed); !bytes.Equal(got, data) {
+		t.Errorf("RC4 round trip = %q, want %q", got, data)
+	}
+}
+
+func TestPadPassword(t *testing.T) {
+	if got := padPassword(""); !bytes.Equal(got, passwordPadding) {
+		t.Errorf("empty password pads to %x", got)
+	}
+	got := padPassword("17081990")
+	if len(got) != 32 || string(got[:8]) != "17081990" || !bytes.Equal(got[8:], passwordPadding[:24]) {
+		t.Errorf("padPassword = %x", got)
+	}
+	long := padPassword("0123456789012345678901234567890123456789")
+	if string(long) != "01234567890123456789012345678901" {
+		t.Errorf("long password not truncated to 32 bytes: %q", long)
+	}
+}
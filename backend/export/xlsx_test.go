@@ -0,0 +1,143 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestXLSXRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSXWriter(&buf, "Rekap: Absensi/2026", []string{"Nama", "Jam", "Rekening"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{
+		{"Budi <Santoso> & Co", "7.50", "0123456789"},
+		{"Siti", "-12", ""},
+	}
+	for _, r := range rows {
+		if err := w.WriteRow(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadTable(bytes.NewReader(buf.Bytes()), "rekap.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Nama", "Jam", "Rekening"},
+		{"Budi <Santoso> & Co", "7.50", "0123456789"},
+		{"Siti", "-12", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadTable = %q, want %q", got, want)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(body)
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Rekap Absensi2026"`) {
+		t.Errorf("sheet name not sanitized: %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet, `<c r="B2"><v>7.50</v></c>`) {
+		t.Errorf("plain number not stored as a number: %s", sheet)
+	}
+	if !strings.Contains(sheet, `<c r="C2" t="inlineStr">`) {
+		t.Errorf("number with a leading zero not stored as text: %s", sheet)
+	}
+	if !strings.Contains(sheet, `<c r="A1" t="inlineStr" s="1">`) {
+		t.Errorf("header row not bold: %s", sheet)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+		if got := columnIndex(want + "1"); got != i {
+			t.Errorf("columnIndex(%q) = %d, want %d", want+"1", got, i)
+		}
+	}
+}
+
+func TestIsPlainNumber(t *testing.T) {
+	cases := map[string]bool{
+		"0": true, "12": true, "-3": true, "0.5": true, "1234.56": true,
+		"": false, "007": false, "1e5": false, "+1": false, "12a": false,
+		"3201234567890123": false, // NIK, longer than a float keeps exactly
+	}
+	for in, want := range cases {
+		if got := isPlainNumber(in); got != want {
+			t.Errorf("isPlainNumber(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestSheetTitle(t *testing.T) {
+	if got := sheetTitle("[]:*?/\\"); got != "Sheet1" {
+		t.Errorf("sheetTitle of only invalid characters = %q, want Sheet1", got)
+	}
+	if got := sheetTitle(strings.Repeat("é", 40)); got != strings.Repeat("é", 31) {
+		t.Errorf("sheetTitle did not trim to 31 characters: %q", got)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "csv", Document{Headers: []string{"Nama", "Catatan"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]string{"Ani", "pagi, siang"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\xEF\xBB\xBFNama,Catatan\nAni,\"pagi, siang\"\n"; buf.String() != want {
+		t.Fatalf("csv = %q, want %q", buf.String(), want)
+	}
+	got, err := ReadTable(&buf, "x.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"Nama", "Catatan"}, {"Ani", "pagi, siang"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadTable = %q, want %q", got, want)
+	}
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(io.Discard, "docx", Document{}); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestRupiah(t *testing.T) {
+	cases := map[int64]string{0: "Rp 0", 999: "Rp 999", 1000: "Rp 1.000", 1234567: "Rp 1.234.567", -50000: "-Rp 50.000"}
+	for in, want := range cases {
+		if got := Rupiah(in); got != want {
+			t.Errorf("Rupiah(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var activityCodePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// GetActivityTaxonomyMongo returns the active activity types and categories for the attendance form
+func GetActivityTaxonomyMongo(c *gin.Context) {
+	types, err := database.GetActivityTypesMongo(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	categories, err := database.GetActivityCategoriesMongo(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"types": types, "categories": categories})
+}
+
+// --- Activity Type Admin Handlers ---
+
+func GetActivityTypesMongo(c *gin.Context) {
+	types, err := database.GetActivityTypesMongo(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, types)
+}
+
+func CreateActivityTypeMongo(c *gin.Context) {
+	var input struct {
+		Code      string `json:"code" binding:"required"`
+		Label     string `json:"label" binding:"required"`
+		Active    *bool  `json:"active"`
+		SortOrder int    `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !activityCodePattern.MatchString(input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code must contain only lowercase letters, digits and underscores"})
+		return
+	}
+
+	created, err := database.CreateActivityTypeMongo(database.ActivityTypeMongo{
+		Code:      input.Code,
+		Label:     strings.TrimSpace(input.Label),
+		Active:    input.Active == nil || *input.Active,
+		SortOrder: input.SortOrder,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Activity type code already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateActivityTypeMongo(c *gin.Context) {
+	id := c.Param("id")
+
+	var input struct {
+		Label     string `json:"label" binding:"required"`
+		Active    bool   `json:"active"`
+		SortOrder int    `json:"sort_order"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := database.GetActivityTypeByIDMongo(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity type not found"})
+		return
+	}
+	err := database.UpdateActivityTypeMongo(id, database.ActivityTypeMongo{
+		Label:     strings.TrimSpace(input.Label),
+		Active:    input.Active,
+		SortOrder: input.SortOrder,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Activity type updated"})
+}
+
+// DeleteActivityTypeMongo removes an unused type; types already used by attendance can only be deactivated
+func DeleteActivityTypeMongo(c *gin.Context) {
+	id := c.Param("id")
+	t, err := database.GetActivityTypeByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity type not found"})
+		return
+	}
+
+	used, err := database.CountAttendanceByActivityCode("activity_type_code", t.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Activity type is used by attendance records; deactivate it instead"})
+		return
+	}
+
+	if err := database.DeleteActivityTypeMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Activity type deleted"})
+}
+
+// --- Activity Category Admin Handlers ---
+
+type activityCategoryInput struct {
+	Label             string   `json:"label" binding:"required"`
+	TypeCodes         []string `json:"type_codes"`
+	Active            *bool    `json:"active"`
+	RequiresSchool    bool     `json:"requires_school"`
+	RequiresTimeRange bool     `json:"requires_time_range"`
+	SortOrder         int      `json:"sort_order"`
+}
+
+// categoryFromInput checks that the referenced type codes exist
+func categoryFromInput(c *gin.Context, input activityCategoryInput) (database.ActivityCategoryMongo, bool) {
+	types, err := database.GetActivityTypesMongo(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return database.ActivityCategoryMongo{}, false
+	}
+	known := make(map[string]bool)
+	for _, t := range types {
+		known[t.Code] = true
+	}
+	if input.TypeCodes == nil {
+		input.TypeCodes = []string{}
+	}
+	for _, code := range input.TypeCodes {
+		if !known[code] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown activity type code: " + code})
+			return database.ActivityCategoryMongo{}, false
+		}
+	}
+
+	return database.ActivityCategoryMongo{
+		Label:             strings.TrimSpace(input.Label),
+		TypeCodes:         input.TypeCodes,
+		Active:            input.Active == nil || *input.Active,
+		RequiresSchool:    input.RequiresSchool,
+		RequiresTimeRange: input.RequiresTimeRange,
+		SortOrder:         input.SortOrder,
+	}, true
+}
+
+func GetActivityCategoriesMongo(c *gin.Context) {
+	categories, err := database.GetActivityCategoriesMongo(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func CreateActivityCategoryMongo(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+		activityCategoryInput
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !activityCodePattern.MatchString(input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code must contain only lowercase letters, digits and underscores"})
+		return
+	}
+
+	category, ok := categoryFromInput(c, input.activityCategoryInput)
+	if !ok {
+		return
+	}
+	category.Code = input.Code
+	category.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	created, err := database.CreateActivityCategoryMongo(category)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Activity category code already exists"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateActivityCategoryMongo(c *gin.Context) {
+	id := c.Param("id")
+
+	var input activityCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := database.GetActivityCategoryByIDMongo(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity category not found"})
+		return
+	}
+
+	category, ok := categoryFromInput(c, input)
+	if !ok {
+		return
+	}
+	if err := database.UpdateActivityCategoryMongo(id, category); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Activity category updated"})
+}
+
+// DeleteActivityCategoryMongo removes an unused category; used categories can only be deactivated
+func DeleteActivityCategoryMongo(c *gin.Context) {
+	id := c.Param("id")
+	category, err := database.GetActivityCategoryByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Activity category not found"})
+		return
+	}
+
+	used, err := database.CountAttendanceByActivityCode("activity_category_codes", category.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Activity category is used by attendance records; deactivate it instead"})
+		return
+	}
+
+	if err := database.DeleteActivityCategoryMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Activity category deleted"})
+}
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Import-only rules in addition to the attendance rules
+const (
+	RuleRequired    = "required"
+	RuleUnknownUser = "unknown_user"
+)
+
+const maxImportRows = 5000
+
+// Paper sheets are always entered after the fact, so these rules only warn during an import.
+// The warnings are recorded on the entry as overrides by the importing admin.
+var importWarningRules = map[string]bool{
+	RuleMaxBackdate:  true,
+	RuleWorkingHours: true,
+}
+
+// importColumns maps accepted header spellings to attendance fields
+var importColumns = map[string]string{
+	"email":               "email",
+	"date":                "date",
+	"tanggal":             "date",
+	"session":             "session",
+	"sesi":                "session",
+	"activity_type":       "activity_type",
+	"type":                "activity_type",
+	"activity_categories": "activity_categories",
+	"categories":          "activity_categories",
+	"category":            "activity_categories",
+	"starting_time":       "starting_time",
+	"start_time":          "starting_time",
+	"start":               "starting_time",
+	"ending_time":         "ending_time",
+	"end_time":            "ending_time",
+	"end":                 "ending_time",
+	"activity_details":    "activity_details",
+	"details":             "activity_details",
+	"activity_notes":      "activity_notes",
+	"notes":               "activity_notes",
+	"school_id":           "school",
+	"school":              "school",
+	"sekolah":             "school",
+}
+
+// ImportRowResult is the dry-run outcome of one spreadsheet row
+type ImportRowResult struct {
+	Row          int                    `json:"row"` // spreadsheet row number, header is row 1
+	Email        string                 `json:"email"`
+	Date         string                 `json:"date"`
+	ActivityType string                 `json:"activity_type"`
+	Status       string                 `json:"status"` // ok, warning, error
+	Errors       []AttendanceFieldError `json:"errors,omitempty"`
+	Warnings     []AttendanceFieldError `json:"warnings,omitempty"`
+}
+
+// Excel date serials accepted for 1990-01-01 through 2099-12-31; other numbers are not dates
+const (
+	minSheetDateSerial = 32874
+	maxSheetDateSerial = 73050
+)
+
+// normalizeSheetDate accepts YYYYMMDD, YYYY-MM-DD, DD/MM/YYYY and Excel date serials
+func normalizeSheetDate(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) == 8 {
+		if t, err := time.Parse("20060102", value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		if serial < minSheetDateSerial || serial >= maxSheetDateSerial+1 {
+			return value
+		}
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(serial)).Format("2006-01-02")
+	}
+	for _, layout := range []string{"2006-01-02", "02/01/2006", "2/1/2006", "02-01-2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return value
+}
+
+// normalizeSheetTime accepts H:MM, HH.MM and Excel time fractions and returns HH:MM
+func normalizeSheetTime(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if fraction, err := strconv.ParseFloat(value, 64); err == nil && fraction >= 0 && fraction < 1 {
+		minutes := int(math.Round(fraction * 24 * 60))
+		return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+	}
+	value = strings.Replace(value, ".", ":", 1)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04")
+		}
+	}
+	return value
+}
+
+func splitCategories(value string) []string {
+	categories := []string{}
+	for _, c := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// ImportAttendanceMongo validates an uploaded CSV/XLSX of attendance rows. By default it only
+// returns a dry-run report; with confirm=true and no row errors it inserts every row as one batch.
+func ImportAttendanceMongo(c *gin.Context) {
+	adminID := c.MustGet("userID").(string)
+	confirm := c.PostForm("confirm") == "true" || c.Query("confirm") == "true"
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	table, err := export.ReadTable(file, header.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(table) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak berisi data"})
+		return
+	}
+	if len(table)-1 > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maksimal %d baris per import", maxImportRows)})
+		return
+	}
+
+	columns := make(map[string]int)
+	for i, h := range table[0] {
+		key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(h)), " ", "_")
+		if field, ok := importColumns[key]; ok {
+			columns[field] = i
+		}
+	}
+	for _, required := range []string{"email", "date", "activity_type"} {
+		if _, ok := columns[required]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required column: " + required})
+			return
+		}
+	}
+
+	rules, err := database.GetAttendanceRulesMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	users, err := database.GetAllUsersMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	userIDs := make(map[string]string)
+	for _, u := range users {
+		userIDs[strings.ToLower(u.Email)] = u.ID.Hex()
+	}
+	schools, err := database.GetSchoolsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// The school column may hold either the school ID or its name
+	schoolIDs := make(map[string]string)
+	for _, s := range schools {
+		schoolIDs[s.ID.Hex()] = s.ID.Hex()
+		schoolIDs[strings.ToLower(s.Name)] = s.ID.Hex()
+	}
+
+	// Preload what validation reads for every user and date in the file
+	lookupUsers := []string{}
+	seenUsers := make(map[string]bool)
+	from, to := "", ""
+	for _, row := range table[1:] {
+		if idx, ok := columns["email"]; ok && idx < len(row) {
+			if id, ok := userIDs[strings.ToLower(strings.TrimSpace(row[idx]))]; ok && !seenUsers[id] {
+				seenUsers[id] = true
+				lookupUsers = append(lookupUsers, id)
+			}
+		}
+		if idx := columns["date"]; idx < len(row) {
+			date := normalizeSheetDate(row[idx])
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				continue
+			}
+			if from == "" || date < from {
+				from = date
+			}
+			if date > to {
+				to = date
+			}
+		}
+	}
+	lookup, err := loadAttendanceLookup(lookupUsers, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var results []ImportRowResult
+	var records []database.AttendanceMongo
+	// Rows accepted so far, by user and date, so entries within the file are checked against each other
+	accepted := make(map[string][]database.AttendanceMongo)
+	errorCount, warningCount := 0, 0
+
+	for i, row := range table[1:] {
+		cell := func(field string) string {
+			if idx, ok := columns[field]; ok && idx < len(row) {
+				return strings.TrimSpace(row[idx])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+
+		email := strings.ToLower(cell("email"))
+		att := database.AttendanceMongo{
+			Date:               normalizeSheetDate(cell("date")),
+			ActivityType:       cell("activity_type"),
+			ActivityCategories: splitCategories(cell("activity_categories")),
+			ActivityDetails:    cell("activity_details"),
+			StartingTime:       normalizeSheetTime(cell("starting_time")),
+			EndingTime:         normalizeSheetTime(cell("ending_time")),
+			ActivityNotes:      cell("activity_notes"),
+			Session:            cell("session"),
+			Status:             "present",
+			CreatedAt:          now,
+		}
+		result := ImportRowResult{Row: i + 2, Email: email, Date: att.Date}
+
+		var fieldErrors []AttendanceFieldError
+		if email == "" {
+			fieldErrors = append(fieldErrors, newFieldError("email", RuleRequired, "Email wajib diisi"))
+		} else if id, ok := userIDs[email]; ok {
+			att.UserID = id
+		} else {
+			fieldErrors = append(fieldErrors, newFieldError("email", RuleUnknownUser, "Email tidak terdaftar"))
+		}
+		if school := cell("school"); school != "" {
+			if id, ok := schoolIDs[strings.ToLower(school)]; ok {
+				att.SchoolID = id
+			} else {
+				fieldErrors = append(fieldErrors, newFieldError("school_id", RuleSchoolRequired, "Sekolah tidak ditemukan: "+school))
+			}
+		}
+		if att.ActivityType == "" {
+			fieldErrors = append(fieldErrors, newFieldError("activity_type", RuleRequired, "Tipe aktivitas wajib diisi"))
+		}
+		if att.UserID != "" {
+			ruleErrors, err := validateAttendance(&att, *rules, lookup)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			fieldErrors = append(fieldErrors, ruleErrors...)
+			fieldErrors = append(fieldErrors, conflictErrors(att, accepted[att.UserID+"|"+att.Date], *rules)...)
+		}
+
+		result.ActivityType = att.ActivityType
+
+		seen := make(map[string]bool)
+		for _, e := range fieldErrors {
+			if !importWarningRules[e.Rule] {
+				result.Errors = append(result.Errors, e)
+				continue
+			}
+			result.Warnings = append(result.Warnings, e)
+			if !seen[e.Rule] {
+				seen[e.Rule] = true
+				att.RuleOverrides = append(att.RuleOverrides, database.AttendanceRuleOverride{
+					Rule:          e.Rule,
+					Justification: "Bulk import: " + header.Filename,
+					OverriddenBy:  adminID,
+					OverriddenAt:  now,
+				})
+			}
+		}
+
+		switch {
+		case len(result.Errors) > 0:
+			result.Status = "error"
+			errorCount++
+		case len(result.Warnings) > 0:
+			result.Status = "warning"
+			warningCount++
+		default:
+			result.Status = "ok"
+		}
+		if result.Status != "error" {
+			key := att.UserID + "|" + att.Date
+			accepted[key] = append(accepted[key], att)
+			records = append(records, att)
+		}
+		results = append(results, result)
+	}
+
+	report := gin.H{
+		"dry_run":       !confirm,
+		"file_name":     header.Filename,
+		"total_rows":    len(results),
+		"valid_rows":    len(records),
+		"error_count":   errorCount,
+		"warning_count": warningCount,
+		"rows":          results,
+	}
+
+	if !confirm {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if errorCount > 0 {
+		report["error"] = "Import dibatalkan: perbaiki baris yang error lalu unggah ulang"
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File tidak berisi data"})
+		return
+	}
+
+	batch, err := database.AddAttendanceImportMongo(database.AttendanceImportMongo{
+		FileName:     header.Filename,
+		ImportedBy:   adminID,
+		WarningCount: warningCount,
+		Status:       "imported",
+		CreatedAt:    now,
+	}, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	report["batch"] = batch
+	c.JSON(http.StatusCreated, report)
+}
+
+func GetAttendanceImportsMongo(c *gin.Context) {
+	batches, err := database.GetAttendanceImportsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, batches)
+}
+
+// RollbackAttendanceImportMongo deletes every entry created by an import batch.
+// Batches touching a locked timesheet period cannot be rolled back until it is reopened.
+func RollbackAttendanceImportMongo(c *gin.Context) {
+	id := c.Param("id")
+
+	batch, err := database.GetAttendanceImportByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import batch not found"})
+		return
+	}
+	if batch.Status == "rolled_back" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Import batch sudah di-rollback"})
+		return
+	}
+
+	records, err := database.GetAttendanceByImportBatch(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	checked := make(map[string]bool)
+	for _, att := range records {
+		period := att.Date
+		if len(period) > 7 {
+			period = period[:7]
+		}
+		key := att.UserID + "|" + period
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+		locked, err := database.IsPeriodLockedMongo(att.UserID, att.Date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if locked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Timesheet periode " + period + " sudah dikunci; buka kembali sebelum rollback"})
+			return
+		}
+	}
+
+	deleted, err := database.RollbackAttendanceImportMongo(id, c.MustGet("userID").(string), time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Import batch rolled back", "deleted": deleted})
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Attendance rule identifiers, returned in field errors and used to request overrides
+const (
+	RuleInvalidDate          = "invalid_date"
+	RuleInvalidTime          = "invalid_time"
+	RuleFutureDate           = "future_date"
+	RuleMaxBackdate          = "max_backdate"
+	RuleTimeRange            = "time_range"
+	RuleWorkingHours         = "working_hours"
+	RuleNoOverlap            = "no_overlap"
+	RuleApprovedLeave        = "approved_leave"
+	RuleDuplicateSchoolClass = "duplicate_school_class"
+	RulePeriodLocked         = "period_locked"
+	RuleActivityTaxonomy     = "activity_taxonomy"
+	RuleSchoolRequired       = "school_required"
+	RuleTimeRequired         = "time_required"
+)
+
+const schoolClassCategory = "School Class"
+
+// Format errors and locked periods cannot be overridden; everything else can be bypassed by an admin with a justification
+var nonOverridableRules = map[string]bool{
+	RuleInvalidDate:  true,
+	RuleInvalidTime:  true,
+	RuleTimeRange:    true,
+	RulePeriodLocked: true,
+	// Unknown or inactive types and categories would break grouping in reports
+	RuleActivityTaxonomy: true,
+}
+
+// AttendanceFieldError describes a single failed attendance rule
+type AttendanceFieldError struct {
+	Field       string `json:"field"`
+	Rule        string `json:"rule"`
+	Message     string `json:"message"`
+	Overridable bool   `json:"overridable"`
+}
+
+// AttendanceOverrideInput is sent by admins to bypass specific rules
+type AttendanceOverrideInput struct {
+	Rules         []string `json:"rules"`
+	Justification string   `json:"justification"`
+}
+
+func newFieldError(field, rule, message string) AttendanceFieldError {
+	return AttendanceFieldError{
+		Field:       field,
+		Rule:        rule,
+		Message:     message,
+		Overridable: !nonOverridableRules[rule],
+	}
+}
+
+// parseClock converts "HH:MM" into minutes since midnight
+func parseClock(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// schoolTag returns the lower-cased "[School]" prefix of activity notes
+func schoolTag(notes string) string {
+	return strings.ToLower(database.SchoolNameFromNotes(notes))
+}
+
+// sameSchool compares linked school IDs, falling back to the notes prefix for unlinked entries
+func sameSchool(a, b database.AttendanceMongo) bool {
+	if a.SchoolID != "" && b.SchoolID != "" {
+		return a.SchoolID == b.SchoolID
+	}
+	return schoolTag(a.ActivityNotes) == schoolTag(b.ActivityNotes)
+}
+
+func hasCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// attendanceLookup holds what validateAttendance reads from the database, preloaded for a
+// batch of entries such as an import. A nil lookup queries the database per entry.
+type attendanceLookup struct {
+	types      []database.ActivityTypeMongo
+	categories []database.ActivityCategoryMongo
+	schools    map[string]bool
+	locked     map[string]bool                       // "userID|YYYY-MM"
+	leave      map[string][]database.WorkPermitMongo // "userID|date"
+	existing   map[string][]database.AttendanceMongo // "userID|date"
+}
+
+// loadAttendanceLookup preloads validation data for userIDs between two dates (inclusive)
+func loadAttendanceLookup(userIDs []string, from, to string) (*attendanceLookup, error) {
+	l := &attendanceLookup{
+		schools:  make(map[string]bool),
+		leave:    make(map[string][]database.WorkPermitMongo),
+		existing: make(map[string][]database.AttendanceMongo),
+	}
+	var err error
+	if l.types, err = database.GetActivityTypesMongo(true); err != nil {
+		return nil, err
+	}
+	if l.categories, err = database.GetActivityCategoriesMongo(true); err != nil {
+		return nil, err
+	}
+	schools, err := database.GetSchoolsMongo()
+	if err != nil {
+		return nil, err
+	}
+	for _, school := range schools {
+		l.schools[school.ID.Hex()] = true
+	}
+	if len(userIDs) == 0 || from == "" {
+		l.locked = map[string]bool{}
+		return l, nil
+	}
+
+	periods := []string{}
+	start, _ := time.Parse("2006-01-02", from[:7]+"-01")
+	for m := start; m.Format("2006-01") <= to[:7]; m = m.AddDate(0, 1, 0) {
+		periods = append(periods, m.Format("2006-01"))
+	}
+	if l.locked, err = database.GetLockedPeriodsMongo(userIDs, periods); err != nil {
+		return nil, err
+	}
+	permits, err := database.GetApprovedWorkPermitsForUsersMongo(userIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, wp := range permits {
+		l.leave[wp.UserID+"|"+wp.Date] = append(l.leave[wp.UserID+"|"+wp.Date], wp)
+	}
+	records, err := database.GetAttendanceForUsersMongo(userIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, att := range records {
+		l.existing[att.UserID+"|"+att.Date] = append(l.existing[att.UserID+"|"+att.Date], att)
+	}
+	return l, nil
+}
+
+func (l *attendanceLookup) taxonomy() ([]database.ActivityTypeMongo, []database.ActivityCategoryMongo, error) {
+	if l != nil {
+		return l.types, l.categories, nil
+	}
+	types, err := database.GetActivityTypesMongo(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := database.GetActivityCategoriesMongo(true)
+	return types, categories, err
+}
+
+func (l *attendanceLookup) schoolExists(id string) bool {
+	if l != nil {
+		return l.schools[id]
+	}
+	_, err := database.GetSchoolByIDMongo(id)
+	return err == nil
+}
+
+func (l *attendanceLookup) periodLocked(userID, date string) (bool, error) {
+	if l != nil {
+		return l.locked[userID+"|"+date[:7]], nil
+	}
+	return database.IsPeriodLockedMongo(userID, date)
+}
+
+func (l *attendanceLookup) approvedLeave(userID, date string) ([]database.WorkPermitMongo, error) {
+	if l != nil {
+		return l.leave[userID+"|"+date], nil
+	}
+	return database.GetApprovedWorkPermitsByUserAndDate(userID, date)
+}
+
+func (l *attendanceLookup) attendanceOn(userID, date string) ([]database.AttendanceMongo, error) {
+	if l != nil {
+		return l.existing[userID+"|"+date], nil
+	}
+	return database.GetAttendanceByUserAndDate(userID, date)
+}
+
+// applyActivityTaxonomy resolves the activity type and categories (by code or label) against the
+// managed taxonomy, normalizes att to the canonical labels and codes, and checks category requirements
+func applyActivityTaxonomy(att *database.AttendanceMongo, lookup *attendanceLookup) ([]AttendanceFieldError, error) {
+	types, categories, err := lookup.taxonomy()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []AttendanceFieldError
+	var activityType *database.ActivityTypeMongo
+	for i := range types {
+		if types[i].Code == att.ActivityType || strings.EqualFold(types[i].Label, strings.TrimSpace(att.ActivityType)) {
+			activityType = &types[i]
+			break
+		}
+	}
+	if activityType == nil {
+		errs = append(errs, newFieldError("activity_type", RuleActivityTaxonomy, "Tipe aktivitas tidak dikenal: "+att.ActivityType))
+		return errs, nil
+	}
+	att.ActivityType = activityType.Label
+	att.ActivityTypeCode = activityType.Code
+
+	labels := []string{}
+	codes := []string{}
+	needsSchool, needsTime := "", ""
+	for _, value := range att.ActivityCategories {
+		var category *database.ActivityCategoryMongo
+		for i := range categories {
+			if categories[i].Code == value || strings.EqualFold(categories[i].Label, strings.TrimSpace(value)) {
+				category = &categories[i]
+				break
+			}
+		}
+		if category == nil {
+			errs = append(errs, newFieldError("activity_categories", RuleActivityTaxonomy, "Kategori aktivitas tidak dikenal: "+value))
+			continue
+		}
+		if len(category.TypeCodes) > 0 && !hasCategory(category.TypeCodes, activityType.Code) {
+			errs = append(errs, newFieldError("activity_categories", RuleActivityTaxonomy, "Kategori "+category.Label+" tidak berlaku untuk "+activityType.Label))
+			continue
+		}
+		labels = append(labels, category.Label)
+		codes = append(codes, category.Code)
+		if category.RequiresSchool && needsSchool == "" {
+			needsSchool = category.Label
+		}
+		if category.RequiresTimeRange && needsTime == "" {
+			needsTime = category.Label
+		}
+	}
+	att.ActivityCategories = labels
+	att.ActivityCategoryCodes = codes
+
+	if att.SchoolID != "" {
+		if !lookup.schoolExists(att.SchoolID) {
+			errs = append(errs, newFieldError("school_id", RuleSchoolRequired, "Sekolah tidak ditemukan"))
+		}
+	} else if needsSchool != "" {
+		errs = append(errs, newFieldError("school_id", RuleSchoolRequired, "Pilih sekolah untuk "+needsSchool))
+	}
+	if needsTime != "" && (att.StartingTime == "" || att.EndingTime == "") {
+		errs = append(errs, newFieldError("starting_time", RuleTimeRequired, "Jam mulai dan jam selesai wajib diisi untuk "+needsTime))
+	}
+	return errs, nil
+}
+
+// validateAttendance checks a new attendance entry against the activity taxonomy and the
+// configured rules. att is normalized to the taxonomy's canonical labels and codes. lookup may
+// be nil (see attendanceLookup).
+func validateAttendance(att *database.AttendanceMongo, rules database.AttendanceRulesMongo, lookup *attendanceLookup) ([]AttendanceFieldError, error) {
+	errs, err := applyActivityTaxonomy(att, lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", att.Date, time.Local)
+	if err != nil {
+		errs = append(errs, newFieldError("date", RuleInvalidDate, "Tanggal harus berformat YYYY-MM-DD"))
+		return errs, nil
+	}
+
+	locked, err := lookup.periodLocked(att.UserID, att.Date)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		errs = append(errs, newFieldError("date", RulePeriodLocked, "Timesheet periode ini sudah dikunci"))
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if rules.CheckFutureDate && date.After(today) {
+		errs = append(errs, newFieldError("date", RuleFutureDate, "Absensi tidak boleh untuk tanggal yang akan datang"))
+	}
+	if rules.CheckBackdate && date.Before(today.AddDate(0, 0, -rules.MaxBackdateDays)) {
+		errs = append(errs, newFieldError("date", RuleMaxBackdate, "Absensi melewati batas pengisian mundur"))
+	}
+
+	// Times are optional (only Event & Private activities use them)
+	start, hasStart := parseClock(att.StartingTime)
+	end, hasEnd := parseClock(att.EndingTime)
+	if att.StartingTime != "" && !hasStart {
+		errs = append(errs, newFieldError("starting_time", RuleInvalidTime, "Jam mulai harus berformat HH:MM"))
+	}
+	if att.EndingTime != "" && !hasEnd {
+		errs = append(errs, newFieldError("ending_time", RuleInvalidTime, "Jam selesai harus berformat HH:MM"))
+	}
+	hasRange := hasStart && hasEnd
+	if rules.CheckTimeRange && hasRange && end <= start {
+		errs = append(errs, newFieldError("ending_time", RuleTimeRange, "Jam selesai harus setelah jam mulai"))
+	}
+	if rules.CheckWorkingHours {
+		dayStart, okStart := parseClock(rules.WorkdayStart)
+		dayEnd, okEnd := parseClock(rules.WorkdayEnd)
+		if hasStart && okStart && start < dayStart {
+			errs = append(errs, newFieldError("starting_time", RuleWorkingHours, "Jam mulai di luar jam kerja ("+rules.WorkdayStart+" - "+rules.WorkdayEnd+")"))
+		}
+		if hasEnd && okEnd && end > dayEnd {
+			errs = append(errs, newFieldError("ending_time", RuleWorkingHours, "Jam selesai di luar jam kerja ("+rules.WorkdayStart+" - "+rules.WorkdayEnd+")"))
+		}
+	}
+
+	if rules.CheckApprovedLeave {
+		permits, err := lookup.approvedLeave(att.UserID, att.Date)
+		if err != nil {
+			return nil, err
+		}
+		for _, wp := range permits {
+			if wp.Session != "Half Day" {
+				errs = append(errs, newFieldError("date", RuleApprovedLeave, "Anda memiliki izin/cuti yang disetujui pada tanggal ini"))
+				break
+			}
+		}
+	}
+
+	if !rules.CheckOverlap && !rules.CheckDuplicateSchoolClass {
+		return errs, nil
+	}
+
+	existing, err := lookup.attendanceOn(att.UserID, att.Date)
+	if err != nil {
+		return nil, err
+	}
+	return append(errs, conflictErrors(*att, existing, rules)...), nil
+}
+
+// conflictErrors checks att against other entries of the same user and date for
+// overlapping times and duplicate School Class sessions
+func conflictErrors(att database.AttendanceMongo, existing []database.AttendanceMongo, rules database.AttendanceRulesMongo) []AttendanceFieldError {
+	var errs []AttendanceFieldError
+	start, hasStart := parseClock(att.StartingTime)
+	end, hasEnd := parseClock(att.EndingTime)
+	hasRange := hasStart && hasEnd
+	isSchoolClass := hasCategory(att.ActivityCategories, schoolClassCategory)
+	overlapFound, duplicateFound := false, false
+	for _, ex := range existing {
+		if rules.CheckOverlap && hasRange && !overlapFound {
+			exStart, okStart := parseClock(ex.StartingTime)
+			exEnd, okEnd := parseClock(ex.EndingTime)
+			if okStart && okEnd && start < exEnd && exStart < end {
+				errs = append(errs, newFieldError("starting_time", RuleNoOverlap, "Waktu bertabrakan dengan absensi "+ex.StartingTime+" - "+ex.EndingTime))
+				overlapFound = true
+			}
+		}
+		if rules.CheckDuplicateSchoolClass && isSchoolClass && !duplicateFound &&
+			hasCategory(ex.ActivityCategories, schoolClassCategory) &&
+			sameSchool(ex, att) &&
+			ex.StartingTime == att.StartingTime {
+			errs = append(errs, newFieldError("activity_categories", RuleDuplicateSchoolClass, "Sesi School Class ini sudah tercatat"))
+			duplicateFound = true
+		}
+	}
+	return errs
+}
+
+// hasAdminAccess mirrors AdminMiddlewareMongo: admins and managers
+func hasAdminAccess(c *gin.Context) bool {
+	if isAdmin, ok := c.Get("isAdmin"); ok && isAdmin.(bool) {
+		return true
+	}
+	role, _ := c.Get("role")
+	return role == "manager"
+}
+
+// isAdminCaller mirrors AdminOnlyMiddlewareMongo: admins only, managers excluded
+func isAdminCaller(c *gin.Context) bool {
+	isAdmin, ok := c.Get("isAdmin")
+	return ok && isAdmin.(bool)
+}
+
+// applyRuleOverrides removes overridden rule failures and returns the overrides to record.
+// It writes the error response itself and returns ok=false when the override is not allowed.
+func applyRuleOverrides(c *gin.Context, errs []AttendanceFieldError, override *AttendanceOverrideInput) ([]AttendanceFieldError, []database.AttendanceRuleOverride, bool) {
+	if override == nil || len(override.Rules) == 0 {
+		return errs, nil, true
+	}
+	if !isAdminCaller(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can override attendance rules"})
+		return nil, nil, false
+	}
+	if strings.TrimSpace(override.Justification) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Justification is required to override attendance rules"})
+		return nil, nil, false
+	}
+
+	requested := make(map[string]bool)
+	for _, r := range override.Rules {
+		requested[r] = true
+	}
+
+	userID := c.MustGet("userID").(string)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	var remaining []AttendanceFieldError
+	var applied []database.AttendanceRuleOverride
+	seen := make(map[string]bool)
+	for _, e := range errs {
+		if !requested[e.Rule] || !e.Overridable {
+			remaining = append(remaining, e)
+			continue
+		}
+		if seen[e.Rule] {
+			continue
+		}
+		seen[e.Rule] = true
+		applied = append(applied, database.AttendanceRuleOverride{
+			Rule:          e.Rule,
+			Justification: override.Justification,
+			OverriddenBy:  userID,
+			OverriddenAt:  now,
+		})
+	}
+	return remaining, applied, true
+}
+
+// --- Attendance Rules Admin Handlers ---
+
+func GetAttendanceRulesMongo(c *gin.Context) {
+	rules, err := database.GetAttendanceRulesMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func UpdateAttendanceRulesMongo(c *gin.Context) {
+	var input database.AttendanceRulesMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.MaxBackdateDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_backdate_days cannot be negative"})
+		return
+	}
+	start, okStart := parseClock(input.WorkdayStart)
+	end, okEnd := parseClock(input.WorkdayEnd)
+	if !okStart || !okEnd || end <= start {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "workday_start and workday_end must be HH:MM with start before end"})
+		return
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateAttendanceRulesMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, input)
+}
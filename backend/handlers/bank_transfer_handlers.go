@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransferRecord is one salary transfer, the data of a template's detail fields
+type TransferRecord struct {
+	No        int
+	UserID    string
+	Name      string
+	BankCode  string
+	BankName  string
+	Account   string
+	Holder    string
+	Amount    int64
+	Reference string
+	Period    string
+	Date      time.Time
+}
+
+// TransferBatch is the data of a template's header and trailer fields
+type TransferBatch struct {
+	Count         int
+	Total         int64
+	Date          time.Time
+	Reference     string
+	Period        string
+	SourceAccount string
+	CompanyName   string
+}
+
+// TransferIssue lists why an employee cannot be paid by transfer
+type TransferIssue struct {
+	UserID   string   `json:"user_id"`
+	UserName string   `json:"user_name"`
+	Problems []string `json:"problems"`
+}
+
+var transferFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"digits": func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, s)
+	},
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	// amount renders rupiah with a fixed number of decimals, e.g. {{amount .Amount 2}} -> 1500000.00
+	"amount": func(v int64, decimals int) string {
+		if decimals <= 0 {
+			return strconv.FormatInt(v, 10)
+		}
+		return strconv.FormatInt(v, 10) + "." + strings.Repeat("0", decimals)
+	},
+	"cents": func(v int64) string { return strconv.FormatInt(v*100, 10) },
+}
+
+type compiledField struct {
+	field database.TransferField
+	tmpl  *template.Template
+}
+
+func compileFields(fields []database.TransferField, section string) ([]compiledField, error) {
+	compiled := make([]compiledField, len(fields))
+	for i, f := range fields {
+		t, err := template.New(section).Funcs(transferFuncs).Option("missingkey=error").Parse(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s field %d: %v", section, i+1, err)
+		}
+		compiled[i] = compiledField{field: f, tmpl: t}
+	}
+	return compiled, nil
+}
+
+// fixedWidth pads value to the field width. It reports false when value does not fit: a cut
+// account number or amount would send money to the wrong place, so nothing is truncated.
+func fixedWidth(f database.TransferField, value string) (string, bool) {
+	if utf8.RuneCountInString(value) > f.Width {
+		return value, false
+	}
+	pad := f.Pad
+	if pad == "" {
+		pad = " "
+	}
+	fill := strings.Repeat(pad, f.Width-utf8.RuneCountInString(value))
+	if f.Align == "right" {
+		return fill + value, true
+	}
+	return value + fill, true
+}
+
+// transferRenderer renders one record per line in a template's layout
+type transferRenderer struct {
+	t       database.BankTransferTemplateMongo
+	header  []compiledField
+	detail  []compiledField
+	trailer []compiledField
+	buf     bytes.Buffer
+	csv     *csv.Writer
+}
+
+func newTransferRenderer(t database.BankTransferTemplateMongo) (*transferRenderer, error) {
+	r := &transferRenderer{t: t}
+	var err error
+	if r.header, err = compileFields(t.Header, "header"); err != nil {
+		return nil, err
+	}
+	if r.detail, err = compileFields(t.Detail, "detail"); err != nil {
+		return nil, err
+	}
+	if r.trailer, err = compileFields(t.Trailer, "trailer"); err != nil {
+		return nil, err
+	}
+	if t.Format == "csv" {
+		r.csv = csv.NewWriter(&r.buf)
+		r.csv.UseCRLF = t.CRLF
+		if t.Delimiter != "" {
+			r.csv.Comma, _ = utf8.DecodeRuneInString(t.Delimiter)
+		}
+	}
+	return r, nil
+}
+
+// line writes one record and returns the fixed-width values that did not fit their field
+func (r *transferRenderer) line(fields []compiledField, data interface{}) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		var out strings.Builder
+		if err := f.tmpl.Execute(&out, data); err != nil {
+			return nil, err
+		}
+		values[i] = out.String()
+	}
+	if r.csv != nil {
+		return nil, r.csv.Write(values)
+	}
+	var overflows []string
+	for i, f := range fields {
+		value, ok := fixedWidth(f.field, values[i])
+		if !ok {
+			name := f.field.Name
+			if name == "" {
+				name = fmt.Sprintf("kolom %d", i+1)
+			}
+			overflows = append(overflows, fmt.Sprintf("%s %q melebihi lebar %d karakter", name, values[i], f.field.Width))
+		}
+		r.buf.WriteString(value)
+	}
+	if r.t.CRLF {
+		r.buf.WriteString("\r\n")
+	} else {
+		r.buf.WriteString("\n")
+	}
+	return overflows, nil
+}
+
+// render builds the file. Values too wide for a fixed-width field come back as issues, per
+// employee for detail lines; a file with issues must not be sent to the bank.
+func (r *transferRenderer) render(batch TransferBatch, records []TransferRecord) ([]byte, []TransferIssue, error) {
+	if r.csv != nil && r.t.ColumnNames {
+		names := make([]string, len(r.t.Detail))
+		for i, f := range r.t.Detail {
+			names[i] = f.Name
+		}
+		if err := r.csv.Write(names); err != nil {
+			return nil, nil, err
+		}
+	}
+	issues := []TransferIssue{}
+	overflows, err := r.line(r.header, batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(overflows) > 0 {
+		issues = append(issues, TransferIssue{UserName: "Header", Problems: overflows})
+	}
+	for _, rec := range records {
+		overflows, err := r.line(r.detail, rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(overflows) > 0 {
+			issues = append(issues, TransferIssue{UserID: rec.UserID, UserName: rec.Name, Problems: overflows})
+		}
+	}
+	if overflows, err = r.line(r.trailer, batch); err != nil {
+		return nil, nil, err
+	}
+	if len(overflows) > 0 {
+		issues = append(issues, TransferIssue{UserName: "Trailer", Problems: overflows})
+	}
+	if r.csv != nil {
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return r.buf.Bytes(), issues, nil
+}
+
+func validateTransferTemplate(t database.BankTransferTemplateMongo) error {
+	if t.Code == "" || t.Name == "" {
+		return fmt.Errorf("code and name are required")
+	}
+	if len(t.Detail) == 0 {
+		return fmt.Errorf("detail needs at least one field")
+	}
+	switch t.Format {
+	case "csv":
+		if utf8.RuneCountInString(t.Delimiter) > 1 {
+			return fmt.Errorf("delimiter must be a single character")
+		}
+	case "fixed":
+		for _, fields := range [][]database.TransferField{t.Header, t.Detail, t.Trailer} {
+			for _, f := range fields {
+				if f.Width <= 0 || utf8.RuneCountInString(f.Pad) > 1 {
+					return fmt.Errorf("fixed-width fields need a positive width and a single padding character")
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("format must be csv or fixed")
+	}
+
+	// Render a sample so template errors surface when saving rather than on payday
+	r, err := newTransferRenderer(t)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, _, err = r.render(TransferBatch{Count: 1, Total: 1000000, Date: now, Reference: "GAJI", Period: now.Format("2006-01")},
+		[]TransferRecord{{No: 1, Name: "Sample", BankCode: "014", BankName: "BCA", Account: "1234567890", Holder: "Sample", Amount: 1000000, Reference: "GAJI", Period: now.Format("2006-01"), Date: now}})
+	return err
+}
+
+// normalizeAccount strips the separators people type into account numbers
+func normalizeAccount(account string) string {
+	return strings.NewReplacer(" ", "", "-", "", ".", "").Replace(strings.TrimSpace(account))
+}
+
+// checkBankData reports what is missing or invalid in a user's transfer details
+func checkBankData(user *database.UserMongo, banks map[string]database.BankRule) []string {
+	if user == nil {
+		return []string{"Karyawan tidak ditemukan"}
+	}
+	var problems []string
+	rule, known := banks[strings.TrimSpace(user.BankCode)]
+	switch {
+	case strings.TrimSpace(user.BankCode) == "":
+		problems = append(problems, "Kode bank belum diisi")
+	case !known:
+		problems = append(problems, "Kode bank "+user.BankCode+" tidak terdaftar")
+	}
+
+	account := normalizeAccount(user.BankAccount)
+	switch {
+	case account == "":
+		problems = append(problems, "Nomor rekening belum diisi")
+	case strings.Trim(account, "0123456789") != "":
+		problems = append(problems, "Nomor rekening hanya boleh berisi angka")
+	case known && (len(account) < rule.MinDigits || (rule.MaxDigits > 0 && len(account) > rule.MaxDigits)):
+		if rule.MinDigits == rule.MaxDigits {
+			problems = append(problems, fmt.Sprintf("Nomor rekening %s harus %d digit", rule.Name, rule.MinDigits))
+		} else {
+			problems = append(problems, fmt.Sprintf("Nomor rekening %s harus %d-%d digit", rule.Name, rule.MinDigits, rule.MaxDigits))
+		}
+	}
+
+	if strings.TrimSpace(user.BankAccountHolder) == "" {
+		problems = append(problems, "Nama pemilik rekening belum diisi")
+	}
+	return problems
+}
+
+// transferRecords builds one record per payslip with positive net pay and reports employees
+// whose bank data would make the transfer fail. It writes the error response itself.
+func transferRecords(c *gin.Context, run *database.PayrollRunMongo) ([]TransferRecord, []TransferIssue, bool) {
+	payslips, err := database.GetPayslipsByRunMongo(run.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	settings, err := database.GetBankSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	banks := make(map[string]database.BankRule, len(settings.Banks))
+	for _, b := range settings.Banks {
+		banks[b.Code] = b
+	}
+
+	userIDs := make([]string, len(payslips))
+	for i, p := range payslips {
+		userIDs[i] = p.UserID
+	}
+	users, err := database.GetUsersByIDsMongo(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	reference := c.DefaultQuery("reference", "GAJI "+run.Period)
+	records := []TransferRecord{}
+	issues := []TransferIssue{}
+	for _, p := range payslips {
+		if p.Net <= 0 {
+			continue
+		}
+		var user *database.UserMongo
+		if u, ok := users[p.UserID]; ok {
+			user = &u
+		}
+		if problems := checkBankData(user, banks); len(problems) > 0 {
+			issues = append(issues, TransferIssue{UserID: p.UserID, UserName: p.UserName, Problems: problems})
+			continue
+		}
+		records = append(records, TransferRecord{
+			UserID:    p.UserID,
+			Name:      user.Name,
+			BankCode:  strings.TrimSpace(user.BankCode),
+			BankName:  banks[strings.TrimSpace(user.BankCode)].Name,
+			Account:   normalizeAccount(user.BankAccount),
+			Holder:    strings.TrimSpace(user.BankAccountHolder),
+			Amount:    p.Net,
+			Reference: reference,
+			Period:    run.Period,
+		})
+	}
+	return records, issues, true
+}
+
+// --- Bank settings and templates ---
+
+func GetBankSettingsMongo(c *gin.Context) {
+	settings, err := database.GetBankSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateBankSettingsMongo(c *gin.Context) {
+	var input database.BankSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]bool)
+	for _, b := range input.Banks {
+		if b.Code == "" || b.Name == "" || b.MinDigits <= 0 || (b.MaxDigits > 0 && b.MaxDigits < b.MinDigits) || seen[b.Code] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each bank needs a unique code, a name and a valid digit range"})
+			return
+		}
+		seen[b.Code] = true
+	}
+	if input.Banks == nil {
+		input.Banks = []database.BankRule{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateBankSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+func GetBankTransferTemplatesMongo(c *gin.Context) {
+	templates, err := database.GetBankTransferTemplatesMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func CreateBankTransferTemplateMongo(c *gin.Context) {
+	var input database.BankTransferTemplateMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateTransferTemplate(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := database.GetBankTransferTemplateByCodeMongo(input.Code); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template code already exists"})
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	input.CreatedAt = now
+	input.UpdatedAt = now
+	created, err := database.CreateBankTransferTemplateMongo(input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateBankTransferTemplateMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input database.BankTransferTemplateMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateTransferTemplate(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if existing, err := database.GetBankTransferTemplateByCodeMongo(input.Code); err == nil && existing.ID.Hex() != id {
+		c.JSON(http.StatusConflict, gin.H{"error": "Template code already exists"})
+		return
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	if err := database.UpdateBankTransferTemplateMongo(id, input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template updated"})
+}
+
+func DeleteBankTransferTemplateMongo(c *gin.Context) {
+	if err := database.DeleteBankTransferTemplateMongo(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted"})
+}
+
+// --- Transfer files ---
+
+// ValidateBankTransferMongo lists who would be paid and who has missing or invalid bank data
+func ValidateBankTransferMongo(c *gin.Context) {
+	run, err := database.GetPayrollRunByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	records, issues, ok := transferRecords(c, run)
+	if !ok {
+		return
+	}
+	var total int64
+	for _, r := range records {
+		total += r.Amount
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"ready":  len(records),
+		"total":  total,
+		"issues": issues,
+	})
+}
+
+// ExportBankTransferMongo renders a finalized run as a bulk-transfer file with ?template=CODE.
+// Employees with bad bank data block the export unless ?skip_invalid=true leaves them out;
+// values too wide for a fixed-width field always block it.
+func ExportBankTransferMongo(c *gin.Context) {
+	run, err := database.GetPayrollRunByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	if run.Status != "finalized" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only finalized payroll runs can be exported for transfer"})
+		return
+	}
+	tmpl, err := database.GetBankTransferTemplateByCodeMongo(c.Query("template"))
+	if err != nil || !tmpl.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template must be the code of an active bank transfer template"})
+		return
+	}
+	date := time.Now()
+	if d := c.Query("date"); d != "" {
+		if date, err = time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	records, issues, ok := transferRecords(c, run)
+	if !ok {
+		return
+	}
+	if len(issues) > 0 && c.Query("skip_invalid") != "true" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Data rekening beberapa karyawan belum lengkap atau tidak valid", "issues": issues})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No payslips to transfer"})
+		return
+	}
+
+	company, _ := export.Company()
+	batch := TransferBatch{
+		Count:         len(records),
+		Date:          date,
+		Reference:     c.DefaultQuery("reference", "GAJI "+run.Period),
+		Period:        run.Period,
+		SourceAccount: tmpl.SourceAccount,
+		CompanyName:   company,
+	}
+	for i := range records {
+		records[i].No = i + 1
+		records[i].Date = date
+		batch.Total += records[i].Amount
+	}
+
+	renderer, err := newTransferRenderer(*tmpl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	file, overflows, err := renderer.render(batch, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(overflows) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Beberapa nilai melebihi lebar kolom template bank; perbaiki template atau datanya", "issues": overflows})
+		return
+	}
+
+	ext := tmpl.Extension
+	if ext == "" {
+		ext = "txt"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="transfer-%s-%s.%s"`, tmpl.Code, run.Period, ext))
+	c.Header("X-Transfer-Count", strconv.Itoa(len(records)))
+	c.Header("X-Transfer-Skipped", strconv.Itoa(len(issues)))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", file)
+}
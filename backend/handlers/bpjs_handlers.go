@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BPJSReportRow is one member's contribution to a program in a period
+type BPJSReportRow struct {
+	UserID         string `json:"user_id"`
+	UserName       string `json:"user_name"`
+	MemberNo       string `json:"member_no"`
+	Wage           int64  `json:"wage"`
+	EmployeeAmount int64  `json:"employee_amount"`
+	EmployerAmount int64  `json:"employer_amount"`
+	Total          int64  `json:"total"`
+}
+
+func bpjsMemberNo(user database.UserMongo, agency string) string {
+	if agency == "kesehatan" {
+		return strings.TrimSpace(user.BPJSKesehatanNo)
+	}
+	return strings.TrimSpace(user.BPJSKetenagakerjaanNo)
+}
+
+// bpjsLines computes each active program for a member of its agency: the employee share is
+// deducted from pay and the employer share is recorded as an employer line
+func bpjsLines(user database.UserMongo, structure database.SalaryStructureMongo, settings database.BPJSSettingsMongo) ([]database.PayslipLine, []database.PayslipBPJS) {
+	wage := structure.BasePay
+	for _, a := range structure.Allowances {
+		wage += a.Amount
+	}
+
+	var lines []database.PayslipLine
+	var contributions []database.PayslipBPJS
+	for _, prog := range settings.Programs {
+		memberNo := bpjsMemberNo(user, prog.Agency)
+		if !prog.Active || memberNo == "" || wage <= 0 {
+			continue
+		}
+		base := wage
+		if prog.WageCap > 0 && base > prog.WageCap {
+			base = prog.WageCap
+		}
+		if prog.WageFloor > 0 && base < prog.WageFloor {
+			base = prog.WageFloor
+		}
+		employee := int64(math.Round(float64(base) * prog.EmployeeRate / 100))
+		employer := int64(math.Round(float64(base) * prog.EmployerRate / 100))
+		source := database.PayslipLineSource{Kind: "bpjs", RefIDs: []string{structure.ID.Hex()}, Note: "No. " + memberNo + ", upah " + export.Rupiah(base)}
+
+		if employee > 0 {
+			lines = append(lines, database.PayslipLine{
+				Code: "BPJS_" + prog.Code, Name: prog.Name + " (Karyawan)", Type: "deduction",
+				Amount: employee, Taxable: prog.EmployeeDeductible, Source: source,
+			})
+		}
+		if employer > 0 {
+			lines = append(lines, database.PayslipLine{
+				Code: "BPJS_" + prog.Code + "_ER", Name: prog.Name + " (Perusahaan)", Type: "employer",
+				Amount: employer, Taxable: prog.EmployerTaxable, Source: source,
+			})
+		}
+		contributions = append(contributions, database.PayslipBPJS{
+			Program:        prog.Code,
+			Agency:         prog.Agency,
+			MemberNo:       memberNo,
+			Wage:           base,
+			EmployeeAmount: employee,
+			EmployerAmount: employer,
+		})
+	}
+	return lines, contributions
+}
+
+func validateBPJSSettings(settings database.BPJSSettingsMongo) error {
+	seen := make(map[string]bool)
+	for _, p := range settings.Programs {
+		if p.Code == "" || p.Name == "" {
+			return fmt.Errorf("every program needs a code and name")
+		}
+		if seen[p.Code] {
+			return fmt.Errorf("program code %s is used twice", p.Code)
+		}
+		seen[p.Code] = true
+		if p.Agency != "kesehatan" && p.Agency != "ketenagakerjaan" {
+			return fmt.Errorf("agency of %s must be kesehatan or ketenagakerjaan", p.Code)
+		}
+		if p.EmployerRate < 0 || p.EmployerRate > 100 || p.EmployeeRate < 0 || p.EmployeeRate > 100 {
+			return fmt.Errorf("rates of %s must be between 0 and 100", p.Code)
+		}
+		if p.WageCap < 0 || p.WageFloor < 0 || (p.WageCap > 0 && p.WageFloor > p.WageCap) {
+			return fmt.Errorf("wage floor of %s must not exceed its cap", p.Code)
+		}
+	}
+	return nil
+}
+
+func GetBPJSSettingsMongo(c *gin.Context) {
+	settings, err := database.GetBPJSSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateBPJSSettingsMongo(c *gin.Context) {
+	var input database.BPJSSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBPJSSettings(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Programs == nil {
+		input.Programs = []database.BPJSProgram{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateBPJSSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+// bpjsReport collects a program's contributions from the period's payslips. It writes the error
+// response itself.
+func bpjsReport(c *gin.Context) (string, database.BPJSProgram, []BPJSReportRow, bool) {
+	period := c.DefaultQuery("period", time.Now().Format("2006-01"))
+	if _, _, err := payrollPeriod(period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", database.BPJSProgram{}, nil, false
+	}
+	settings, err := database.GetBPJSSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", database.BPJSProgram{}, nil, false
+	}
+	code := strings.ToUpper(c.Query("program"))
+	var program database.BPJSProgram
+	for _, p := range settings.Programs {
+		if p.Code == code {
+			program = p
+		}
+	}
+	if program.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "program must be one of the configured BPJS program codes"})
+		return "", program, nil, false
+	}
+
+	payslips, err := database.GetPayslipsByPeriodMongo(period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", program, nil, false
+	}
+	rows := []BPJSReportRow{}
+	for _, p := range payslips {
+		for _, b := range p.BPJS {
+			if b.Program != program.Code {
+				continue
+			}
+			rows = append(rows, BPJSReportRow{
+				UserID:         p.UserID,
+				UserName:       p.UserName,
+				MemberNo:       b.MemberNo,
+				Wage:           b.Wage,
+				EmployeeAmount: b.EmployeeAmount,
+				EmployerAmount: b.EmployerAmount,
+				Total:          b.EmployeeAmount + b.EmployerAmount,
+			})
+		}
+	}
+	return period, program, rows, true
+}
+
+// GetBPJSReportMongo lists ?program= contributions for ?period=YYYY-MM from its payroll run
+func GetBPJSReportMongo(c *gin.Context) {
+	period, program, rows, ok := bpjsReport(c)
+	if !ok {
+		return
+	}
+	run, err := database.GetPayrollRunByPeriodMongo(period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	runStatus := ""
+	if run != nil {
+		runStatus = run.Status
+	}
+
+	var employee, employer int64
+	for _, r := range rows {
+		employee += r.EmployeeAmount
+		employer += r.EmployerAmount
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"period":     period,
+		"program":    program,
+		"run_status": runStatus,
+		"rows":       rows,
+		"total": gin.H{
+			"members":  len(rows),
+			"employee": employee,
+			"employer": employer,
+			"total":    employee + employer,
+		},
+	})
+}
+
+func ExportBPJSReportMongo(c *gin.Context) {
+	period, program, rows, ok := bpjsReport(c)
+	if !ok {
+		return
+	}
+
+	w, ok := startExport(c, "bpjs-"+strings.ToLower(program.Code)+"-"+period, export.Document{
+		Title:      "Laporan Iuran " + program.Name,
+		Subtitle:   "Periode " + period,
+		Headers:    []string{"No. Peserta", "Nama", "Upah", "Iuran Karyawan", "Iuran Perusahaan", "Total"},
+		Signatures: []string{"Dibuat oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+	var wage, employee, employer int64
+	for _, r := range rows {
+		if err := w.WriteRow([]string{r.MemberNo, r.UserName, export.Rupiah(r.Wage), export.Rupiah(r.EmployeeAmount), export.Rupiah(r.EmployerAmount), export.Rupiah(r.Total)}); err != nil {
+			log.Printf("Export BPJS report error: %v", err)
+			return
+		}
+		wage += r.Wage
+		employee += r.EmployeeAmount
+		employer += r.EmployerAmount
+	}
+	if err := w.WriteRow([]string{"Total", "", export.Rupiah(wage), export.Rupiah(employee), export.Rupiah(employer), export.Rupiah(employee + employer)}); err != nil {
+		log.Printf("Export BPJS report error: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export BPJS report error: %v", err)
+	}
+}
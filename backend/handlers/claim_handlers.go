@@ -0,0 +1,518 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"kkhris-clone/storage"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// maxClaimReceipts bounds the receipts on one claim; size and type limits come from the
+// "receipt" file purpose
+const maxClaimReceipts = 5
+
+func findClaimCategory(settings database.ClaimSettingsMongo, code string) (database.ClaimCategory, bool) {
+	for _, cat := range settings.Categories {
+		if strings.EqualFold(cat.Code, code) {
+			return cat, true
+		}
+	}
+	return database.ClaimCategory{}, false
+}
+
+// checkClaimLimits enforces the category's per-claim and monthly limits. Pending claims count
+// toward the month so several small claims cannot slip past it.
+func checkClaimLimits(cat database.ClaimCategory, userID, date, excludeID string, amount int64) error {
+	if cat.PerClaimLimit > 0 && amount > cat.PerClaimLimit {
+		return fmt.Errorf("Batas %s per klaim adalah %s", cat.Name, export.Rupiah(cat.PerClaimLimit))
+	}
+	if cat.MonthlyLimit <= 0 {
+		return nil
+	}
+	from, to, err := payrollPeriod(date[:7])
+	if err != nil {
+		return err
+	}
+	used, err := database.SumClaimsMongo(userID, cat.Code, from, to, excludeID)
+	if err != nil {
+		return err
+	}
+	if used+amount > cat.MonthlyLimit {
+		return fmt.Errorf("Batas %s per bulan adalah %s, sudah terpakai %s", cat.Name, export.Rupiah(cat.MonthlyLimit), export.Rupiah(used))
+	}
+	return nil
+}
+
+// nextOpenPayrollPeriod is the first period from the given month whose payroll run is still a
+// draft or not created yet
+func nextOpenPayrollPeriod(from time.Time) (string, error) {
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 24; i++ {
+		period := month.Format("2006-01")
+		run, err := database.GetPayrollRunByPeriodMongo(period)
+		if err != nil {
+			return "", err
+		}
+		if run == nil || run.Status == "draft" {
+			return period, nil
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return "", fmt.Errorf("no open payroll period found")
+}
+
+// approveClaim is called when the claim's pending request is approved. Claims paid through
+// payroll are queued on the next open period, whose draft run is recalculated.
+func approveClaim(id, approvedBy string) error {
+	claim, err := database.GetClaimByIDMongo(id)
+	if err != nil {
+		return err
+	}
+	settings, err := database.GetClaimSettingsMongo()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	set := bson.M{
+		"status":        "approved",
+		"payout_method": settings.PayoutMethod,
+		"approved_by":   approvedBy,
+		"approved_at":   now.Format("2006-01-02 15:04:05"),
+	}
+	period := ""
+	if settings.PayoutMethod == "payroll" {
+		if period, err = nextOpenPayrollPeriod(now); err != nil {
+			return err
+		}
+		set["payroll_period"] = period
+	}
+	updated, err := database.UpdateClaimMongo(id, "pending", set)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("claim is %s, not pending", claim.Status)
+	}
+	if period != "" {
+		if err := refreshDraftRun(period); err != nil {
+			log.Printf("Recalculate payroll %s after claim approval error: %v", period, err)
+		}
+	}
+	return nil
+}
+
+func rejectClaim(id, reason string) error {
+	updated, err := database.UpdateClaimMongo(id, "pending", bson.M{"status": "rejected", "reject_reason": reason})
+	if err == nil && !updated {
+		err = fmt.Errorf("claim is not pending")
+	}
+	return err
+}
+
+// claimLines turns the approved claims queued on a payroll period into non-taxable earning lines
+func claimLines(period string) (map[string][]database.PayslipLine, error) {
+	claims, err := database.GetClaimsMongo(database.ClaimFilter{Status: "approved", PayoutMethod: "payroll", PayrollPeriod: period})
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string][]database.PayslipLine)
+	for _, cl := range claims {
+		lines[cl.UserID] = append(lines[cl.UserID], database.PayslipLine{
+			Code: "REIMBURSE_" + cl.Category, Name: "Reimbursement " + cl.CategoryName, Type: "earning", Amount: cl.Amount,
+			Source: database.PayslipLineSource{Kind: "claim", RefIDs: []string{cl.ID.Hex()}, Note: cl.Date + " " + cl.Description},
+		})
+	}
+	return lines, nil
+}
+
+// --- Employee claims ---
+
+func GetMyClaimsMongo(c *gin.Context) {
+	claims, err := database.GetClaimsMongo(database.ClaimFilter{UserID: c.MustGet("userID").(string), Status: c.Query("status")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+// GetClaimCategoriesMongo lists the active categories and their limits for the claim form
+func GetClaimCategoriesMongo(c *gin.Context) {
+	settings, err := database.GetClaimSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	categories := []database.ClaimCategory{}
+	for _, cat := range settings.Categories {
+		if cat.Active {
+			categories = append(categories, cat)
+		}
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func AddClaimMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var input struct {
+		Category     string `json:"category" binding:"required"`
+		Date         string `json:"date" binding:"required"`
+		Amount       int64  `json:"amount" binding:"required"`
+		Description  string `json:"description" binding:"required"`
+		SchoolID     string `json:"school_id"`
+		AttendanceID string `json:"attendance_id"`
+		Receipts     []struct {
+			FileID string `json:"file_id"`
+			Name   string `json:"name"`
+			Data   string `json:"data"` // legacy base64 data URL
+		} `json:"receipts"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	if input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	settings, err := database.GetClaimSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cat, ok := findClaimCategory(*settings, input.Category)
+	if !ok || !cat.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown claim category"})
+		return
+	}
+
+	if len(input.Receipts) > maxClaimReceipts {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Maksimal %d bukti per klaim", maxClaimReceipts)})
+		return
+	}
+	if cat.RequiresReceipt && len(input.Receipts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Klaim " + cat.Name + " wajib melampirkan bukti"})
+		return
+	}
+	receipts := []database.ClaimReceipt{}
+	for _, r := range input.Receipts {
+		ref := r.FileID
+		if ref == "" {
+			ref = r.Data
+		}
+		if ref == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "receipt needs file_id"})
+			return
+		}
+		fileID, err := resolveFileRef(c, userID, "receipt", ref)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		f, err := database.GetFileByIDMongo(fileID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		name := r.Name
+		if name == "" {
+			name = f.Name
+		}
+		receipts = append(receipts, database.ClaimReceipt{FileID: fileID, Name: name, ContentType: f.ContentType, Size: int(f.Size)})
+	}
+
+	// A linked attendance entry must be the claimant's own and supplies the school
+	if input.AttendanceID != "" {
+		att, err := database.GetAttendanceByIDMongo(input.AttendanceID)
+		if err != nil || att.UserID != userID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Attendance entry not found"})
+			return
+		}
+		if att.SchoolID != "" {
+			input.SchoolID = att.SchoolID
+		}
+	}
+	if input.SchoolID != "" {
+		if _, err := database.GetSchoolByIDMongo(input.SchoolID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "School not found"})
+			return
+		}
+	}
+
+	if err := checkClaimLimits(cat, userID, input.Date, "", input.Amount); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, _ := database.GetUserByIDMongo(userID)
+	userName := "Unknown"
+	if user != nil {
+		userName = user.Name
+	}
+
+	claim := database.ClaimMongo{
+		UserID:       userID,
+		UserName:     userName,
+		Category:     cat.Code,
+		CategoryName: cat.Name,
+		Date:         input.Date,
+		Amount:       input.Amount,
+		Description:  input.Description,
+		SchoolID:     input.SchoolID,
+		AttendanceID: input.AttendanceID,
+		Receipts:     receipts,
+		Status:       "pending",
+		CreatedAt:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	created, err := database.AddClaimMongo(claim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// Check the monthly limit again now that the claim is stored. Of two claims submitted at
+	// once, the later check sees both, so together they cannot get past the limit.
+	if err := checkClaimLimits(cat, userID, input.Date, created.ID.Hex(), input.Amount); err != nil {
+		database.DeleteClaimMongo(created.ID.Hex())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Route through the shared approval pipeline
+	req := database.PendingRequestMongo{
+		Type:      "claim",
+		UserID:    userID,
+		UserName:  userName,
+		Date:      input.Date,
+		Reason:    input.Description,
+		Details:   fmt.Sprintf("%s - %s (%d bukti)", cat.Name, export.Rupiah(input.Amount), len(receipts)),
+		Status:    "pending",
+		CreatedAt: time.Now().Format("2006-01-02"),
+		RefID:     created.ID.Hex(),
+	}
+	if _, err := database.AddPendingRequestMongo(req); err != nil {
+		database.DeleteClaimMongo(created.ID.Hex())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func DeleteClaimMongo(c *gin.Context) {
+	id := c.Param("id")
+	claim, err := database.GetClaimByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+	if claim.UserID != c.MustGet("userID").(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own claims"})
+		return
+	}
+	if claim.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending claims can be deleted"})
+		return
+	}
+
+	if err := database.DeleteClaimMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	database.DeletePendingRequestByRefID(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Claim deleted"})
+}
+
+// sendClaimReceipt serves receipt :index of a claim
+func sendClaimReceipt(c *gin.Context, claim *database.ClaimMongo) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(claim.Receipts) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+	receipt := claim.Receipts[index]
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, strings.ReplaceAll(receipt.Name, `"`, "")))
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	// Receipts from before the file service are still inline until MigrateInlineFiles runs
+	if receipt.FileID == "" {
+		contentType, data, err := storage.ParseDataURL(receipt.Data)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+	f, err := database.GetFileByIDMongo(receipt.FileID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+	content, err := database.OpenFileMongo(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+	c.DataFromReader(http.StatusOK, f.Size, f.ContentType, content, nil)
+}
+
+func GetMyClaimReceiptMongo(c *gin.Context) {
+	claim, err := database.GetClaimByIDMongo(c.Param("id"))
+	if err != nil || claim.UserID != c.MustGet("userID").(string) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+	sendClaimReceipt(c, claim)
+}
+
+// --- Admin ---
+
+func GetClaimSettingsMongo(c *gin.Context) {
+	settings, err := database.GetClaimSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateClaimSettingsMongo(c *gin.Context) {
+	var input database.ClaimSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.PayoutMethod != "payroll" && input.PayoutMethod != "transfer" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payout_method must be payroll or transfer"})
+		return
+	}
+	seen := make(map[string]bool)
+	for i, cat := range input.Categories {
+		code := strings.ToUpper(strings.TrimSpace(cat.Code))
+		if code == "" || strings.TrimSpace(cat.Name) == "" || seen[code] || cat.PerClaimLimit < 0 || cat.MonthlyLimit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each category needs a unique code, a name and non-negative limits"})
+			return
+		}
+		seen[code] = true
+		input.Categories[i].Code = code
+	}
+	if input.Categories == nil {
+		input.Categories = []database.ClaimCategory{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateClaimSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+func claimFilterFromQuery(c *gin.Context) database.ClaimFilter {
+	return database.ClaimFilter{
+		UserID:        c.Query("user_id"),
+		Status:        c.Query("status"),
+		Category:      strings.ToUpper(c.Query("category")),
+		From:          c.Query("from"),
+		To:            c.Query("to"),
+		PayoutMethod:  c.Query("payout_method"),
+		PayrollPeriod: c.Query("payroll_period"),
+	}
+}
+
+func GetClaimsMongo(c *gin.Context) {
+	claims, err := database.GetClaimsMongo(claimFilterFromQuery(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+func GetClaimReceiptMongo(c *gin.Context) {
+	claim, err := database.GetClaimByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Claim not found"})
+		return
+	}
+	sendClaimReceipt(c, claim)
+}
+
+// ExportClaimPayoutsMongo lists approved claims awaiting a separate transfer, with bank details
+func ExportClaimPayoutsMongo(c *gin.Context) {
+	claims, err := database.GetClaimsMongo(database.ClaimFilter{Status: "approved", PayoutMethod: "transfer", From: c.Query("from"), To: c.Query("to")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	users, err := database.GetAllUsersMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	usersByID := make(map[string]database.UserMongo, len(users))
+	for _, u := range users {
+		usersByID[u.ID.Hex()] = u
+	}
+
+	w, ok := startExport(c, "reimbursement-payout-"+time.Now().Format("2006-01-02"), export.Document{
+		Title:      "Daftar Pembayaran Reimbursement",
+		Subtitle:   "Per " + time.Now().Format("2006-01-02"),
+		Headers:    []string{"ID Klaim", "Nama", "Kategori", "Tanggal", "Keterangan", "Kode Bank", "No. Rekening", "Pemilik Rekening", "Jumlah"},
+		Signatures: []string{"Dibuat oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+	var total int64
+	for _, cl := range claims {
+		u := usersByID[cl.UserID]
+		if err := w.WriteRow([]string{cl.ID.Hex(), cl.UserName, cl.CategoryName, cl.Date, cl.Description, u.BankCode, u.BankAccount, u.BankAccountHolder, export.Rupiah(cl.Amount)}); err != nil {
+			log.Printf("Export claim payouts error: %v", err)
+			return
+		}
+		total += cl.Amount
+	}
+	if err := w.WriteRow([]string{"Total", "", "", "", "", "", "", "", export.Rupiah(total)}); err != nil {
+		log.Printf("Export claim payouts error: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export claim payouts error: %v", err)
+	}
+}
+
+// MarkClaimsPaidMongo records that approved claims on the payout list were transferred
+func MarkClaimsPaidMongo(c *gin.Context) {
+	var input struct {
+		IDs       []string `json:"ids" binding:"required"`
+		Reference string   `json:"reference" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	paid, err := database.MarkClaimsPaidMongo(input.IDs, time.Now().Format("2006-01-02 15:04:05"), input.Reference)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("%d claims marked as paid", paid), "paid": paid, "skipped": int64(len(input.IDs)) - paid})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Who may read an employee field, from least to most privileged
+const (
+	accessDirectory = iota // any signed-in user
+	accessManager          // managers: personal and employment details
+	accessFull             // the employee themselves and admins
+)
+
+// employeeFieldAccess lists the fields visible below full access; fields not listed here,
+// such as NIK, NPWP, bank and BPJS details, need full access
+var employeeFieldAccess = map[string]int{
+	"id":            accessDirectory,
+	"user_id":       accessDirectory,
+	"name":          accessDirectory,
+	"email":         accessDirectory,
+	"phone":         accessDirectory,
+	"center":        accessDirectory,
+	"roles":         accessDirectory,
+	"jabatan":       accessDirectory,
+	"branch_id":     accessDirectory,
+	"photo_url":     accessDirectory,
+	"photo_file_id": accessDirectory,
+	"reports_to":    accessDirectory,
+
+	"role":               accessManager,
+	"is_admin":           accessManager,
+	"sex":                accessManager,
+	"pob":                accessManager,
+	"dob":                accessManager,
+	"age":                accessManager,
+	"religion":           accessManager,
+	"address1":           accessManager,
+	"address2":           accessManager,
+	"education_level":    accessManager,
+	"institution":        accessManager,
+	"major":              accessManager,
+	"graduation_year":    accessManager,
+	"hire_date":          accessManager,
+	"employment_type":    accessManager,
+	"probation_end":      accessManager,
+	"contract_start":     accessManager,
+	"contract_end":       accessManager,
+	"termination_date":   accessManager,
+	"termination_reason": accessManager,
+}
+
+// employeeProfile is the full view of an employee record. Callers fill photo_url first.
+func employeeProfile(user *database.UserMongo) gin.H {
+	return gin.H{
+		"id":              user.ID.Hex(),
+		"user_id":         user.ID.Hex(),
+		"email":           user.Email,
+		"name":            user.Name,
+		"role":            user.Role,
+		"is_admin":        user.IsAdmin,
+		"center":          user.Center,
+		"roles":           user.Roles,
+		"photo_url":       user.PhotoURL,
+		"branch_id":       user.BranchID,
+		"sex":             user.Sex,
+		"pob":             user.PoB,
+		"dob":             user.DoB,
+		"age":             user.Age,
+		"religion":        user.Religion,
+		"phone":           user.Phone,
+		"address1":        user.Address1,
+		"address2":        user.Address2,
+		"nik":             user.NIK,
+		"npwp":            user.NPWP,
+		"education_level": user.EducationLevel,
+		"institution":     user.Institution,
+		"major":           user.Major,
+		"graduation_year": user.GraduationYear,
+		"bank_account":    user.BankAccount,
+		"status_ptkp":     user.StatusPTKP,
+		"jabatan":         user.Jabatan,
+		// BPJS membership
+		"bpjs_kesehatan_no":       user.BPJSKesehatanNo,
+		"bpjs_ketenagakerjaan_no": user.BPJSKetenagakerjaanNo,
+		// Salary transfer
+		"bank_code":           user.BankCode,
+		"bank_account_holder": user.BankAccountHolder,
+		// Employment
+		"hire_date":          user.HireDate,
+		"termination_date":   user.TerminationDate,
+		"employment_type":    user.EmploymentType,
+		"probation_end":      user.ProbationEnd,
+		"contract_start":     user.ContractStart,
+		"contract_end":       user.ContractEnd,
+		"termination_reason": user.TerminationReason,
+		// Reporting line
+		"reports_to": user.ReportsTo,
+		// Uploaded photo; photo_url above is its signed URL
+		"photo_file_id": user.PhotoFileID,
+	}
+}
+
+// employeeAccess is the caller's access to one employee's fields
+func employeeAccess(c *gin.Context, user *database.UserMongo) int {
+	if isAdmin, ok := c.Get("isAdmin"); ok && isAdmin.(bool) {
+		return accessFull
+	}
+	if c.MustGet("userID").(string) == user.ID.Hex() {
+		return accessFull
+	}
+	if role, _ := c.Get("role"); role == "manager" {
+		return accessManager
+	}
+	return accessDirectory
+}
+
+// employeeView returns the fields of an employee the caller may read
+func employeeView(c *gin.Context, user *database.UserMongo) gin.H {
+	withPhotoURL(c, user)
+	profile := employeeProfile(user)
+	access := employeeAccess(c, user)
+	if access == accessFull {
+		return profile
+	}
+	view := gin.H{}
+	for field, value := range profile {
+		if level, ok := employeeFieldAccess[field]; ok && level <= access {
+			view[field] = value
+		}
+	}
+	return view
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"kkhris-clone/database"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateEmployment checks the employment type and that the dates are valid and in order
+func validateEmployment(u *database.UserMongo) error {
+	if _, ok := database.EmploymentTypes[u.EmploymentType]; u.EmploymentType != "" && !ok {
+		return errors.New("employment_type must be permanent, contract, freelance or intern")
+	}
+	dates := map[string]string{
+		"hire_date":        u.HireDate,
+		"probation_end":    u.ProbationEnd,
+		"contract_start":   u.ContractStart,
+		"contract_end":     u.ContractEnd,
+		"termination_date": u.TerminationDate,
+	}
+	for field, value := range dates {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return errors.New(field + " must be YYYY-MM-DD")
+		}
+	}
+	// YYYY-MM-DD compares correctly as a string
+	if u.EmploymentType == "contract" && u.ContractEnd == "" {
+		return errors.New("contract_end is required for contract (PKWT) employees")
+	}
+	if u.ContractStart != "" && u.ContractEnd != "" && u.ContractEnd < u.ContractStart {
+		return errors.New("contract_end must not be before contract_start")
+	}
+	if u.HireDate != "" && u.ProbationEnd != "" && u.ProbationEnd < u.HireDate {
+		return errors.New("probation_end must not be before hire_date")
+	}
+	if u.HireDate != "" && u.TerminationDate != "" && u.TerminationDate < u.HireDate {
+		return errors.New("termination_date must not be before hire_date")
+	}
+	if u.TerminationDate == "" && u.TerminationReason != "" {
+		return errors.New("termination_reason requires termination_date")
+	}
+	return nil
+}
+
+// recordEmploymentEvents stores what changed in the employment terms. The user is already saved,
+// so a failure is logged rather than returned.
+func recordEmploymentEvents(c *gin.Context, before, after *database.UserMongo, note string) {
+	events := database.EmploymentEvents(before, after)
+	if err := database.AddEmploymentEventsMongo(events, c.MustGet("userID").(string), note, time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("Record employment events for %s error: %v", after.ID.Hex(), err)
+	}
+}
+
+func employmentResponse(user *database.UserMongo) (gin.H, error) {
+	events, err := database.GetEmploymentEventsMongo(user.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	year := time.Now().Year()
+	return gin.H{
+		"user_id":            user.ID.Hex(),
+		"name":               user.Name,
+		"employment_type":    user.EmploymentType,
+		"hire_date":          user.HireDate,
+		"probation_end":      user.ProbationEnd,
+		"contract_start":     user.ContractStart,
+		"contract_end":       user.ContractEnd,
+		"termination_date":   user.TerminationDate,
+		"termination_reason": user.TerminationReason,
+		"leave_entitlement":  database.LeaveEntitlement(user, year),
+		"events":             events,
+	}, nil
+}
+
+// GetEmploymentMongo returns a user's employment terms and event history
+func GetEmploymentMongo(c *gin.Context) {
+	user, err := database.GetUserByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	resp, err := employmentResponse(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetMyEmploymentMongo returns the caller's own employment terms and history
+func GetMyEmploymentMongo(c *gin.Context) {
+	user, err := database.GetUserByIDMongo(c.MustGet("userID").(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	resp, err := employmentResponse(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateEmploymentMongo changes the employment terms, e.g. a contract extension or termination,
+// and records the change with an optional note. Omitted fields keep their value; an empty string
+// clears one.
+func UpdateEmploymentMongo(c *gin.Context) {
+	var input struct {
+		EmploymentType    *string `json:"employment_type"`
+		HireDate          *string `json:"hire_date"`
+		ProbationEnd      *string `json:"probation_end"`
+		ContractStart     *string `json:"contract_start"`
+		ContractEnd       *string `json:"contract_end"`
+		TerminationDate   *string `json:"termination_date"`
+		TerminationReason *string `json:"termination_reason"`
+		Note              string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	existing, err := database.GetUserByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	updated := *existing
+	for _, f := range []struct {
+		value *string
+		field *string
+	}{
+		{input.EmploymentType, &updated.EmploymentType},
+		{input.HireDate, &updated.HireDate},
+		{input.ProbationEnd, &updated.ProbationEnd},
+		{input.ContractStart, &updated.ContractStart},
+		{input.ContractEnd, &updated.ContractEnd},
+		{input.TerminationDate, &updated.TerminationDate},
+		{input.TerminationReason, &updated.TerminationReason},
+	} {
+		if f.value != nil {
+			*f.field = *f.value
+		}
+	}
+	if err := validateEmployment(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateEmploymentMongo(&updated); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordEmploymentEvents(c, existing, &updated, input.Note)
+
+	resp, err := employmentResponse(&updated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetEmploymentAlertsMongo lists contract expiry and probation review alerts (?status=open|acknowledged)
+func GetEmploymentAlertsMongo(c *gin.Context) {
+	alerts, err := database.GetEmploymentAlertsMongo(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+func AcknowledgeEmploymentAlertMongo(c *gin.Context) {
+	ok, err := database.AcknowledgeEmploymentAlertMongo(c.Param("id"), c.MustGet("userID").(string), time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Alert not found or already acknowledged"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alert acknowledged"})
+}
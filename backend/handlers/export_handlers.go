@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startExport validates ?format= (csv, xlsx or pdf; default csv), sets download headers
+// and returns a writer streaming to the response. It writes the error response itself.
+func startExport(c *gin.Context, filename string, doc export.Document) (export.RowWriter, bool) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	contentType, ext, err := export.ContentType(format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, ext))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format, doc)
+	if err != nil {
+		// Headers are already sent; all we can do is log
+		log.Printf("Export %s error: %v", filename, err)
+		return nil, false
+	}
+	return w, true
+}
+
+func periodLabel(from, to string) string {
+	switch {
+	case from != "" && to != "":
+		return "Periode " + from + " s/d " + to
+	case from != "":
+		return "Sejak " + from
+	case to != "":
+		return "Sampai " + to
+	}
+	return "Semua periode"
+}
+
+func attendanceStatusLabel(status string) string {
+	switch status {
+	case "present":
+		return "Hadir"
+	case "ijin":
+		return "Izin"
+	case "sakit":
+		return "Sakit"
+	case "absent", "alpha":
+		return "Alpha"
+	case "holiday":
+		return "Libur"
+	case "weekend":
+		return "Akhir Pekan"
+	case "upcoming":
+		return "-"
+	}
+	return status
+}
+
+// ExportAttendanceRecapMongo streams the recap with the same filters and scoping as GetAttendanceRecapMongo
+func ExportAttendanceRecapMongo(c *gin.Context) {
+	filter := recapFilterFromQuery(c)
+
+	w, ok := startExport(c, "rekap-absensi-"+time.Now().Format("20060102"), export.Document{
+		Title:      "Rekap Absensi",
+		Subtitle:   periodLabel(filter.From, filter.To),
+		Headers:    []string{"Tanggal", "Nama", "Cabang", "Sesi", "Status", "Tipe Aktivitas", "Kategori", "Detail Aktivitas", "Catatan", "Waktu Mulai", "Waktu Selesai"},
+		Signatures: []string{"Dibuat oleh", "Diperiksa oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+
+	err := database.StreamAttendanceRecapMongo(filter, func(r database.AttendanceRecapRow) error {
+		return w.WriteRow([]string{
+			r.Date, r.UserName, r.BranchName, r.Session, attendanceStatusLabel(r.Status), r.ActivityType,
+			strings.Join(r.ActivityCategories, ", "), r.ActivityDetails, r.ActivityNotes, r.StartingTime, r.EndingTime,
+		})
+	})
+	if err != nil {
+		log.Printf("Export attendance recap error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export attendance recap error: %v", err)
+	}
+}
+
+func writeTimesheetExport(c *gin.Context, ts *database.TimesheetMongo) {
+	subtitle := ts.UserName + " - " + ts.Period + " - Status: " + ts.Status
+	if ts.SignedAt != "" {
+		subtitle += " (ditandatangani " + ts.SignedAt + ")"
+	}
+
+	w, ok := startExport(c, "timesheet-"+filenameSlug(ts.Period)+"-"+filenameSlug(ts.UserName), export.Document{
+		Title:      "Timesheet Bulanan",
+		Subtitle:   subtitle,
+		Headers:    []string{"Tanggal", "Jenis Hari", "Status", "Jenis Izin", "Jam", "Sesi", "School Class", "Aktivitas"},
+		Signatures: []string{"Karyawan", "Manajer"},
+	})
+	if !ok {
+		return
+	}
+
+	for _, d := range ts.Days {
+		dayType := d.DayType
+		if d.Holiday != "" {
+			dayType += " (" + d.Holiday + ")"
+		}
+		if err := w.WriteRow([]string{
+			d.Date, dayType, attendanceStatusLabel(d.Status), d.LeaveType,
+			strconv.FormatFloat(float64(d.Minutes)/60, 'f', 2, 64),
+			strconv.Itoa(d.Sessions), strconv.Itoa(d.SchoolClasses), strings.Join(d.Activities, ", "),
+		}); err != nil {
+			log.Printf("Export timesheet error: %v", err)
+			return
+		}
+	}
+	t := ts.Totals
+	if err := w.WriteRow([]string{
+		"Total", "", fmt.Sprintf("Hadir %d, Izin %d, Sakit %d, Alpha %d", t.PresentDays, t.LeaveDays, t.SickDays, t.AbsentDays), "",
+		strconv.FormatFloat(float64(t.Minutes)/60, 'f', 2, 64), strconv.Itoa(t.Sessions), strconv.Itoa(t.SchoolClasses), "",
+	}); err != nil {
+		log.Printf("Export timesheet error: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export timesheet error: %v", err)
+	}
+}
+
+// ExportTimesheetMongo exports the caller's own timesheet for a period
+func ExportTimesheetMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	period := c.Param("period")
+
+	if _, err := time.Parse("2006-01", period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be YYYY-MM"})
+		return
+	}
+
+	ts, err := currentTimesheet(userID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeTimesheetExport(c, ts)
+}
+
+func ExportTimesheetAdminMongo(c *gin.Context) {
+	ts, err := database.GetTimesheetByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+	writeTimesheetExport(c, ts)
+}
+
+func leaveFilterFromQuery(c *gin.Context) database.LeaveReportFilter {
+	return database.LeaveReportFilter{
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		BranchID:   c.Query("branch_id"),
+		UserID:     c.Query("user_id"),
+		Statuses:   splitQueryList(c.Query("status")),
+		LeaveTypes: splitQueryList(c.Query("leave_type")),
+	}
+}
+
+// GetLeaveReportMongo lists work permits with user and branch names
+func GetLeaveReportMongo(c *gin.Context) {
+	rows, err := database.GetLeaveReportMongo(leaveFilterFromQuery(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func ExportLeaveReportMongo(c *gin.Context) {
+	filter := leaveFilterFromQuery(c)
+	rows, err := database.GetLeaveReportMongo(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	w, ok := startExport(c, "laporan-cuti-"+time.Now().Format("20060102"), export.Document{
+		Title:      "Laporan Cuti & Izin",
+		Subtitle:   periodLabel(filter.From, filter.To),
+		Headers:    []string{"Tanggal", "Nama", "Cabang", "Sesi", "Jenis", "Alasan", "Status"},
+		Signatures: []string{"Dibuat oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+	for _, r := range rows {
+		if err := w.WriteRow([]string{r.Date, r.UserName, r.BranchName, r.Session, r.LeaveType, r.Reason, r.Status}); err != nil {
+			log.Printf("Export leave report error: %v", err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export leave report error: %v", err)
+	}
+}
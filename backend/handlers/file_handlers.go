@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"kkhris-clone/database"
+	"kkhris-clone/storage"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// fileURLTTL is how long a signed download URL stays valid
+	fileURLTTL = 15 * time.Minute
+	// maxUploadBytes bounds the multipart body: the largest purpose plus form overhead
+	maxUploadBytes = 6 << 20
+)
+
+// filePurpose limits what an upload may contain
+type filePurpose struct {
+	MaxBytes int64
+	Types    map[string]bool
+}
+
+var imageTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/webp": true}
+var documentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "application/pdf": true}
+
+var filePurposes = map[string]filePurpose{
+	"photo":    {MaxBytes: 2 << 20, Types: imageTypes},
+	"document": {MaxBytes: 5 << 20, Types: documentTypes},
+	"receipt":  {MaxBytes: 2 << 20, Types: documentTypes},
+}
+
+var (
+	fileURLKey     []byte
+	fileURLKeyOnce sync.Once
+)
+
+// fileURLSecret signs download URLs with its own key, never the JWT secret. FILE_URL_SECRET
+// lets several instances share it; without it each process signs with a random key, so links
+// stop working after a restart.
+func fileURLSecret() []byte {
+	fileURLKeyOnce.Do(func() {
+		if s := os.Getenv("FILE_URL_SECRET"); s != "" {
+			fileURLKey = []byte(s)
+			return
+		}
+		fileURLKey = make([]byte, 32)
+		if _, err := rand.Read(fileURLKey); err != nil {
+			log.Fatalf("Generate file URL key: %v", err)
+		}
+		log.Println("FILE_URL_SECRET is not set; signed file links are valid for this process only")
+	})
+	return fileURLKey
+}
+
+func fileSignature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, fileURLSecret())
+	mac.Write([]byte(id + "." + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apiBaseURL is where the browser reaches the API: PUBLIC_API_URL, or the request's own host
+func apiBaseURL(c *gin.Context) string {
+	if base := os.Getenv("PUBLIC_API_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api"
+}
+
+// signedFileURL returns a download URL for a file that works without the auth header, so it can
+// be used directly in <img> and <a> tags, until it expires
+func signedFileURL(c *gin.Context, id string) string {
+	if id == "" {
+		return ""
+	}
+	expires := time.Now().Add(fileURLTTL).Unix()
+	return fmt.Sprintf("%s/files/%s/content?expires=%d&sig=%s", apiBaseURL(c), id, expires, fileSignature(id, expires))
+}
+
+// sniffContentType trusts the bytes rather than the Content-Type the client sent
+func sniffContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
+
+// saveUpload validates data against the purpose and stores it for the owner
+func saveUpload(ownerID, purposeName, name string, data []byte) (*database.FileMongo, error) {
+	purpose, ok := filePurposes[purposeName]
+	if !ok {
+		return nil, fmt.Errorf("purpose must be photo, document or receipt")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("File kosong")
+	}
+	if int64(len(data)) > purpose.MaxBytes {
+		return nil, fmt.Errorf("Ukuran file maksimal %d MB", purpose.MaxBytes>>20)
+	}
+	contentType := sniffContentType(data)
+	if !purpose.Types[contentType] {
+		return nil, fmt.Errorf("Tipe file %s tidak diizinkan", contentType)
+	}
+	return database.SaveFileMongo(database.FileMongo{
+		OwnerID:     ownerID,
+		Purpose:     purposeName,
+		Name:        name,
+		ContentType: contentType,
+		CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
+	}, data)
+}
+
+// resolveFileRef turns what a client sent for a file field into a file ID. New clients send the
+// ID of a file they uploaded; older clients still send a base64 data URL, which is stored here.
+func resolveFileRef(c *gin.Context, ownerID, purpose, ref string) (string, error) {
+	switch {
+	case ref == "":
+		return "", nil
+	case strings.HasPrefix(ref, "data:"):
+		_, data, err := storage.ParseDataURL(ref)
+		if err != nil {
+			return "", err
+		}
+		f, err := saveUpload(ownerID, purpose, purpose, data)
+		if err != nil {
+			return "", err
+		}
+		return f.ID.Hex(), nil
+	}
+	f, err := database.GetFileByIDMongo(ref)
+	if err != nil {
+		return "", fmt.Errorf("File tidak ditemukan")
+	}
+	// Only the uploader may attach a file, admins included: an admin filling in someone else's
+	// record attaches files they uploaded themselves, never another user's upload
+	if f.OwnerID != c.MustGet("userID").(string) || f.Purpose != purpose {
+		return "", fmt.Errorf("File tidak dapat digunakan")
+	}
+	return f.ID.Hex(), nil
+}
+
+// canReadFile lets owners and admins read any file and everyone read photos
+func canReadFile(c *gin.Context, f *database.FileMongo) bool {
+	if f.Purpose == "photo" || f.OwnerID == c.MustGet("userID").(string) {
+		return true
+	}
+	if isAdmin, _ := c.Get("isAdmin"); isAdmin == true {
+		return true
+	}
+	role, _ := c.Get("role")
+	return role == "manager"
+}
+
+// --- Response helpers ---
+
+// storedPhotoURL keeps external photo links and drops data URLs and our own signed URLs, which
+// edit forms send back unchanged
+func storedPhotoURL(url string) string {
+	if strings.HasPrefix(url, "data:") || (strings.Contains(url, "/files/") && strings.Contains(url, "sig=")) {
+		return ""
+	}
+	return url
+}
+
+func withPhotoURL(c *gin.Context, user *database.UserMongo) {
+	if user.PhotoFileID != "" {
+		user.PhotoURL = signedFileURL(c, user.PhotoFileID)
+	}
+}
+
+func withPermitFileURLs(c *gin.Context, permits []database.WorkPermitMongo) {
+	for i := range permits {
+		if permits[i].SupportingFileID != "" {
+			permits[i].SupportingFile = signedFileURL(c, permits[i].SupportingFileID)
+		}
+	}
+}
+
+func withRequestFileURLs(c *gin.Context, requests []database.PendingRequestMongo) {
+	ids := []string{}
+	for _, r := range requests {
+		if r.SupportingFileID != "" {
+			ids = append(ids, r.SupportingFileID)
+		}
+	}
+	files, err := database.GetFilesByIDsMongo(ids)
+	if err != nil {
+		files = map[string]database.FileMongo{}
+	}
+	for i := range requests {
+		if requests[i].SupportingFileID != "" {
+			requests[i].SupportingFile = signedFileURL(c, requests[i].SupportingFileID)
+			requests[i].SupportingFileType = files[requests[i].SupportingFileID].ContentType
+		}
+	}
+}
+
+// --- Handlers ---
+
+// UploadFileMongo accepts a multipart "file" with a "purpose" and returns the file ID to put in
+// documents, plus a signed URL for immediate preview
+func UploadFileMongo(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	purposeName := c.PostForm("purpose")
+	purpose, ok := filePurposes[purposeName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "purpose must be photo, document or receipt"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required (max 5 MB)"})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, purpose.MaxBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := saveUpload(c.MustGet("userID").(string), purposeName, header.Filename, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"id":           f.ID.Hex(),
+		"name":         f.Name,
+		"content_type": f.ContentType,
+		"size":         f.Size,
+		"url":          signedFileURL(c, f.ID.Hex()),
+	})
+}
+
+// GetFileURLMongo issues a fresh signed URL for a file the caller may read
+func GetFileURLMongo(c *gin.Context) {
+	f, err := database.GetFileByIDMongo(c.Param("id"))
+	if err != nil || !canReadFile(c, f) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":           f.ID.Hex(),
+		"name":         f.Name,
+		"content_type": f.ContentType,
+		"size":         f.Size,
+		"url":          signedFileURL(c, f.ID.Hex()),
+		"expires_in":   int(fileURLTTL.Seconds()),
+	})
+}
+
+// ServeFileMongo streams a file to anyone holding an unexpired signed URL
+func ServeFileMongo(c *gin.Context) {
+	id := c.Param("id")
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(fileSignature(id, expires)), []byte(c.Query("sig"))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Link file tidak valid atau sudah kedaluwarsa"})
+		return
+	}
+	f, err := database.GetFileByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	content, err := database.OpenFileMongo(f)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, strings.ReplaceAll(f.Name, `"`, "")))
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", expires-time.Now().Unix()))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, f.Size, f.ContentType, content, nil)
+}
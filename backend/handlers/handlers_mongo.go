@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"kkhris-clone/database"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +36,10 @@ func LoginMongo(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	if !database.UserIsActive(user.Status) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Akun tidak aktif. Hubungi admin."})
+		return
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id":  user.ID.Hex(),
@@ -82,6 +88,15 @@ func AuthMiddlewareMongo() gin.HandlerFunc {
 		}
 
 		claims := token.Claims.(jwt.MapClaims)
+
+		// Tokens stop working as soon as the account is deactivated or deleted
+		status, err := database.GetUserStatusMongo(claims["user_id"].(string))
+		if err != nil || !database.UserIsActive(status) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is not active"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userID", claims["user_id"].(string))
 		c.Set("email", claims["email"].(string))
 		c.Set("role", claims["role"].(string))
@@ -105,6 +120,18 @@ func AdminMiddlewareMongo() gin.HandlerFunc {
 	}
 }
 
+// AdminOnlyMiddlewareMongo guards routes managers must not reach, such as payroll and compensation
+func AdminOnlyMiddlewareMongo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAdmin, exists := c.Get("isAdmin"); !exists || !isAdmin.(bool) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func GetCurrentUserMongo(c *gin.Context) {
 	userID := c.MustGet("userID").(string)
 
@@ -131,34 +158,9 @@ func GetUserProfileMongo(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	withPhotoURL(c, user)
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":              user.ID.Hex(),
-		"email":           user.Email,
-		"name":            user.Name,
-		"role":            user.Role,
-		"is_admin":        user.IsAdmin,
-		"center":          user.Center,
-		"roles":           user.Roles,
-		"photo_url":       user.PhotoURL,
-		"branch_id":       user.BranchID,
-		"sex":             user.Sex,
-		"pob":             user.PoB,
-		"dob":             user.DoB,
-		"age":             user.Age,
-		"religion":        user.Religion,
-		"phone":           user.Phone,
-		"address1":        user.Address1,
-		"nik":             user.NIK,
-		"npwp":            user.NPWP,
-		"education_level": user.EducationLevel,
-		"institution":     user.Institution,
-		"major":           user.Major,
-		"graduation_year": user.GraduationYear,
-		"bank_account":    user.BankAccount,
-		"status_ptkp":     user.StatusPTKP,
-		"jabatan":         user.Jabatan,
-	})
+	c.JSON(http.StatusOK, employeeProfile(user))
 }
 
 // --- Attendance Handlers ---
@@ -211,6 +213,10 @@ func AddAttendanceMongo(c *gin.Context) {
 		ActivityDocs       string   `json:"activity_docs"`
 		ActivityNotes      string   `json:"activity_notes"`
 		Session            string   `json:"session"`
+		SchoolID           string   `json:"school_id"`
+		// Admin-only: record on behalf of another user and/or bypass rules
+		UserID   string                   `json:"user_id"`
+		Override *AttendanceOverrideInput `json:"override"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -218,6 +224,14 @@ func AddAttendanceMongo(c *gin.Context) {
 		return
 	}
 
+	if input.UserID != "" && input.UserID != userID {
+		if !isAdminCaller(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only record your own attendance"})
+			return
+		}
+		userID = input.UserID
+	}
+
 	att := database.AttendanceMongo{
 		UserID:             userID,
 		Date:               input.Date,
@@ -229,10 +243,31 @@ func AddAttendanceMongo(c *gin.Context) {
 		ActivityDocs:       input.ActivityDocs,
 		ActivityNotes:      input.ActivityNotes,
 		Session:            input.Session,
+		SchoolID:           input.SchoolID,
 		Status:             "present",
 		CreatedAt:          time.Now().Format("2006-01-02 15:04:05"),
 	}
 
+	rules, err := database.GetAttendanceRulesMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	fieldErrors, err := validateAttendance(&att, *rules, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	fieldErrors, overrides, ok := applyRuleOverrides(c, fieldErrors, input.Override)
+	if !ok {
+		return
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Attendance validation failed", "fields": fieldErrors})
+		return
+	}
+	att.RuleOverrides = overrides
+
 	created, err := database.AddAttendanceMongo(att)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -244,64 +279,114 @@ func AddAttendanceMongo(c *gin.Context) {
 
 // --- Employee Handlers ---
 
+// GetEmployeesMongo lists the directory; each entry has the fields the caller may read
 func GetEmployeesMongo(c *gin.Context) {
-	employees, err := database.GetAllEmployeesMongo()
+	users, err := database.GetDirectoryUsersMongo()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	employees := make([]gin.H, 0, len(users))
+	for i := range users {
+		employees = append(employees, employeeView(c, &users[i]))
+	}
 
 	c.JSON(http.StatusOK, employees)
 }
 
+// GetAttendanceRecapMongo returns a filtered, cursor-paginated attendance recap.
+// Admins and managers see everything; other users see their own records and everyone's leave.
 func GetAttendanceRecapMongo(c *gin.Context) {
-	// Get all attendance records with user names
-	records, err := database.GetAllAttendanceRecordsMongo()
+	filter := recapFilterFromQuery(c)
+
+	records, nextCursor, err := database.GetAttendanceRecapMongo(filter)
+	if err == database.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Map to include user names
-	var result []gin.H
-	userCache := make(map[string]string)
-
-	for _, r := range records {
-		userName := ""
-		if cached, ok := userCache[r.UserID]; ok {
-			userName = cached
-		} else {
-			user, err := database.GetUserByIDMongo(r.UserID)
-			if err == nil && user != nil {
-				userName = user.Name
-				userCache[r.UserID] = userName
-			}
+	response := gin.H{
+		"items":       records,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	}
+	// Totals cover the whole filtered set, so only compute them for the first page
+	if filter.Cursor == "" {
+		summary, err := database.GetAttendanceRecapSummaryMongo(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+		response["summary"] = summary
+	}
 
-		result = append(result, gin.H{
-			"id":                  r.ID.Hex(),
-			"user_id":             r.UserID,
-			"user_name":           userName,
-			"date":                r.Date,
-			"session":             r.Session,
-			"status":              r.Status,
-			"activity_type":       r.ActivityType,
-			"activity_categories": r.ActivityCategories,
-			"activity_details":    r.ActivityDetails,
-			"activity_notes":      r.ActivityNotes,
-			"starting_time":       r.StartingTime,
-			"ending_time":         r.EndingTime,
-			"check_in":            r.StartingTime,
-			"check_out":           r.EndingTime,
-		})
+	c.JSON(http.StatusOK, response)
+}
+
+// recapFilterFromQuery reads the recap filters shared by the JSON and export endpoints
+func recapFilterFromQuery(c *gin.Context) database.AttendanceRecapFilter {
+	filter := database.AttendanceRecapFilter{
+		From:          c.Query("from"),
+		To:            c.Query("to"),
+		BranchID:      c.Query("branch_id"),
+		UserID:        c.Query("user_id"),
+		Statuses:      splitQueryList(c.Query("status")),
+		ActivityTypes: splitQueryList(c.Query("activity_type")),
+		Cursor:        c.Query("cursor"),
+		Limit:         100,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
 	}
+	if !hasAdminAccess(c) {
+		filter.ViewerID = c.MustGet("userID").(string)
+	}
+	return filter
+}
 
-	c.JSON(http.StatusOK, result)
+// splitQueryList turns "a,b" into ["a", "b"], dropping blanks
+func splitQueryList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
+// GetEmployeeMongo returns one employee with the fields the caller may read. Employees hidden
+// from the directory or deactivated are visible only to themselves, managers and admins.
 func GetEmployeeMongo(c *gin.Context) {
-	// For now, return empty - needs implementation
-	c.JSON(http.StatusOK, gin.H{})
+	user, err := database.GetUserByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+	if isAdmin, _ := c.Get("isAdmin"); user.Status == "deleted" && isAdmin != true {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+	if employeeAccess(c, user) == accessDirectory {
+		listed, err := database.InDirectoryMongo(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !listed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, employeeView(c, user))
 }
 
 // --- Announcements Handlers ---
@@ -472,6 +557,7 @@ func GetWorkPermitsMongo(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	withPermitFileURLs(c, permits)
 
 	c.JSON(http.StatusOK, permits)
 }
@@ -484,13 +570,24 @@ func AddWorkPermitMongo(c *gin.Context) {
 		Session        string `json:"session" binding:"required"`
 		LeaveType      string `json:"leave_type" binding:"required"`
 		Reason         string `json:"reason" binding:"required"`
-		SupportingFile string `json:"supporting_file"`
+		SupportingFile string `json:"supporting_file"` // legacy base64 data URL
+		// ID returned by POST /api/files
+		SupportingFileID string `json:"supporting_file_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	fileRef := input.SupportingFileID
+	if fileRef == "" {
+		fileRef = input.SupportingFile
+	}
+	fileID, err := resolveFileRef(c, userID, "document", fileRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Block Annual/Personal leave when quota is exhausted
 	// Annual and Personal leaves consume quota (Sick does not)
@@ -503,19 +600,20 @@ func AddWorkPermitMongo(c *gin.Context) {
 	}
 
 	// Require supporting file for sick leave
-	if input.LeaveType == "Sakit" && input.SupportingFile == "" {
+	if input.LeaveType == "Sakit" && fileID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File pendukung (surat dokter) wajib diisi untuk izin Sakit"})
 		return
 	}
 
 	wp := database.WorkPermitMongo{
-		UserID:         userID,
-		Date:           input.Date,
-		Session:        input.Session,
-		LeaveType:      input.LeaveType,
-		Reason:         input.Reason,
-		SupportingFile: input.SupportingFile,
-		Status:         "pending",
+		UserID:    userID,
+		Date:      input.Date,
+		Session:   input.Session,
+		LeaveType: input.LeaveType,
+		Reason:    input.Reason,
+		Status:    "pending",
+		// Stored in the file service
+		SupportingFileID: fileID,
 	}
 
 	created, err := database.AddWorkPermitMongo(wp)
@@ -532,16 +630,17 @@ func AddWorkPermitMongo(c *gin.Context) {
 	}
 
 	req := database.PendingRequestMongo{
-		Type:           "work_permit",
-		UserID:         userID,
-		UserName:       userName,
-		Date:           input.Date,
-		Reason:         input.Reason,
-		Details:        input.LeaveType + " - " + input.Session,
-		Status:         "pending",
-		CreatedAt:      time.Now().Format("2006-01-02"),
-		RefID:          created.ID.Hex(),
-		SupportingFile: input.SupportingFile,
+		Type:      "work_permit",
+		UserID:    userID,
+		UserName:  userName,
+		Date:      input.Date,
+		Reason:    input.Reason,
+		Details:   input.LeaveType + " - " + input.Session,
+		Status:    "pending",
+		CreatedAt: time.Now().Format("2006-01-02"),
+		RefID:     created.ID.Hex(),
+		// Same file as the work permit
+		SupportingFileID: fileID,
 	}
 	database.AddPendingRequestMongo(req)
 
@@ -613,16 +712,32 @@ func GetUserNotificationsMongo(c *gin.Context) {
 
 // --- Pending Requests Handlers ---
 
+// GetPendingRequestsMongo lists the pending requests the caller may decide (see approvalScope)
 func GetPendingRequestsMongo(c *gin.Context) {
-	requests, err := database.GetPendingRequestsMongo()
+	approverIDs, includeUnrouted, all, err := approvalScope(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var requests []database.PendingRequestMongo
+	if all {
+		requests, err = database.GetPendingRequestsMongo()
+	} else {
+		requests, err = database.GetPendingRequestsForApproversMongo(approverIDs, includeUnrouted)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	withRequestFileURLs(c, requests)
 
 	c.JSON(http.StatusOK, requests)
 }
 
+// pipelineRequestTypes are created by their own handlers together with the record in ref_id;
+// approving one changes that record, so clients may not file them directly
+var pipelineRequestTypes = map[string]bool{"work_permit": true, "overtime": true, "timesheet": true, "claim": true}
+
 func AddPendingRequestMongo(c *gin.Context) {
 	userID := c.MustGet("userID").(string)
 
@@ -638,6 +753,18 @@ func AddPendingRequestMongo(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if pipelineRequestTypes[input.Type] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request type " + input.Type + " is submitted through its own endpoint"})
+		return
+	}
+
+	if input.Type == "delete_attendance" {
+		att, ok := deletableAttendance(c, userID, input.RefID)
+		if !ok {
+			return
+		}
+		input.Date = att.Date
+	}
 
 	user, _ := database.GetUserByIDMongo(userID)
 	userName := "Unknown"
@@ -666,6 +793,75 @@ func AddPendingRequestMongo(c *gin.Context) {
 	c.JSON(http.StatusCreated, created)
 }
 
+// deletableAttendance loads the attendance a delete_attendance request targets and checks that
+// it belongs to userID and that its own date lies in an open period. It writes the error
+// response and returns false otherwise.
+func deletableAttendance(c *gin.Context, userID, refID string) (*database.AttendanceMongo, bool) {
+	att, err := database.GetAttendanceByIDMongo(refID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attendance not found"})
+		return nil, false
+	}
+	if att.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own attendance"})
+		return nil, false
+	}
+	locked, err := database.IsPeriodLockedMongo(userID, att.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if locked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Timesheet periode ini sudah dikunci"})
+		return nil, false
+	}
+	return att, true
+}
+
+// decideRequest checks that req is still pending and, for requests that write attendance,
+// that its period is not locked, then marks it decided. It writes the error response and
+// returns false when the request cannot be decided.
+func decideRequest(c *gin.Context, req *database.PendingRequestMongo, status, reason string) bool {
+	if req.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request sudah diproses"})
+		return false
+	}
+	if status == "approved" && req.Type == "delete_attendance" && req.RefID != "" {
+		if _, ok := deletableAttendance(c, req.UserID, req.RefID); !ok {
+			return false
+		}
+	}
+	if status == "approved" && req.Type == "work_permit" && req.RefID != "" {
+		locked, err := database.IsPeriodLockedMongo(req.UserID, req.Date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if locked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Timesheet periode ini sudah dikunci"})
+			return false
+		}
+	}
+	decided, err := database.UpdateRequestStatusMongo(req.ID.Hex(), status, reason, c.MustGet("userID").(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !decided {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request sudah diproses"})
+		return false
+	}
+	return true
+}
+
+// undoDecision reopens a request whose decision could not be applied and reports the failure
+func undoDecision(c *gin.Context, id, message string) {
+	if err := database.ReopenRequestMongo(id); err != nil {
+		log.Printf("Reopen request %s error: %v", id, err)
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
 func ApproveRequestMongo(c *gin.Context) {
 	id := c.Param("id")
 
@@ -675,18 +871,25 @@ func ApproveRequestMongo(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
 		return
 	}
+	if ok, err := canDecideRequest(c, req); err != nil || !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Request ini tidak ditujukan kepada Anda"})
+		return
+	}
+	if !decideRequest(c, req, "approved", "") {
+		return
+	}
 
 	// Handle based on request type
 	if req.Type == "delete_attendance" && req.RefID != "" {
 		// Delete the attendance
 		if err := database.DeleteAttendanceMongo(req.RefID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attendance: " + err.Error()})
+			undoDecision(c, id, "Failed to delete attendance: "+err.Error())
 			return
 		}
 	} else if req.Type == "work_permit" && req.RefID != "" {
 		// Update work permit status
 		if err := database.UpdateWorkPermitStatus(req.RefID, "approved"); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work permit: " + err.Error()})
+			undoDecision(c, id, "Failed to update work permit: "+err.Error())
 			return
 		}
 
@@ -719,13 +922,21 @@ func ApproveRequestMongo(c *gin.Context) {
 			quota.Remaining = quota.Total - quota.Used
 			database.UpdateLeaveQuotaMongo(*quota)
 		}
-	}
-
-	// Update request status
-	err = database.UpdateRequestStatusMongo(id, "approved", "")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	} else if req.Type == "overtime" && req.RefID != "" {
+		if err := decideOvertime(req, "approved"); err != nil {
+			undoDecision(c, id, "Failed to update overtime request: "+err.Error())
+			return
+		}
+	} else if req.Type == "timesheet" && req.RefID != "" {
+		if err := signOffTimesheet(req.RefID, c.MustGet("userID").(string)); err != nil {
+			undoDecision(c, id, "Failed to sign off timesheet: "+err.Error())
+			return
+		}
+	} else if req.Type == "claim" && req.RefID != "" {
+		if err := approveClaim(req.RefID, c.MustGet("userID").(string)); err != nil {
+			undoDecision(c, id, "Failed to approve claim: "+err.Error())
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Request approved"})
@@ -749,20 +960,35 @@ func RejectRequestMongo(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Request not found"})
 		return
 	}
+	if ok, err := canDecideRequest(c, req); err != nil || !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Request ini tidak ditujukan kepada Anda"})
+		return
+	}
+	if !decideRequest(c, req, "rejected", input.Reason) {
+		return
+	}
 
 	// Update work permit status if applicable
 	if req.Type == "work_permit" && req.RefID != "" {
 		if err := database.UpdateWorkPermitStatus(req.RefID, "rejected"); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work permit: " + err.Error()})
+			undoDecision(c, id, "Failed to update work permit: "+err.Error())
+			return
+		}
+	} else if req.Type == "overtime" && req.RefID != "" {
+		if err := decideOvertime(req, "rejected"); err != nil {
+			undoDecision(c, id, "Failed to update overtime request: "+err.Error())
+			return
+		}
+	} else if req.Type == "timesheet" && req.RefID != "" {
+		if err := rejectTimesheet(req.RefID, input.Reason); err != nil {
+			undoDecision(c, id, "Failed to update timesheet: "+err.Error())
+			return
+		}
+	} else if req.Type == "claim" && req.RefID != "" {
+		if err := rejectClaim(req.RefID, input.Reason); err != nil {
+			undoDecision(c, id, "Failed to update claim: "+err.Error())
 			return
 		}
-	}
-
-	// Update request status
-	err = database.UpdateRequestStatusMongo(id, "rejected", input.Reason)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Request rejected"})
@@ -780,12 +1006,17 @@ func GetAdminStatsMongo(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// GetAllUsersMongo lists users; ?status=active|inactive|deleted|all narrows or widens the
+// default of everyone not deleted
 func GetAllUsersMongo(c *gin.Context) {
-	users, err := database.GetAllUsersMongo()
+	users, err := database.GetUsersByStatusMongo(c.Query("status"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range users {
+		withPhotoURL(c, &users[i])
+	}
 
 	c.JSON(http.StatusOK, users)
 }
@@ -819,6 +1050,24 @@ func CreateUserMongo(c *gin.Context) {
 		StatusPTKP      string `json:"status_ptkp"`
 		Jabatan         string `json:"jabatan"`
 		ShowInDirectory bool   `json:"show_in_directory"`
+		// BPJS membership
+		BPJSKesehatanNo       string `json:"bpjs_kesehatan_no"`
+		BPJSKetenagakerjaanNo string `json:"bpjs_ketenagakerjaan_no"`
+		// Salary transfer
+		BankCode          string `json:"bank_code"`
+		BankAccountHolder string `json:"bank_account_holder"`
+		// Employment
+		HireDate          string `json:"hire_date"`
+		TerminationDate   string `json:"termination_date"`
+		EmploymentType    string `json:"employment_type"`
+		ProbationEnd      string `json:"probation_end"`
+		ContractStart     string `json:"contract_start"`
+		ContractEnd       string `json:"contract_end"`
+		TerminationReason string `json:"termination_reason"`
+		// Direct manager's user ID
+		ReportsTo string `json:"reports_to"`
+		// ID returned by POST /api/files; photo_url may still carry a legacy data URL
+		PhotoFileID string `json:"photo_file_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -832,6 +1081,16 @@ func CreateUserMongo(c *gin.Context) {
 		return
 	}
 
+	photoRef := input.PhotoFileID
+	if photoRef == "" && strings.HasPrefix(input.PhotoURL, "data:") {
+		photoRef = input.PhotoURL
+	}
+	photoFileID, err := resolveFileRef(c, c.MustGet("userID").(string), "photo", photoRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	user := database.UserMongo{
 		Email:           input.Email,
 		Password:        string(hashedPass),
@@ -840,7 +1099,7 @@ func CreateUserMongo(c *gin.Context) {
 		IsAdmin:         input.IsAdmin,
 		Center:          input.Center,
 		Roles:           input.Roles,
-		PhotoURL:        input.PhotoURL,
+		PhotoURL:        storedPhotoURL(input.PhotoURL),
 		BranchID:        input.BranchID,
 		Sex:             input.Sex,
 		PoB:             input.PoB,
@@ -859,6 +1118,32 @@ func CreateUserMongo(c *gin.Context) {
 		StatusPTKP:      input.StatusPTKP,
 		Jabatan:         input.Jabatan,
 		ShowInDirectory: input.ShowInDirectory,
+		// BPJS membership
+		BPJSKesehatanNo:       input.BPJSKesehatanNo,
+		BPJSKetenagakerjaanNo: input.BPJSKetenagakerjaanNo,
+		// Salary transfer
+		BankCode:          input.BankCode,
+		BankAccountHolder: input.BankAccountHolder,
+		// Employment
+		HireDate:          input.HireDate,
+		TerminationDate:   input.TerminationDate,
+		EmploymentType:    input.EmploymentType,
+		ProbationEnd:      input.ProbationEnd,
+		ContractStart:     input.ContractStart,
+		ContractEnd:       input.ContractEnd,
+		TerminationReason: input.TerminationReason,
+		// Reporting line
+		ReportsTo: input.ReportsTo,
+		// Uploaded photo
+		PhotoFileID: photoFileID,
+	}
+	if err := validateEmployment(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.ValidateReportsToMongo("", user.ReportsTo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	created, err := database.CreateUserMongo(user)
@@ -866,6 +1151,7 @@ func CreateUserMongo(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	recordEmploymentEvents(c, &database.UserMongo{}, created, "")
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":       created.ID.Hex(),
@@ -905,6 +1191,24 @@ func UpdateUserMongo(c *gin.Context) {
 		StatusPTKP      string `json:"status_ptkp"`
 		Jabatan         string `json:"jabatan"`
 		ShowInDirectory bool   `json:"show_in_directory"`
+		// BPJS membership
+		BPJSKesehatanNo       string `json:"bpjs_kesehatan_no"`
+		BPJSKetenagakerjaanNo string `json:"bpjs_ketenagakerjaan_no"`
+		// Salary transfer
+		BankCode          string `json:"bank_code"`
+		BankAccountHolder string `json:"bank_account_holder"`
+		// Employment
+		HireDate          string `json:"hire_date"`
+		TerminationDate   string `json:"termination_date"`
+		EmploymentType    string `json:"employment_type"`
+		ProbationEnd      string `json:"probation_end"`
+		ContractStart     string `json:"contract_start"`
+		ContractEnd       string `json:"contract_end"`
+		TerminationReason string `json:"termination_reason"`
+		// Direct manager's user ID
+		ReportsTo string `json:"reports_to"`
+		// ID returned by POST /api/files; photo_url may still carry a legacy data URL
+		PhotoFileID string `json:"photo_file_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -919,6 +1223,19 @@ func UpdateUserMongo(c *gin.Context) {
 		return
 	}
 
+	// An unchanged photo is sent back as is; a new one must be the caller's own upload
+	photoFileID := existingUser.PhotoFileID
+	photoRef := input.PhotoFileID
+	if photoRef == "" && strings.HasPrefix(input.PhotoURL, "data:") {
+		photoRef = input.PhotoURL
+	}
+	if photoRef != existingUser.PhotoFileID {
+		if photoFileID, err = resolveFileRef(c, c.MustGet("userID").(string), "photo", photoRef); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	user := database.UserMongo{
 		Email:           input.Email,
 		Password:        existingUser.Password, // Preserve existing password
@@ -927,7 +1244,7 @@ func UpdateUserMongo(c *gin.Context) {
 		IsAdmin:         input.IsAdmin,
 		Center:          input.Center,
 		Roles:           input.Roles,
-		PhotoURL:        input.PhotoURL,
+		PhotoURL:        storedPhotoURL(input.PhotoURL),
 		BranchID:        input.BranchID,
 		Sex:             input.Sex,
 		PoB:             input.PoB,
@@ -946,6 +1263,32 @@ func UpdateUserMongo(c *gin.Context) {
 		StatusPTKP:      input.StatusPTKP,
 		Jabatan:         input.Jabatan,
 		ShowInDirectory: input.ShowInDirectory,
+		// BPJS membership
+		BPJSKesehatanNo:       input.BPJSKesehatanNo,
+		BPJSKetenagakerjaanNo: input.BPJSKetenagakerjaanNo,
+		// Salary transfer
+		BankCode:          input.BankCode,
+		BankAccountHolder: input.BankAccountHolder,
+		// Employment
+		HireDate:          input.HireDate,
+		TerminationDate:   input.TerminationDate,
+		EmploymentType:    input.EmploymentType,
+		ProbationEnd:      input.ProbationEnd,
+		ContractStart:     input.ContractStart,
+		ContractEnd:       input.ContractEnd,
+		TerminationReason: input.TerminationReason,
+		// Reporting line
+		ReportsTo: input.ReportsTo,
+		// Uploaded photo
+		PhotoFileID: photoFileID,
+	}
+	if err := validateEmployment(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.ValidateReportsToMongo(existingUser.ID.Hex(), user.ReportsTo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	err = database.UpdateUserMongo(id, user)
@@ -953,27 +1296,111 @@ func UpdateUserMongo(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if user.ReportsTo != existingUser.ReportsTo {
+		if err := database.ReroutePendingRequestsMongo(id); err != nil {
+			log.Printf("Reroute pending requests of %s error: %v", id, err)
+		}
+	}
+	user.ID = existingUser.ID
+	recordEmploymentEvents(c, existingUser, &user, "")
 
 	c.JSON(http.StatusOK, gin.H{"message": "User updated"})
 }
 
+// DeleteUserMongo offboards a user: login is blocked and the account disappears from lists,
+// but its history stays until the deleted_users retention period passes or it is restored
 func DeleteUserMongo(c *gin.Context) {
+	setUserStatus(c, []string{"active", "inactive"}, "deleted", "User deleted")
+}
+
+// DeactivateUserMongo blocks login but keeps the user in lists, e.g. during a suspension
+func DeactivateUserMongo(c *gin.Context) {
+	setUserStatus(c, []string{"active"}, "inactive", "User deactivated")
+}
+
+// RestoreUserMongo reactivates a deactivated or deleted user
+func RestoreUserMongo(c *gin.Context) {
+	setUserStatus(c, []string{"inactive", "deleted"}, "active", "User restored")
+}
+
+func setUserStatus(c *gin.Context, from []string, status, message string) {
 	id := c.Param("id")
+	actorID := c.MustGet("userID").(string)
+	if id == actorID && status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate or delete your own account"})
+		return
+	}
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&input)
+	if input.Reason == "" {
+		input.Reason = c.Query("reason")
+	}
 
-	err := database.DeleteUserMongo(id)
+	target, err := database.GetUserByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	// Managers may manage employees but not the admin accounts above them
+	if isAdmin, _ := c.Get("isAdmin"); target.IsAdmin && isAdmin != true {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	ok, err := database.SetUserStatusMongo(id, from, status, input.Reason, actorID, time.Now().Format("2006-01-02 15:04:05"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "User status does not allow this change"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// PurgeUserMongo permanently removes a deleted user once the deleted_users retention period has
+// passed. Registered on the admin-only routes; managers cannot purge.
+func PurgeUserMongo(c *gin.Context) {
+	id := c.Param("id")
+	user, err := database.GetUserByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Status != "deleted" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only deleted users can be purged"})
+		return
+	}
 
-	// Cascade delete related data
-	_ = database.DeleteAttendanceByUser(id)
-	_ = database.DeleteWorkPermitsByUser(id)
-	_ = database.DeletePendingRequestsByUser(id)
-	_ = database.DeleteAwardsByUser(id)
-	_ = database.DeleteLeaveQuotaByUser(id)
+	settings, err := database.GetRetentionSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	days := 0
+	for _, p := range settings.Policies {
+		if p.Category == "deleted_users" {
+			days = p.Days
+		}
+	}
+	deletedAt, err := time.ParseInLocation("2006-01-02 15:04:05", user.DeletedAt, time.Local)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User has no deletion date"})
+		return
+	}
+	if purgeFrom := deletedAt.AddDate(0, 0, days); time.Now().Before(purgeFrom) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Akun baru dapat dihapus permanen mulai " + purgeFrom.Format("2006-01-02")})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+	removed, err := database.PurgeUserMongo(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User purged", "records_removed": removed})
 }
 
 // --- Branch Handlers ---
@@ -1066,6 +1493,8 @@ func UpdateBranchMongo(c *gin.Context) {
 
 // --- Employee CRUD Handlers ---
 
+// CreateEmployeeMongo adds an employee without a login; an admin can set a password later
+// through the user form
 func CreateEmployeeMongo(c *gin.Context) {
 	var input struct {
 		Name           string `json:"name" binding:"required"`
@@ -1095,49 +1524,51 @@ func CreateEmployeeMongo(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Email != "" {
+		if _, err := database.GetUserByEmail(input.Email); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email sudah digunakan"})
+			return
+		}
+	}
 
-	emp := database.EmployeeMongo{
-		Name:           input.Name,
-		Center:         input.Center,
-		Roles:          input.Roles,
-		PhotoURL:       input.PhotoURL,
-		BranchID:       input.BranchID,
-		Sex:            input.Sex,
-		PoB:            input.PoB,
-		DoB:            input.DoB,
-		Age:            input.Age,
-		Religion:       input.Religion,
-		Phone:          input.Phone,
-		Address1:       input.Address1,
-		NIK:            input.NIK,
-		NPWP:           input.NPWP,
-		EducationLevel: input.EducationLevel,
-		Institution:    input.Institution,
-		Major:          input.Major,
-		GraduationYear: input.GraduationYear,
-		BankAccount:    input.BankAccount,
-		StatusPTKP:     input.StatusPTKP,
-	}
-
-	created, err := database.CreateEmployeeMongo(emp)
+	user := database.UserMongo{
+		Email:           input.Email,
+		Name:            input.Name,
+		Role:            "staff",
+		Center:          input.Center,
+		Roles:           input.Roles,
+		PhotoURL:        storedPhotoURL(input.PhotoURL),
+		BranchID:        input.BranchID,
+		Sex:             input.Sex,
+		PoB:             input.PoB,
+		DoB:             input.DoB,
+		Age:             input.Age,
+		Religion:        input.Religion,
+		Phone:           input.Phone,
+		Address1:        input.Address1,
+		NIK:             input.NIK,
+		NPWP:            input.NPWP,
+		EducationLevel:  input.EducationLevel,
+		Institution:     input.Institution,
+		Major:           input.Major,
+		GraduationYear:  input.GraduationYear,
+		BankAccount:     input.BankAccount,
+		StatusPTKP:      input.StatusPTKP,
+		ShowInDirectory: true,
+	}
+
+	created, err := database.CreateUserMongo(user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, created)
+	c.JSON(http.StatusCreated, employeeView(c, created))
 }
 
+// DeleteEmployeeMongo offboards the employee's user record, the same as DELETE /admin/users/:id
 func DeleteEmployeeMongo(c *gin.Context) {
-	id := c.Param("id")
-
-	err := database.DeleteEmployeeMongo(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Employee deleted"})
+	DeleteUserMongo(c)
 }
 
 // GetAdminLogsMongo returns recent system activity logs
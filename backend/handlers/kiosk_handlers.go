@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"kkhris-clone/database"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Kiosk QR tokens rotate every kioskTokenStep. A scanned token is accepted for the current
+// step and the one before it, so a code shown just before rotation still works.
+const (
+	kioskTokenStep   = 30 * time.Second
+	kioskTokenLeeway = 1
+)
+
+// kioskToken signs the branch and time step with the branch secret, TOTP-style.
+// The token is "<branch id>.<step>.<signature>".
+func kioskToken(branchID, secret string, step int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(branchID + "." + strconv.FormatInt(step, 10)))
+	return branchID + "." + strconv.FormatInt(step, 10) + "." + hex.EncodeToString(mac.Sum(nil)[:10])
+}
+
+func kioskStep(t time.Time) int64 {
+	return t.Unix() / int64(kioskTokenStep/time.Second)
+}
+
+// verifyKioskToken checks the token signature and freshness and returns the branch it belongs to
+func verifyKioskToken(token string) (*database.BranchMongo, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("QR code tidak valid")
+	}
+	step, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("QR code tidak valid")
+	}
+	now := kioskStep(time.Now())
+	if step > now || step < now-kioskTokenLeeway {
+		return nil, fmt.Errorf("QR code sudah kedaluwarsa, silakan scan ulang")
+	}
+
+	branch, err := database.GetBranchByIDMongo(parts[0])
+	if err != nil || branch.KioskSecret == "" {
+		return nil, fmt.Errorf("QR code tidak valid")
+	}
+	if !hmac.Equal([]byte(kioskToken(parts[0], branch.KioskSecret, step)), []byte(token)) {
+		return nil, fmt.Errorf("QR code tidak valid")
+	}
+	return branch, nil
+}
+
+// RotateKioskSecretMongo generates a new kiosk secret for a branch, invalidating codes on screen
+func RotateKioskSecretMongo(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := database.GetBranchByIDMongo(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.SetBranchKioskSecretMongo(id, hex.EncodeToString(buf)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kiosk secret rotated"})
+}
+
+// IssueKioskDeviceKeyMongo issues the credential a branch's kiosk display uses to fetch QR
+// tokens, replacing any earlier one. The key is returned once; only its hash is stored.
+func IssueKioskDeviceKeyMongo(c *gin.Context) {
+	id := c.Param("id")
+	branch, err := database.GetBranchByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+	if branch.KioskSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kiosk belum diaktifkan untuk cabang ini"})
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	key := id + "." + hex.EncodeToString(buf)
+	if err := database.SetBranchKioskDeviceKeyMongo(id, kioskKeyHash(key)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"branch_id": id, "device_key": key})
+}
+
+func kioskKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// KioskDeviceMiddleware authenticates a kiosk display by the X-Kiosk-Key header issued by
+// IssueKioskDeviceKeyMongo and sets the branch it belongs to as "kioskBranch"
+func KioskDeviceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("X-Kiosk-Key"))
+		branchID, _, _ := strings.Cut(key, ".")
+		branch, err := database.GetBranchByIDMongo(branchID)
+		if err != nil || branch.KioskDeviceKeyHash == "" ||
+			!hmac.Equal([]byte(kioskKeyHash(key)), []byte(branch.KioskDeviceKeyHash)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid kiosk device key"})
+			c.Abort()
+			return
+		}
+		c.Set("kioskBranch", branch)
+		c.Next()
+	}
+}
+
+// GetKioskTokenMongo returns the current QR token for the kiosk's branch. The kiosk display
+// polls this and re-renders the QR code before expires_in runs out.
+func GetKioskTokenMongo(c *gin.Context) {
+	branch := c.MustGet("kioskBranch").(*database.BranchMongo)
+	if branch.KioskSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Kiosk belum diaktifkan untuk cabang ini"})
+		return
+	}
+
+	id := branch.ID.Hex()
+	now := time.Now()
+	step := kioskStep(now)
+	stepSeconds := int64(kioskTokenStep / time.Second)
+	c.JSON(http.StatusOK, gin.H{
+		"branch_id":   id,
+		"branch_name": branch.Name,
+		"token":       kioskToken(id, branch.KioskSecret, step),
+		"expires_in":  (step+1)*stepSeconds - now.Unix(),
+	})
+}
+
+// KioskCheckInMongo records a clock-in for the logged-in user at the branch whose QR code was scanned
+func KioskCheckInMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var input struct {
+		Token              string   `json:"token" binding:"required"`
+		ActivityType       string   `json:"activity_type"`
+		ActivityCategories []string `json:"activity_categories"`
+		ActivityNotes      string   `json:"activity_notes"`
+		Session            string   `json:"session"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	branch, err := verifyKioskToken(input.Token)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	att := database.AttendanceMongo{
+		UserID:             userID,
+		Date:               now.Format("2006-01-02"),
+		ActivityType:       input.ActivityType,
+		ActivityCategories: input.ActivityCategories,
+		ActivityDetails:    "Check-in di " + branch.Name,
+		StartingTime:       now.Format("15:04"),
+		ActivityNotes:      input.ActivityNotes,
+		Session:            input.Session,
+		Status:             "present",
+		CreatedAt:          now.Format("2006-01-02 15:04:05"),
+		BranchID:           branch.ID.Hex(),
+		Source:             "kiosk",
+	}
+	if att.ActivityType == "" {
+		att.ActivityType = "Daily Activity"
+	}
+	if att.ActivityCategories == nil {
+		att.ActivityCategories = []string{}
+	}
+
+	existing, err := database.GetAttendanceByUserAndDate(userID, att.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, ex := range existing {
+		if ex.Source == "kiosk" && ex.BranchID == att.BranchID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Anda sudah check-in di cabang ini hari ini", "attendance": ex})
+			return
+		}
+	}
+
+	rules, err := database.GetAttendanceRulesMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	fieldErrors, err := validateAttendance(&att, *rules, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Attendance validation failed", "fields": fieldErrors})
+		return
+	}
+
+	created, err := database.AddAttendanceMongo(att)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// KioskCheckOutMongo records the clock-out time on the caller's open kiosk check-in at the
+// branch whose QR code was scanned
+func KioskCheckOutMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	branch, err := verifyKioskToken(input.Token)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	date := now.Format("2006-01-02")
+	existing, err := database.GetAttendanceByUserAndDate(userID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var open *database.AttendanceMongo
+	for i := range existing {
+		if existing[i].Source == "kiosk" && existing[i].BranchID == branch.ID.Hex() && existing[i].EndingTime == "" {
+			open = &existing[i]
+			break
+		}
+	}
+	if open == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Belum ada check-in di cabang ini hari ini"})
+		return
+	}
+
+	locked, err := database.IsPeriodLockedMongo(userID, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if locked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Timesheet periode ini sudah dikunci"})
+		return
+	}
+	endingTime := now.Format("15:04")
+	start, _ := parseClock(open.StartingTime)
+	if end, _ := parseClock(endingTime); end <= start {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Attendance validation failed", "fields": []AttendanceFieldError{
+			newFieldError("ending_time", RuleTimeRange, "Jam selesai harus setelah jam mulai"),
+		}})
+		return
+	}
+
+	updated, err := database.SetAttendanceEndingTimeMongo(open.ID, endingTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Anda sudah check-out di cabang ini hari ini"})
+		return
+	}
+	open.EndingTime = endingTime
+	c.JSON(http.StatusOK, open)
+}
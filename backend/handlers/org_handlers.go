@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Approval Routing ---
+
+// approvalScope returns whose routed requests the caller may decide: their own and those of
+// managers they are acting for today. Requests without an approver stay with managers, as
+// before reporting lines existed. all is set for admins, who may decide any request.
+func approvalScope(c *gin.Context) (approverIDs []string, includeUnrouted, all bool, err error) {
+	if isAdmin, ok := c.Get("isAdmin"); ok && isAdmin.(bool) {
+		return nil, true, true, nil
+	}
+	userID := c.MustGet("userID").(string)
+	actingFor, err := database.ActingForMongo(userID, time.Now().Format("2006-01-02"))
+	if err != nil {
+		return nil, false, false, err
+	}
+	role, _ := c.Get("role")
+	return append([]string{userID}, actingFor...), role == "manager", false, nil
+}
+
+// canDecideRequest reports whether the caller may approve or reject req. Only admins may
+// decide their own requests.
+func canDecideRequest(c *gin.Context, req *database.PendingRequestMongo) (bool, error) {
+	approverIDs, includeUnrouted, all, err := approvalScope(c)
+	if err != nil || all {
+		return all, err
+	}
+	if req.UserID == c.MustGet("userID").(string) {
+		return false, nil
+	}
+	if req.ApproverID == "" {
+		return includeUnrouted, nil
+	}
+	for _, id := range approverIDs {
+		if id == req.ApproverID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// --- Reporting Lines ---
+
+// SetReportsToMongo sets or clears a user's manager and reroutes their pending requests
+func SetReportsToMongo(c *gin.Context) {
+	var input struct {
+		ReportsTo string `json:"reports_to"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := database.GetUserByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err := database.ValidateReportsToMongo(user.ID.Hex(), input.ReportsTo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user.ReportsTo = input.ReportsTo
+	if err := database.UpdateUserMongo(user.ID.Hex(), *user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.ReroutePendingRequestsMongo(user.ID.Hex()); err != nil {
+		log.Printf("Reroute pending requests of %s error: %v", user.ID.Hex(), err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Reporting line updated", "reports_to": user.ReportsTo})
+}
+
+// OrgNode is one person in the org chart
+type OrgNode struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Jabatan       string     `json:"jabatan"`
+	Roles         string     `json:"roles"`
+	BranchID      string     `json:"branch_id"`
+	PhotoURL      string     `json:"photo_url"`
+	ReportsTo     string     `json:"reports_to,omitempty"`
+	ActingManager string     `json:"acting_manager,omitempty"` // delegate deciding this person's approvals today
+	Reports       []*OrgNode `json:"reports"`
+}
+
+// GetOrgChartMongo returns the reporting tree of active users; staff only see the people listed
+// in the directory. ?branch_id= limits it to one branch, where people whose manager works
+// elsewhere or is hidden become roots; ?root= returns the subtree under one person.
+func GetOrgChartMongo(c *gin.Context) {
+	var users []database.UserMongo
+	var err error
+	if hasAdminAccess(c) {
+		users, err = database.GetUsersByStatusMongo("active")
+	} else {
+		users, err = database.GetDirectoryUsersMongo()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	delegations, err := database.GetDelegationsMongo("")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	today := time.Now().Format("2006-01-02")
+	acting := make(map[string]string)
+	for _, d := range delegations {
+		if d.Status == "active" && d.StartDate <= today && d.EndDate >= today {
+			acting[d.ManagerID] = d.DelegateID
+		}
+	}
+
+	branchID := c.Query("branch_id")
+	nodes := make(map[string]*OrgNode)
+	for i := range users {
+		u := &users[i]
+		branch := ""
+		if u.BranchID != nil {
+			branch = fmt.Sprint(u.BranchID)
+		}
+		if branchID != "" && branch != branchID {
+			continue
+		}
+		withPhotoURL(c, u)
+		nodes[u.ID.Hex()] = &OrgNode{
+			ID:        u.ID.Hex(),
+			Name:      u.Name,
+			Jabatan:   u.Jabatan,
+			Roles:     u.Roles,
+			BranchID:  branch,
+			PhotoURL:  u.PhotoURL,
+			ReportsTo: u.ReportsTo,
+			Reports:   []*OrgNode{},
+		}
+	}
+
+	// A reporting line that loops back would nest people inside themselves; everyone on the loop
+	// becomes a root instead
+	cyclic := reportingCycles(nodes)
+	roots := []*OrgNode{}
+	for id, node := range nodes {
+		node.ActingManager = acting[node.ReportsTo]
+		manager, ok := nodes[node.ReportsTo]
+		if !ok || cyclic[id] {
+			roots = append(roots, node)
+			continue
+		}
+		manager.Reports = append(manager.Reports, node)
+	}
+	for _, node := range nodes {
+		sortOrgNodes(node.Reports)
+	}
+	sortOrgNodes(roots)
+
+	if root := c.Query("root"); root != "" {
+		node, ok := nodes[root]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found in org chart"})
+			return
+		}
+		roots = []*OrgNode{node}
+	}
+	c.JSON(http.StatusOK, gin.H{"roots": roots, "count": len(nodes)})
+}
+
+// reportingCycles returns the IDs of people whose chain of managers leads back to themselves
+func reportingCycles(nodes map[string]*OrgNode) map[string]bool {
+	const (
+		walking = 1
+		done    = 2
+	)
+	state := make(map[string]int, len(nodes))
+	cyclic := make(map[string]bool)
+	for id := range nodes {
+		path := []string{}
+		cur := id
+		for {
+			node, ok := nodes[cur]
+			if !ok || state[cur] != 0 {
+				break
+			}
+			state[cur] = walking
+			path = append(path, cur)
+			cur = node.ReportsTo
+		}
+		if state[cur] == walking {
+			for i := len(path) - 1; i >= 0; i-- {
+				cyclic[path[i]] = true
+				if path[i] == cur {
+					break
+				}
+			}
+		}
+		for _, p := range path {
+			state[p] = done
+		}
+	}
+	return cyclic
+}
+
+func sortOrgNodes(nodes []*OrgNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+}
+
+// --- Delegations ---
+
+// CreateDelegationMongo names an acting manager for an absence. Managers delegate their own
+// approvals; admins may pass manager_id to delegate on someone's behalf.
+func CreateDelegationMongo(c *gin.Context) {
+	var input struct {
+		ManagerID  string `json:"manager_id"`
+		DelegateID string `json:"delegate_id" binding:"required"`
+		StartDate  string `json:"start_date" binding:"required"`
+		EndDate    string `json:"end_date" binding:"required"`
+		Reason     string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := c.MustGet("userID").(string)
+	isAdmin, _ := c.Get("isAdmin")
+	if input.ManagerID == "" {
+		input.ManagerID = userID
+	}
+	if input.ManagerID != userID && isAdmin != true {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can delegate on behalf of another manager"})
+		return
+	}
+	start, errStart := time.Parse("2006-01-02", input.StartDate)
+	end, errEnd := time.Parse("2006-01-02", input.EndDate)
+	if errStart != nil || errEnd != nil || end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date must be YYYY-MM-DD, with end_date not before start_date"})
+		return
+	}
+	if input.DelegateID == input.ManagerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A manager cannot delegate to themselves"})
+		return
+	}
+	manager, err := database.GetUserByIDMongo(input.ManagerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Manager not found"})
+		return
+	}
+	delegate, err := database.GetUserByIDMongo(input.DelegateID)
+	if err != nil || !database.UserIsActive(delegate.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Delegate not found or not active"})
+		return
+	}
+
+	created, err := database.AddDelegationMongo(database.DelegationMongo{
+		ManagerID:    input.ManagerID,
+		ManagerName:  manager.Name,
+		DelegateID:   input.DelegateID,
+		DelegateName: delegate.Name,
+		StartDate:    input.StartDate,
+		EndDate:      input.EndDate,
+		Reason:       input.Reason,
+		CreatedBy:    userID,
+		CreatedAt:    time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if err == database.ErrDelegationOverlap {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetDelegationsMongo lists delegations the caller gave or received; admins get all with ?all=true
+func GetDelegationsMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	if isAdmin, _ := c.Get("isAdmin"); isAdmin == true && c.Query("all") == "true" {
+		userID = ""
+	}
+	delegations, err := database.GetDelegationsMongo(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delegations)
+}
+
+// CancelDelegationMongo ends a delegation early; the manager who gave it or an admin may cancel
+func CancelDelegationMongo(c *gin.Context) {
+	d, err := database.GetDelegationByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delegation not found"})
+		return
+	}
+	userID := c.MustGet("userID").(string)
+	if isAdmin, _ := c.Get("isAdmin"); d.ManagerID != userID && isAdmin != true {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the delegating manager or an admin can cancel this delegation"})
+		return
+	}
+	ok, err := database.CancelDelegationMongo(d.ID, userID, time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "Delegation is not active"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Delegation cancelled"})
+}
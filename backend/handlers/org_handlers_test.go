@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReportingCycles(t *testing.T) {
+	nodes := map[string]*OrgNode{}
+	for id, reportsTo := range map[string]string{
+		"ceo": "",
+		"cto": "ceo",
+		"dev": "cto",
+		"a":   "b", // a and b report to each other
+		"b":   "a",
+		"c":   "a", // under the loop but not on it
+		"d":   "d", // reports to themselves
+		"e":   "gone",
+	} {
+		nodes[id] = &OrgNode{ID: id, ReportsTo: reportsTo}
+	}
+	want := map[string]bool{"a": true, "b": true, "d": true}
+	if got := reportingCycles(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("reportingCycles = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DailyOvertime is overtime derived from one day of attendance
+type DailyOvertime struct {
+	Date          string  `json:"date"`
+	DayType       string  `json:"day_type"`
+	WorkedMinutes int     `json:"worked_minutes"`
+	Minutes       int     `json:"overtime_minutes"`
+	WeightedHours float64 `json:"weighted_hours"`
+}
+
+// periodFromQuery reads ?from=&to= (YYYY-MM-DD) or ?month=YYYY-MM, defaulting to the current month
+func periodFromQuery(c *gin.Context) (string, string, error) {
+	from, to := c.Query("from"), c.Query("to")
+	if from != "" || to != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			return "", "", fmt.Errorf("from must be YYYY-MM-DD")
+		}
+		if _, err := time.Parse("2006-01-02", to); err != nil {
+			return "", "", fmt.Errorf("to must be YYYY-MM-DD")
+		}
+		if to < from {
+			return "", "", fmt.Errorf("to must not be before from")
+		}
+		return from, to, nil
+	}
+
+	month := c.Query("month")
+	start := time.Now()
+	if month != "" {
+		parsed, err := time.Parse("2006-01", month)
+		if err != nil {
+			return "", "", fmt.Errorf("month must be YYYY-MM")
+		}
+		start = parsed
+	}
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.Local)
+	last := first.AddDate(0, 1, -1)
+	return first.Format("2006-01-02"), last.Format("2006-01-02"), nil
+}
+
+// overtimeDayType classifies a date as weekday, weekend or holiday
+func overtimeDayType(date string, holidays map[string]string) string {
+	if _, ok := holidays[date]; ok {
+		return "holiday"
+	}
+	t, err := time.Parse("2006-01-02", date)
+	if err == nil && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return "weekend"
+	}
+	return "weekday"
+}
+
+func overtimeTiers(settings database.OvertimeSettingsMongo, dayType string) []database.OvertimeTier {
+	switch dayType {
+	case "holiday":
+		return settings.HolidayTiers
+	case "weekend":
+		return settings.WeekendTiers
+	}
+	return settings.WeekdayTiers
+}
+
+// weightOvertime converts overtime minutes into pay-weighted hours using sequential tiers
+func weightOvertime(minutes int, tiers []database.OvertimeTier) float64 {
+	remaining := float64(minutes) / 60
+	weighted := 0.0
+	for _, tier := range tiers {
+		if remaining <= 0 {
+			break
+		}
+		hours := remaining
+		if tier.Hours > 0 && tier.Hours < remaining {
+			hours = tier.Hours
+		}
+		weighted += hours * tier.Multiplier
+		remaining -= hours
+	}
+	// Time past a last tier with finite hours stays at its multiplier rather than going unpaid
+	if remaining > 0 && len(tiers) > 0 {
+		weighted += remaining * tiers[len(tiers)-1].Multiplier
+	}
+	return math.Round(weighted*100) / 100
+}
+
+// attendanceMinutes sums the timed, present attendance per date
+func attendanceMinutes(records []database.AttendanceMongo) map[string]int {
+	worked := make(map[string]int)
+	for _, r := range records {
+		if r.Status != "present" {
+			continue
+		}
+		start, okStart := parseClock(r.StartingTime)
+		end, okEnd := parseClock(r.EndingTime)
+		if okStart && okEnd && end > start {
+			worked[r.Date] += end - start
+		}
+	}
+	return worked
+}
+
+// rosteredMinutes sums the coach's scheduled class time on a date
+func rosteredMinutes(schedules []database.SchoolScheduleMongo, date string) int {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+	total := 0
+	for _, s := range schedules {
+		start, okStart := parseClock(s.StartTime)
+		end, okEnd := parseClock(s.EndTime)
+		if okStart && okEnd && end > start && scheduleRunsOn(s, day) {
+			total += end - start
+		}
+	}
+	return total
+}
+
+// expectedMinutes is the working time a day owes before overtime starts: the standard day, or
+// the roster when it is longer, on weekdays; the roster on weekends; nothing on holidays.
+func expectedMinutes(dayType, date string, settings database.OvertimeSettingsMongo, roster []database.SchoolScheduleMongo) int {
+	rostered := rosteredMinutes(roster, date)
+	switch dayType {
+	case "holiday":
+		return 0
+	case "weekend":
+		return rostered
+	}
+	if rostered > settings.StandardDailyMinutes {
+		return rostered
+	}
+	return settings.StandardDailyMinutes
+}
+
+// computeOvertime derives overtime per day: minutes worked beyond the employee's expected
+// time for that day (see expectedMinutes).
+func computeOvertime(records []database.AttendanceMongo, settings database.OvertimeSettingsMongo, holidays map[string]string, roster []database.SchoolScheduleMongo) []DailyOvertime {
+	result := []DailyOvertime{}
+	for date, worked := range attendanceMinutes(records) {
+		dayType := overtimeDayType(date, holidays)
+		minutes := worked - expectedMinutes(dayType, date, settings, roster)
+		if minutes <= 0 {
+			continue
+		}
+		result = append(result, DailyOvertime{
+			Date:          date,
+			DayType:       dayType,
+			WorkedMinutes: worked,
+			Minutes:       minutes,
+			WeightedHours: weightOvertime(minutes, overtimeTiers(settings, dayType)),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	return result
+}
+
+// --- Overtime Handlers ---
+
+// GetComputedOvertimeMongo returns overtime derived from the caller's attendance for a period
+func GetComputedOvertimeMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	from, to, err := periodFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := database.GetOvertimeSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := database.GetAttendanceByUserAndPeriod(userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	holidays, err := database.GetHolidayDatesMongo(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roster, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{CoachID: userID, ActiveOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": from,
+		"to":   to,
+		"days": computeOvertime(records, *settings, holidays, roster),
+	})
+}
+
+func GetOvertimeRequestsMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	requests, err := database.GetOvertimeRequestsByUser(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if requests == nil {
+		requests = []database.OvertimeRequestMongo{}
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+func AddOvertimeRequestMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var input struct {
+		Date      string `json:"date" binding:"required"`
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+		Minutes   int    `json:"minutes"`
+		Reason    string `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	settings, err := database.GetOvertimeSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	holidays, err := database.GetHolidayDatesMongo(input.Date, input.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	dayType := overtimeDayType(input.Date, holidays)
+
+	// Overtime backed by the day's attendance, measured against the employee's roster
+	records, err := database.GetAttendanceByUserAndDate(userID, input.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	roster, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{CoachID: userID, ActiveOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recorded := 0
+	for _, day := range computeOvertime(records, *settings, holidays, roster) {
+		recorded = day.Minutes
+	}
+	// Pending and approved requests for the day are already claimed against that overtime
+	requested, err := database.GetRequestedOvertimeMinutesMongo(userID, input.Date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	available := recorded - requested
+
+	// Minutes come from an explicit time range, an explicit amount, or the day's attendance;
+	// declared minutes may not exceed what attendance shows
+	minutes := input.Minutes
+	if input.StartTime != "" || input.EndTime != "" {
+		start, okStart := parseClock(input.StartTime)
+		end, okEnd := parseClock(input.EndTime)
+		if !okStart || !okEnd || end <= start {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time must be HH:MM with start before end"})
+			return
+		}
+		minutes = end - start
+	} else if minutes == 0 {
+		minutes = available
+	}
+	if minutes <= 0 && requested > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Lembur pada tanggal ini sudah diajukan (%d menit)", requested)})
+		return
+	}
+	if minutes <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada lembur yang tercatat pada tanggal ini"})
+		return
+	}
+	if minutes > available {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Lembur yang diajukan (%d menit) melebihi sisa lembur menurut absensi (%d dari %d menit)", minutes, available, recorded)})
+		return
+	}
+	// The daily cap applies to working days only; rest-day overtime is bounded by the tiers
+	if dayType == "weekday" && settings.MaxDailyMinutes > 0 && requested+minutes > settings.MaxDailyMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Lembur hari kerja maksimal %d menit per hari", settings.MaxDailyMinutes)})
+		return
+	}
+
+	ot := database.OvertimeRequestMongo{
+		UserID:        userID,
+		Date:          input.Date,
+		StartTime:     input.StartTime,
+		EndTime:       input.EndTime,
+		Minutes:       minutes,
+		DayType:       dayType,
+		WeightedHours: weightOvertime(minutes, overtimeTiers(*settings, dayType)),
+		Reason:        input.Reason,
+		Status:        "pending",
+		CreatedAt:     time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	created, err := database.AddOvertimeRequestMongo(ot)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Route through the shared approval pipeline
+	user, _ := database.GetUserByIDMongo(userID)
+	userName := "Unknown"
+	if user != nil {
+		userName = user.Name
+	}
+
+	req := database.PendingRequestMongo{
+		Type:      "overtime",
+		UserID:    userID,
+		UserName:  userName,
+		Date:      input.Date,
+		Reason:    input.Reason,
+		Details:   fmt.Sprintf("%d menit - %s", minutes, dayType),
+		Status:    "pending",
+		CreatedAt: time.Now().Format("2006-01-02"),
+		RefID:     created.ID.Hex(),
+	}
+	if _, err := database.AddPendingRequestMongo(req); err != nil {
+		database.DeleteOvertimeRequestMongo(created.ID.Hex())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// decideOvertime applies an approval decision to the overtime request behind it
+func decideOvertime(req *database.PendingRequestMongo, status string) error {
+	updated, err := database.UpdateOvertimeRequestStatus(req.RefID, req.UserID, status)
+	if err == nil && !updated {
+		err = fmt.Errorf("overtime request is not pending")
+	}
+	return err
+}
+
+func DeleteOvertimeRequestMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	id := c.Param("id")
+
+	ot, err := database.GetOvertimeRequestByIDMongo(id)
+	if err != nil || ot == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Overtime request not found"})
+		return
+	}
+	if ot.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own overtime requests"})
+		return
+	}
+	if ot.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending overtime requests can be deleted"})
+		return
+	}
+
+	if err := database.DeleteOvertimeRequestMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	database.DeletePendingRequestByRefID(id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Overtime request deleted"})
+}
+
+// GetOvertimeSummaryMongo totals approved overtime per user for a period
+func GetOvertimeSummaryMongo(c *gin.Context) {
+	from, to, err := periodFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requests, err := database.GetOvertimeRequestsByPeriod(c.Query("user_id"), "approved", from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type userSummary struct {
+		UserID          string         `json:"user_id"`
+		UserName        string         `json:"user_name"`
+		TotalMinutes    int            `json:"total_minutes"`
+		MinutesByDay    map[string]int `json:"minutes_by_day_type"`
+		WeightedHours   float64        `json:"weighted_hours"`
+		ApprovedEntries int            `json:"approved_entries"`
+	}
+
+	userIDs := []string{}
+	for _, r := range requests {
+		userIDs = append(userIDs, r.UserID)
+	}
+	users, err := database.GetUsersByIDsMongo(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	summaries := make(map[string]*userSummary)
+	for _, r := range requests {
+		s, ok := summaries[r.UserID]
+		if !ok {
+			s = &userSummary{UserID: r.UserID, UserName: users[r.UserID].Name, MinutesByDay: map[string]int{}}
+			summaries[r.UserID] = s
+		}
+		s.TotalMinutes += r.Minutes
+		s.MinutesByDay[r.DayType] += r.Minutes
+		s.WeightedHours = math.Round((s.WeightedHours+r.WeightedHours)*100) / 100
+		s.ApprovedEntries++
+	}
+
+	result := []*userSummary{}
+	for _, s := range summaries {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].UserName) < strings.ToLower(result[j].UserName)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":  from,
+		"to":    to,
+		"users": result,
+	})
+}
+
+func GetOvertimeSettingsMongo(c *gin.Context) {
+	settings, err := database.GetOvertimeSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateOvertimeSettingsMongo(c *gin.Context) {
+	var input database.OvertimeSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.StandardDailyMinutes <= 0 || input.HourlyDivisor <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "standard_daily_minutes and hourly_divisor must be positive"})
+		return
+	}
+	for _, tiers := range [][]database.OvertimeTier{input.WeekdayTiers, input.WeekendTiers, input.HolidayTiers} {
+		if len(tiers) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each day type needs at least one overtime tier"})
+			return
+		}
+		for _, t := range tiers {
+			if t.Hours < 0 || t.Multiplier <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Tier hours cannot be negative and multipliers must be positive"})
+				return
+			}
+		}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateOvertimeSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, input)
+}
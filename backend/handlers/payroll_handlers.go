@@ -0,0 +1,775 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kkhris-clone/database"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// payrollInputs holds the period-wide data every payslip in a run draws from
+type payrollInputs struct {
+	Period      string
+	From        string
+	To          string
+	WorkingDays int
+	Holidays    map[string]string
+	Settings    database.PayrollSettingsMongo
+	Overtime    database.OvertimeSettingsMongo
+	OvertimeBy  map[string][]database.OvertimeRequestMongo
+	Adjustments map[string][]database.PayrollAdjustmentMongo
+	TaxRules    database.TaxRuleSetMongo
+	BPJS        database.BPJSSettingsMongo
+	THR         map[string][]database.PayslipLine
+	Claims      map[string][]database.PayslipLine
+}
+
+// payrollPeriod turns YYYY-MM into the first and last day of the month
+func payrollPeriod(period string) (string, string, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return "", "", fmt.Errorf("period must be YYYY-MM")
+	}
+	return start.Format("2006-01-02"), start.AddDate(0, 1, -1).Format("2006-01-02"), nil
+}
+
+// workingDays counts weekdays between from and to that are not holidays
+func workingDays(from, to string, holidays map[string]string) int {
+	start, _ := time.Parse("2006-01-02", from)
+	end, _ := time.Parse("2006-01-02", to)
+	days := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if overtimeDayType(d.Format("2006-01-02"), holidays) == "weekday" {
+			days++
+		}
+	}
+	return days
+}
+
+func loadPayrollInputs(period string) (*payrollInputs, error) {
+	from, to, err := payrollPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := database.GetHolidayDatesMongo(from, to)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := database.GetPayrollSettingsMongo()
+	if err != nil {
+		return nil, err
+	}
+	overtime, err := database.GetOvertimeSettingsMongo()
+	if err != nil {
+		return nil, err
+	}
+	requests, err := database.GetOvertimeRequestsByPeriod("", "approved", from, to)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := database.GetPayrollAdjustmentsMongo(period, "")
+	if err != nil {
+		return nil, err
+	}
+	bpjs, err := database.GetBPJSSettingsMongo()
+	if err != nil {
+		return nil, err
+	}
+	thr, err := thrLines(period)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := claimLines(period)
+	if err != nil {
+		return nil, err
+	}
+	if period[5:] == "12" {
+		// The December reconciliation sums the year's finalized payslips, so an open run would
+		// leave its withholding out
+		open, err := database.GetOpenPayrollRunsForTaxYearMongo(period[:4], period)
+		if err != nil {
+			return nil, err
+		}
+		if len(open) > 0 {
+			periods := make([]string, len(open))
+			for i, r := range open {
+				periods[i] = r.Period
+			}
+			return nil, fmt.Errorf("finalize payroll %s before the December tax reconciliation", strings.Join(periods, ", "))
+		}
+	}
+	year, _ := strconv.Atoi(period[:4])
+	taxRules, err := database.GetTaxRuleSetForYearMongo(year)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("no PPh 21 rules configured for %d", year)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	in := &payrollInputs{
+		Period:      period,
+		From:        from,
+		To:          to,
+		WorkingDays: workingDays(from, to, holidays),
+		Holidays:    holidays,
+		Settings:    *settings,
+		Overtime:    *overtime,
+		OvertimeBy:  make(map[string][]database.OvertimeRequestMongo),
+		Adjustments: make(map[string][]database.PayrollAdjustmentMongo),
+		TaxRules:    *taxRules,
+		BPJS:        *bpjs,
+		THR:         thr,
+		Claims:      claims,
+	}
+	for _, r := range requests {
+		in.OvertimeBy[r.UserID] = append(in.OvertimeBy[r.UserID], r)
+	}
+	for _, a := range adjustments {
+		in.Adjustments[a.UserID] = append(in.Adjustments[a.UserID], a)
+	}
+	return in, nil
+}
+
+func isUnpaidLeave(leaveType string, settings database.PayrollSettingsMongo) bool {
+	for _, t := range settings.UnpaidLeaveTypes {
+		if strings.EqualFold(t, leaveType) {
+			return true
+		}
+	}
+	return false
+}
+
+// computePayslip builds one employee's payslip. Every line records the records it came from
+// so a payslip can be traced back to attendance, overtime, leave, an adjustment or the tax rules.
+func computePayslip(user database.UserMongo, structure database.SalaryStructureMongo, in *payrollInputs) (database.PayslipMongo, error) {
+	userID := user.ID.Hex()
+	structureRef := []string{structure.ID.Hex()}
+	var lines []database.PayslipLine
+
+	if structure.BasePay > 0 {
+		lines = append(lines, database.PayslipLine{
+			Code: "BASE", Name: "Gaji Pokok", Type: "earning", Amount: structure.BasePay, Taxable: true,
+			Source: database.PayslipLineSource{Kind: "salary_structure", RefIDs: structureRef, Note: "Effective " + structure.EffectiveFrom},
+		})
+	}
+	for _, a := range structure.Allowances {
+		lines = append(lines, database.PayslipLine{
+			Code: a.Code, Name: a.Name, Type: "earning", Amount: a.Amount, Taxable: a.Taxable,
+			Source: database.PayslipLineSource{Kind: "salary_structure", RefIDs: structureRef},
+		})
+	}
+
+	// Teaching sessions: the School Class attendance the teaching hours report counts
+	if structure.SessionRate > 0 {
+		sessions, err := database.GetTeachingSessionsMongo(database.TeachingHoursFilter{From: in.From, To: in.To, UserID: userID})
+		if err != nil {
+			return database.PayslipMongo{}, err
+		}
+		var refs []string
+		for _, att := range sessions {
+			refs = append(refs, att.ID.Hex())
+		}
+		if len(refs) > 0 {
+			lines = append(lines, database.PayslipLine{
+				Code: "SESSION", Name: "Honor Sesi Mengajar", Type: "earning",
+				Quantity: float64(len(refs)), Rate: structure.SessionRate,
+				Amount: int64(len(refs)) * structure.SessionRate, Taxable: true,
+				Source: database.PayslipLineSource{Kind: "attendance", RefIDs: refs},
+			})
+		}
+	}
+
+	// Approved overtime is paid on the hourly rate derived from base pay
+	if requests := in.OvertimeBy[userID]; len(requests) > 0 && structure.BasePay > 0 && in.Overtime.HourlyDivisor > 0 {
+		hourly := float64(structure.BasePay) / float64(in.Overtime.HourlyDivisor)
+		hours := 0.0
+		var refs []string
+		for _, r := range requests {
+			hours += r.WeightedHours
+			refs = append(refs, r.ID.Hex())
+		}
+		lines = append(lines, database.PayslipLine{
+			Code: "OVERTIME", Name: "Lembur", Type: "earning",
+			Quantity: math.Round(hours*100) / 100, Rate: int64(math.Round(hourly)),
+			Amount: int64(math.Round(hours * hourly)), Taxable: true,
+			Source: database.PayslipLineSource{Kind: "overtime_request", RefIDs: refs, Note: fmt.Sprintf("Base pay / %d", in.Overtime.HourlyDivisor)},
+		})
+	}
+
+	// Unpaid leave is deducted per working day of base pay; half-day leave counts as half
+	if structure.BasePay > 0 && in.WorkingDays > 0 {
+		permits, err := database.GetApprovedWorkPermitsByUserAndPeriod(userID, in.From, in.To)
+		if err != nil {
+			return database.PayslipMongo{}, err
+		}
+		days := 0.0
+		var refs []string
+		for _, p := range permits {
+			if !isUnpaidLeave(p.LeaveType, in.Settings) || overtimeDayType(p.Date, in.Holidays) != "weekday" {
+				continue
+			}
+			if p.Session == "Half Day" {
+				days += 0.5
+			} else {
+				days++
+			}
+			refs = append(refs, p.ID.Hex())
+		}
+		if days > 0 {
+			daily := float64(structure.BasePay) / float64(in.WorkingDays)
+			lines = append(lines, database.PayslipLine{
+				Code: "UNPAID_LEAVE", Name: "Potongan Cuti Tidak Dibayar", Type: "deduction",
+				Quantity: days, Rate: int64(math.Round(daily)),
+				Amount: int64(math.Round(days * daily)), Taxable: true, PayCut: true,
+				Source: database.PayslipLineSource{Kind: "work_permit", RefIDs: refs, Note: fmt.Sprintf("%d working days", in.WorkingDays)},
+			})
+		}
+	}
+
+	lines = append(lines, in.THR[userID]...)
+	lines = append(lines, in.Claims[userID]...)
+
+	for _, d := range structure.Deductions {
+		lines = append(lines, database.PayslipLine{
+			Code: d.Code, Name: d.Name, Type: "deduction", Amount: d.Amount, Taxable: d.Taxable,
+			Source: database.PayslipLineSource{Kind: "salary_structure", RefIDs: structureRef},
+		})
+	}
+	bpjs, contributions := bpjsLines(user, structure, in.BPJS)
+	lines = append(lines, bpjs...)
+	for _, a := range in.Adjustments[userID] {
+		lines = append(lines, database.PayslipLine{
+			Code: a.Code, Name: a.Name, Type: a.Type, Amount: a.Amount, Taxable: a.Taxable,
+			Source: database.PayslipLineSource{Kind: "adjustment", RefIDs: []string{a.ID.Hex()}, Note: a.Note},
+		})
+	}
+
+	payslip := database.PayslipMongo{
+		Period:      in.Period,
+		UserID:      userID,
+		UserName:    user.Name,
+		Jabatan:     user.Jabatan,
+		BankAccount: user.BankAccount,
+		NPWP:        user.NPWP,
+		StatusPTKP:  user.StatusPTKP,
+		Lines:       lines,
+		BPJS:        contributions,
+	}
+	if err := applyPPh21(&payslip, in); err != nil {
+		return database.PayslipMongo{}, err
+	}
+	totalPayslip(&payslip)
+	return payslip, nil
+}
+
+// totalPayslip recomputes gross, deductions, net and employer contributions from the lines
+func totalPayslip(p *database.PayslipMongo) {
+	p.Gross, p.TotalDeductions, p.EmployerContributions = 0, 0, 0
+	for _, l := range p.Lines {
+		switch l.Type {
+		case "deduction":
+			p.TotalDeductions += l.Amount
+		case "employer":
+			p.EmployerContributions += l.Amount
+		default:
+			p.Gross += l.Amount
+		}
+	}
+	p.Net = p.Gross - p.TotalDeductions
+}
+
+// structureHolders loads the users of the given salary structures sorted by name. Deleted and
+// inactive accounts are included: whether someone is paid follows the employment dates, and an
+// employee deleted after their last day still gets that month's payslip.
+func structureHolders(structures map[string]database.SalaryStructureMongo) ([]database.UserMongo, error) {
+	ids := make([]string, 0, len(structures))
+	for id := range structures {
+		ids = append(ids, id)
+	}
+	byID, err := database.GetUsersByIDsMongo(ids)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]database.UserMongo, 0, len(byID))
+	for _, u := range byID {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+// computeRun recomputes every payslip of a draft run and stores them with the run totals
+func computeRun(run *database.PayrollRunMongo) error {
+	in, err := loadPayrollInputs(run.Period)
+	if err != nil {
+		return err
+	}
+	structures, err := database.GetEffectiveSalaryStructuresMongo(in.To)
+	if err != nil {
+		return err
+	}
+	users, err := structureHolders(structures)
+	if err != nil {
+		return err
+	}
+	from, _ := time.Parse("2006-01-02", in.From)
+	to, _ := time.Parse("2006-01-02", in.To)
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	runID := run.ID.Hex()
+	totals := database.PayrollTotals{}
+	payslips := []database.PayslipMongo{}
+	for _, user := range users {
+		if !database.EmployedDuring(&user, from, to) {
+			continue
+		}
+		structure := structures[user.ID.Hex()]
+		payslip, err := computePayslip(user, structure, in)
+		if err != nil {
+			return err
+		}
+		payslip.RunID = runID
+		payslip.Status = run.Status
+		payslip.CreatedAt = now
+		payslips = append(payslips, payslip)
+
+		totals.Employees++
+		totals.Gross += payslip.Gross
+		totals.Deductions += payslip.TotalDeductions
+		totals.Net += payslip.Net
+		totals.EmployerContributions += payslip.EmployerContributions
+	}
+
+	claimed, err := database.BeginPayrollRunComputeMongo(runID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return errRunNotDraft
+	}
+	if err := database.ReplacePayslipsMongo(runID, payslips); err != nil {
+		database.EndPayrollRunComputeMongo(runID, nil)
+		return err
+	}
+	run.Totals = totals
+	run.ComputedAt = now
+	return database.EndPayrollRunComputeMongo(runID, bson.M{"totals": totals, "computed_at": now})
+}
+
+// errRunNotDraft is returned by computeRun when the run left draft or is being recomputed
+var errRunNotDraft = errors.New("payroll run is no longer a draft or is being recalculated")
+
+// computeRunStatus maps a computeRun error to its HTTP status
+func computeRunStatus(err error) int {
+	if errors.Is(err, errRunNotDraft) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// payrollPeriodEditable rejects changes to a period whose run has left draft
+func payrollPeriodEditable(c *gin.Context, period string) bool {
+	run, err := database.GetPayrollRunByPeriodMongo(period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if run != nil && run.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payroll periode " + period + " sudah " + run.Status})
+		return false
+	}
+	return true
+}
+
+func validComponents(components []database.SalaryComponent) bool {
+	for _, comp := range components {
+		if strings.TrimSpace(comp.Code) == "" || strings.TrimSpace(comp.Name) == "" || comp.Amount < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// --- Payroll Settings ---
+
+func GetPayrollSettingsMongo(c *gin.Context) {
+	settings, err := database.GetPayrollSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdatePayrollSettingsMongo(c *gin.Context) {
+	var input database.PayrollSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.UnpaidLeaveTypes == nil {
+		input.UnpaidLeaveTypes = []string{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdatePayrollSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+// --- Salary Structures ---
+
+func GetSalaryStructuresMongo(c *gin.Context) {
+	structures, err := database.GetSalaryStructuresMongo(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, structures)
+}
+
+// CreateSalaryStructureMongo adds a new structure; earlier ones stay for the periods they covered
+func CreateSalaryStructureMongo(c *gin.Context) {
+	var input struct {
+		UserID        string                     `json:"user_id" binding:"required"`
+		EffectiveFrom string                     `json:"effective_from" binding:"required"`
+		BasePay       int64                      `json:"base_pay"`
+		Allowances    []database.SalaryComponent `json:"allowances"`
+		Deductions    []database.SalaryComponent `json:"deductions"`
+		SessionRate   int64                      `json:"session_rate"`
+		Notes         string                     `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", input.EffectiveFrom); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effective_from must be YYYY-MM-DD"})
+		return
+	}
+	if input.BasePay < 0 || input.SessionRate < 0 || !validComponents(input.Allowances) || !validComponents(input.Deductions) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amounts cannot be negative and every component needs a code and name"})
+		return
+	}
+	if _, err := database.GetUserByIDMongo(input.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		return
+	}
+	if !payrollPeriodEditable(c, input.EffectiveFrom[:7]) {
+		return
+	}
+	if input.Allowances == nil {
+		input.Allowances = []database.SalaryComponent{}
+	}
+	if input.Deductions == nil {
+		input.Deductions = []database.SalaryComponent{}
+	}
+
+	created, err := database.CreateSalaryStructureMongo(database.SalaryStructureMongo{
+		UserID:        input.UserID,
+		EffectiveFrom: input.EffectiveFrom,
+		BasePay:       input.BasePay,
+		Allowances:    input.Allowances,
+		Deductions:    input.Deductions,
+		SessionRate:   input.SessionRate,
+		Notes:         input.Notes,
+		CreatedBy:     c.MustGet("userID").(string),
+		CreatedAt:     time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func DeleteSalaryStructureMongo(c *gin.Context) {
+	id := c.Param("id")
+	used, err := database.CountFinalizedPayslipsByRefMongo(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Struktur gaji sudah dipakai payroll final dan tidak dapat dihapus"})
+		return
+	}
+
+	if err := database.DeleteSalaryStructureMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Salary structure deleted"})
+}
+
+// --- Payroll Adjustments ---
+
+func GetPayrollAdjustmentsMongo(c *gin.Context) {
+	adjustments, err := database.GetPayrollAdjustmentsMongo(c.Query("period"), c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, adjustments)
+}
+
+func CreatePayrollAdjustmentMongo(c *gin.Context) {
+	var input struct {
+		UserID  string `json:"user_id" binding:"required"`
+		Period  string `json:"period" binding:"required"`
+		Type    string `json:"type" binding:"required"`
+		Code    string `json:"code" binding:"required"`
+		Name    string `json:"name" binding:"required"`
+		Amount  int64  `json:"amount" binding:"required"`
+		Taxable bool   `json:"taxable"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, _, err := payrollPeriod(input.Period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (input.Type != "earning" && input.Type != "deduction") || input.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be earning or deduction with a positive amount"})
+		return
+	}
+	if _, err := database.GetUserByIDMongo(input.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+		return
+	}
+	if !payrollPeriodEditable(c, input.Period) {
+		return
+	}
+
+	created, err := database.CreatePayrollAdjustmentMongo(database.PayrollAdjustmentMongo{
+		UserID:    input.UserID,
+		Period:    input.Period,
+		Type:      input.Type,
+		Code:      input.Code,
+		Name:      input.Name,
+		Amount:    input.Amount,
+		Taxable:   input.Taxable,
+		Note:      input.Note,
+		CreatedBy: c.MustGet("userID").(string),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func DeletePayrollAdjustmentMongo(c *gin.Context) {
+	id := c.Param("id")
+	adj, err := database.GetPayrollAdjustmentByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
+		return
+	}
+	if !payrollPeriodEditable(c, adj.Period) {
+		return
+	}
+
+	if err := database.DeletePayrollAdjustmentMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Adjustment deleted"})
+}
+
+// --- Payroll Runs ---
+
+func GetPayrollRunsMongo(c *gin.Context) {
+	runs, err := database.GetPayrollRunsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// CreatePayrollRunMongo drafts the run for a period and computes its payslips
+func CreatePayrollRunMongo(c *gin.Context) {
+	var input struct {
+		Period string `json:"period" binding:"required"`
+		Notes  string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, _, err := payrollPeriod(input.Period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	existing, err := database.GetPayrollRunByPeriodMongo(input.Period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payroll periode " + input.Period + " sudah ada", "id": existing.ID.Hex()})
+		return
+	}
+
+	run, err := database.CreatePayrollRunMongo(database.PayrollRunMongo{
+		Period:    input.Period,
+		Status:    "draft",
+		Notes:     input.Notes,
+		CreatedBy: c.MustGet("userID").(string),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if errors.Is(err, database.ErrPayrollRunExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payroll periode " + input.Period + " sudah ada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := computeRun(run); err != nil {
+		c.JSON(computeRunStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, run)
+}
+
+// GetPayrollRunMongo returns the run with its payslip summaries
+func GetPayrollRunMongo(c *gin.Context) {
+	run, err := database.GetPayrollRunByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	payslips, err := database.GetPayslipsByRunMongo(run.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"run": run, "payslips": payslips})
+}
+
+// RecalculatePayrollRunMongo picks up attendance, overtime, leave and adjustment changes; drafts only
+func RecalculatePayrollRunMongo(c *gin.Context) {
+	run, err := database.GetPayrollRunByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	if run.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft payroll runs can be recalculated"})
+		return
+	}
+	if err := computeRun(run); err != nil {
+		c.JSON(computeRunStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// UpdatePayrollRunStatusMongo moves a run draft -> reviewed -> finalized. A reviewed run can be
+// reopened to draft; a finalized run is locked for good.
+func UpdatePayrollRunStatusMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := database.GetPayrollRunByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	adminID := c.MustGet("userID").(string)
+	set := bson.M{"status": input.Status}
+	switch {
+	case input.Status == "reviewed" && run.Status == "draft":
+		set["reviewed_by"] = adminID
+		set["reviewed_at"] = now
+	case input.Status == "draft" && run.Status == "reviewed":
+		set["reviewed_by"] = ""
+		set["reviewed_at"] = ""
+	case input.Status == "finalized" && run.Status == "reviewed":
+		set["finalized_by"] = adminID
+		set["finalized_at"] = now
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change payroll run status from " + run.Status + " to " + input.Status})
+		return
+	}
+
+	updated, err := database.UpdatePayrollRunMongo(id, run.Status, set)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Payroll run was changed by someone else, reload and try again"})
+		return
+	}
+	if input.Status == "finalized" {
+		err = database.IssuePayslipsMongo(id, now)
+		if err == nil {
+			err = database.MarkPayrollClaimsPaidMongo(run.Period, id, now)
+		}
+	} else {
+		err = database.SetPayslipsStatusMongo(id, input.Status)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payroll run marked as " + input.Status})
+}
+
+func DeletePayrollRunMongo(c *gin.Context) {
+	id := c.Param("id")
+	run, err := database.GetPayrollRunByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	if run.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft payroll runs can be deleted"})
+		return
+	}
+
+	if err := database.DeletePayrollRunMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payroll run deleted"})
+}
+
+// GetPayslipMongo returns a payslip with its traceable lines
+func GetPayslipMongo(c *gin.Context) {
+	payslip, err := database.GetPayslipByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip not found"})
+		return
+	}
+	c.JSON(http.StatusOK, payslip)
+}
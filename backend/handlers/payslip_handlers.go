@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// payslipPassword is the employee's date of birth as DDMMYYYY, or "" when it is unknown
+func payslipPassword(user *database.UserMongo) string {
+	if user == nil {
+		return ""
+	}
+	for _, layout := range []string{"2006-01-02", "02-01-2006", "02/01/2006"} {
+		if dob, err := time.Parse(layout, strings.TrimSpace(user.DoB)); err == nil {
+			return dob.Format("02012006")
+		}
+	}
+	return ""
+}
+
+// filenameSlug lowercases s and replaces anything but letters and digits with dashes, so user
+// data is safe inside a Content-Disposition header
+func filenameSlug(s string) string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, s)
+	return strings.Trim(slug, "-")
+}
+
+func payslipFilename(p database.PayslipMongo) string {
+	return "slip-gaji-" + filenameSlug(p.Period) + "-" + filenameSlug(p.UserName) + ".pdf"
+}
+
+func payslipLineRows(lines []database.PayslipLine, lineType string) ([][]string, int64) {
+	rows := [][]string{}
+	var total int64
+	for _, l := range lines {
+		if l.Type != lineType {
+			continue
+		}
+		detail := l.Source.Note
+		if l.Quantity > 0 && l.Rate > 0 {
+			detail = fmt.Sprintf("%g x %s", l.Quantity, export.Rupiah(l.Rate))
+		}
+		rows = append(rows, []string{l.Name, detail, export.Rupiah(l.Amount)})
+		total += l.Amount
+	}
+	return rows, total
+}
+
+// renderPayslip writes the payslip PDF, protected with password when it is set
+func renderPayslip(p database.PayslipMongo, password string) ([]byte, error) {
+	title := "Slip Gaji"
+	if p.Status != "finalized" {
+		title += " (DRAFT)"
+	}
+	pdf := export.NewPDF(false)
+	pdf.Header(title, "Periode "+p.Period)
+
+	info := [][2]string{
+		{"Nama", p.UserName},
+		{"Jabatan", p.Jabatan},
+		{"NPWP", p.NPWP},
+		{"Status PTKP", p.StatusPTKP},
+		{"No. Rekening", p.BankAccount},
+	}
+	if p.Version > 1 {
+		info = append(info, [2]string{"Versi", fmt.Sprintf("%d, diterbitkan ulang %s", p.Version, p.ReissuedAt)})
+	}
+	pdf.KeyValues(info)
+
+	earnings, gross := payslipLineRows(p.Lines, "earning")
+	earnings = append(earnings, []string{"Total Pendapatan", "", export.Rupiah(gross)})
+	pdf.Table([]string{"Pendapatan", "Keterangan", "Jumlah"}, earnings)
+
+	deductions, totalDeductions := payslipLineRows(p.Lines, "deduction")
+	deductions = append(deductions, []string{"Total Potongan", "", export.Rupiah(totalDeductions)})
+	pdf.Table([]string{"Potongan", "Keterangan", "Jumlah"}, deductions)
+
+	pdf.KeyValues([][2]string{{"Gaji Bersih (Take Home Pay)", export.Rupiah(p.Net)}})
+
+	if employer, _ := payslipLineRows(p.Lines, "employer"); len(employer) > 0 {
+		pdf.Paragraph(9, true, "Iuran ditanggung perusahaan (tidak mengurangi gaji)")
+		pdf.Table([]string{"Iuran", "Keterangan", "Jumlah"}, employer)
+	}
+	pdf.Paragraph(8, false, "Dokumen ini dibuat oleh sistem dan sah tanpa tanda tangan.")
+
+	if password != "" {
+		pdf.SetPassword(password)
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// errNoPayslipPassword means payslips must be protected but the employee has no usable date of
+// birth; the payslip is not served rather than sent unprotected
+var errNoPayslipPassword = errors.New("payslip protection is on but the employee's date of birth is missing or invalid")
+
+// payslipDocument renders a payslip for download, deciding protection from the payroll settings
+func payslipDocument(p database.PayslipMongo, user *database.UserMongo, settings database.PayrollSettingsMongo) ([]byte, bool, error) {
+	password := ""
+	if settings.ProtectPayslips {
+		if password = payslipPassword(user); password == "" {
+			return nil, false, errNoPayslipPassword
+		}
+	}
+	doc, err := renderPayslip(p, password)
+	return doc, password != "", err
+}
+
+func payslipAudit(c *gin.Context, p database.PayslipMongo, action string, protected bool, note string) database.PayslipAuditMongo {
+	return database.PayslipAuditMongo{
+		PayslipID: p.ID.Hex(),
+		RunID:     p.RunID,
+		Period:    p.Period,
+		OwnerID:   p.UserID,
+		ActorID:   c.MustGet("userID").(string),
+		Action:    action,
+		Version:   p.Version,
+		Protected: protected,
+		Note:      note,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// sendPayslipPDF renders the payslip, records the download and sends the file
+func sendPayslipPDF(c *gin.Context, p *database.PayslipMongo, action string) {
+	settings, err := database.GetPayrollSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, _ := database.GetUserByIDMongo(p.UserID)
+	doc, protected, err := payslipDocument(*p, user, *settings)
+	if errors.Is(err, errNoPayslipPassword) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slip gaji tidak dapat diunduh karena tanggal lahir " + p.UserName + " belum diisi atau tidak valid; hubungi HR"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.AddPayslipAuditMongo([]database.PayslipAuditMongo{payslipAudit(c, *p, action, protected, "")}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, payslipFilename(*p)))
+	c.Data(http.StatusOK, "application/pdf", doc)
+}
+
+// --- Employee payslip portal ---
+
+// ownIssuedPayslip loads a finalized payslip of the caller. It writes the error response itself.
+func ownIssuedPayslip(c *gin.Context) (*database.PayslipMongo, bool) {
+	payslip, err := database.GetPayslipByIDMongo(c.Param("id"))
+	if err != nil || payslip.UserID != c.MustGet("userID").(string) || payslip.Status != "finalized" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip not found"})
+		return nil, false
+	}
+	return payslip, true
+}
+
+// GetMyPayslipsMongo lists the caller's issued payslips
+func GetMyPayslipsMongo(c *gin.Context) {
+	payslips, err := database.GetIssuedPayslipsByUserMongo(c.MustGet("userID").(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, payslips)
+}
+
+func GetMyPayslipMongo(c *gin.Context) {
+	payslip, ok := ownIssuedPayslip(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, payslip)
+}
+
+func DownloadMyPayslipMongo(c *gin.Context) {
+	payslip, ok := ownIssuedPayslip(c)
+	if !ok {
+		return
+	}
+	sendPayslipPDF(c, payslip, "download")
+}
+
+// --- Admin ---
+
+func DownloadPayslipMongo(c *gin.Context) {
+	payslip, err := database.GetPayslipByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip not found"})
+		return
+	}
+	sendPayslipPDF(c, payslip, "admin_download")
+}
+
+func GetPayslipAuditMongo(c *gin.Context) {
+	entries, err := database.GetPayslipAuditMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ReissuePayslipMongo issues a new version of a finalized payslip with the employee's current
+// name, position, NPWP and bank account. Amounts stay as finalized.
+func ReissuePayslipMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payslip, err := database.GetPayslipByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip not found"})
+		return
+	}
+	if payslip.Status != "finalized" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only payslips of finalized payroll runs can be re-issued"})
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	set := bson.M{
+		"reissued_by":    c.MustGet("userID").(string),
+		"reissued_at":    now,
+		"reissue_reason": input.Reason,
+	}
+	if user, err := database.GetUserByIDMongo(payslip.UserID); err == nil {
+		set["user_name"] = user.Name
+		set["jabatan"] = user.Jabatan
+		set["npwp"] = user.NPWP
+		set["bank_account"] = user.BankAccount
+	}
+	if err := database.ReissuePayslipMongo(id, set); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := database.GetPayslipByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.AddPayslipAuditMongo([]database.PayslipAuditMongo{payslipAudit(c, *updated, "reissue", false, input.Reason)}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DownloadPayrollRunZipMongo bundles every payslip PDF of a run into one zip
+func DownloadPayrollRunZipMongo(c *gin.Context) {
+	run, err := database.GetPayrollRunByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found"})
+		return
+	}
+	payslips, err := database.GetFullPayslipsByRunMongo(run.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := database.GetPayrollSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	userIDs := make([]string, len(payslips))
+	for i, p := range payslips {
+		userIDs[i] = p.UserID
+	}
+	// Looked up by ID so deleted employees still get their date of birth as the password
+	users, err := database.GetUsersByIDsMongo(userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if settings.ProtectPayslips {
+		missing := []string{}
+		for _, p := range payslips {
+			if user, ok := users[p.UserID]; !ok || payslipPassword(&user) == "" {
+				missing = append(missing, p.UserName)
+			}
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":       "Tanggal lahir belum diisi atau tidak valid, slip gaji tidak dapat dilindungi",
+				"missing_dob": missing,
+			})
+			return
+		}
+	}
+
+	// Build the archive first so a rendering error can still be reported as JSON
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	audit := []database.PayslipAuditMongo{}
+	used := make(map[string]int)
+	for _, p := range payslips {
+		var user *database.UserMongo
+		if u, ok := users[p.UserID]; ok {
+			user = &u
+		}
+		doc, protected, err := payslipDocument(p, user, *settings)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		name := payslipFilename(p)
+		if used[name]++; used[name] > 1 {
+			name = strings.TrimSuffix(name, ".pdf") + fmt.Sprintf("-%d.pdf", used[name])
+		}
+		f, err := zw.Create(name)
+		if err == nil {
+			_, err = f.Write(doc)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit = append(audit, payslipAudit(c, p, "bulk_download", protected, ""))
+	}
+	if err := zw.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.AddPayslipAuditMongo(audit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="slip-gaji-%s.zip"`, run.Period))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"kkhris-clone/database"
+	"testing"
+)
+
+func TestPayslipPassword(t *testing.T) {
+	cases := map[string]string{
+		"1990-08-17":   "17081990",
+		"17-08-1990":   "17081990",
+		" 17/08/1990 ": "17081990",
+		"17 Agustus":   "",
+		"":             "",
+	}
+	for dob, want := range cases {
+		if got := payslipPassword(&database.UserMongo{DoB: dob}); got != want {
+			t.Errorf("payslipPassword(%q) = %q, want %q", dob, got, want)
+		}
+	}
+	if got := payslipPassword(nil); got != "" {
+		t.Errorf("payslipPassword(nil) = %q", got)
+	}
+}
+
+func TestPayslipDocumentFailsClosed(t *testing.T) {
+	p := database.PayslipMongo{Period: "2026-01", UserName: "Budi", Status: "finalized"}
+	protect := database.PayrollSettingsMongo{ProtectPayslips: true}
+
+	for _, user := range []*database.UserMongo{nil, {DoB: ""}, {DoB: "unknown"}} {
+		if doc, _, err := payslipDocument(p, user, protect); !errors.Is(err, errNoPayslipPassword) || doc != nil {
+			t.Errorf("user %+v: got %d bytes, err %v; want errNoPayslipPassword", user, len(doc), err)
+		}
+	}
+
+	doc, protected, err := payslipDocument(p, &database.UserMongo{DoB: "1990-08-17"}, protect)
+	if err != nil || !protected || !bytes.Contains(doc, []byte("/Encrypt")) {
+		t.Errorf("protected payslip: protected %v, err %v", protected, err)
+	}
+
+	doc, protected, err = payslipDocument(p, nil, database.PayrollSettingsMongo{})
+	if err != nil || protected || bytes.Contains(doc, []byte("/Encrypt")) {
+		t.Errorf("unprotected payslip: protected %v, err %v", protected, err)
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+	"kkhris-clone/scheduler"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// jobScheduler is set by main once the jobs are registered
+var jobScheduler *scheduler.Scheduler
+
+func SetScheduler(s *scheduler.Scheduler) {
+	jobScheduler = s
+}
+
+// GetJobsMongo lists scheduled jobs with their schedule, next run and latest run
+func GetJobsMongo(c *gin.Context) {
+	jobs, err := jobScheduler.Status()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	leader := ""
+	if lock, err := database.GetLockMongo("scheduler:leader"); err == nil && lock != nil {
+		leader = lock.Owner
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":     jobs,
+		"instance": jobScheduler.Instance(),
+		"leader":   leader,
+	})
+}
+
+// UpdateJobMongo overrides a job's schedule or disables it. An empty schedule restores the default.
+func UpdateJobMongo(c *gin.Context) {
+	name := c.Param("name")
+	if !jobScheduler.HasJob(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	var input struct {
+		Schedule string `json:"schedule"`
+		Enabled  bool   `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Schedule != "" {
+		if _, err := scheduler.Parse(input.Schedule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	override := database.JobOverride{Schedule: input.Schedule, Disabled: !input.Enabled}
+	if err := database.UpdateJobOverrideMongo(name, override, time.Now().Format("2006-01-02 15:04:05"), c.MustGet("userID").(string)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job updated"})
+}
+
+// RunJobMongo starts a job now; its outcome appears in the run history
+func RunJobMongo(c *gin.Context) {
+	name := c.Param("name")
+	if !jobScheduler.HasJob(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	err := jobScheduler.RunNow(name, c.MustGet("userID").(string))
+	if err == scheduler.ErrJobRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job sedang berjalan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job started"})
+}
+
+// GetJobRunsMongo lists run history, optionally for one job (?job=), newest first
+func GetJobRunsMongo(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	runs, err := database.GetJobRunsMongo(c.Query("job"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// --- Retention ---
+
+func GetRetentionSettingsMongo(c *gin.Context) {
+	settings, err := database.GetRetentionSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"policies":   settings.Policies,
+		"categories": database.RetentionCategories,
+		"updated_at": settings.UpdatedAt,
+		"updated_by": settings.UpdatedBy,
+	})
+}
+
+func UpdateRetentionSettingsMongo(c *gin.Context) {
+	var input database.RetentionSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]bool)
+	for _, p := range input.Policies {
+		if _, ok := database.FindRetentionCategory(p.Category); !ok || seen[p.Category] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or duplicate category: " + p.Category})
+			return
+		}
+		if p.Days < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be at least 1 for " + p.Category})
+			return
+		}
+		seen[p.Category] = true
+	}
+	if input.Policies == nil {
+		input.Policies = []database.RetentionPolicy{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateRetentionSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	settings, err := database.GetRetentionSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// --- Cleanup ---
+
+// GetOrphanReportMongo reports what the orphan_cleanup job would archive, without changing data
+func GetOrphanReportMongo(c *gin.Context) {
+	result, err := database.CleanupOrphanedData(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetArchivedRecordsMongo lists records removed by cleanups that can still be restored
+func GetArchivedRecordsMongo(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "100"), 10, 64)
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	records, err := database.GetArchivedRecordsMongo(c.Query("collection"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
+
+func RestoreArchivedRecordMongo(c *gin.Context) {
+	rec, err := database.RestoreArchivedRecordMongo(c.Param("id"))
+	if err == mongo.ErrNoDocuments || err == primitive.ErrInvalidHex {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archived record not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Record restored", "collection": rec.Collection})
+}
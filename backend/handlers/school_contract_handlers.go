@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ContractDelivery compares sessions delivered in the current contract term with the pro-rated target
+type ContractDelivery struct {
+	ContractID      string `json:"contract_id"`
+	ContractNumber  string `json:"contract_number"`
+	SchoolID        string `json:"school_id"`
+	SchoolName      string `json:"school_name"`
+	TermStart       string `json:"term_start"`
+	TermEnd         string `json:"term_end"`
+	SessionsPerTerm int    `json:"sessions_per_term"`
+	Expected        int    `json:"expected"`
+	Delivered       int    `json:"delivered"`
+	Status          string `json:"status"` // on_track, over, under
+}
+
+type schoolContractInput struct {
+	SchoolID        string                  `json:"school_id" binding:"required"`
+	ContractNumber  string                  `json:"contract_number"`
+	StartDate       string                  `json:"start_date" binding:"required"`
+	EndDate         string                  `json:"end_date" binding:"required"`
+	RatePerSession  int64                   `json:"rate_per_session" binding:"required"`
+	SessionsPerTerm int                     `json:"sessions_per_term" binding:"required"`
+	TermMonths      int                     `json:"term_months"`
+	PaymentTermDays int                     `json:"payment_term_days"`
+	BillingContact  database.BillingContact `json:"billing_contact"`
+	Notes           string                  `json:"notes"`
+}
+
+// contractFromInput validates a contract and rejects windows overlapping another contract of the
+// same school, which would bill the same sessions twice. It writes the error response itself.
+func contractFromInput(c *gin.Context, excludeID string, input schoolContractInput) (database.SchoolContractMongo, bool) {
+	contract := database.SchoolContractMongo{
+		SchoolID:        input.SchoolID,
+		ContractNumber:  input.ContractNumber,
+		StartDate:       input.StartDate,
+		EndDate:         input.EndDate,
+		RatePerSession:  input.RatePerSession,
+		SessionsPerTerm: input.SessionsPerTerm,
+		TermMonths:      input.TermMonths,
+		PaymentTermDays: input.PaymentTermDays,
+		BillingContact:  input.BillingContact,
+		Notes:           input.Notes,
+	}
+	if contract.TermMonths == 0 {
+		contract.TermMonths = 6
+	}
+	if contract.PaymentTermDays == 0 {
+		contract.PaymentTermDays = 14
+	}
+
+	start, errStart := time.Parse("2006-01-02", contract.StartDate)
+	end, errEnd := time.Parse("2006-01-02", contract.EndDate)
+	if errStart != nil || errEnd != nil || end.Before(start) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date must be YYYY-MM-DD with start before end"})
+		return contract, false
+	}
+	if contract.RatePerSession <= 0 || contract.SessionsPerTerm <= 0 || contract.TermMonths < 0 || contract.PaymentTermDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate_per_session and sessions_per_term must be positive"})
+		return contract, false
+	}
+	if _, err := database.GetSchoolByIDMongo(contract.SchoolID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "School not found"})
+		return contract, false
+	}
+
+	existing, err := database.GetSchoolContractsMongo(contract.SchoolID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return contract, false
+	}
+	for _, ex := range existing {
+		if ex.ID.Hex() != excludeID && windowsOverlap(ex.StartDate, ex.EndDate, contract.StartDate, contract.EndDate) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sekolah ini sudah memiliki kontrak " + ex.ContractNumber + " (" + ex.StartDate + " s/d " + ex.EndDate + ")"})
+			return contract, false
+		}
+	}
+	return contract, true
+}
+
+// contractTerm returns the term containing date; terms run back to back from the contract start
+func contractTerm(contract database.SchoolContractMongo, date time.Time) (time.Time, time.Time) {
+	start, _ := time.Parse("2006-01-02", contract.StartDate)
+	contractEnd, _ := time.Parse("2006-01-02", contract.EndDate)
+	for contract.TermMonths > 0 && !start.AddDate(0, contract.TermMonths, 0).After(date) {
+		start = start.AddDate(0, contract.TermMonths, 0)
+	}
+	end := contractEnd
+	if contract.TermMonths > 0 {
+		if termEnd := start.AddDate(0, contract.TermMonths, -1); termEnd.Before(end) {
+			end = termEnd
+		}
+	}
+	return start, end
+}
+
+// deliveryStatus allows 10% (at least one session) either side of the pro-rated target
+func deliveryStatus(expected, delivered, perTerm int) string {
+	tolerance := expected / 10
+	if tolerance < 1 {
+		tolerance = 1
+	}
+	switch {
+	case delivered > perTerm || delivered > expected+tolerance:
+		return "over"
+	case delivered < expected-tolerance:
+		return "under"
+	}
+	return "on_track"
+}
+
+// computeDelivery measures the term containing asOf, clipped to the contract window
+func computeDelivery(contract database.SchoolContractMongo, asOf time.Time) (ContractDelivery, error) {
+	contractStart, _ := time.Parse("2006-01-02", contract.StartDate)
+	contractEnd, _ := time.Parse("2006-01-02", contract.EndDate)
+	if asOf.Before(contractStart) {
+		asOf = contractStart
+	}
+	if asOf.After(contractEnd) {
+		asOf = contractEnd
+	}
+
+	termStart, termEnd := contractTerm(contract, asOf)
+	delivered, err := database.CountSchoolClassSessionsMongo(contract.SchoolID, termStart.Format("2006-01-02"), asOf.Format("2006-01-02"))
+	if err != nil {
+		return ContractDelivery{}, err
+	}
+
+	termDays := termEnd.Sub(termStart).Hours()/24 + 1
+	elapsedDays := asOf.Sub(termStart).Hours()/24 + 1
+	expected := int(math.Round(float64(contract.SessionsPerTerm) * elapsedDays / termDays))
+
+	schoolName := ""
+	if school, err := database.GetSchoolByIDMongo(contract.SchoolID); err == nil {
+		schoolName = school.Name
+	}
+	return ContractDelivery{
+		ContractID:      contract.ID.Hex(),
+		ContractNumber:  contract.ContractNumber,
+		SchoolID:        contract.SchoolID,
+		SchoolName:      schoolName,
+		TermStart:       termStart.Format("2006-01-02"),
+		TermEnd:         termEnd.Format("2006-01-02"),
+		SessionsPerTerm: contract.SessionsPerTerm,
+		Expected:        expected,
+		Delivered:       int(delivered),
+		Status:          deliveryStatus(expected, int(delivered), contract.SessionsPerTerm),
+	}, nil
+}
+
+// --- School Contract Handlers ---
+
+func GetSchoolContractsMongo(c *gin.Context) {
+	contracts, err := database.GetSchoolContractsMongo(c.Query("school_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, contracts)
+}
+
+func CreateSchoolContractMongo(c *gin.Context) {
+	var input schoolContractInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	contract, ok := contractFromInput(c, "", input)
+	if !ok {
+		return
+	}
+	contract.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	created, err := database.CreateSchoolContractMongo(contract)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateSchoolContractMongo(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := database.GetSchoolContractByIDMongo(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contract not found"})
+		return
+	}
+
+	var input schoolContractInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	contract, ok := contractFromInput(c, id, input)
+	if !ok {
+		return
+	}
+
+	if err := database.UpdateSchoolContractMongo(id, contract); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Contract updated"})
+}
+
+func DeleteSchoolContractMongo(c *gin.Context) {
+	id := c.Param("id")
+	invoices, err := database.GetInvoicesMongo(database.InvoiceFilter{ContractID: id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, inv := range invoices {
+		if inv.Status != "draft" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Kontrak sudah memiliki invoice terkirim dan tidak dapat dihapus"})
+			return
+		}
+	}
+
+	if err := database.DeleteSchoolContractMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Contract deleted"})
+}
+
+// GetContractDeliveryMongo flags over- and under-delivery for every contract active on ?date= (default today)
+func GetContractDeliveryMongo(c *gin.Context) {
+	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
+	asOf, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	contracts, err := database.GetActiveSchoolContractsMongo(date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	deliveries := []ContractDelivery{}
+	for _, contract := range contracts {
+		delivery, err := computeDelivery(contract, asOf)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// --- Invoice Handlers ---
+
+// buildInvoice bills the sessions delivered under a contract in one month. It returns nil when
+// nothing was delivered.
+func buildInvoice(contract database.SchoolContractMongo, period string, monthStart time.Time) (*database.InvoiceMongo, error) {
+	from := monthStart.Format("2006-01-02")
+	to := monthStart.AddDate(0, 1, -1).Format("2006-01-02")
+	if contract.StartDate > from {
+		from = contract.StartDate
+	}
+	if contract.EndDate < to {
+		to = contract.EndDate
+	}
+
+	records, err := database.GetSchoolClassSessionsMongo(contract.SchoolID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	school, err := database.GetSchoolByIDMongo(contract.SchoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	coachIDs := make([]string, 0, len(records))
+	for _, att := range records {
+		coachIDs = append(coachIDs, att.UserID)
+	}
+	coaches, err := database.GetUsersByIDsMongo(coachIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	coachNames := make(map[string]string)
+	var coachOrder []string
+	sessionsByCoach := make(map[string]int)
+	sessions := []database.InvoiceSession{}
+	for _, att := range records {
+		if _, ok := coachNames[att.UserID]; !ok {
+			coachNames[att.UserID] = att.UserID
+			if user, ok := coaches[att.UserID]; ok {
+				coachNames[att.UserID] = user.Name
+			}
+			coachOrder = append(coachOrder, att.UserID)
+		}
+		sessionsByCoach[att.UserID]++
+		sessions = append(sessions, database.InvoiceSession{
+			AttendanceID: att.ID.Hex(),
+			Date:         att.Date,
+			StartTime:    att.StartingTime,
+			EndTime:      att.EndingTime,
+			CoachName:    coachNames[att.UserID],
+			Details:      att.ActivityDetails,
+		})
+	}
+
+	invoice := &database.InvoiceMongo{
+		ContractID:     contract.ID.Hex(),
+		ContractNumber: contract.ContractNumber,
+		SchoolID:       contract.SchoolID,
+		SchoolName:     school.Name,
+		BillingContact: contract.BillingContact,
+		Period:         period,
+		Sessions:       sessions,
+		Status:         "draft",
+	}
+	for _, coachID := range coachOrder {
+		line := database.InvoiceLine{
+			Description: "Sesi kelas - " + coachNames[coachID],
+			Quantity:    sessionsByCoach[coachID],
+			UnitPrice:   contract.RatePerSession,
+		}
+		line.Amount = int64(line.Quantity) * line.UnitPrice
+		invoice.Lines = append(invoice.Lines, line)
+		invoice.Total += line.Amount
+	}
+
+	toDate, _ := time.Parse("2006-01-02", to)
+	delivery, err := computeDelivery(contract, toDate)
+	if err != nil {
+		return nil, err
+	}
+	invoice.DeliveryStatus = delivery.Status
+	return invoice, nil
+}
+
+// GenerateInvoicesMongo creates (or regenerates drafts of) the monthly invoices for every contract
+// active in the period. Invoices already sent or paid are left untouched.
+func GenerateInvoicesMongo(c *gin.Context) {
+	var input struct {
+		Period   string `json:"period" binding:"required"` // YYYY-MM
+		SchoolID string `json:"school_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	monthStart, err := time.Parse("2006-01", input.Period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be YYYY-MM"})
+		return
+	}
+
+	contracts, err := database.GetActiveSchoolContractsMongo(monthStart.Format("2006-01-02"), monthStart.AddDate(0, 1, -1).Format("2006-01-02"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	adminID := c.MustGet("userID").(string)
+	created, regenerated, skipped := []database.InvoiceMongo{}, []database.InvoiceMongo{}, []gin.H{}
+	for _, contract := range contracts {
+		if input.SchoolID != "" && contract.SchoolID != input.SchoolID {
+			continue
+		}
+		existing, err := database.GetInvoiceByContractAndPeriodMongo(contract.ID.Hex(), input.Period)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil && existing.Status != "draft" {
+			skipped = append(skipped, gin.H{"contract_id": contract.ID.Hex(), "reason": "Invoice " + existing.Number + " sudah " + existing.Status})
+			continue
+		}
+
+		invoice, err := buildInvoice(contract, input.Period, monthStart)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if invoice == nil {
+			skipped = append(skipped, gin.H{"contract_id": contract.ID.Hex(), "reason": "Tidak ada sesi pada periode ini"})
+			continue
+		}
+		invoice.IssueDate = now.Format("2006-01-02")
+		invoice.DueDate = now.AddDate(0, 0, contract.PaymentTermDays).Format("2006-01-02")
+		invoice.CreatedBy = adminID
+		invoice.CreatedAt = now.Format("2006-01-02 15:04:05")
+
+		if existing != nil {
+			invoice.Number = existing.Number
+			if err := database.ReplaceDraftInvoiceMongo(existing.ID, *invoice); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			invoice.ID = existing.ID
+			regenerated = append(regenerated, *invoice)
+			continue
+		}
+
+		seq, err := database.NextSequenceMongo("invoice-" + monthStart.Format("2006"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		invoice.Number = fmt.Sprintf("INV/%s/%s/%04d", monthStart.Format("2006"), monthStart.Format("01"), seq)
+		saved, err := database.CreateInvoiceMongo(*invoice)
+		if errors.Is(err, database.ErrInvoiceExists) {
+			// Another run created it between the lookup and the insert
+			skipped = append(skipped, gin.H{"contract_id": contract.ID.Hex(), "reason": "Invoice sudah dibuat"})
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		created = append(created, *saved)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"created": created, "regenerated": regenerated, "skipped": skipped})
+}
+
+func GetInvoicesMongo(c *gin.Context) {
+	invoices, err := database.GetInvoicesMongo(database.InvoiceFilter{
+		Period:   c.Query("period"),
+		Status:   c.Query("status"),
+		SchoolID: c.Query("school_id"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, invoices)
+}
+
+func GetInvoiceMongo(c *gin.Context) {
+	invoice, err := database.GetInvoiceByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+	c.JSON(http.StatusOK, invoice)
+}
+
+// UpdateInvoiceStatusMongo moves an invoice forward: draft -> sent -> paid
+func UpdateInvoiceStatusMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invoice, err := database.GetInvoiceByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	set := bson.M{"status": input.Status}
+	switch {
+	case input.Status == "sent" && invoice.Status == "draft":
+		set["sent_at"] = now
+	case input.Status == "paid" && invoice.Status == "sent":
+		set["paid_at"] = now
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change invoice status from " + invoice.Status + " to " + input.Status})
+		return
+	}
+
+	if err := database.UpdateInvoiceStatusMongo(id, set); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice marked as " + input.Status})
+}
+
+func DeleteInvoiceMongo(c *gin.Context) {
+	deleted, err := database.DeleteDraftInvoiceMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft invoices can be deleted"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invoice deleted"})
+}
+
+// GetInvoicePDFMongo renders the invoice with its session list
+func GetInvoicePDFMongo(c *gin.Context) {
+	invoice, err := database.GetInvoiceByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	p := export.NewPDF(false)
+	p.Header("INVOICE", invoice.Number)
+	p.KeyValues([][2]string{
+		{"Kepada", invoice.SchoolName},
+		{"Up.", invoice.BillingContact.Name},
+		{"Alamat", invoice.BillingContact.Address},
+		{"No. Kontrak", invoice.ContractNumber},
+		{"Periode", invoice.Period},
+		{"Tanggal", invoice.IssueDate},
+		{"Jatuh Tempo", invoice.DueDate},
+		{"Status", invoice.Status},
+	})
+
+	rows := [][]string{}
+	for _, line := range invoice.Lines {
+		rows = append(rows, []string{line.Description, strconv.Itoa(line.Quantity), export.Rupiah(line.UnitPrice), export.Rupiah(line.Amount)})
+	}
+	rows = append(rows, []string{"TOTAL", "", "", export.Rupiah(invoice.Total)})
+	p.Table([]string{"Deskripsi", "Jumlah Sesi", "Harga per Sesi", "Subtotal"}, rows)
+
+	p.Paragraph(10, true, "Rincian Sesi")
+	sessionRows := [][]string{}
+	for _, s := range invoice.Sessions {
+		sessionRows = append(sessionRows, []string{s.Date, s.StartTime + " - " + s.EndTime, s.CoachName, s.Details})
+	}
+	p.Table([]string{"Tanggal", "Jam", "Coach", "Keterangan"}, sessionRows)
+	p.Signatures([]string{"Hormat kami"})
+
+	filename := "invoice-" + invoice.Period + "-" + invoice.ID.Hex()
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.pdf"`)
+	c.Status(http.StatusOK)
+	if err := p.Output(c.Writer); err != nil {
+		log.Printf("Invoice PDF error: %v", err)
+	}
+}
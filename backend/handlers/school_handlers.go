@@ -3,6 +3,8 @@ package handlers
 import (
 	"kkhris-clone/database"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -75,6 +77,12 @@ func UpdateSchoolMongo(c *gin.Context) {
 func DeleteSchoolMongo(c *gin.Context) {
 	id := c.Param("id")
 
+	// Remove the school's timetable first
+	if err := database.DeleteSchoolSchedulesBySchool(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	err := database.DeleteSchoolMongo(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -83,3 +91,477 @@ func DeleteSchoolMongo(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "School deleted"})
 }
+
+// --- School Class Schedule Handlers ---
+
+var scheduleDayNames = []string{"", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu", "Minggu"}
+
+type schoolScheduleInput struct {
+	CoachID       string `json:"coach_id" binding:"required"`
+	Day           int    `json:"day" binding:"required"` // 1 Monday ... 7 Sunday
+	StartTime     string `json:"start_time" binding:"required"`
+	EndTime       string `json:"end_time" binding:"required"`
+	Level         string `json:"level"`
+	ClassName     string `json:"class_name" binding:"required"`
+	EffectiveFrom string `json:"effective_from"`
+	EffectiveTo   string `json:"effective_to"`
+	Active        *bool  `json:"active"`
+}
+
+// scheduleFromInput validates a timetable slot and rejects double-booking the coach.
+// It writes the error response itself.
+func scheduleFromInput(c *gin.Context, schoolID, excludeID string, input schoolScheduleInput) (database.SchoolScheduleMongo, bool) {
+	schedule := database.SchoolScheduleMongo{
+		SchoolID:      schoolID,
+		CoachID:       input.CoachID,
+		Day:           input.Day,
+		StartTime:     input.StartTime,
+		EndTime:       input.EndTime,
+		Level:         input.Level,
+		ClassName:     input.ClassName,
+		EffectiveFrom: input.EffectiveFrom,
+		EffectiveTo:   input.EffectiveTo,
+		Active:        input.Active == nil || *input.Active,
+	}
+
+	if schedule.Day < 1 || schedule.Day > 7 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "day must be 1 (Monday) to 7 (Sunday)"})
+		return schedule, false
+	}
+	start, okStart := parseClock(schedule.StartTime)
+	end, okEnd := parseClock(schedule.EndTime)
+	if !okStart || !okEnd || end <= start {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_time and end_time must be HH:MM with start before end"})
+		return schedule, false
+	}
+	for _, d := range []string{schedule.EffectiveFrom, schedule.EffectiveTo} {
+		if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "effective_from and effective_to must be YYYY-MM-DD"})
+			return schedule, false
+		}
+	}
+	if schedule.EffectiveFrom != "" && schedule.EffectiveTo != "" && schedule.EffectiveTo < schedule.EffectiveFrom {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effective_to must not be before effective_from"})
+		return schedule, false
+	}
+	if _, err := database.GetUserByIDMongo(schedule.CoachID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coach not found"})
+		return schedule, false
+	}
+
+	if !schedule.Active {
+		return schedule, true
+	}
+	existing, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{CoachID: schedule.CoachID, Day: schedule.Day, ActiveOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return schedule, false
+	}
+	for _, ex := range existing {
+		if ex.ID.Hex() == excludeID || !windowsOverlap(ex.EffectiveFrom, ex.EffectiveTo, schedule.EffectiveFrom, schedule.EffectiveTo) {
+			continue
+		}
+		exStart, _ := parseClock(ex.StartTime)
+		exEnd, _ := parseClock(ex.EndTime)
+		if start < exEnd && exStart < end {
+			c.JSON(http.StatusConflict, gin.H{"error": "Coach sudah memiliki kelas " + ex.ClassName + " pada " + scheduleDayNames[ex.Day] + " " + ex.StartTime + " - " + ex.EndTime})
+			return schedule, false
+		}
+	}
+	return schedule, true
+}
+
+// windowsOverlap reports whether two optional date windows intersect; empty bounds are open
+func windowsOverlap(fromA, toA, fromB, toB string) bool {
+	if toA != "" && fromB != "" && toA < fromB {
+		return false
+	}
+	if toB != "" && fromA != "" && toB < fromA {
+		return false
+	}
+	return true
+}
+
+func GetSchoolSchedulesMongo(c *gin.Context) {
+	schedules, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{SchoolID: c.Param("id")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+func CreateSchoolScheduleMongo(c *gin.Context) {
+	schoolID := c.Param("id")
+	if _, err := database.GetSchoolByIDMongo(schoolID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "School not found"})
+		return
+	}
+
+	var input schoolScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule, ok := scheduleFromInput(c, schoolID, "", input)
+	if !ok {
+		return
+	}
+	schedule.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	created, err := database.CreateSchoolScheduleMongo(schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateSchoolScheduleMongo(c *gin.Context) {
+	id := c.Param("id")
+	current, err := database.GetSchoolScheduleByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+
+	var input schoolScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule, ok := scheduleFromInput(c, current.SchoolID, id, input)
+	if !ok {
+		return
+	}
+
+	if err := database.UpdateSchoolScheduleMongo(id, schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule updated"})
+}
+
+func DeleteSchoolScheduleMongo(c *gin.Context) {
+	if err := database.DeleteSchoolScheduleMongo(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
+}
+
+// GetSchoolRosterMongo lists a school's active timetable by weekday with coach names
+func GetSchoolRosterMongo(c *gin.Context) {
+	schoolID := c.Param("id")
+	school, err := database.GetSchoolByIDMongo(schoolID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "School not found"})
+		return
+	}
+	schedules, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{SchoolID: schoolID, ActiveOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type rosterClass struct {
+		database.SchoolScheduleMongo
+		CoachName string `json:"coach_name"`
+	}
+	type rosterDay struct {
+		Day     int           `json:"day"`
+		DayName string        `json:"day_name"`
+		Classes []rosterClass `json:"classes"`
+	}
+
+	coachIDs := []string{}
+	for _, s := range schedules {
+		coachIDs = append(coachIDs, s.CoachID)
+	}
+	coaches, err := database.GetUsersByIDsMongo(coachIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := []rosterDay{}
+	for _, s := range schedules {
+		if len(days) == 0 || days[len(days)-1].Day != s.Day {
+			days = append(days, rosterDay{Day: s.Day, DayName: scheduleDayNames[s.Day]})
+		}
+		last := &days[len(days)-1]
+		last.Classes = append(last.Classes, rosterClass{SchoolScheduleMongo: s, CoachName: coaches[s.CoachID].Name})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"school": school, "days": days})
+}
+
+// AttendancePrefill is a ready-to-submit attendance draft for a scheduled class
+type AttendancePrefill struct {
+	Date               string   `json:"date"`
+	ActivityType       string   `json:"activity_type"`
+	ActivityCategories []string `json:"activity_categories"`
+	ActivityDetails    string   `json:"activity_details"`
+	StartingTime       string   `json:"starting_time"`
+	EndingTime         string   `json:"ending_time"`
+	ActivityNotes      string   `json:"activity_notes"`
+	SchoolID           string   `json:"school_id"`
+}
+
+// ScheduledClass is one dated occurrence of a timetable slot
+type ScheduledClass struct {
+	ScheduleID   string             `json:"schedule_id"`
+	Date         string             `json:"date"`
+	DayName      string             `json:"day_name"`
+	StartTime    string             `json:"start_time"`
+	EndTime      string             `json:"end_time"`
+	SchoolID     string             `json:"school_id"`
+	SchoolName   string             `json:"school_name"`
+	Level        string             `json:"level"`
+	ClassName    string             `json:"class_name"`
+	Holiday      string             `json:"holiday,omitempty"`
+	Attended     bool               `json:"attended"`
+	AttendanceID string             `json:"attendance_id,omitempty"`
+	Prefill      *AttendancePrefill `json:"prefill,omitempty"`
+}
+
+// scheduledClasses expands a coach's active timetable into dated classes between from and to,
+// marking holidays and classes that already have matching School Class attendance
+func scheduledClasses(coachID string, from, to time.Time) ([]ScheduledClass, error) {
+	schedules, err := database.GetSchoolSchedulesMongo(database.SchoolScheduleFilter{CoachID: coachID, ActiveOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	fromStr, toStr := from.Format("2006-01-02"), to.Format("2006-01-02")
+	holidays, err := database.GetHolidayDatesMongo(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	records, err := database.GetAttendanceByUserAndPeriod(coachID, fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	attendanceByDate := make(map[string][]database.AttendanceMongo)
+	for _, att := range records {
+		attendanceByDate[att.Date] = append(attendanceByDate[att.Date], att)
+	}
+	schools, err := database.GetSchoolsMongo()
+	if err != nil {
+		return nil, err
+	}
+	schoolNames := make(map[string]string)
+	for _, school := range schools {
+		schoolNames[school.ID.Hex()] = school.Name
+	}
+
+	classes := []ScheduledClass{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		for _, s := range schedules {
+			if !scheduleRunsOn(s, d) {
+				continue
+			}
+			class := ScheduledClass{
+				ScheduleID: s.ID.Hex(),
+				Date:       date,
+				DayName:    scheduleDayNames[s.Day],
+				StartTime:  s.StartTime,
+				EndTime:    s.EndTime,
+				SchoolID:   s.SchoolID,
+				SchoolName: schoolNames[s.SchoolID],
+				Level:      s.Level,
+				ClassName:  s.ClassName,
+				Holiday:    holidays[date],
+			}
+			for _, att := range attendanceByDate[date] {
+				if att.SchoolID == s.SchoolID && att.StartingTime == s.StartTime {
+					class.Attended = true
+					class.AttendanceID = att.ID.Hex()
+					break
+				}
+			}
+			if !class.Attended && class.Holiday == "" {
+				details := s.ClassName
+				if s.Level != "" {
+					details += " (" + s.Level + ")"
+				}
+				class.Prefill = &AttendancePrefill{
+					Date:               date,
+					ActivityType:       "Daily Activity",
+					ActivityCategories: []string{schoolClassCategory},
+					ActivityDetails:    details,
+					StartingTime:       s.StartTime,
+					EndingTime:         s.EndTime,
+					ActivityNotes:      "[" + class.SchoolName + "] ",
+					SchoolID:           s.SchoolID,
+				}
+			}
+			classes = append(classes, class)
+		}
+	}
+	return classes, nil
+}
+
+// scheduleCoachID returns the caller, or ?user_id= for admins and managers
+func scheduleCoachID(c *gin.Context) string {
+	if userID := c.Query("user_id"); userID != "" && hasAdminAccess(c) {
+		return userID
+	}
+	return c.MustGet("userID").(string)
+}
+
+// GetMyScheduleMongo lists upcoming classes from ?from= (default today) for ?days= days (default 7, max 31)
+func GetMyScheduleMongo(c *gin.Context) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if f := c.Query("from"); f != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", f, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	days := 7
+	if d, err := strconv.Atoi(c.Query("days")); err == nil && d > 0 {
+		days = d
+	}
+	if days > 31 {
+		days = 31
+	}
+
+	classes, err := scheduledClasses(scheduleCoachID(c), from, from.AddDate(0, 0, days-1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, classes)
+}
+
+// GetSchedulePrefillMongo returns attendance drafts for scheduled classes on ?date= that have no attendance yet
+func GetSchedulePrefillMongo(c *gin.Context) {
+	date, err := time.ParseInLocation("2006-01-02", c.DefaultQuery("date", time.Now().Format("2006-01-02")), time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+
+	classes, err := scheduledClasses(scheduleCoachID(c), date, date)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	prefills := []ScheduledClass{}
+	for _, class := range classes {
+		if class.Prefill != nil {
+			prefills = append(prefills, class)
+		}
+	}
+	c.JSON(http.StatusOK, prefills)
+}
+
+// GenerateScheduleReminders raises reminders for every coach with a timetable: classes starting
+// within the next hour and past classes still missing attendance inside the backdate window.
+// Reminders of classes that have since been attended are resolved. Run by the
+// schedule_reminders job.
+func GenerateScheduleReminders(now time.Time) (map[string]int64, error) {
+	rules, err := database.GetAttendanceRulesMongo()
+	if err != nil {
+		return nil, err
+	}
+	coachIDs, err := database.GetScheduledCoachIDsMongo()
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	nowClock := now.Hour()*60 + now.Minute()
+	todayStr := today.Format("2006-01-02")
+	createdAt := now.Format("2006-01-02 15:04:05")
+	counts := map[string]int64{"starting_soon": 0, "missing_attendance": 0}
+	for _, coachID := range coachIDs {
+		classes, err := scheduledClasses(coachID, today.AddDate(0, 0, -rules.MaxBackdateDays), today)
+		if err != nil {
+			return counts, err
+		}
+		for _, class := range classes {
+			if class.Holiday != "" {
+				continue
+			}
+			if class.Attended {
+				if err := database.ResolveScheduleRemindersMongo(coachID, class.ScheduleID, class.Date, ""); err != nil {
+					return counts, err
+				}
+				continue
+			}
+			start, _ := parseClock(class.StartTime)
+			end, _ := parseClock(class.EndTime)
+			var kind, message string
+			switch {
+			case class.Date == todayStr && start >= nowClock && start-nowClock <= 60:
+				kind, message = "starting_soon", "Kelas "+class.ClassName+" di "+class.SchoolName+" mulai pukul "+class.StartTime
+			case class.Date < todayStr || end <= nowClock:
+				kind, message = "missing_attendance", "Absensi kelas "+class.ClassName+" di "+class.SchoolName+" ("+class.Date+") belum diisi"
+			default:
+				continue
+			}
+			if err := database.ResolveScheduleRemindersMongo(coachID, class.ScheduleID, class.Date, kind); err != nil {
+				return counts, err
+			}
+			added, err := database.AddScheduleReminderMongo(database.ScheduleReminderMongo{
+				UserID:     coachID,
+				ScheduleID: class.ScheduleID,
+				Date:       class.Date,
+				Kind:       kind,
+				Message:    message,
+				SchoolID:   class.SchoolID,
+				SchoolName: class.SchoolName,
+				ClassName:  class.ClassName,
+				StartTime:  class.StartTime,
+				EndTime:    class.EndTime,
+				CreatedAt:  createdAt,
+			})
+			if err != nil {
+				return counts, err
+			}
+			if added {
+				counts[kind]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// GetScheduleRemindersMongo lists the open reminders raised for the coach
+func GetScheduleRemindersMongo(c *gin.Context) {
+	reminders, err := database.GetOpenScheduleRemindersMongo(scheduleCoachID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reminders)
+}
+
+// DismissScheduleReminderMongo hides one of the caller's reminders
+func DismissScheduleReminderMongo(c *gin.Context) {
+	ok, err := database.DismissScheduleReminderMongo(c.Param("id"), c.MustGet("userID").(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Reminder dismissed"})
+}
+
+// scheduleRunsOn reports whether a weekly schedule holds a class on day
+func scheduleRunsOn(s database.SchoolScheduleMongo, day time.Time) bool {
+	isoDay := int(day.Weekday())
+	if isoDay == 0 {
+		isoDay = 7
+	}
+	date := day.Format("2006-01-02")
+	return s.Day == isoDay && (s.EffectiveFrom == "" || date >= s.EffectiveFrom) && (s.EffectiveTo == "" || date <= s.EffectiveTo)
+}
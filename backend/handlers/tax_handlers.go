@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// normalizePTKP turns StatusPTKP spellings like "k1", "K-1" or "K/1" into "K/1" and reports
+// marital status and dependents. Unknown or empty values fall back to TK/0, the highest withholding.
+func normalizePTKP(status string, maxDependents int) (string, bool, int, bool) {
+	s := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "/", "").Replace(status))
+	married := false
+	switch {
+	case strings.HasPrefix(s, "TK"):
+		s = s[2:]
+	case strings.HasPrefix(s, "KI"): // K/I: spouse income combined, withheld as married
+		married, s = true, s[2:]
+	case strings.HasPrefix(s, "K"):
+		married, s = true, s[1:]
+	default:
+		return "TK/0", false, 0, false
+	}
+	dependents, err := strconv.Atoi(s)
+	if err != nil || dependents < 0 {
+		return "TK/0", false, 0, false
+	}
+	if maxDependents > 0 && dependents > maxDependents {
+		dependents = maxDependents
+	}
+	prefix := "TK/"
+	if married {
+		prefix = "K/"
+	}
+	return prefix + strconv.Itoa(dependents), married, dependents, true
+}
+
+// hasNPWP treats a 15- or 16-digit number (NIK counts since 2024) that is not all zeros as registered
+func hasNPWP(npwp string) bool {
+	digits := 0
+	nonZero := false
+	for _, r := range npwp {
+		if r >= '0' && r <= '9' {
+			digits++
+			nonZero = nonZero || r != '0'
+		}
+	}
+	return digits >= 15 && nonZero
+}
+
+func bracketRate(brackets []database.TaxBracket, amount int64) float64 {
+	for _, b := range brackets {
+		if b.UpTo == 0 || amount <= b.UpTo {
+			return b.Rate
+		}
+	}
+	return 0
+}
+
+// progressiveTax applies each bracket's rate to the slice of amount falling inside it
+func progressiveTax(brackets []database.TaxBracket, amount int64) int64 {
+	tax := 0.0
+	lower := int64(0)
+	for _, b := range brackets {
+		if amount <= lower {
+			break
+		}
+		upper := amount
+		if b.UpTo != 0 && b.UpTo < amount {
+			upper = b.UpTo
+		}
+		tax += float64(upper-lower) * b.Rate / 100
+		lower = b.UpTo
+		if b.UpTo == 0 {
+			break
+		}
+	}
+	return int64(math.Round(tax))
+}
+
+func withSurcharge(amount int64, surcharge float64, noNPWP bool) int64 {
+	if !noNPWP || surcharge == 0 {
+		return amount
+	}
+	return int64(math.Round(float64(amount) * (100 + surcharge) / 100))
+}
+
+// applyPPh21 adds the PPh 21 line. January to November withhold the TER rate for the PTKP
+// category on monthly taxable gross; December reconciles the year with the Pasal 17 brackets
+// against what finalized payslips already withheld, refunding any over-withholding.
+func applyPPh21(p *database.PayslipMongo, in *payrollInputs) error {
+	rules := in.TaxRules
+	status, married, dependents, known := normalizePTKP(p.StatusPTKP, rules.PTKP.MaxDependents)
+
+	tax := database.PayslipTax{RuleYear: rules.Year, StatusPTKP: status, NoNPWP: !hasNPWP(p.NPWP)}
+	for _, l := range p.Lines {
+		if !l.Taxable {
+			continue
+		}
+		switch {
+		case l.Type == "deduction" && l.PayCut:
+			tax.TaxableGross -= l.Amount
+		case l.Type == "deduction":
+			tax.Deductible += l.Amount
+		default:
+			tax.TaxableGross += l.Amount
+		}
+	}
+
+	source := database.PayslipLineSource{Kind: "tax", RefIDs: []string{rules.ID.Hex()}}
+	if in.Period[5:] != "12" {
+		tax.Method = "ter"
+		tax.Category = rules.TERCategories[status]
+		brackets := rules.TERTables[tax.Category]
+		if len(brackets) == 0 {
+			return fmt.Errorf("PPh 21 rules %d have no TER table for %s", rules.Year, status)
+		}
+		tax.Rate = bracketRate(brackets, tax.TaxableGross)
+		tax.Amount = withSurcharge(int64(math.Round(float64(tax.TaxableGross)*tax.Rate/100)), rules.NoNPWPSurcharge, tax.NoNPWP)
+		source.Note = fmt.Sprintf("TER %s %g%%", tax.Category, tax.Rate)
+	} else {
+		prior, err := database.GetFinalizedPayslipsForTaxYearMongo(p.UserID, in.Period[:4], in.Period)
+		if err != nil {
+			return err
+		}
+		tax.Method = "annual"
+		tax.AnnualGross = tax.TaxableGross
+		deductible := tax.Deductible
+		months := int64(1)
+		for _, ps := range prior {
+			if ps.Tax == nil {
+				continue
+			}
+			tax.AnnualGross += ps.Tax.TaxableGross
+			deductible += ps.Tax.Deductible
+			tax.WithheldBefore += ps.Tax.Amount
+			months++
+			source.RefIDs = append(source.RefIDs, ps.ID.Hex())
+		}
+
+		tax.MissingPeriods = missingTaxPeriods(in.Period, prior)
+
+		tax.PositionCost = min(int64(math.Round(float64(tax.AnnualGross)*rules.PositionCostRate/100)), rules.PositionCostMaxMonthly*months)
+		tax.AnnualNet = tax.AnnualGross - tax.PositionCost - deductible
+		tax.PTKP = rules.PTKP.Self + int64(dependents)*rules.PTKP.PerDependent
+		if married {
+			tax.PTKP += rules.PTKP.Married
+		}
+		// Taxable income is rounded down to the thousand
+		if tax.AnnualNet > tax.PTKP {
+			tax.TaxableIncome = (tax.AnnualNet - tax.PTKP) / 1000 * 1000
+		}
+		tax.AnnualTax = withSurcharge(progressiveTax(rules.AnnualBrackets, tax.TaxableIncome), rules.NoNPWPSurcharge, tax.NoNPWP)
+		tax.Amount = tax.AnnualTax - tax.WithheldBefore
+		source.Note = fmt.Sprintf("Rekonsiliasi tahunan %d bulan", months)
+		if len(tax.MissingPeriods) > 0 {
+			source.Note += "; tanpa slip final: " + strings.Join(tax.MissingPeriods, ", ")
+		}
+	}
+	if !known && p.StatusPTKP != "" {
+		source.Note += "; StatusPTKP " + p.StatusPTKP + " tidak dikenal, dipakai TK/0"
+	} else if !known {
+		source.Note += "; StatusPTKP kosong, dipakai TK/0"
+	}
+	if tax.NoNPWP {
+		source.Note += fmt.Sprintf("; tanpa NPWP +%g%%", rules.NoNPWPSurcharge)
+	}
+
+	p.Tax = &tax
+	switch {
+	case tax.Amount > 0:
+		p.Lines = append(p.Lines, database.PayslipLine{
+			Code: "PPH21", Name: "PPh 21", Type: "deduction", Amount: tax.Amount, Source: source,
+		})
+	case tax.Amount < 0:
+		p.Lines = append(p.Lines, database.PayslipLine{
+			Code: "PPH21_REFUND", Name: "Pengembalian Kelebihan PPh 21", Type: "earning", Amount: -tax.Amount, Source: source,
+		})
+	}
+	return nil
+}
+
+// missingTaxPeriods lists the months of period's year before period that have no finalized
+// payslip with a tax record, e.g. months before the employee joined or without a payroll run
+func missingTaxPeriods(period string, prior []database.PayslipMongo) []string {
+	covered := make(map[string]bool, len(prior))
+	for _, ps := range prior {
+		if ps.Tax != nil {
+			covered[ps.Period] = true
+		}
+	}
+	end, err := time.Parse("2006-01", period)
+	if err != nil {
+		return nil
+	}
+	var missing []string
+	for m := time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, time.UTC); m.Before(end); m = m.AddDate(0, 1, 0) {
+		if p := m.Format("2006-01"); !covered[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+func validBrackets(brackets []database.TaxBracket) bool {
+	if len(brackets) == 0 || brackets[len(brackets)-1].UpTo != 0 {
+		return false
+	}
+	prev := int64(0)
+	for i, b := range brackets {
+		if b.Rate < 0 || b.Rate > 100 {
+			return false
+		}
+		if i < len(brackets)-1 && b.UpTo <= prev {
+			return false
+		}
+		prev = b.UpTo
+	}
+	return true
+}
+
+func validateTaxRules(rules database.TaxRuleSetMongo) error {
+	if rules.Year < 2000 || rules.Year > 2100 {
+		return fmt.Errorf("year is out of range")
+	}
+	if rules.PTKP.Self <= 0 || rules.PTKP.Married < 0 || rules.PTKP.PerDependent < 0 {
+		return fmt.Errorf("ptkp amounts must be positive")
+	}
+	if rules.PositionCostRate < 0 || rules.PositionCostMaxMonthly < 0 || rules.NoNPWPSurcharge < 0 {
+		return fmt.Errorf("position cost and NPWP surcharge cannot be negative")
+	}
+	if !validBrackets(rules.AnnualBrackets) {
+		return fmt.Errorf("annual_brackets must ascend and end with an open bracket (up_to 0)")
+	}
+	for category, brackets := range rules.TERTables {
+		if !validBrackets(brackets) {
+			return fmt.Errorf("TER table %s must ascend and end with an open bracket (up_to 0)", category)
+		}
+	}
+	for status, category := range rules.TERCategories {
+		if normalized, _, _, ok := normalizePTKP(status, 0); !ok || normalized != status {
+			return fmt.Errorf("ter_categories key %s must be a PTKP status like TK/0 or K/1", status)
+		}
+		if _, ok := rules.TERTables[category]; !ok {
+			return fmt.Errorf("TER category %s for %s has no table", category, status)
+		}
+	}
+	return nil
+}
+
+// --- Tax Rules ---
+
+func GetTaxRulesMongo(c *gin.Context) {
+	rules, err := database.GetTaxRuleSetsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// GetTaxRuleMongo returns the rule set in force for a year, which may be an earlier year's version
+func GetTaxRuleMongo(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+	rules, err := database.GetTaxRuleSetForYearMongo(year)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No tax rules for this year"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// SaveTaxRuleMongo creates or replaces the version for a year; later years without their own
+// version pick it up too
+func SaveTaxRuleMongo(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+	var input database.TaxRuleSetMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Year = year
+	if err := validateTaxRules(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	used, err := database.CountFinalizedPayslipsByTaxRuleMongo(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Aturan pajak ini sudah dipakai payroll final; buat versi untuk tahun berikutnya"})
+		return
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+	if err := database.SaveTaxRuleSetMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+func DeleteTaxRuleMongo(c *gin.Context) {
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+	used, err := database.CountFinalizedPayslipsByTaxRuleMongo(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if used > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Aturan pajak ini sudah dipakai payroll final dan tidak dapat dihapus"})
+		return
+	}
+
+	deleted, err := database.DeleteTaxRuleSetMongo(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No tax rules for this year"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rules deleted"})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePTKP(t *testing.T) {
+	cases := []struct {
+		in         string
+		want       string
+		married    bool
+		dependents int
+		known      bool
+	}{
+		{"TK/0", "TK/0", false, 0, true},
+		{"tk1", "TK/1", false, 1, true},
+		{"K-2", "K/2", true, 2, true},
+		{"k / 3", "K/3", true, 3, true},
+		{"K/I/1", "K/1", true, 1, true},
+		{"K/5", "K/3", true, 3, true}, // capped at MaxDependents
+		{"", "TK/0", false, 0, false},
+		{"kawin", "TK/0", false, 0, false},
+		{"TK/x", "TK/0", false, 0, false},
+	}
+	for _, tc := range cases {
+		got, married, dependents, known := normalizePTKP(tc.in, 3)
+		if got != tc.want || married != tc.married || dependents != tc.dependents || known != tc.known {
+			t.Errorf("normalizePTKP(%q) = %q, %v, %d, %v; want %q, %v, %d, %v",
+				tc.in, got, married, dependents, known, tc.want, tc.married, tc.dependents, tc.known)
+		}
+	}
+}
+
+func TestHasNPWP(t *testing.T) {
+	cases := map[string]bool{
+		"01.234.567.8-901.000": true,
+		"3171012345678901":     true, // NIK
+		"00.000.000.0-000.000": false,
+		"12345":                false,
+		"":                     false,
+	}
+	for in, want := range cases {
+		if got := hasNPWP(in); got != want {
+			t.Errorf("hasNPWP(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestTERRates(t *testing.T) {
+	rules := database.DefaultTaxRuleSet()
+	cases := []struct {
+		status string
+		gross  int64
+		want   float64
+	}{
+		{"TK/0", 5400000, 0},
+		{"TK/0", 5400001, 0.25},
+		{"TK/0", 10000000, 2},
+		{"K/1", 8000000, 1},
+		{"K/3", 6600000, 0},
+		{"K/3", 20000000, 8},
+		{"TK/0", 2000000000, 34},
+	}
+	for _, tc := range cases {
+		brackets := rules.TERTables[rules.TERCategories[tc.status]]
+		if got := bracketRate(brackets, tc.gross); got != tc.want {
+			t.Errorf("TER %s on %d = %g%%, want %g%%", tc.status, tc.gross, got, tc.want)
+		}
+	}
+}
+
+func TestProgressiveTax(t *testing.T) {
+	brackets := database.DefaultTaxRuleSet().AnnualBrackets
+	cases := map[int64]int64{
+		0:          0,
+		60000000:   3000000,
+		100000000:  9000000,
+		300000000:  44000000,
+		6000000000: 1794000000, // 3M + 28.5M + 62.5M + 1.35B, then 35% of the last billion
+	}
+	for amount, want := range cases {
+		if got := progressiveTax(brackets, amount); got != want {
+			t.Errorf("progressiveTax(%d) = %d, want %d", amount, got, want)
+		}
+	}
+}
+
+func TestWithSurcharge(t *testing.T) {
+	if got := withSurcharge(100000, 20, true); got != 120000 {
+		t.Errorf("withSurcharge without NPWP = %d, want 120000", got)
+	}
+	if got := withSurcharge(100000, 20, false); got != 100000 {
+		t.Errorf("withSurcharge with NPWP = %d, want 100000", got)
+	}
+}
+
+func TestDefaultTaxRulesAreValid(t *testing.T) {
+	if err := validateTaxRules(database.DefaultTaxRuleSet()); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := database.DefaultTaxRuleSet()
+	rules.AnnualBrackets = []database.TaxBracket{{UpTo: 60000000, Rate: 5}, {UpTo: 250000000, Rate: 15}}
+	if err := validateTaxRules(rules); err == nil {
+		t.Error("annual brackets without an open last bracket were accepted")
+	}
+
+	rules = database.DefaultTaxRuleSet()
+	rules.TERCategories["TK/0"] = "D"
+	if err := validateTaxRules(rules); err == nil {
+		t.Error("TER category without a table was accepted")
+	}
+}
+
+func TestApplyPPh21MonthlyTER(t *testing.T) {
+	in := &payrollInputs{Period: "2024-03", TaxRules: database.DefaultTaxRuleSet()}
+	p := &database.PayslipMongo{
+		StatusPTKP: "k1",
+		Lines: []database.PayslipLine{
+			{Code: "BASIC", Type: "earning", Amount: 7500000, Taxable: true},
+			{Code: "TRANSPORT", Type: "earning", Amount: 500000, Taxable: true},
+			{Code: "MEAL", Type: "earning", Amount: 300000},
+			{Code: "JHT", Type: "deduction", Amount: 150000, Taxable: true},
+		},
+	}
+	if err := applyPPh21(p, in); err != nil {
+		t.Fatal(err)
+	}
+	if p.Tax.Method != "ter" || p.Tax.Category != "B" || p.Tax.TaxableGross != 8000000 || p.Tax.Rate != 1 {
+		t.Fatalf("tax = %+v", *p.Tax)
+	}
+	// 1% of 8,000,000 plus the 20% surcharge for a missing NPWP
+	if p.Tax.Amount != 96000 {
+		t.Errorf("amount = %d, want 96000", p.Tax.Amount)
+	}
+	last := p.Lines[len(p.Lines)-1]
+	if last.Code != "PPH21" || last.Type != "deduction" || last.Amount != 96000 {
+		t.Errorf("PPh 21 line = %+v", last)
+	}
+}
+
+func TestMissingTaxPeriods(t *testing.T) {
+	prior := []database.PayslipMongo{{Period: "2024-04"}} // no tax record
+	for _, period := range []string{"2024-01", "2024-02", "2024-05", "2024-06", "2024-07", "2024-08", "2024-09", "2024-10", "2024-11"} {
+		prior = append(prior, database.PayslipMongo{Period: period, Tax: &database.PayslipTax{}})
+	}
+	want := []string{"2024-03", "2024-04"}
+	if got := missingTaxPeriods("2024-12", prior); !reflect.DeepEqual(got, want) {
+		t.Errorf("missingTaxPeriods = %v, want %v", got, want)
+	}
+	if got := missingTaxPeriods("2024-01", nil); got != nil {
+		t.Errorf("missingTaxPeriods for January = %v, want none", got)
+	}
+}
+
+func TestApplyPPh21UnpaidLeaveLowersTax(t *testing.T) {
+	payslip := func(unpaid int64) *database.PayslipMongo {
+		p := &database.PayslipMongo{
+			StatusPTKP: "TK/0",
+			NPWP:       "01.234.567.8-901.000",
+			Lines: []database.PayslipLine{
+				{Code: "BASE", Type: "earning", Amount: 10000000, Taxable: true},
+				{Code: "JHT", Type: "deduction", Amount: 200000, Taxable: true},
+			},
+		}
+		if unpaid > 0 {
+			p.Lines = append(p.Lines, database.PayslipLine{Code: "UNPAID_LEAVE", Type: "deduction", Amount: unpaid, Taxable: true, PayCut: true})
+		}
+		return p
+	}
+
+	in := &payrollInputs{Period: "2024-03", TaxRules: database.DefaultTaxRuleSet()}
+	full, cut := payslip(0), payslip(4000000)
+	if err := applyPPh21(full, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyPPh21(cut, in); err != nil {
+		t.Fatal(err)
+	}
+	if cut.Tax.TaxableGross != 6000000 || cut.Tax.Deductible != 200000 {
+		t.Errorf("taxable gross %d, deductible %d; want 6000000 and 200000", cut.Tax.TaxableGross, cut.Tax.Deductible)
+	}
+	if cut.Tax.Amount >= full.Tax.Amount {
+		t.Errorf("tax with unpaid leave %d, without %d", cut.Tax.Amount, full.Tax.Amount)
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TeachingHoursTotal sums the report for one school or one coach
+type TeachingHoursTotal struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Sessions int     `json:"sessions"`
+	Minutes  int     `json:"minutes"`
+	Hours    float64 `json:"hours"`
+}
+
+func teachingHoursFilter(c *gin.Context) (database.TeachingHoursFilter, bool) {
+	from, to, err := periodFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return database.TeachingHoursFilter{}, false
+	}
+	return database.TeachingHoursFilter{
+		From:     from,
+		To:       to,
+		SchoolID: c.Query("school_id"),
+		UserID:   c.Query("user_id"),
+	}, true
+}
+
+// totalTeachingHours rolls rows up by key, sorted by name
+func totalTeachingHours(rows []database.TeachingHoursRow, key func(database.TeachingHoursRow) (string, string)) []TeachingHoursTotal {
+	index := make(map[string]int)
+	totals := []TeachingHoursTotal{}
+	for _, r := range rows {
+		id, name := key(r)
+		i, ok := index[id]
+		if !ok {
+			i = len(totals)
+			index[id] = i
+			totals = append(totals, TeachingHoursTotal{ID: id, Name: name})
+		}
+		totals[i].Sessions += r.Sessions
+		totals[i].Minutes += r.Minutes
+		totals[i].Hours = float64(totals[i].Minutes) / 60
+	}
+	sort.Slice(totals, func(a, b int) bool { return totals[a].Name < totals[b].Name })
+	return totals
+}
+
+// GetTeachingHoursReportMongo reports School Class sessions and hours per school, coach and month.
+// Period: from/to or month=YYYY-MM (default current month).
+func GetTeachingHoursReportMongo(c *gin.Context) {
+	filter, ok := teachingHoursFilter(c)
+	if !ok {
+		return
+	}
+
+	rows, err := database.GetTeachingHoursMongo(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	unlinked, err := database.CountUnlinkedSchoolClassMongo(filter.From, filter.To)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": filter.From,
+		"to":   filter.To,
+		"rows": rows,
+		"by_school": totalTeachingHours(rows, func(r database.TeachingHoursRow) (string, string) {
+			return r.SchoolID, r.SchoolName
+		}),
+		"by_coach": totalTeachingHours(rows, func(r database.TeachingHoursRow) (string, string) {
+			return r.UserID, r.UserName
+		}),
+		// School Class entries without a linked school are not counted above
+		"unlinked_sessions": unlinked,
+	})
+}
+
+func ExportTeachingHoursMongo(c *gin.Context) {
+	filter, ok := teachingHoursFilter(c)
+	if !ok {
+		return
+	}
+
+	rows, err := database.GetTeachingHoursMongo(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	w, ok := startExport(c, "jam-mengajar-"+filter.From+"-"+filter.To, export.Document{
+		Title:      "Laporan Jam Mengajar Sekolah",
+		Subtitle:   periodLabel(filter.From, filter.To),
+		Headers:    []string{"Bulan", "Sekolah", "Coach", "Sesi", "Jam"},
+		Signatures: []string{"Dibuat oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+	totalSessions, totalMinutes := 0, 0
+	for _, r := range rows {
+		if err := w.WriteRow([]string{r.Month, r.SchoolName, r.UserName, strconv.Itoa(r.Sessions), strconv.FormatFloat(r.Hours, 'f', 2, 64)}); err != nil {
+			log.Printf("Export teaching hours error: %v", err)
+			return
+		}
+		totalSessions += r.Sessions
+		totalMinutes += r.Minutes
+	}
+	if err := w.WriteRow([]string{"Total", "", "", strconv.Itoa(totalSessions), strconv.FormatFloat(float64(totalMinutes)/60, 'f', 2, 64)}); err != nil {
+		log.Printf("Export teaching hours error: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export teaching hours error: %v", err)
+	}
+}
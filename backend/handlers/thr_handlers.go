@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"kkhris-clone/export"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// thrHolidayFor returns the holiday whose THR schedule pays an employee of the given religion
+func thrHolidayFor(religion string, settings database.THRSettingsMongo) string {
+	r := strings.ToLower(strings.TrimSpace(religion))
+	for _, h := range settings.Holidays {
+		if r != "" && strings.ToLower(h.Religion) == r {
+			return h.Holiday
+		}
+	}
+	// "Kristen Protestan", "Katholik" and similar spellings match on the listed name
+	for _, h := range settings.Holidays {
+		if r != "" && strings.Contains(r, strings.ToLower(h.Religion)) {
+			return h.Holiday
+		}
+	}
+	return settings.DefaultHoliday
+}
+
+// serviceMonths counts the full months worked from hire up to the cutoff
+func serviceMonths(hire, cutoff time.Time) int {
+	months := (cutoff.Year()-hire.Year())*12 + int(cutoff.Month()-hire.Month())
+	if cutoff.Day() < hire.Day() {
+		months--
+	}
+	if months < 0 {
+		return 0
+	}
+	return months
+}
+
+// thrAmount follows Permenaker 6/2016: one month's wage after 12 months of service, months/12 of
+// it from one month, nothing before that
+func thrAmount(wage int64, months int) int64 {
+	switch {
+	case months >= 12:
+		return wage
+	case months >= 1:
+		return wage * int64(months) / 12
+	}
+	return 0
+}
+
+// computeTHREntries builds the review sheet of a schedule: every employee with a salary structure
+// whose religion maps to the schedule's holiday, service counted up to the holiday. Deleted
+// accounts are included so employees who left shortly before the holiday are still assessed.
+func computeTHREntries(schedule *database.THRScheduleMongo, settings database.THRSettingsMongo) ([]database.THREntry, error) {
+	holidayDate, err := time.Parse("2006-01-02", schedule.HolidayDate)
+	if err != nil {
+		return nil, err
+	}
+	structures, err := database.GetEffectiveSalaryStructuresMongo(schedule.HolidayDate)
+	if err != nil {
+		return nil, err
+	}
+	users, err := structureHolders(structures)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []database.THREntry{}
+	for _, user := range users {
+		if !strings.EqualFold(thrHolidayFor(user.Religion, settings), schedule.Holiday) {
+			continue
+		}
+		// Recent leavers stay on the sheet with the reason they get nothing; long-gone ones do not
+		if !database.EmployedDuring(&user, holidayDate.AddDate(-1, 0, 0), holidayDate.AddDate(1, 0, 0)) {
+			continue
+		}
+		structure := structures[user.ID.Hex()]
+		entry := database.THREntry{
+			UserID:      user.ID.Hex(),
+			UserName:    user.Name,
+			Religion:    user.Religion,
+			HireDate:    user.HireDate,
+			StructureID: structure.ID.Hex(),
+			Wage:        structure.BasePay,
+		}
+		if settings.IncludeAllowances {
+			for _, a := range structure.Allowances {
+				entry.Wage += a.Amount
+			}
+		}
+		if strings.TrimSpace(user.Religion) == "" {
+			entry.Note = "Agama belum diisi, dijadwalkan ke " + settings.DefaultHoliday
+		}
+
+		hire, err := time.Parse("2006-01-02", user.HireDate)
+		eligible, reason := database.THREligibility(&user, holidayDate)
+		switch {
+		case !eligible:
+			entry.Note = reason
+		case err != nil:
+			entry.Note = "Tanggal masuk kerja belum diisi atau tidak valid"
+		case hire.After(holidayDate):
+			entry.Note = "Mulai bekerja setelah hari raya"
+		default:
+			entry.ServiceMonths = serviceMonths(hire, holidayDate)
+			entry.Computed = thrAmount(entry.Wage, entry.ServiceMonths)
+			if entry.ServiceMonths < 1 {
+				entry.Note = "Masa kerja kurang dari 1 bulan"
+			}
+		}
+		entry.Amount = entry.Computed
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].UserName < entries[j].UserName })
+	return entries, nil
+}
+
+func thrTotal(entries []database.THREntry) int64 {
+	var total int64
+	for _, e := range entries {
+		total += e.Amount
+	}
+	return total
+}
+
+// thrLines loads the THR lines approved schedules add to each employee's payslip in a period
+func thrLines(period string) (map[string][]database.PayslipLine, error) {
+	schedules, err := database.GetApprovedTHRSchedulesByPeriodMongo(period)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string][]database.PayslipLine)
+	for _, s := range schedules {
+		for _, e := range s.Entries {
+			if e.Amount <= 0 {
+				continue
+			}
+			note := fmt.Sprintf("Masa kerja %d bulan", e.ServiceMonths)
+			if e.Overridden {
+				note = e.Note
+			}
+			lines[e.UserID] = append(lines[e.UserID], database.PayslipLine{
+				Code: "THR", Name: "THR " + s.Holiday, Type: "earning", Amount: e.Amount, Taxable: true,
+				Source: database.PayslipLineSource{Kind: "thr", RefIDs: []string{s.ID.Hex()}, Note: note},
+			})
+		}
+	}
+	return lines, nil
+}
+
+// refreshDraftRun recomputes the period's payroll run so it picks up THR changes; finalized or
+// reviewed runs are never touched because payrollPeriodEditable guards every caller
+func refreshDraftRun(period string) error {
+	run, err := database.GetPayrollRunByPeriodMongo(period)
+	if err != nil || run == nil || run.Status != "draft" {
+		return err
+	}
+	return computeRun(run)
+}
+
+// --- THR Settings ---
+
+func GetTHRSettingsMongo(c *gin.Context) {
+	settings, err := database.GetTHRSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func UpdateTHRSettingsMongo(c *gin.Context) {
+	var input database.THRSettingsMongo
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := make(map[string]bool)
+	for _, h := range input.Holidays {
+		key := strings.ToLower(strings.TrimSpace(h.Religion))
+		if key == "" || strings.TrimSpace(h.Holiday) == "" || seen[key] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each religion needs a holiday and may be listed once"})
+			return
+		}
+		seen[key] = true
+	}
+	if strings.TrimSpace(input.DefaultHoliday) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "default_holiday is required"})
+		return
+	}
+	if input.PayDaysBefore < 7 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "THR wajib dibayar paling lambat 7 hari sebelum hari raya"})
+		return
+	}
+	if input.Holidays == nil {
+		input.Holidays = []database.THRHoliday{}
+	}
+
+	input.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	input.UpdatedBy = c.MustGet("userID").(string)
+
+	if err := database.UpdateTHRSettingsMongo(input); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, input)
+}
+
+// --- THR Schedules ---
+
+func GetTHRSchedulesMongo(c *gin.Context) {
+	year, _ := strconv.Atoi(c.Query("year"))
+	schedules, err := database.GetTHRSchedulesMongo(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+func GetTHRScheduleMongo(c *gin.Context) {
+	schedule, err := database.GetTHRScheduleByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// CreateTHRScheduleMongo schedules a holiday's THR and computes the review sheet. The pay date
+// defaults to the configured number of days before the holiday.
+func CreateTHRScheduleMongo(c *gin.Context) {
+	var input struct {
+		Holiday     string `json:"holiday" binding:"required"`
+		HolidayDate string `json:"holiday_date" binding:"required"`
+		PayDate     string `json:"pay_date"`
+		Notes       string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	holidayDate, err := time.Parse("2006-01-02", input.HolidayDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "holiday_date must be YYYY-MM-DD"})
+		return
+	}
+	settings, err := database.GetTHRSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	payDate := holidayDate.AddDate(0, 0, -settings.PayDaysBefore)
+	if input.PayDate != "" {
+		if payDate, err = time.Parse("2006-01-02", input.PayDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pay_date must be YYYY-MM-DD"})
+			return
+		}
+	}
+	if holidayDate.Sub(payDate) < 7*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "THR wajib dibayar paling lambat 7 hari sebelum hari raya"})
+		return
+	}
+
+	holiday := strings.TrimSpace(input.Holiday)
+	existing, err := database.GetTHRScheduleMongo(holidayDate.Year(), holiday)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "THR " + holiday + " " + strconv.Itoa(holidayDate.Year()) + " sudah dijadwalkan"})
+		return
+	}
+	period := payDate.Format("2006-01")
+	if !payrollPeriodEditable(c, period) {
+		return
+	}
+
+	now := time.Now().Format("2006-01-02 15:04:05")
+	schedule := database.THRScheduleMongo{
+		Year:        holidayDate.Year(),
+		Holiday:     holiday,
+		HolidayDate: input.HolidayDate,
+		PayDate:     payDate.Format("2006-01-02"),
+		Period:      period,
+		Status:      "draft",
+		Notes:       input.Notes,
+		CreatedBy:   c.MustGet("userID").(string),
+		CreatedAt:   now,
+		ComputedAt:  now,
+	}
+	if schedule.Entries, err = computeTHREntries(&schedule, *settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	schedule.Total = thrTotal(schedule.Entries)
+
+	created, err := database.CreateTHRScheduleMongo(schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// RecalculateTHRScheduleMongo recomputes a draft from current profiles and salary structures,
+// keeping manual overrides
+func RecalculateTHRScheduleMongo(c *gin.Context) {
+	id := c.Param("id")
+	schedule, err := database.GetTHRScheduleByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+	if schedule.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft THR schedules can be recalculated"})
+		return
+	}
+	settings, err := database.GetTHRSettingsMongo()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := computeTHREntries(schedule, *settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	overrides := make(map[string]database.THREntry)
+	for _, e := range schedule.Entries {
+		if e.Overridden {
+			overrides[e.UserID] = e
+		}
+	}
+	for i, e := range entries {
+		if o, ok := overrides[e.UserID]; ok {
+			entries[i].Amount, entries[i].Overridden, entries[i].Note = o.Amount, true, o.Note
+		}
+	}
+
+	updated, err := database.UpdateTHRScheduleMongo(id, "draft", bson.M{
+		"entries":     entries,
+		"total":       thrTotal(entries),
+		"computed_at": time.Now().Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "THR schedule was changed by someone else, reload and try again"})
+		return
+	}
+	GetTHRScheduleMongo(c)
+}
+
+// OverrideTHREntryMongo sets an employee's amount on a draft by hand; a reason is required
+func OverrideTHREntryMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Amount int64  `json:"amount"`
+		Note   string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Amount < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount cannot be negative"})
+		return
+	}
+	schedule, err := database.GetTHRScheduleByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+	if schedule.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft THR schedules can be edited"})
+		return
+	}
+	found := false
+	for i, e := range schedule.Entries {
+		if e.UserID == c.Param("userId") {
+			schedule.Entries[i].Amount = input.Amount
+			schedule.Entries[i].Overridden = true
+			schedule.Entries[i].Note = input.Note
+			found = true
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee is not on this THR schedule"})
+		return
+	}
+
+	updated, err := database.UpdateTHRScheduleMongo(id, "draft", bson.M{
+		"entries": schedule.Entries,
+		"total":   thrTotal(schedule.Entries),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "THR schedule was changed by someone else, reload and try again"})
+		return
+	}
+	GetTHRScheduleMongo(c)
+}
+
+// UpdateTHRScheduleStatusMongo approves a draft, which pays it through the payroll run of its
+// period, or returns it to draft while that run is still a draft
+func UpdateTHRScheduleStatusMongo(c *gin.Context) {
+	id := c.Param("id")
+	var input struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	schedule, err := database.GetTHRScheduleByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+
+	set := bson.M{"status": input.Status}
+	switch {
+	case input.Status == "approved" && schedule.Status == "draft":
+		set["approved_by"] = c.MustGet("userID").(string)
+		set["approved_at"] = time.Now().Format("2006-01-02 15:04:05")
+	case input.Status == "draft" && schedule.Status == "approved":
+		set["approved_by"] = ""
+		set["approved_at"] = ""
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change THR schedule status from " + schedule.Status + " to " + input.Status})
+		return
+	}
+	if !payrollPeriodEditable(c, schedule.Period) {
+		return
+	}
+
+	updated, err := database.UpdateTHRScheduleMongo(id, schedule.Status, set)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "THR schedule was changed by someone else, reload and try again"})
+		return
+	}
+	if err := refreshDraftRun(schedule.Period); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Status updated but payroll " + schedule.Period + " could not be recalculated: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "THR schedule marked as " + input.Status})
+}
+
+func DeleteTHRScheduleMongo(c *gin.Context) {
+	id := c.Param("id")
+	schedule, err := database.GetTHRScheduleByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+	if schedule.Status != "draft" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only draft THR schedules can be deleted"})
+		return
+	}
+	if err := database.DeleteTHRScheduleMongo(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "THR schedule deleted"})
+}
+
+// ExportTHRScheduleMongo writes the review sheet of a schedule
+func ExportTHRScheduleMongo(c *gin.Context) {
+	schedule, err := database.GetTHRScheduleByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "THR schedule not found"})
+		return
+	}
+
+	w, ok := startExport(c, "thr-"+filenameSlug(schedule.Holiday)+"-"+strconv.Itoa(schedule.Year), export.Document{
+		Title:      "Tunjangan Hari Raya " + schedule.Holiday + " " + strconv.Itoa(schedule.Year),
+		Subtitle:   "Hari raya " + schedule.HolidayDate + ", dibayar " + schedule.PayDate + " (payroll " + schedule.Period + ")",
+		Headers:    []string{"Nama", "Agama", "Tanggal Masuk", "Masa Kerja (bulan)", "Upah", "Perhitungan", "THR", "Keterangan"},
+		Signatures: []string{"Dibuat oleh", "Disetujui oleh"},
+	})
+	if !ok {
+		return
+	}
+	for _, e := range schedule.Entries {
+		if err := w.WriteRow([]string{e.UserName, e.Religion, e.HireDate, strconv.Itoa(e.ServiceMonths), export.Rupiah(e.Wage), export.Rupiah(e.Computed), export.Rupiah(e.Amount), e.Note}); err != nil {
+			log.Printf("Export THR schedule error: %v", err)
+			return
+		}
+	}
+	if err := w.WriteRow([]string{"Total", "", "", "", "", "", export.Rupiah(schedule.Total), ""}); err != nil {
+		log.Printf("Export THR schedule error: %v", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export THR schedule error: %v", err)
+	}
+}
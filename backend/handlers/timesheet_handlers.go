@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"fmt"
+	"kkhris-clone/database"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// leaveStatus maps a work permit leave type to the attendance status used on approval
+func leaveStatus(leaveType string) string {
+	if leaveType == "Sakit" || leaveType == "Sick" {
+		return "sakit"
+	}
+	return "ijin"
+}
+
+// buildTimesheet assembles a user's month from attendance, approved work permits and holidays
+func buildTimesheet(userID, period string) (*database.TimesheetMongo, error) {
+	first, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	last := first.AddDate(0, 1, -1)
+	from, to := first.Format("2006-01-02"), last.Format("2006-01-02")
+
+	records, err := database.GetAttendanceByUserAndPeriod(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	permits, err := database.GetApprovedWorkPermitsByUserAndPeriod(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	holidays, err := database.GetHolidayDatesMongo(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := make(map[string][]database.AttendanceMongo)
+	for _, r := range records {
+		byDate[r.Date] = append(byDate[r.Date], r)
+	}
+	permitByDate := make(map[string]database.WorkPermitMongo)
+	for _, wp := range permits {
+		permitByDate[wp.Date] = wp
+	}
+
+	ts := &database.TimesheetMongo{
+		UserID: userID,
+		Period: period,
+		Status: "draft",
+		Days:   []database.TimesheetDay{},
+	}
+	if user, err := database.GetUserByIDMongo(userID); err == nil {
+		ts.UserName = user.Name
+	}
+
+	today := time.Now().Format("2006-01-02")
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		day := database.TimesheetDay{
+			Date:       date,
+			DayType:    overtimeDayType(date, holidays),
+			Holiday:    holidays[date],
+			Activities: []string{},
+		}
+
+		present := false
+		for _, r := range byDate[date] {
+			if r.Status != "present" {
+				continue
+			}
+			present = true
+			day.Sessions++
+			day.Activities = append(day.Activities, r.ActivityType)
+			if hasCategory(r.ActivityCategories, schoolClassCategory) {
+				day.SchoolClasses++
+			}
+		}
+		day.Minutes = attendanceMinutes(byDate[date])[date]
+
+		wp, onLeave := permitByDate[date]
+		switch {
+		case onLeave && wp.Session != "Half Day":
+			day.Status = leaveStatus(wp.LeaveType)
+			day.LeaveType = wp.LeaveType
+		case present:
+			day.Status = "present"
+			if onLeave {
+				day.LeaveType = wp.LeaveType
+			}
+		case day.DayType == "holiday":
+			day.Status = "holiday"
+		case day.DayType == "weekend":
+			day.Status = "weekend"
+		case date > today:
+			day.Status = "upcoming"
+		default:
+			day.Status = "absent"
+		}
+
+		switch day.Status {
+		case "present":
+			ts.Totals.PresentDays++
+		case "ijin":
+			ts.Totals.LeaveDays++
+		case "sakit":
+			ts.Totals.SickDays++
+		case "holiday":
+			ts.Totals.HolidayDays++
+		case "absent":
+			ts.Totals.AbsentDays++
+		}
+		ts.Totals.Minutes += day.Minutes
+		ts.Totals.Sessions += day.Sessions
+		ts.Totals.SchoolClasses += day.SchoolClasses
+		ts.Days = append(ts.Days, day)
+	}
+
+	return ts, nil
+}
+
+// currentTimesheet returns the stored snapshot once submitted, otherwise a live draft
+func currentTimesheet(userID, period string) (*database.TimesheetMongo, error) {
+	stored, err := database.GetTimesheetMongo(userID, period)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil && stored.Status != "draft" && stored.Status != "rejected" {
+		return stored, nil
+	}
+
+	ts, err := buildTimesheet(userID, period)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		ts.ID = stored.ID
+		ts.Status = stored.Status
+		ts.RejectReason = stored.RejectReason
+	}
+	return ts, nil
+}
+
+// --- Timesheet Handlers ---
+
+func GetTimesheetMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	period := c.Param("period")
+
+	if _, err := time.Parse("2006-01", period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be YYYY-MM"})
+		return
+	}
+
+	ts, err := currentTimesheet(userID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ts)
+}
+
+func SubmitTimesheetMongo(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	period := c.Param("period")
+
+	if _, err := time.Parse("2006-01", period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be YYYY-MM"})
+		return
+	}
+	if period > time.Now().Format("2006-01") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Timesheet bulan yang akan datang belum dapat diajukan"})
+		return
+	}
+
+	stored, err := database.GetTimesheetMongo(userID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stored != nil && (stored.Status == "submitted" || stored.Status == "approved") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Timesheet has already been " + stored.Status})
+		return
+	}
+
+	ts, err := buildTimesheet(userID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ts.Status = "submitted"
+	ts.SubmittedAt = time.Now().Format("2006-01-02 15:04:05")
+
+	saved, err := database.SaveTimesheetMongo(*ts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Managers sign off through the shared approval pipeline
+	req := database.PendingRequestMongo{
+		Type:      "timesheet",
+		UserID:    userID,
+		UserName:  saved.UserName,
+		Date:      period,
+		Reason:    "Timesheet " + period,
+		Details:   fmt.Sprintf("%d hari hadir - %.1f jam", saved.Totals.PresentDays, float64(saved.Totals.Minutes)/60),
+		Status:    "pending",
+		CreatedAt: time.Now().Format("2006-01-02"),
+		RefID:     saved.ID.Hex(),
+	}
+	database.AddPendingRequestMongo(req)
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// GetTimesheetsAdminMongo lists submitted timesheets for review
+func GetTimesheetsAdminMongo(c *gin.Context) {
+	timesheets, err := database.GetTimesheetsMongo(c.Query("period"), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if timesheets == nil {
+		timesheets = []database.TimesheetMongo{}
+	}
+	c.JSON(http.StatusOK, timesheets)
+}
+
+func GetTimesheetByIDAdminMongo(c *gin.Context) {
+	ts, err := database.GetTimesheetByIDMongo(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+	c.JSON(http.StatusOK, ts)
+}
+
+// ReopenTimesheetMongo unlocks a submitted or signed-off timesheet so attendance can be corrected
+func ReopenTimesheetMongo(c *gin.Context) {
+	id := c.Param("id")
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+
+	ts, err := database.GetTimesheetByIDMongo(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Timesheet not found"})
+		return
+	}
+
+	updated, err := database.UpdateTimesheetStatusMongo(id, []string{"submitted", "approved"}, bson.M{
+		"status":        "rejected",
+		"reject_reason": input.Reason,
+		"signed_by":     "",
+		"signed_at":     "",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only submitted or signed-off timesheets can be reopened"})
+		return
+	}
+	if ts.Status == "submitted" {
+		database.DeletePendingRequestByRefID(id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Timesheet reopened"})
+}
+
+// signOffTimesheet locks a submitted timesheet; called when its pending request is approved
+func signOffTimesheet(id, signedBy string) error {
+	updated, err := database.UpdateTimesheetStatusMongo(id, []string{"submitted"}, bson.M{
+		"status":        "approved",
+		"signed_by":     signedBy,
+		"signed_at":     time.Now().Format("2006-01-02 15:04:05"),
+		"reject_reason": "",
+	})
+	if err == nil && !updated {
+		err = fmt.Errorf("timesheet is not submitted")
+	}
+	return err
+}
+
+func rejectTimesheet(id, reason string) error {
+	updated, err := database.UpdateTimesheetStatusMongo(id, []string{"submitted"}, bson.M{
+		"status":        "rejected",
+		"reject_reason": reason,
+	})
+	if err == nil && !updated {
+		err = fmt.Errorf("timesheet is not submitted")
+	}
+	return err
+}
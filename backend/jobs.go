@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"kkhris-clone/database"
+	"kkhris-clone/handlers"
+	"kkhris-clone/scheduler"
+)
+
+// newScheduler registers the background jobs. Schedules are in server local time and can be
+// changed or disabled from /api/admin/jobs.
+func newScheduler() *scheduler.Scheduler {
+	s := scheduler.New()
+	s.Register(scheduler.Job{
+		Name:        "retention",
+		Description: "Remove data older than the retention policies allow",
+		Schedule:    "0 2 * * *",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			return database.ApplyRetentionPolicies(ctx, time.Now())
+		},
+	})
+	s.Register(scheduler.Job{
+		Name:        "orphan_cleanup",
+		Description: "Archive records that belong to users who no longer exist",
+		Schedule:    "30 2 * * *",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			result, err := database.CleanupOrphanedData(ctx, false)
+			if result == nil {
+				return nil, err
+			}
+			counts := make(map[string]int64)
+			for _, r := range result.Collections {
+				counts[r.Collection] = r.Archived
+				if r.MissingUserID > 0 {
+					log.Printf("Orphan cleanup: %d %s records have no user_id", r.MissingUserID, r.Collection)
+					counts[r.Collection+"_missing_user_id"] = r.MissingUserID
+				}
+			}
+			return counts, err
+		},
+	})
+	s.Register(scheduler.Job{
+		Name:        "employment_alerts",
+		Description: "Raise alerts for contracts ending within 30, 14 or 7 days and probation reviews due",
+		Schedule:    "0 7 * * *",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			return database.GenerateEmploymentAlertsMongo(time.Now())
+		},
+	})
+	s.Register(scheduler.Job{
+		Name:        "schedule_reminders",
+		Description: "Remind coaches of classes starting within the hour and classes still missing attendance",
+		Schedule:    "*/15 * * * *",
+		Run: func(ctx context.Context) (map[string]int64, error) {
+			return handlers.GenerateScheduleReminders(time.Now())
+		},
+	})
+	return s
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"kkhris-clone/database"
 	"kkhris-clone/handlers"
@@ -24,27 +26,74 @@ func main() {
 	if err := database.ConnectMongoDB(); err != nil {
 		log.Fatal("Failed to connect to MongoDB:", err)
 	}
+	if err := database.InitFileStorage(); err != nil {
+		log.Fatal("Failed to open file storage:", err)
+	}
 
 	// Seed initial data if empty
 	seed.SeedMongoDB()
 
-	// Run cleanup tasks on startup
-	if deleted, err := database.CleanupExpiredLeaveAttendance(); err != nil {
-		log.Printf("Cleanup expired leaves error: %v", err)
-	} else if deleted > 0 {
-		log.Printf("Cleaned up %d expired leave attendance records", deleted)
+	if err := database.EnsureAttendanceIndexes(); err != nil {
+		log.Printf("Ensure attendance indexes error: %v", err)
+	}
+	if err := database.EnsureActivityTaxonomy(time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("Ensure activity taxonomy error: %v", err)
+	} else if _, updated, err := database.RunMigrationOnce("backfill_activity_codes", database.BackfillActivityCodes); err != nil {
+		log.Printf("Backfill activity codes error: %v", err)
+	} else if updated > 0 {
+		log.Printf("Backfilled activity codes on %d attendance records", updated)
+	}
+	if err := database.EnsureTaxRules(time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("Ensure tax rules error: %v", err)
+	}
+	if err := database.EnsureBankTransferTemplates(time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("Ensure bank transfer templates error: %v", err)
 	}
-	if cleared, err := database.CleanupOldSupportFiles(); err != nil {
-		log.Printf("Cleanup old support files error: %v", err)
-	} else if cleared > 0 {
-		log.Printf("Cleared supporting files from %d old work permits", cleared)
+	if err := database.EnsureFileIndexes(); err != nil {
+		log.Printf("Ensure file indexes error: %v", err)
+	}
+	// Before the inline file migration, so merged photos are moved to storage too
+	if merged, created, err := database.MigrateLegacyEmployees(); err != nil {
+		log.Printf("Migrate legacy employees error: %v", err)
+	} else if merged+created > 0 {
+		log.Printf("Migrated legacy employees: %d merged into users, %d created as users", merged, created)
+	}
+	if migrated, err := database.MigrateInlineFiles(time.Now().Format("2006-01-02 15:04:05")); err != nil {
+		log.Printf("Migrate inline files error: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Moved inline files of %d documents to file storage", migrated)
+	}
+	if _, linked, err := database.RunMigrationOnce("backfill_attendance_school_ids", database.BackfillAttendanceSchoolIDs); err != nil {
+		log.Printf("Backfill attendance school IDs error: %v", err)
+	} else if linked > 0 {
+		log.Printf("Linked %d school class attendance records to schools", linked)
 	}
 
-	if deleted, err := database.CleanupOrphanedData(); err != nil {
-		log.Printf("Cleanup orphaned data error: %v", err)
-	} else if deleted > 0 {
-		log.Printf("Cleaned up %d orphaned records", deleted)
+	// Cleanup and retention run as scheduled jobs on one replica
+	if err := database.EnsureJobRunIndexes(); err != nil {
+		log.Printf("Ensure job run indexes error: %v", err)
+	}
+	if err := database.EnsureArchiveIndexes(); err != nil {
+		log.Printf("Ensure archive indexes error: %v", err)
 	}
+	if err := database.EnsureOrgIndexes(); err != nil {
+		log.Printf("Ensure org indexes error: %v", err)
+	}
+	if err := database.EnsureEmploymentIndexes(); err != nil {
+		log.Printf("Ensure employment indexes error: %v", err)
+	}
+	if err := database.EnsureScheduleReminderIndexes(); err != nil {
+		log.Printf("Ensure schedule reminder indexes error: %v", err)
+	}
+	if err := database.EnsureInvoiceIndexes(); err != nil {
+		log.Printf("Ensure invoice indexes error: %v", err)
+	}
+	if err := database.EnsurePayrollRunIndexes(); err != nil {
+		log.Printf("Ensure payroll run indexes error: %v", err)
+	}
+	jobs := newScheduler()
+	jobs.Start(context.Background())
+	handlers.SetScheduler(jobs)
 
 	// Setup Gin
 	r := gin.Default()
@@ -61,7 +110,7 @@ func main() {
 		config.AllowOrigins = []string{"http://localhost:3000"}
 	}
 
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-Kiosk-Key"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	r.Use(cors.New(config))
 
@@ -79,6 +128,15 @@ func main() {
 		api.POST("/auth/login", handlers.LoginMongo)
 		api.POST("/auth/logout", handlers.Logout)
 		api.POST("/auth/change-password", handlers.ChangePassword)
+		// Signed, time-limited file links (see GET /api/files/:id/url)
+		api.GET("/files/:id/content", handlers.ServeFileMongo)
+	}
+
+	// Kiosk displays sign in with a per-branch device key instead of a user session
+	kiosk := r.Group("/api/kiosk")
+	kiosk.Use(handlers.KioskDeviceMiddleware())
+	{
+		kiosk.GET("/token", handlers.GetKioskTokenMongo)
 	}
 
 	// Protected routes (auth required)
@@ -93,6 +151,16 @@ func main() {
 		protected.GET("/attendance", handlers.GetAttendanceMongo)
 		protected.GET("/attendance/calendar", handlers.GetAttendanceCalendarMongo)
 		protected.POST("/attendance", handlers.AddAttendanceMongo)
+		protected.POST("/attendance/kiosk-checkin", handlers.KioskCheckInMongo)
+		protected.POST("/attendance/kiosk-checkout", handlers.KioskCheckOutMongo)
+		protected.GET("/activity-taxonomy", handlers.GetActivityTaxonomyMongo)
+		protected.GET("/schools", handlers.GetSchoolsMongo)
+
+		// Class schedule
+		protected.GET("/schedule", handlers.GetMyScheduleMongo)
+		protected.GET("/schedule/prefill", handlers.GetSchedulePrefillMongo)
+		protected.GET("/schedule/reminders", handlers.GetScheduleRemindersMongo)
+		protected.PUT("/schedule/reminders/:id/dismiss", handlers.DismissScheduleReminderMongo)
 
 		// Employees
 		protected.GET("/employees", handlers.GetEmployeesMongo)
@@ -108,6 +176,10 @@ func main() {
 		protected.GET("/branches", handlers.GetBranchesMongo)
 		protected.GET("/awards", handlers.GetAwardsMongo)
 
+		// Uploaded files (photos, supporting documents, receipts)
+		protected.POST("/files", handlers.UploadFileMongo)
+		protected.GET("/files/:id/url", handlers.GetFileURLMongo)
+
 		// Work Permits
 		protected.GET("/work-permits", handlers.GetWorkPermitsMongo)
 		protected.POST("/work-permits", handlers.AddWorkPermitMongo)
@@ -115,14 +187,48 @@ func main() {
 
 		// Leave quota and requests
 		protected.GET("/leave-quota", handlers.GetLeaveQuotaMongo)
+		protected.GET("/employment", handlers.GetMyEmploymentMongo)
 		protected.GET("/notifications", handlers.GetUserNotificationsMongo)
 		protected.POST("/requests", handlers.AddPendingRequestMongo)
 
+		// Org chart and approvals routed to the caller as manager or acting manager
+		protected.GET("/org-chart", handlers.GetOrgChartMongo)
+		protected.GET("/approvals", handlers.GetPendingRequestsMongo)
+		protected.PUT("/approvals/:id/approve", handlers.ApproveRequestMongo)
+		protected.PUT("/approvals/:id/reject", handlers.RejectRequestMongo)
+		protected.GET("/delegations", handlers.GetDelegationsMongo)
+		protected.POST("/delegations", handlers.CreateDelegationMongo)
+		protected.DELETE("/delegations/:id", handlers.CancelDelegationMongo)
+
+		// Overtime
+		protected.GET("/overtime", handlers.GetOvertimeRequestsMongo)
+		protected.GET("/overtime/computed", handlers.GetComputedOvertimeMongo)
+		protected.POST("/overtime", handlers.AddOvertimeRequestMongo)
+		protected.DELETE("/overtime/:id", handlers.DeleteOvertimeRequestMongo)
+
+		// Expense reimbursement claims (approval goes through /admin/requests)
+		protected.GET("/claims", handlers.GetMyClaimsMongo)
+		protected.GET("/claims/categories", handlers.GetClaimCategoriesMongo)
+		protected.POST("/claims", handlers.AddClaimMongo)
+		protected.DELETE("/claims/:id", handlers.DeleteClaimMongo)
+		protected.GET("/claims/:id/receipts/:index", handlers.GetMyClaimReceiptMongo)
+
+		// Payslips of finalized payroll runs
+		protected.GET("/payslips", handlers.GetMyPayslipsMongo)
+		protected.GET("/payslips/:id", handlers.GetMyPayslipMongo)
+		protected.GET("/payslips/:id/pdf", handlers.DownloadMyPayslipMongo)
+
+		// Timesheets
+		protected.GET("/timesheets/:period", handlers.GetTimesheetMongo)
+		protected.POST("/timesheets/:period/submit", handlers.SubmitTimesheetMongo)
+		protected.GET("/timesheets/:period/export", handlers.ExportTimesheetMongo)
+
 		// User profile
 		protected.GET("/profile", handlers.GetUserProfileMongo)
 
-		// Leave list - accessible to all users to see who's on leave
+		// Recap - own records plus everyone's leave for non-admins
 		protected.GET("/attendance-recap", handlers.GetAttendanceRecapMongo)
+		protected.GET("/attendance-recap/export", handlers.ExportAttendanceRecapMongo)
 	}
 
 	// Admin routes (admin only)
@@ -135,6 +241,13 @@ func main() {
 		admin.POST("/users", handlers.CreateUserMongo)
 		admin.PUT("/users/:id", handlers.UpdateUserMongo)
 		admin.DELETE("/users/:id", handlers.DeleteUserMongo)
+		admin.PUT("/users/:id/deactivate", handlers.DeactivateUserMongo)
+		admin.POST("/users/:id/restore", handlers.RestoreUserMongo)
+		admin.PUT("/users/:id/reports-to", handlers.SetReportsToMongo)
+		// Employment lifecycle
+		admin.GET("/users/:id/employment", handlers.GetEmploymentMongo)
+		admin.GET("/employment/alerts", handlers.GetEmploymentAlertsMongo)
+		admin.PUT("/employment/alerts/:id/ack", handlers.AcknowledgeEmploymentAlertMongo)
 		admin.GET("/requests", handlers.GetPendingRequestsMongo)
 		admin.PUT("/requests/:id/approve", handlers.ApproveRequestMongo)
 		admin.PUT("/requests/:id/reject", handlers.RejectRequestMongo)
@@ -143,6 +256,8 @@ func main() {
 		admin.POST("/branches", handlers.CreateBranchMongo)
 		admin.PUT("/branches/:id", handlers.UpdateBranchMongo)
 		admin.DELETE("/branches/:id", handlers.DeleteBranchMongo)
+		admin.POST("/branches/:id/kiosk-secret", handlers.RotateKioskSecretMongo)
+		admin.POST("/branches/:id/kiosk-device", handlers.IssueKioskDeviceKeyMongo)
 		// Employees
 		admin.POST("/employees", handlers.CreateEmployeeMongo)
 		admin.DELETE("/employees/:id", handlers.DeleteEmployeeMongo)
@@ -154,6 +269,29 @@ func main() {
 		admin.DELETE("/calendar-events/:id", handlers.DeleteCalendarEventMongo)
 		// Attendance Recap
 		admin.GET("/attendance-recap", handlers.GetAttendanceRecapMongo)
+		admin.GET("/attendance-recap/export", handlers.ExportAttendanceRecapMongo)
+		// Bulk attendance import
+		admin.POST("/attendance-imports", handlers.ImportAttendanceMongo)
+		admin.GET("/attendance-imports", handlers.GetAttendanceImportsMongo)
+		admin.POST("/attendance-imports/:id/rollback", handlers.RollbackAttendanceImportMongo)
+		// Leave report
+		admin.GET("/leave-report", handlers.GetLeaveReportMongo)
+		admin.GET("/leave-report/export", handlers.ExportLeaveReportMongo)
+		// Teaching hours per school and coach
+		admin.GET("/teaching-hours", handlers.GetTeachingHoursReportMongo)
+		admin.GET("/teaching-hours/export", handlers.ExportTeachingHoursMongo)
+		// Attendance Rules
+		admin.GET("/attendance-rules", handlers.GetAttendanceRulesMongo)
+		admin.PUT("/attendance-rules", handlers.UpdateAttendanceRulesMongo)
+		// Overtime
+		admin.GET("/overtime/summary", handlers.GetOvertimeSummaryMongo)
+		admin.GET("/overtime-settings", handlers.GetOvertimeSettingsMongo)
+		admin.PUT("/overtime-settings", handlers.UpdateOvertimeSettingsMongo)
+		// Timesheets (sign-off goes through /requests/:id/approve)
+		admin.GET("/timesheets", handlers.GetTimesheetsAdminMongo)
+		admin.GET("/timesheets/:id", handlers.GetTimesheetByIDAdminMongo)
+		admin.PUT("/timesheets/:id/reopen", handlers.ReopenTimesheetMongo)
+		admin.GET("/timesheets/:id/export", handlers.ExportTimesheetAdminMongo)
 		// Logs
 		admin.GET("/logs", handlers.GetAdminLogsMongo)
 		// Awards
@@ -164,6 +302,111 @@ func main() {
 		admin.POST("/schools", handlers.CreateSchoolMongo)
 		admin.PUT("/schools/:id", handlers.UpdateSchoolMongo)
 		admin.DELETE("/schools/:id", handlers.DeleteSchoolMongo)
+		admin.GET("/schools/:id/schedules", handlers.GetSchoolSchedulesMongo)
+		admin.POST("/schools/:id/schedules", handlers.CreateSchoolScheduleMongo)
+		admin.GET("/schools/:id/roster", handlers.GetSchoolRosterMongo)
+		admin.PUT("/school-schedules/:id", handlers.UpdateSchoolScheduleMongo)
+		admin.DELETE("/school-schedules/:id", handlers.DeleteSchoolScheduleMongo)
+
+		// School contracts & invoicing
+		admin.GET("/school-contracts", handlers.GetSchoolContractsMongo)
+		admin.POST("/school-contracts", handlers.CreateSchoolContractMongo)
+		admin.GET("/school-contracts/delivery", handlers.GetContractDeliveryMongo)
+		admin.PUT("/school-contracts/:id", handlers.UpdateSchoolContractMongo)
+		admin.DELETE("/school-contracts/:id", handlers.DeleteSchoolContractMongo)
+		admin.GET("/invoices", handlers.GetInvoicesMongo)
+		admin.POST("/invoices/generate", handlers.GenerateInvoicesMongo)
+		admin.GET("/invoices/:id", handlers.GetInvoiceMongo)
+		admin.GET("/invoices/:id/pdf", handlers.GetInvoicePDFMongo)
+		admin.PUT("/invoices/:id/status", handlers.UpdateInvoiceStatusMongo)
+		admin.DELETE("/invoices/:id", handlers.DeleteInvoiceMongo)
+
+		// Activity taxonomy
+		admin.GET("/activity-types", handlers.GetActivityTypesMongo)
+		admin.POST("/activity-types", handlers.CreateActivityTypeMongo)
+		admin.PUT("/activity-types/:id", handlers.UpdateActivityTypeMongo)
+		admin.DELETE("/activity-types/:id", handlers.DeleteActivityTypeMongo)
+		admin.GET("/activity-categories", handlers.GetActivityCategoriesMongo)
+		admin.POST("/activity-categories", handlers.CreateActivityCategoryMongo)
+		admin.PUT("/activity-categories/:id", handlers.UpdateActivityCategoryMongo)
+		admin.DELETE("/activity-categories/:id", handlers.DeleteActivityCategoryMongo)
+
+		admin.GET("/claim-settings", handlers.GetClaimSettingsMongo)
+		admin.PUT("/claim-settings", handlers.UpdateClaimSettingsMongo)
+		admin.GET("/claims", handlers.GetClaimsMongo)
+		admin.GET("/claims/payouts/export", handlers.ExportClaimPayoutsMongo)
+		admin.POST("/claims/payouts/paid", handlers.MarkClaimsPaidMongo)
+		admin.GET("/claims/:id/receipts/:index", handlers.GetClaimReceiptMongo)
+	}
+
+	// Admin-only routes: payroll, compensation data and data retention are not open to managers
+	adminOnly := r.Group("/api/admin")
+	adminOnly.Use(handlers.AuthMiddlewareMongo())
+	adminOnly.Use(handlers.AdminOnlyMiddlewareMongo())
+	{
+		// Payroll
+		adminOnly.GET("/payroll-settings", handlers.GetPayrollSettingsMongo)
+		adminOnly.PUT("/payroll-settings", handlers.UpdatePayrollSettingsMongo)
+		adminOnly.GET("/salary-structures", handlers.GetSalaryStructuresMongo)
+		adminOnly.POST("/salary-structures", handlers.CreateSalaryStructureMongo)
+		adminOnly.DELETE("/salary-structures/:id", handlers.DeleteSalaryStructureMongo)
+		adminOnly.GET("/payroll-adjustments", handlers.GetPayrollAdjustmentsMongo)
+		adminOnly.POST("/payroll-adjustments", handlers.CreatePayrollAdjustmentMongo)
+		adminOnly.DELETE("/payroll-adjustments/:id", handlers.DeletePayrollAdjustmentMongo)
+		adminOnly.GET("/payroll-runs", handlers.GetPayrollRunsMongo)
+		adminOnly.POST("/payroll-runs", handlers.CreatePayrollRunMongo)
+		adminOnly.GET("/payroll-runs/:id", handlers.GetPayrollRunMongo)
+		adminOnly.POST("/payroll-runs/:id/recalculate", handlers.RecalculatePayrollRunMongo)
+		adminOnly.PUT("/payroll-runs/:id/status", handlers.UpdatePayrollRunStatusMongo)
+		adminOnly.DELETE("/payroll-runs/:id", handlers.DeletePayrollRunMongo)
+		adminOnly.GET("/payroll-runs/:id/payslips/zip", handlers.DownloadPayrollRunZipMongo)
+		adminOnly.GET("/payroll-runs/:id/bank-transfer/validate", handlers.ValidateBankTransferMongo)
+		adminOnly.GET("/payroll-runs/:id/bank-transfer", handlers.ExportBankTransferMongo)
+		adminOnly.GET("/thr-settings", handlers.GetTHRSettingsMongo)
+		adminOnly.PUT("/thr-settings", handlers.UpdateTHRSettingsMongo)
+		adminOnly.GET("/thr-schedules", handlers.GetTHRSchedulesMongo)
+		adminOnly.POST("/thr-schedules", handlers.CreateTHRScheduleMongo)
+		adminOnly.GET("/thr-schedules/:id", handlers.GetTHRScheduleMongo)
+		adminOnly.GET("/thr-schedules/:id/export", handlers.ExportTHRScheduleMongo)
+		adminOnly.POST("/thr-schedules/:id/recalculate", handlers.RecalculateTHRScheduleMongo)
+		adminOnly.PUT("/thr-schedules/:id/entries/:userId", handlers.OverrideTHREntryMongo)
+		adminOnly.PUT("/thr-schedules/:id/status", handlers.UpdateTHRScheduleStatusMongo)
+		adminOnly.DELETE("/thr-schedules/:id", handlers.DeleteTHRScheduleMongo)
+		adminOnly.GET("/bank-settings", handlers.GetBankSettingsMongo)
+		adminOnly.PUT("/bank-settings", handlers.UpdateBankSettingsMongo)
+		adminOnly.GET("/bank-transfer-templates", handlers.GetBankTransferTemplatesMongo)
+		adminOnly.POST("/bank-transfer-templates", handlers.CreateBankTransferTemplateMongo)
+		adminOnly.PUT("/bank-transfer-templates/:id", handlers.UpdateBankTransferTemplateMongo)
+		adminOnly.DELETE("/bank-transfer-templates/:id", handlers.DeleteBankTransferTemplateMongo)
+		adminOnly.GET("/payslips/:id", handlers.GetPayslipMongo)
+		adminOnly.GET("/payslips/:id/pdf", handlers.DownloadPayslipMongo)
+		adminOnly.GET("/payslips/:id/audit", handlers.GetPayslipAuditMongo)
+		adminOnly.POST("/payslips/:id/reissue", handlers.ReissuePayslipMongo)
+		adminOnly.GET("/bpjs-settings", handlers.GetBPJSSettingsMongo)
+		adminOnly.PUT("/bpjs-settings", handlers.UpdateBPJSSettingsMongo)
+		adminOnly.GET("/bpjs-report", handlers.GetBPJSReportMongo)
+		adminOnly.GET("/bpjs-report/export", handlers.ExportBPJSReportMongo)
+		adminOnly.GET("/tax-rules", handlers.GetTaxRulesMongo)
+		adminOnly.GET("/tax-rules/:year", handlers.GetTaxRuleMongo)
+		adminOnly.PUT("/tax-rules/:year", handlers.SaveTaxRuleMongo)
+		adminOnly.DELETE("/tax-rules/:year", handlers.DeleteTaxRuleMongo)
+
+		// Purging a user cannot be undone
+		adminOnly.DELETE("/users/:id/purge", handlers.PurgeUserMongo)
+
+		// Employment terms drive payroll and THR eligibility
+		adminOnly.PUT("/users/:id/employment", handlers.UpdateEmploymentMongo)
+
+		// Scheduled jobs and data retention
+		adminOnly.GET("/jobs", handlers.GetJobsMongo)
+		adminOnly.PUT("/jobs/:name", handlers.UpdateJobMongo)
+		adminOnly.POST("/jobs/:name/run", handlers.RunJobMongo)
+		adminOnly.GET("/job-runs", handlers.GetJobRunsMongo)
+		adminOnly.GET("/retention-settings", handlers.GetRetentionSettingsMongo)
+		adminOnly.PUT("/retention-settings", handlers.UpdateRetentionSettingsMongo)
+		adminOnly.GET("/cleanup/orphans", handlers.GetOrphanReportMongo)
+		adminOnly.GET("/archived-records", handlers.GetArchivedRecordsMongo)
+		adminOnly.POST("/archived-records/:id/restore", handlers.RestoreArchivedRecordMongo)
 	}
 
 	log.Println("Server starting on :8080")
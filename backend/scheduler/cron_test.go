@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func at(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"a * * * *",
+		"@often",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) accepted an invalid expression", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	cases := []struct {
+		spec string
+		from string
+		want string
+	}{
+		// Steps, and strictly after the given minute
+		{"*/15 * * * *", "2026-10-19 10:07", "2026-10-19 10:15"},
+		{"*/15 * * * *", "2026-10-19 10:15", "2026-10-19 10:30"},
+		{"*/15 * * * *", "2026-10-19 23:50", "2026-10-20 00:00"},
+		// "5/15" starts at 5
+		{"5/15 * * * *", "2026-10-19 10:21", "2026-10-19 10:35"},
+		// Range with a step
+		{"0 8-18/2 * * *", "2026-10-19 12:30", "2026-10-19 14:00"},
+		{"0 8-18/2 * * *", "2026-10-19 18:00", "2026-10-20 08:00"},
+		// Lists
+		{"0 9 1,15 * *", "2026-10-02 00:00", "2026-10-15 09:00"},
+		// Weekdays skip the weekend (2026-10-16 is a Friday)
+		{"0 9 * * 1-5", "2026-10-16 10:00", "2026-10-19 09:00"},
+		// 7 is Sunday too (2026-10-18 is a Sunday)
+		{"0 6 * * 7", "2026-10-16 00:00", "2026-10-18 06:00"},
+		// Both day fields restricted: either matches, as in cron
+		{"0 0 20 * 5", "2026-10-17 00:00", "2026-10-20 00:00"},
+		{"0 0 20 * 5", "2026-10-20 00:00", "2026-10-23 00:00"},
+		// Month and year rollover
+		{"0 0 1 * *", "2026-12-15 08:00", "2027-01-01 00:00"},
+		{"30 2 31 * *", "2026-11-01 00:00", "2026-12-31 02:30"},
+		// Leap day
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		// Descriptors
+		{"@daily", "2026-10-19 13:45", "2026-10-20 00:00"},
+		{"@hourly", "2026-10-19 13:45", "2026-10-19 14:00"},
+		{"@weekly", "2026-10-19 13:45", "2026-10-25 00:00"},
+		{"@monthly", "2026-10-19 13:45", "2026-11-01 00:00"},
+		{"@yearly", "2026-10-19 13:45", "2027-01-01 00:00"},
+	}
+	for _, tc := range cases {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.spec, err)
+			continue
+		}
+		if got := s.Next(at(tc.from)); !got.Equal(at(tc.want)) {
+			t.Errorf("%q after %s = %s, want %s", tc.spec, tc.from, got.Format("2006-01-02 15:04"), tc.want)
+		}
+	}
+}
+
+func TestScheduleNextIgnoresSeconds(t *testing.T) {
+	s, _ := Parse("* * * * *")
+	from := time.Date(2026, 10, 19, 10, 7, 59, 999, time.UTC)
+	if got := s.Next(from); !got.Equal(at("2026-10-19 10:08")) {
+		t.Errorf("Next = %s, want 10:08:00", got)
+	}
+}
+
+func TestScheduleNextNeverMatches(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(at("2026-01-01 00:00")); !got.IsZero() {
+		t.Errorf("30 February matched %s", got)
+	}
+}
+
+func TestScheduleNextKeepsLocation(t *testing.T) {
+	jakarta := time.FixedZone("WIB", 7*60*60)
+	s, _ := Parse("0 2 * * *")
+	got := s.Next(time.Date(2026, 10, 19, 23, 0, 0, 0, jakarta))
+	if want := time.Date(2026, 10, 20, 2, 0, 0, 0, jakarta); !got.Equal(want) || got.Location() != jakarta {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
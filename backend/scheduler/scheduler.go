@@ -0,0 +1,392 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"kkhris-clone/database"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// leaderLock is held by the replica that runs scheduled jobs
+	leaderLock = "scheduler:leader"
+	leaderTTL  = 90 * time.Second
+	tick       = 30 * time.Second
+	// jobLockTTL bounds how long a crashed run keeps its job locked; live runs renew it
+	jobLockTTL = 15 * time.Minute
+)
+
+// ErrJobRunning is returned when a job is already running on some replica
+var ErrJobRunning = errors.New("job is already running")
+
+// ErrLockLost fails a run whose job lock was taken over or could not be renewed; the job's
+// context is cancelled so it does not keep running next to another replica's run
+var ErrLockLost = errors.New("job lock lost")
+
+// Job is a named task run on a cron schedule. Run returns counts to record with the run, such
+// as the number of records removed per category.
+type Job struct {
+	Name        string
+	Description string
+	Schedule    string // default cron expression; admins can override it
+	Run         func(ctx context.Context) (map[string]int64, error)
+}
+
+// JobStatus describes a job for the admin API
+type JobStatus struct {
+	Name            string                `json:"name"`
+	Description     string                `json:"description"`
+	Schedule        string                `json:"schedule"`
+	DefaultSchedule string                `json:"default_schedule"`
+	Enabled         bool                  `json:"enabled"`
+	Running         bool                  `json:"running"`
+	NextRun         string                `json:"next_run,omitempty"`
+	LastRun         *database.JobRunMongo `json:"last_run,omitempty"`
+}
+
+// Scheduler runs registered jobs on the replica that holds the leader lock. Every job run takes
+// the job's own lock too, so a manual run never overlaps a scheduled one.
+type Scheduler struct {
+	instance string
+	mu       sync.Mutex
+	jobs     map[string]Job
+	running  map[string]bool
+	started  time.Time
+	leader   bool
+}
+
+func New() *Scheduler {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Scheduler{
+		instance: fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix)),
+		jobs:     make(map[string]Job),
+		running:  make(map[string]bool),
+	}
+}
+
+// Register adds a job; it panics on an invalid default schedule since that is a programming error
+func (s *Scheduler) Register(job Job) {
+	if _, err := Parse(job.Schedule); err != nil {
+		panic(fmt.Sprintf("scheduler: job %s: %v", job.Name, err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Name] = job
+}
+
+func (s *Scheduler) Instance() string { return s.instance }
+
+// IsLeader reports whether this replica held the leader lock at the last tick
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Start runs the scheduling loop until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.started = time.Now()
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		s.tick(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				database.ReleaseLockMongo(leaderLock, s.instance)
+				return
+			case <-ticker.C:
+				s.tick(ctx)
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	leader, err := database.AcquireLockMongo(leaderLock, s.instance, leaderTTL)
+	if err != nil {
+		log.Printf("Scheduler leader lock error: %v", err)
+		leader = false
+	}
+	s.mu.Lock()
+	if leader != s.leader {
+		log.Printf("Scheduler: %s leader=%v", s.instance, leader)
+	}
+	s.leader = leader
+	s.mu.Unlock()
+	if !leader {
+		return
+	}
+
+	settings, err := database.GetJobSettingsMongo()
+	if err != nil {
+		log.Printf("Scheduler settings error: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, job := range s.jobList() {
+		sched, enabled := s.effectiveSchedule(job, settings)
+		if !enabled {
+			continue
+		}
+		due, err := s.dueAt(job.Name, sched, now)
+		if err != nil {
+			log.Printf("Scheduler %s: %v", job.Name, err)
+			continue
+		}
+		if due.IsZero() {
+			continue
+		}
+		go func(job Job, due time.Time) {
+			if _, err := s.run(ctx, job, "schedule", "", due); err != nil && err != ErrJobRunning {
+				log.Printf("Scheduled job %s failed: %v", job.Name, err)
+			}
+		}(job, due)
+	}
+}
+
+// dueAt returns the scheduled time the job should run for now, or zero if it is not due. Runs
+// missed while no replica was leading collapse into one.
+func (s *Scheduler) dueAt(name string, sched *Schedule, now time.Time) (time.Time, error) {
+	since := s.started
+	last, err := database.GetLastScheduledRunMongo(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if last != nil {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", last.ScheduledFor, time.Local); err == nil {
+			since = t
+		}
+	}
+	next := sched.Next(since)
+	if next.IsZero() || next.After(now) {
+		return time.Time{}, nil
+	}
+	// Skip ahead to the latest missed slot
+	for n := sched.Next(next); !n.IsZero() && !n.After(now); n = sched.Next(n) {
+		next = n
+	}
+	return next, nil
+}
+
+func (s *Scheduler) jobList() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Name < jobs[b].Name })
+	return jobs
+}
+
+func (s *Scheduler) effectiveSchedule(job Job, settings *database.JobSettingsMongo) (*Schedule, bool) {
+	spec := job.Schedule
+	override := settings.Jobs[job.Name]
+	if override.Schedule != "" {
+		spec = override.Schedule
+	}
+	sched, err := Parse(spec)
+	if err != nil {
+		// Overrides are validated when saved; fall back to the default if one slipped through
+		log.Printf("Scheduler %s: invalid schedule %q: %v", job.Name, spec, err)
+		sched, _ = Parse(job.Schedule)
+	}
+	return sched, !override.Disabled
+}
+
+// run executes a job under its lock and records the run
+func (s *Scheduler) run(ctx context.Context, job Job, trigger, triggeredBy string, scheduledFor time.Time) (*database.JobRunMongo, error) {
+	// This replica may re-acquire its own lock, so its runs are tracked in memory first
+	s.mu.Lock()
+	if s.running[job.Name] {
+		s.mu.Unlock()
+		return nil, ErrJobRunning
+	}
+	s.running[job.Name] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.Name)
+		s.mu.Unlock()
+	}()
+
+	lockName := "job:" + job.Name
+	ok, err := database.AcquireLockMongo(lockName, s.instance, jobLockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrJobRunning
+	}
+	defer database.ReleaseLockMongo(lockName, s.instance)
+
+	// Keep the lock while the job runs longer than its TTL, and stop the job once the lock is
+	// gone or may expire before the next renewal
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		renew := time.NewTicker(jobLockTTL / 3)
+		defer renew.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-renew.C:
+				ok, err := database.AcquireLockMongo(lockName, s.instance, jobLockTTL)
+				switch {
+				case err == nil && ok:
+					renewed = time.Now()
+				case err == nil:
+					cancel(ErrLockLost)
+					return
+				default:
+					log.Printf("Renew lock for job %s: %v", job.Name, err)
+					if time.Since(renewed)+jobLockTTL/3 >= jobLockTTL {
+						cancel(ErrLockLost)
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	if err := database.AbandonJobRunsMongo(job.Name, start.Format("2006-01-02 15:04:05")); err != nil {
+		return nil, err
+	}
+	run := database.JobRunMongo{
+		Job:         job.Name,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Instance:    s.instance,
+		Status:      "running",
+		StartedAt:   start.Format("2006-01-02 15:04:05"),
+	}
+	if !scheduledFor.IsZero() {
+		run.ScheduledFor = scheduledFor.Format("2006-01-02 15:04:05")
+	}
+	created, err := database.AddJobRunMongo(run)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, runErr := safeRun(jobCtx, job)
+	if errors.Is(context.Cause(jobCtx), ErrLockLost) {
+		runErr = errors.Join(ErrLockLost, runErr)
+	}
+	finished := time.Now()
+	set := bson.M{
+		"status":      "success",
+		"counts":      counts,
+		"finished_at": finished.Format("2006-01-02 15:04:05"),
+		"duration_ms": finished.Sub(start).Milliseconds(),
+	}
+	if runErr != nil {
+		set["status"] = "failed"
+		set["error"] = runErr.Error()
+	}
+	if err := database.UpdateJobRunMongo(created.ID, set); err != nil {
+		log.Printf("Record job run %s: %v", job.Name, err)
+	}
+	created.Status = set["status"].(string)
+	created.Counts = counts
+	created.FinishedAt = set["finished_at"].(string)
+	created.DurationMs = set["duration_ms"].(int64)
+	if runErr != nil {
+		created.Error = runErr.Error()
+	}
+	return created, runErr
+}
+
+// safeRun turns a panicking job into a failed run instead of a crashed server
+func safeRun(ctx context.Context, job Job) (counts map[string]int64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// RunNow starts a job outside its schedule on this replica and returns once the run is recorded
+// as started. It returns ErrJobRunning if the job is already running anywhere.
+func (s *Scheduler) RunNow(name, triggeredBy string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown job %s", name)
+	}
+	lock, err := database.GetLockMongo("job:" + name)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		return ErrJobRunning
+	}
+	go func() {
+		if _, err := s.run(context.Background(), job, "manual", triggeredBy, time.Time{}); err != nil && err != ErrJobRunning {
+			log.Printf("Job %s (manual) failed: %v", name, err)
+		}
+	}()
+	return nil
+}
+
+// HasJob reports whether a job is registered
+func (s *Scheduler) HasJob(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.jobs[name]
+	return ok
+}
+
+// Status lists every job with its effective schedule, next run and latest run
+func (s *Scheduler) Status() ([]JobStatus, error) {
+	settings, err := database.GetJobSettingsMongo()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := database.GetLatestJobRunsMongo()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	statuses := []JobStatus{}
+	for _, job := range s.jobList() {
+		sched, enabled := s.effectiveSchedule(job, settings)
+		st := JobStatus{
+			Name:            job.Name,
+			Description:     job.Description,
+			Schedule:        job.Schedule,
+			DefaultSchedule: job.Schedule,
+			Enabled:         enabled,
+		}
+		if o := settings.Jobs[job.Name].Schedule; o != "" {
+			st.Schedule = o
+		}
+		if enabled {
+			if next := sched.Next(now); !next.IsZero() {
+				st.NextRun = next.Format("2006-01-02 15:04:05")
+			}
+		}
+		if run, ok := latest[job.Name]; ok {
+			st.LastRun = &run
+			st.Running = run.Status == "running"
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
@@ -44,15 +44,15 @@ func SeedMongoDB() {
 	}
 	log.Println("Seeded 3 users")
 
-	// Seed employees
-	employees := []database.EmployeeMongo{
-		{Name: "John Doe", Center: "Bekasi", Roles: "Teacher", PhotoURL: "", Sex: "Male", Phone: "08123456789"},
-		{Name: "Jane Smith", Center: "Sukabumi", Roles: "Admin", PhotoURL: "", Sex: "Female", Phone: "08987654321"},
-		{Name: "Bob Wilson", Center: "Bandung", Roles: "Teacher", PhotoURL: "", Sex: "Male", Phone: "08111222333"},
+	// Seed employees without a login
+	employees := []database.UserMongo{
+		{Name: "John Doe", Role: "staff", Center: "Bekasi", Roles: "Teacher", PhotoURL: "", Sex: "Male", Phone: "08123456789", ShowInDirectory: true},
+		{Name: "Jane Smith", Role: "staff", Center: "Sukabumi", Roles: "Admin", PhotoURL: "", Sex: "Female", Phone: "08987654321", ShowInDirectory: true},
+		{Name: "Bob Wilson", Role: "staff", Center: "Bandung", Roles: "Teacher", PhotoURL: "", Sex: "Male", Phone: "08111222333", ShowInDirectory: true},
 	}
 
 	for _, e := range employees {
-		database.CreateEmployeeMongo(e)
+		database.CreateUserMongo(e)
 	}
 	log.Println("Seeded 3 employees")
 
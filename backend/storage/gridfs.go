@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFS stores files in a GridFS bucket, using the key as the GridFS file ID
+type GridFS struct {
+	bucket *gridfs.Bucket
+}
+
+func NewGridFS(db *mongo.Database, name string) (*GridFS, error) {
+	bucket, err := gridfs.NewBucket(db, options.GridFSBucket().SetName(name))
+	if err != nil {
+		return nil, err
+	}
+	return &GridFS{bucket: bucket}, nil
+}
+
+func (g *GridFS) Name() string { return "gridfs" }
+
+func (g *GridFS) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	return g.bucket.UploadFromStreamWithID(key, key, r, opts)
+}
+
+func (g *GridFS) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	stream, err := g.bucket.OpenDownloadStream(key)
+	if err == gridfs.ErrFileNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (g *GridFS) Delete(ctx context.Context, key string) error {
+	if err := g.bucket.Delete(key); err != nil && err != gridfs.ErrFileNotFound {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package storage keeps uploaded file contents in GridFS, an S3-compatible bucket or a local
+// directory. Callers address contents by key; metadata lives with the caller.
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when a key has no stored content
+var ErrNotFound = errors.New("storage: file not found")
+
+// Backend stores file contents by key
+type Backend interface {
+	Name() string
+	Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FromEnv picks the backend from STORAGE_DRIVER: gridfs (default), s3 or fs.
+//
+//	gridfs: STORAGE_GRIDFS_BUCKET (default "files") in the application database
+//	s3:     S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY, S3_REGION (default us-east-1)
+//	fs:     STORAGE_DIR (default ./uploads)
+func FromEnv(db *mongo.Database) (Backend, error) {
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "gridfs":
+		return NewGridFS(db, envOr("STORAGE_GRIDFS_BUCKET", "files"))
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Region:    envOr("S3_REGION", "us-east-1"),
+		})
+	case "fs":
+		return NewFilesystem(envOr("STORAGE_DIR", "./uploads"))
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// ParseDataURL splits a base64 data URL ("data:image/png;base64,...") into its type and bytes
+func ParseDataURL(data string) (string, []byte, error) {
+	header, payload, ok := strings.Cut(data, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", nil, fmt.Errorf("not a base64 data URL")
+	}
+	contentType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("data URL is not valid base64")
+	}
+	return contentType, decoded, nil
+}
@@ -2,7 +2,7 @@
 
 import { useState, useEffect } from 'react';
 import { useAuth } from '@/context/AuthContext';
-import { API_BASE_URL } from '@/lib/api';
+import { API_BASE_URL, uploadFile } from '@/lib/api';
 import { useRouter } from 'next/navigation';
 import Link from 'next/link';
 import {
@@ -27,7 +27,8 @@ import {
     Image,
     MessageSquare,
     Upload,
-    Terminal
+    Terminal,
+    RotateCcw
 } from 'lucide-react';
 
 interface User {
@@ -52,7 +53,22 @@ interface User {
     graduation_year?: number;
     bank_account?: string;
     status_ptkp?: string;
+    bpjs_kesehatan_no?: string;
+    bpjs_ketenagakerjaan_no?: string;
+    bank_code?: string;
+    bank_account_holder?: string;
+    hire_date?: string;
+    termination_date?: string;
+    employment_type?: string;
+    probation_end?: string;
+    contract_start?: string;
+    contract_end?: string;
+    termination_reason?: string;
+    reports_to?: string;
+    status?: string;
+    deleted_at?: string;
     photo_url?: string;
+    photo_file_id?: string;
     branch_id?: string | number;
     jabatan?: string;
     show_in_directory?: boolean;
@@ -117,6 +133,7 @@ export default function AdminPage() {
     const router = useRouter();
     const [activeTab, setActiveTab] = useState<TabType>('overview');
     const [users, setUsers] = useState<User[]>([]);
+    const [userStatus, setUserStatus] = useState('');
     const [employees, setEmployees] = useState<Employee[]>([]);
     const [branches, setBranches] = useState<Branch[]>([]);
     const [schools, setSchools] = useState<School[]>([]);
@@ -146,7 +163,7 @@ export default function AdminPage() {
         // Employee profile fields
         sex: '', pob: '', dob: '', age: 0, religion: '', phone: '', address1: '',
         nik: '', npwp: '', education_level: '', institution: '', major: '', graduation_year: 0,
-        bank_account: '', status_ptkp: '', photo_url: '', branch_id: '' as string | number, jabatan: '',
+        bank_account: '', status_ptkp: '', bpjs_kesehatan_no: '', bpjs_ketenagakerjaan_no: '', bank_code: '', bank_account_holder: '', hire_date: '', termination_date: '', employment_type: 'permanent', probation_end: '', contract_start: '', contract_end: '', termination_reason: '', reports_to: '', photo_url: '', photo_file_id: '', branch_id: '' as string | number, jabatan: '',
         show_in_directory: true
     });
 
@@ -170,14 +187,14 @@ export default function AdminPage() {
             return;
         }
         if (token && isAdmin) fetchData();
-    }, [token, isAdmin, authLoading]);
+    }, [token, isAdmin, authLoading, userStatus]);
 
     const fetchData = async () => {
         try {
             const headers = { 'Authorization': `Bearer ${token}` };
 
             // Fetch users
-            const usersRes = await fetch(`${API_BASE_URL}/admin/users`, { headers });
+            const usersRes = await fetch(`${API_BASE_URL}/admin/users${userStatus ? `?status=${userStatus}` : ''}`, { headers });
             if (usersRes.ok) {
                 const usersData = await usersRes.json();
                 setUsers(Array.isArray(usersData) ? usersData : []);
@@ -250,18 +267,18 @@ export default function AdminPage() {
         e.preventDefault();
         setSubmitting(true);
         try {
-            // Convert photo to base64 if uploaded
-            let photoBase64 = userFormData.photo_url;
+            // Upload a new photo first; the user only stores its file ID
+            let photoFileId = userFormData.photo_file_id;
             if (photoFile) {
-                const reader = new FileReader();
-                photoBase64 = await new Promise<string>((resolve, reject) => {
-                    reader.onload = () => resolve(reader.result as string);
-                    reader.onerror = reject;
-                    reader.readAsDataURL(photoFile);
-                });
+                try {
+                    photoFileId = await uploadFile(photoFile, 'photo', token);
+                } catch (err) {
+                    setToast({ message: (err as Error).message, type: 'error' });
+                    return;
+                }
             }
 
-            const formDataToSend = { ...userFormData, photo_url: photoBase64 };
+            const formDataToSend = { ...userFormData, photo_file_id: photoFileId };
             const url = editingUser ? `${API_BASE_URL}/admin/users/${editingUser.id}` : `${API_BASE_URL}/admin/users`;
             const res = await fetch(url, {
                 method: editingUser ? 'PUT' : 'POST',
@@ -275,14 +292,15 @@ export default function AdminPage() {
                 setPhotoFile(null);
                 fetchData();
             } else {
-                setToast({ message: 'Gagal menyimpan user', type: 'error' });
+                const data = await res.json().catch(() => ({}));
+                setToast({ message: data.error || 'Gagal menyimpan user', type: 'error' });
             }
         } catch { setToast({ message: 'Terjadi kesalahan', type: 'error' }); }
         finally { setSubmitting(false); setTimeout(() => setToast(null), 3000); }
     };
 
     const handleDeleteUser = async (userId: number) => {
-        if (!confirm('Yakin ingin menghapus user ini?')) return;
+        if (!confirm('Hapus user ini? Login diblokir, riwayat tetap disimpan dan akun masih bisa dipulihkan.')) return;
         try {
             const res = await fetch(`${API_BASE_URL}/admin/users/${userId}`, {
                 method: 'DELETE', headers: { 'Authorization': `Bearer ${token}` }
@@ -298,6 +316,22 @@ export default function AdminPage() {
         setTimeout(() => setToast(null), 3000);
     };
 
+    const handleRestoreUser = async (userId: number) => {
+        try {
+            const res = await fetch(`${API_BASE_URL}/admin/users/${userId}/restore`, {
+                method: 'POST', headers: { 'Authorization': `Bearer ${token}` }
+            });
+            if (res.ok) {
+                setToast({ message: 'User berhasil dipulihkan!', type: 'success' });
+                fetchData();
+            } else {
+                const data = await res.json().catch(() => ({}));
+                setToast({ message: data.error || 'Gagal memulihkan user', type: 'error' });
+            }
+        } catch { setToast({ message: 'Terjadi kesalahan', type: 'error' }); }
+        setTimeout(() => setToast(null), 3000);
+    };
+
     const handleBranchSubmit = async (e: React.FormEvent) => {
         e.preventDefault();
         try {
@@ -582,7 +616,13 @@ export default function AdminPage() {
                 <div className="glass-card overflow-hidden">
                     <div className="p-4 border-b border-white/10 flex items-center justify-between">
                         <h2 className="text-lg font-bold text-white flex items-center gap-2"><Users className="w-5 h-5 text-violet-400" /> User Management</h2>
-                        <button onClick={() => { setEditingUser(null); setUserFormData({ email: '', password: '', name: '', role: 'staff', is_admin: false, sex: '', pob: '', dob: '', age: 0, religion: '', phone: '', address1: '', nik: '', npwp: '', education_level: '', institution: '', major: '', graduation_year: 0, bank_account: '', status_ptkp: '', photo_url: '', branch_id: '', jabatan: '', show_in_directory: true }); setShowUserModal(true); }} className="btn-gradient flex items-center gap-2 text-sm py-2">
+                        <select value={userStatus} onChange={e => setUserStatus(e.target.value)} className="input-modern text-sm py-2 ml-auto mr-3 w-auto">
+                            <option value="">Semua (kecuali dihapus)</option>
+                            <option value="active">Aktif</option>
+                            <option value="inactive">Nonaktif</option>
+                            <option value="deleted">Dihapus</option>
+                        </select>
+                        <button onClick={() => { setEditingUser(null); setUserFormData({ email: '', password: '', name: '', role: 'staff', is_admin: false, sex: '', pob: '', dob: '', age: 0, religion: '', phone: '', address1: '', nik: '', npwp: '', education_level: '', institution: '', major: '', graduation_year: 0, bank_account: '', status_ptkp: '', bpjs_kesehatan_no: '', bpjs_ketenagakerjaan_no: '', bank_code: '', bank_account_holder: '', hire_date: '', termination_date: '', employment_type: 'permanent', probation_end: '', contract_start: '', contract_end: '', termination_reason: '', reports_to: '', photo_url: '', photo_file_id: '', branch_id: '', jabatan: '', show_in_directory: true }); setShowUserModal(true); }} className="btn-gradient flex items-center gap-2 text-sm py-2">
                             <Plus className="w-4 h-4" /> Tambah User
                         </button>
                     </div>
@@ -593,7 +633,7 @@ export default function AdminPage() {
                                 {users.map(u => (
                                     <tr key={u.id} className="border-t border-white/5 hover:bg-white/5">
                                         <td className="px-6 py-4 text-slate-400">{u.id}</td>
-                                        <td className="px-6 py-4 text-white font-medium">{u.name}</td>
+                                        <td className="px-6 py-4 text-white font-medium">{u.name}{u.status === 'inactive' && <span className="badge badge-warning ml-2">Nonaktif</span>}{u.status === 'deleted' && <span className="badge badge-error ml-2">Dihapus</span>}</td>
                                         <td className="px-6 py-4 text-slate-300">{u.email}</td>
                                         <td className="px-6 py-4"><span className="badge badge-info capitalize">{u.role}</span></td>
                                         <td className="px-6 py-4">{u.is_admin ? <span className="badge badge-warning">Admin</span> : '-'}</td>
@@ -625,7 +665,20 @@ export default function AdminPage() {
                                                         graduation_year: u.graduation_year || 0,
                                                         bank_account: u.bank_account || '',
                                                         status_ptkp: u.status_ptkp || '',
+                                                        bpjs_kesehatan_no: u.bpjs_kesehatan_no || '',
+                                                        bpjs_ketenagakerjaan_no: u.bpjs_ketenagakerjaan_no || '',
+                                                        bank_code: u.bank_code || '',
+                                                        bank_account_holder: u.bank_account_holder || '',
+                                                        hire_date: u.hire_date || '',
+                                                        termination_date: u.termination_date || '',
+                                                        employment_type: u.employment_type || 'permanent',
+                                                        probation_end: u.probation_end || '',
+                                                        contract_start: u.contract_start || '',
+                                                        contract_end: u.contract_end || '',
+                                                        termination_reason: u.termination_reason || '',
+                                                        reports_to: u.reports_to || '',
                                                         photo_url: u.photo_url || '',
+                                                        photo_file_id: u.photo_file_id || '',
                                                         branch_id: String(u.branch_id || ''),
                                                         jabatan: u.jabatan || '',
                                                         show_in_directory: u.show_in_directory !== undefined ? u.show_in_directory : true
@@ -633,7 +686,11 @@ export default function AdminPage() {
                                                     setPhotoFile(null);
                                                     setShowUserModal(true);
                                                 }} className="p-2 rounded-lg bg-violet-600/20 text-violet-400 hover:bg-violet-600/30"><Edit className="w-4 h-4" /></button>
-                                                <button onClick={() => handleDeleteUser(u.id)} className="p-2 rounded-lg bg-rose-600/20 text-rose-400 hover:bg-rose-600/30"><Trash2 className="w-4 h-4" /></button>
+                                                {u.status === 'inactive' || u.status === 'deleted' ? (
+                                                    <button onClick={() => handleRestoreUser(u.id)} className="p-2 rounded-lg bg-emerald-600/20 text-emerald-400 hover:bg-emerald-600/30" title="Pulihkan"><RotateCcw className="w-4 h-4" /></button>
+                                                ) : (
+                                                    <button onClick={() => handleDeleteUser(u.id)} className="p-2 rounded-lg bg-rose-600/20 text-rose-400 hover:bg-rose-600/30"><Trash2 className="w-4 h-4" /></button>
+                                                )}
                                             </div>
                                         </td>
                                     </tr>
@@ -976,6 +1033,20 @@ export default function AdminPage() {
                             </div>
                             <label className="flex items-center gap-2"><input type="checkbox" checked={userFormData.is_admin} onChange={e => setUserFormData({ ...userFormData, is_admin: e.target.checked })} className="w-4 h-4 rounded" /><span className="text-sm text-slate-300">Admin Access</span></label>
                             <div><label className="block text-sm text-slate-400 mb-2 mt-2">Jabatan</label><input type="text" value={userFormData.jabatan || ''} onChange={e => setUserFormData({ ...userFormData, jabatan: e.target.value })} className="input-modern w-full" placeholder="Contoh: Assistant Coach, Teacher, dll" /></div>
+                            <div><label className="block text-sm text-slate-400 mb-2 mt-2">Atasan Langsung</label><select value={userFormData.reports_to || ''} onChange={e => setUserFormData({ ...userFormData, reports_to: e.target.value })} className="input-modern w-full"><option value="">-- Tidak ada --</option>{users.filter(u => String(u.id) !== String(editingUser?.id) && u.status !== 'deleted' && u.status !== 'inactive').map(u => <option key={u.id} value={String(u.id)}>{u.name}{u.jabatan ? ` - ${u.jabatan}` : ''}</option>)}</select></div>
+                            <div><label className="block text-sm text-slate-400 mb-2 mt-2">Status Kepegawaian</label><select value={userFormData.employment_type || 'permanent'} onChange={e => setUserFormData({ ...userFormData, employment_type: e.target.value })} className="input-modern w-full"><option value="permanent">Karyawan Tetap (PKWTT)</option><option value="contract">Kontrak (PKWT)</option><option value="freelance">Harian Lepas / Freelance</option><option value="intern">Magang</option></select></div>
+                            <div><label className="block text-sm text-slate-400 mb-2 mt-2">Tanggal Masuk Kerja</label><input type="date" value={userFormData.hire_date || ''} onChange={e => setUserFormData({ ...userFormData, hire_date: e.target.value })} className="input-modern w-full" /></div>
+                            <div><label className="block text-sm text-slate-400 mb-2 mt-2">Tanggal Keluar</label><input type="date" value={userFormData.termination_date || ''} onChange={e => setUserFormData({ ...userFormData, termination_date: e.target.value, termination_reason: e.target.value ? userFormData.termination_reason : '' })} className="input-modern w-full" /></div>
+                            <div><label className="block text-sm text-slate-400 mb-2 mt-2">Akhir Masa Percobaan</label><input type="date" value={userFormData.probation_end || ''} onChange={e => setUserFormData({ ...userFormData, probation_end: e.target.value })} className="input-modern w-full" /></div>
+                            {userFormData.employment_type === 'contract' && (
+                                <div className="grid grid-cols-2 gap-4">
+                                    <div><label className="block text-sm text-slate-400 mb-2 mt-2">Mulai Kontrak</label><input type="date" value={userFormData.contract_start || ''} onChange={e => setUserFormData({ ...userFormData, contract_start: e.target.value })} className="input-modern w-full" /></div>
+                                    <div><label className="block text-sm text-slate-400 mb-2 mt-2">Akhir Kontrak</label><input type="date" value={userFormData.contract_end || ''} onChange={e => setUserFormData({ ...userFormData, contract_end: e.target.value })} className="input-modern w-full" required /></div>
+                                </div>
+                            )}
+                            {userFormData.termination_date && (
+                                <div><label className="block text-sm text-slate-400 mb-2 mt-2">Alasan Keluar</label><input type="text" value={userFormData.termination_reason || ''} onChange={e => setUserFormData({ ...userFormData, termination_reason: e.target.value })} className="input-modern w-full" placeholder="Contoh: Resign, kontrak berakhir, PHK" /></div>
+                            )}
 
                             {/* Personal Info */}
                             <h3 className="text-sm font-semibold text-cyan-400 border-b border-cyan-500/30 pb-2 pt-4">Data Pribadi</h3>
@@ -1009,8 +1080,12 @@ export default function AdminPage() {
                             {/* Financial */}
                             <h3 className="text-sm font-semibold text-amber-400 border-b border-amber-500/30 pb-2 pt-4">Data Keuangan</h3>
                             <div className="grid grid-cols-2 gap-4">
+                                <div><label className="block text-sm text-slate-400 mb-2">Kode Bank</label><input type="text" placeholder="014" value={userFormData.bank_code || ''} onChange={e => setUserFormData({ ...userFormData, bank_code: e.target.value })} className="input-modern w-full" /></div>
                                 <div><label className="block text-sm text-slate-400 mb-2">No. Rekening</label><input type="text" value={userFormData.bank_account || ''} onChange={e => setUserFormData({ ...userFormData, bank_account: e.target.value })} className="input-modern w-full" /></div>
+                                <div><label className="block text-sm text-slate-400 mb-2">Nama Pemilik Rekening</label><input type="text" value={userFormData.bank_account_holder || ''} onChange={e => setUserFormData({ ...userFormData, bank_account_holder: e.target.value })} className="input-modern w-full" /></div>
                                 <div><label className="block text-sm text-slate-400 mb-2">Status PTKP</label><select value={userFormData.status_ptkp || ''} onChange={e => setUserFormData({ ...userFormData, status_ptkp: e.target.value })} className="input-modern w-full"><option value="">--</option><option value="TK/0">TK/0</option><option value="K/0">K/0</option><option value="K/1">K/1</option><option value="K/2">K/2</option><option value="K/3">K/3</option></select></div>
+                                <div><label className="block text-sm text-slate-400 mb-2">No. BPJS Kesehatan</label><input type="text" value={userFormData.bpjs_kesehatan_no || ''} onChange={e => setUserFormData({ ...userFormData, bpjs_kesehatan_no: e.target.value })} className="input-modern w-full" /></div>
+                                <div><label className="block text-sm text-slate-400 mb-2">No. BPJS Ketenagakerjaan</label><input type="text" value={userFormData.bpjs_ketenagakerjaan_no || ''} onChange={e => setUserFormData({ ...userFormData, bpjs_ketenagakerjaan_no: e.target.value })} className="input-modern w-full" /></div>
                             </div>
                             <div>
                                 <label className="block text-sm text-slate-400 mb-2">Foto Profil</label>
@@ -1034,7 +1109,7 @@ export default function AdminPage() {
                                     {(photoFile || userFormData.photo_url) && (
                                         <button
                                             type="button"
-                                            onClick={() => { setPhotoFile(null); setUserFormData({ ...userFormData, photo_url: '' }); }}
+                                            onClick={() => { setPhotoFile(null); setUserFormData({ ...userFormData, photo_url: '', photo_file_id: '' }); }}
                                             className="absolute right-2 top-1/2 -translate-y-1/2 p-1 hover:bg-white/10 rounded"
                                         >
                                             <X className="w-4 h-4 text-slate-400" />
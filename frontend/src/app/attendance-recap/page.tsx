@@ -126,14 +126,19 @@ export default function AttendanceRecapPage() {
                 setBranches(Array.isArray(branchesData) ? branchesData : []);
             }
 
-            // Fetch all attendance records
-            const attendanceRes = await fetch(`${API_BASE_URL}/admin/attendance-recap`, {
-                headers: { 'Authorization': `Bearer ${token}` }
-            });
-            if (attendanceRes.ok) {
+            // Fetch all attendance records, following the recap cursor page by page
+            const allRecords: AttendanceRecord[] = [];
+            let cursor = '';
+            do {
+                const attendanceRes = await fetch(`${API_BASE_URL}/admin/attendance-recap?limit=500&cursor=${encodeURIComponent(cursor)}`, {
+                    headers: { 'Authorization': `Bearer ${token}` }
+                });
+                if (!attendanceRes.ok) break;
                 const data = await attendanceRes.json();
-                setRecords(Array.isArray(data) ? data : []);
-            }
+                allRecords.push(...(Array.isArray(data.items) ? data.items : []));
+                cursor = data.next_cursor || '';
+            } while (cursor);
+            setRecords(allRecords);
         } catch (error) {
             console.error('Error fetching data:', error);
         } finally {
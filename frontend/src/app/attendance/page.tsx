@@ -57,33 +57,40 @@ const MONTHS = [
     'Juli', 'Agustus', 'September', 'Oktober', 'November', 'Desember'
 ];
 
-const ACTIVITY_TYPES = [
-    { value: 'Daily Activity', label: 'Daily Activity' },
-    { value: 'Event Activity', label: 'Event Activity' },
-];
+interface ActivityType {
+    code: string;
+    label: string;
+}
 
-// Categories for Daily Activity
-const DAILY_CATEGORIES = [
-    { value: 'Regular Class', label: 'Regular Class' },
-    { value: 'School Class', label: 'School Class' },
-    { value: 'Private Class', label: 'Private Class' },
-];
+interface ActivityCategory {
+    code: string;
+    label: string;
+    type_codes: string[];
+    requires_school: boolean;
+    requires_time_range: boolean;
+}
 
-// Categories for Event Activity
-const EVENT_CATEGORIES = [
-    { value: 'Offline', label: 'Offline' },
-    { value: 'Online', label: 'Online' },
-];
+interface School {
+    id: string;
+    name: string;
+}
 
-const SCHOOLS = [
-    'SD Negeri 1',
-    'SD Negeri 2',
-    'SMP Negeri 1',
-    'SMP Negeri 2',
-    'SMA Negeri 1',
-    'SMA Negeri 2',
-    'Other'
-];
+interface ScheduledClass {
+    schedule_id: string;
+    class_name: string;
+    school_name: string;
+    start_time: string;
+    end_time: string;
+    prefill: {
+        activity_type: string;
+        activity_categories: string[];
+        activity_details: string;
+        starting_time: string;
+        ending_time: string;
+        activity_notes: string;
+        school_id: string;
+    };
+}
 
 export default function AttendancePage() {
     const { token, user } = useAuth();
@@ -97,6 +104,10 @@ export default function AttendancePage() {
     const [selectedAttendance, setSelectedAttendance] = useState<Attendance | null>(null);
     const [attendance, setAttendance] = useState<Attendance[]>([]);
     const [employee, setEmployee] = useState<Employee | null>(null);
+    const [activityTypes, setActivityTypes] = useState<ActivityType[]>([]);
+    const [activityCategories, setActivityCategories] = useState<ActivityCategory[]>([]);
+    const [schools, setSchools] = useState<School[]>([]);
+    const [scheduledClasses, setScheduledClasses] = useState<ScheduledClass[]>([]);
     const [loading, setLoading] = useState(true);
     const [submitting, setSubmitting] = useState(false);
     const [currentMonth, setCurrentMonth] = useState(new Date().getMonth());
@@ -128,9 +139,63 @@ export default function AttendancePage() {
         if (token) {
             fetchAttendance();
             fetchEmployeeProfile();
+            fetchActivityOptions();
         }
     }, [token]);
 
+    useEffect(() => {
+        if (token && showModal) {
+            fetchScheduledClasses(formData.date);
+        }
+    }, [token, showModal, formData.date]);
+
+    const fetchScheduledClasses = async (date: string) => {
+        try {
+            const res = await fetch(`${API_BASE_URL}/schedule/prefill?date=${date}`, {
+                headers: { 'Authorization': `Bearer ${token}` }
+            });
+            const data = await res.json();
+            setScheduledClasses(res.ok && Array.isArray(data) ? data : []);
+        } catch (error) {
+            console.error('Error fetching schedule:', error);
+            setScheduledClasses([]);
+        }
+    };
+
+    // Fill the form from a scheduled class; the school tag is stripped from the notes since submit re-adds it
+    const applyScheduledClass = (cls: ScheduledClass) => {
+        setFormData(prev => ({
+            ...prev,
+            activity_type: cls.prefill.activity_type,
+            activity_categories: cls.prefill.activity_categories,
+            activity_details: cls.prefill.activity_details,
+            starting_time: cls.prefill.starting_time,
+            ending_time: cls.prefill.ending_time,
+            activity_notes: cls.prefill.activity_notes.replace(/^\[[^\]]*\]\s*/, ''),
+            school: cls.prefill.school_id
+        }));
+    };
+
+    const fetchActivityOptions = async () => {
+        try {
+            const [taxonomyRes, schoolsRes] = await Promise.all([
+                fetch(`${API_BASE_URL}/activity-taxonomy`, { headers: { 'Authorization': `Bearer ${token}` } }),
+                fetch(`${API_BASE_URL}/schools`, { headers: { 'Authorization': `Bearer ${token}` } })
+            ]);
+            if (taxonomyRes.ok) {
+                const data = await taxonomyRes.json();
+                setActivityTypes(Array.isArray(data.types) ? data.types : []);
+                setActivityCategories(Array.isArray(data.categories) ? data.categories : []);
+            }
+            if (schoolsRes.ok) {
+                const data = await schoolsRes.json();
+                setSchools(Array.isArray(data) ? data : []);
+            }
+        } catch (error) {
+            console.error('Error fetching activity options:', error);
+        }
+    };
+
     const fetchEmployeeProfile = async () => {
         try {
             const res = await fetch(`${API_BASE_URL}/profile`, {
@@ -160,7 +225,13 @@ export default function AttendancePage() {
         }
     };
 
-    const hasSchoolClass = formData.activity_categories.includes('School Class');
+    const selectedType = activityTypes.find(t => t.label === formData.activity_type);
+    const availableCategories = activityCategories.filter(cat =>
+        cat.type_codes.length === 0 || (selectedType && cat.type_codes.includes(selectedType.code))
+    );
+    const selectedCategories = activityCategories.filter(cat => formData.activity_categories.includes(cat.label));
+    const hasSchoolClass = selectedCategories.some(cat => cat.requires_school);
+    const requiresTimeRange = selectedCategories.some(cat => cat.requires_time_range);
 
     const handleCategoryChange = (category: string) => {
         setFormData(prev => ({
@@ -193,12 +264,19 @@ export default function AttendancePage() {
             return;
         }
 
+        if (requiresTimeRange && (!formData.starting_time || !formData.ending_time)) {
+            setToast({ message: 'Starting & Ending Time wajib diisi untuk kategori ini', type: 'error' });
+            setTimeout(() => setToast(null), 3000);
+            return;
+        }
+
         setSubmitting(true);
 
         try {
             // Append school to activity notes if school class
+            const schoolName = schools.find(s => s.id === formData.school)?.name || '';
             const notesWithSchool = hasSchoolClass
-                ? `[${formData.school}] ${formData.activity_notes}`
+                ? `[${schoolName}] ${formData.activity_notes}`
                 : formData.activity_notes;
 
             const res = await fetch(`${API_BASE_URL}/attendance`, {
@@ -209,7 +287,8 @@ export default function AttendancePage() {
                 },
                 body: JSON.stringify({
                     ...formData,
-                    activity_notes: notesWithSchool
+                    activity_notes: notesWithSchool,
+                    school_id: hasSchoolClass ? formData.school : ''
                 })
             });
 
@@ -229,7 +308,9 @@ export default function AttendancePage() {
                     school: ''
                 });
             } else {
-                setToast({ message: 'Gagal menambahkan absensi', type: 'error' });
+                const data = await res.json().catch(() => null);
+                const fieldMessage = data?.fields?.[0]?.message;
+                setToast({ message: fieldMessage || 'Gagal menambahkan absensi', type: 'error' });
             }
         } catch (error) {
             setToast({ message: 'Terjadi kesalahan', type: 'error' });
@@ -596,17 +677,36 @@ export default function AttendancePage() {
                             </div>
 
                             <form onSubmit={handleSubmit} className="space-y-5">
+                                {/* Scheduled classes for the selected date */}
+                                {scheduledClasses.length > 0 && (
+                                    <div>
+                                        <label className="block text-sm text-slate-400 mb-2">Isi dari jadwal kelas</label>
+                                        <div className="flex flex-wrap gap-2">
+                                            {scheduledClasses.map(cls => (
+                                                <button
+                                                    key={cls.schedule_id}
+                                                    type="button"
+                                                    onClick={() => applyScheduledClass(cls)}
+                                                    className="px-3 py-1.5 rounded-lg text-xs bg-violet-500/10 text-violet-300 border border-violet-500/30 hover:bg-violet-500/20"
+                                                >
+                                                    {cls.class_name} - {cls.school_name} ({cls.start_time} - {cls.end_time})
+                                                </button>
+                                            ))}
+                                        </div>
+                                    </div>
+                                )}
+
                                 {/* Row 1: Activity Type & Date */}
                                 <div className="grid grid-cols-2 gap-4">
                                     <div>
                                         <label className="block text-sm text-slate-400 mb-2">Activity Type *</label>
                                         <select
                                             value={formData.activity_type}
-                                            onChange={(e) => setFormData({ ...formData, activity_type: e.target.value })}
+                                            onChange={(e) => setFormData({ ...formData, activity_type: e.target.value, activity_categories: [] })}
                                             className="input-modern w-full"
                                         >
-                                            {ACTIVITY_TYPES.map(type => (
-                                                <option key={type.value} value={type.value}>{type.label}</option>
+                                            {activityTypes.map(type => (
+                                                <option key={type.code} value={type.label}>{type.label}</option>
                                             ))}
                                         </select>
                                     </div>
@@ -630,12 +730,12 @@ export default function AttendancePage() {
                                         Activity Categories * {formData.activity_type === 'Event Activity' && <span className="text-cyan-400">(Event Mode)</span>}
                                     </label>
                                     <div className="flex flex-wrap gap-3">
-                                        {(formData.activity_type === 'Event Activity' ? EVENT_CATEGORIES : DAILY_CATEGORIES).map(cat => (
-                                            <label key={cat.value} className="flex items-center gap-2 cursor-pointer">
+                                        {availableCategories.map(cat => (
+                                            <label key={cat.code} className="flex items-center gap-2 cursor-pointer">
                                                 <input
                                                     type="checkbox"
-                                                    checked={formData.activity_categories.includes(cat.value)}
-                                                    onChange={() => handleCategoryChange(cat.value)}
+                                                    checked={formData.activity_categories.includes(cat.label)}
+                                                    onChange={() => handleCategoryChange(cat.label)}
                                                     className="w-4 h-4 rounded border-slate-600 bg-slate-800 text-violet-500 focus:ring-violet-500"
                                                 />
                                                 <span className="text-sm text-slate-300">{cat.label}</span>
@@ -655,8 +755,8 @@ export default function AttendancePage() {
                                             required
                                         >
                                             <option value="">-- Pilih Sekolah --</option>
-                                            {SCHOOLS.map(school => (
-                                                <option key={school} value={school}>{school}</option>
+                                            {schools.map(school => (
+                                                <option key={school.id} value={school.id}>{school.name}</option>
                                             ))}
                                         </select>
                                     </div>
@@ -690,7 +790,7 @@ export default function AttendancePage() {
                                     <div>
                                         <label className="block text-sm text-slate-400 mb-2 flex items-center gap-1">
                                             <Clock className="w-4 h-4" />
-                                            Starting Time (only for Event & Private)
+                                            Starting Time {requiresTimeRange && <span className="text-rose-400">*</span>}
                                         </label>
                                         <input
                                             type="time"
@@ -702,7 +802,7 @@ export default function AttendancePage() {
                                     <div>
                                         <label className="block text-sm text-slate-400 mb-2 flex items-center gap-1">
                                             <Clock className="w-4 h-4" />
-                                            Ending Time (only for Event & Private)
+                                            Ending Time {requiresTimeRange && <span className="text-rose-400">*</span>}
                                         </label>
                                         <input
                                             type="time"
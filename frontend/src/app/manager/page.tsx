@@ -27,6 +27,7 @@ interface PendingRequest {
     status: string;
     created_at: string;
     supporting_file?: string;
+    supporting_file_type?: string;
     session?: string;
 }
 
@@ -422,7 +423,7 @@ export default function ManagerPage() {
                                 <div>
                                     <p className="text-xs text-slate-500 mb-2">File Pendukung</p>
                                     <div className="p-3 rounded-lg bg-white/5 border border-white/10">
-                                        {selectedRequest.supporting_file.startsWith('data:image') ? (
+                                        {selectedRequest.supporting_file_type?.startsWith('image/') || selectedRequest.supporting_file.startsWith('data:image') ? (
                                             <div className="space-y-2">
                                                 <img
                                                     src={selectedRequest.supporting_file}
@@ -437,7 +438,7 @@ export default function ManagerPage() {
                                                     <Paperclip className="w-4 h-4" /> Download File
                                                 </a>
                                             </div>
-                                        ) : selectedRequest.supporting_file.startsWith('data:application/pdf') ? (
+                                        ) : selectedRequest.supporting_file_type === 'application/pdf' || selectedRequest.supporting_file.startsWith('data:application/pdf') ? (
                                             <div className="flex flex-col items-center gap-2">
                                                 <FileText className="w-12 h-12 text-rose-400" />
                                                 <p className="text-slate-300 text-sm">PDF Document</p>
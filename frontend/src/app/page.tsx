@@ -174,12 +174,12 @@ export default function HomePage() {
 
         // Fetch approved leaves from all work permits (for all users to see who's on leave)
         try {
-          const recapRes = await fetch(`${API_BASE_URL}/attendance-recap`, { headers });
+          const today = new Date().toISOString().split('T')[0];
+          const recapRes = await fetch(`${API_BASE_URL}/attendance-recap?status=ijin,sakit&from=${today}&limit=500`, { headers });
           if (recapRes.ok) {
             const recapData = await recapRes.json();
-            const today = new Date().toISOString().split('T')[0];
-            const leaves = Array.isArray(recapData)
-              ? recapData
+            const leaves = Array.isArray(recapData?.items)
+              ? recapData.items
                 .filter((r: { status: string; date: string }) => (r.status === 'ijin' || r.status === 'sakit') && r.date >= today)
                 .map((r: { user_name: string; date: string; activity_type: string }) => ({
                   user_name: r.user_name,
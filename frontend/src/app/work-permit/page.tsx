@@ -2,7 +2,7 @@
 
 import { useState, useEffect } from 'react';
 import { useAuth } from '@/context/AuthContext';
-import { API_BASE_URL } from '@/lib/api';
+import { API_BASE_URL, uploadFile } from '@/lib/api';
 import {
     Plus,
     FileText,
@@ -110,15 +110,16 @@ export default function WorkPermitPage() {
         setSubmitting(true);
 
         try {
-            // Convert file to base64 if exists
-            let fileBase64 = '';
+            // Upload the file first; the permit only carries its ID
+            let fileId = '';
             if (supportingFile) {
-                const reader = new FileReader();
-                fileBase64 = await new Promise<string>((resolve, reject) => {
-                    reader.onload = () => resolve(reader.result as string);
-                    reader.onerror = reject;
-                    reader.readAsDataURL(supportingFile);
-                });
+                try {
+                    fileId = await uploadFile(supportingFile, 'document', token);
+                } catch (err) {
+                    setToast({ message: (err as Error).message, type: 'error' });
+                    setTimeout(() => setToast(null), 3000);
+                    return;
+                }
             }
 
             const res = await fetch(`${API_BASE_URL}/work-permits`, {
@@ -127,7 +128,7 @@ export default function WorkPermitPage() {
                     'Content-Type': 'application/json',
                     'Authorization': `Bearer ${token}`
                 },
-                body: JSON.stringify({ ...formData, supporting_file: fileBase64 })
+                body: JSON.stringify({ ...formData, supporting_file: '', supporting_file_id: fileId })
             });
 
             if (res.ok) {
@@ -16,6 +16,23 @@ async function fetchAPI<T>(endpoint: string, options?: RequestInit): Promise<T>
     return response.json();
 }
 
+// Files: uploads a photo, document or receipt and returns its file ID for use in forms
+export async function uploadFile(file: File, purpose: 'photo' | 'document' | 'receipt', token: string | null): Promise<string> {
+    const body = new FormData();
+    body.append('purpose', purpose);
+    body.append('file', file);
+    const response = await fetch(`${API_BASE_URL}/files`, {
+        method: 'POST',
+        headers: { 'Authorization': `Bearer ${token}` },
+        body,
+    });
+    const data = await response.json();
+    if (!response.ok) {
+        throw new Error(data.error || 'Upload gagal');
+    }
+    return data.id;
+}
+
 // Auth
 export const login = (email: string, password: string) =>
     fetchAPI('/auth/login', {